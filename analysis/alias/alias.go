@@ -0,0 +1,320 @@
+// Package alias implements alias analysis of LLVM IR, which determines whether
+// two memory locations may refer to the same memory, and whether instructions
+// may read or modify a given memory location.
+//
+// Alias analyses implement the AliasAnalysis interface. Two implementations are
+// provided: Basic, which reasons about the underlying objects of pointers
+// (distinct allocas, global variables and noalias pointers) and constant offsets
+// of getelementptr instructions, and TBAA, which reasons about the type-based
+// alias analysis metadata (!tbaa) of memory accesses. Alias analyses may be
+// combined using Chain.
+package alias
+
+import (
+	"fmt"
+
+	"github.com/llir/llvm/intrinsics"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/datalayout"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/metadata"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/llir/llvm/irutil"
+)
+
+// AliasAnalysis is an alias analysis.
+type AliasAnalysis interface {
+	// Alias returns the alias relation between the memory locations a and b.
+	Alias(a, b MemoryLocation) AliasResult
+	// ModRef returns whether the given instruction may read or modify the
+	// memory location loc.
+	ModRef(inst ir.Instruction, loc MemoryLocation) ModRefInfo
+}
+
+// AliasResult is the alias relation between two memory locations.
+type AliasResult uint8
+
+// Alias relations.
+const (
+	// The memory locations never overlap.
+	NoAlias AliasResult = iota
+	// The memory locations may overlap.
+	MayAlias
+	// The memory locations partially overlap.
+	PartialAlias
+	// The memory locations start at the same address.
+	MustAlias
+)
+
+// String returns the string representation of the alias relation.
+func (r AliasResult) String() string {
+	switch r {
+	case NoAlias:
+		return "NoAlias"
+	case MayAlias:
+		return "MayAlias"
+	case PartialAlias:
+		return "PartialAlias"
+	case MustAlias:
+		return "MustAlias"
+	}
+	return fmt.Sprintf("AliasResult(%d)", uint8(r))
+}
+
+// ModRefInfo specifies whether an instruction may read (ref) or write (mod) a
+// memory location.
+type ModRefInfo uint8
+
+// Mod/ref information.
+const (
+	// The instruction neither reads nor writes the memory location.
+	NoModRef ModRefInfo = 0
+	// The instruction may read the memory location.
+	Ref ModRefInfo = 1 << 0
+	// The instruction may write the memory location.
+	Mod ModRefInfo = 1 << 1
+	// The instruction may read and write the memory location.
+	ModRef = Ref | Mod
+)
+
+// IsMod reports whether the instruction may write the memory location.
+func (mr ModRefInfo) IsMod() bool {
+	return mr&Mod != 0
+}
+
+// IsRef reports whether the instruction may read the memory location.
+func (mr ModRefInfo) IsRef() bool {
+	return mr&Ref != 0
+}
+
+// String returns the string representation of the mod/ref information.
+func (mr ModRefInfo) String() string {
+	switch mr {
+	case NoModRef:
+		return "NoModRef"
+	case Ref:
+		return "Ref"
+	case Mod:
+		return "Mod"
+	case ModRef:
+		return "ModRef"
+	}
+	return fmt.Sprintf("ModRefInfo(%d)", uint8(mr))
+}
+
+// UnknownSize is the size of memory locations of unknown size.
+const UnknownSize = -1
+
+// MemoryLocation is a region of memory.
+type MemoryLocation struct {
+	// Start address of the memory location.
+	Ptr value.Value
+	// Size in bytes of the memory location; or UnknownSize if unknown.
+	Size int64
+	// (optional) Type-based alias analysis access tag of the memory location
+	// (!tbaa metadata).
+	TBAA metadata.MDNode
+}
+
+// Location returns a memory location of the given pointer and size in bytes,
+// with an unknown size if negative.
+func Location(ptr value.Value, size int64) MemoryLocation {
+	if size < 0 {
+		size = UnknownSize
+	}
+	return MemoryLocation{Ptr: ptr, Size: size}
+}
+
+// LocationOf returns the memory location accessed by the given instruction
+// (load, store, cmpxchg, atomicrmw or va_arg), and reports whether the
+// instruction accesses a single memory location. The size of the memory
+// location is computed using the data layout dl.
+func LocationOf(dl *datalayout.Layout, inst ir.Instruction) (MemoryLocation, bool) {
+	var ptr value.Value
+	var typ types.Type
+	switch inst := inst.(type) {
+	case *ir.InstLoad:
+		ptr, typ = inst.Src, inst.ElemType
+	case *ir.InstStore:
+		ptr, typ = inst.Dst, inst.Src.Type()
+	case *ir.InstCmpXchg:
+		ptr, typ = inst.Ptr, inst.New.Type()
+	case *ir.InstAtomicRMW:
+		ptr, typ = inst.Dst, inst.X.Type()
+	case *ir.InstVAArg:
+		return MemoryLocation{Ptr: inst.ArgList, Size: UnknownSize}, true
+	default:
+		return MemoryLocation{}, false
+	}
+	loc := MemoryLocation{Ptr: ptr, Size: int64(dl.StoreSize(typ))}
+	if md, ok := inst.(interface {
+		MDAttachments() []*metadata.Attachment
+	}); ok {
+		loc.TBAA = attachment(md.MDAttachments(), "tbaa")
+	}
+	return loc, true
+}
+
+// --- [ Mod/ref ] -------------------------------------------------------------
+
+// GetModRef returns whether the given instruction may read or modify the
+// memory location loc, based on the alias relations between loc and the memory
+// accessed by inst as given by aa. It provides the ModRef method of alias
+// analyses in terms of their Alias method.
+//
+// Volatile and atomic accesses (stronger than monotonic) are considered to read
+// and write any memory location. Calls are handled based on the readnone,
+// readonly and argmemonly function attributes of the call site and callee, and
+// calls to the memory intrinsics (memcpy, memmove and memset) based on their
+// arguments.
+func GetModRef(aa AliasAnalysis, dl *datalayout.Layout, inst ir.Instruction, loc MemoryLocation) ModRefInfo {
+	mayRead, mayWrite := irutil.MayReadMemory(inst), irutil.MayWriteMemory(inst)
+	if !mayRead && !mayWrite {
+		return NoModRef
+	}
+	switch inst := inst.(type) {
+	case *ir.InstLoad:
+		if mayWrite {
+			// Volatile or ordered.
+			return ModRef
+		}
+		if access, ok := LocationOf(dl, inst); ok && aa.Alias(access, loc) == NoAlias {
+			return NoModRef
+		}
+		return Ref
+	case *ir.InstStore:
+		if mayRead {
+			// Volatile or ordered.
+			return ModRef
+		}
+		if access, ok := LocationOf(dl, inst); ok && aa.Alias(access, loc) == NoAlias {
+			return NoModRef
+		}
+		return Mod
+	case *ir.InstCmpXchg, *ir.InstAtomicRMW, *ir.InstVAArg:
+		if access, ok := LocationOf(dl, inst); ok && aa.Alias(access, loc) == NoAlias {
+			return NoModRef
+		}
+		return ModRef
+	case *ir.InstCall:
+		return callModRef(aa, dl, inst, loc)
+	}
+	var mr ModRefInfo
+	if mayRead {
+		mr |= Ref
+	}
+	if mayWrite {
+		mr |= Mod
+	}
+	return mr
+}
+
+// callModRef returns whether the given call instruction may read or modify the
+// memory location loc.
+func callModRef(aa AliasAnalysis, dl *datalayout.Layout, call *ir.InstCall, loc MemoryLocation) ModRefInfo {
+	mr := ModRef
+	attrs := irutil.CallFuncAttrs(call)
+	if irutil.HasFuncAttr(attrs, enum.FuncAttrReadOnly) {
+		mr = Ref
+	}
+	// Memory intrinsics.
+	switch id, _ := intrinsics.LookupCall(call); id {
+	case intrinsics.Memcpy, intrinsics.Memmove, intrinsics.Memset:
+		if !isFalse(call.Args[3]) {
+			// Volatile memory intrinsics.
+			return ModRef
+		}
+		size := int64(UnknownSize)
+		if n, ok := irutil.Unwrap(call.Args[2]).(*constant.Int); ok && n.X.IsInt64() {
+			size = n.X.Int64()
+		}
+		dst := Location(call.Args[0], size)
+		if id == intrinsics.Memset {
+			if aa.Alias(dst, loc) != NoAlias {
+				return Mod
+			}
+			return NoModRef
+		}
+		src := Location(call.Args[1], size)
+		var res ModRefInfo
+		if aa.Alias(dst, loc) != NoAlias {
+			res |= Mod
+		}
+		if aa.Alias(src, loc) != NoAlias {
+			res |= Ref
+		}
+		return res
+	}
+	if irutil.HasFuncAttr(attrs, enum.FuncAttrArgMemOnly) {
+		// Only memory pointed to by pointer arguments is accessed.
+		for _, arg := range call.Args {
+			arg = irutil.Unwrap(arg)
+			if !types.IsPointer(arg.Type()) {
+				continue
+			}
+			if aa.Alias(Location(arg, UnknownSize), loc) != NoAlias {
+				return mr
+			}
+		}
+		return NoModRef
+	}
+	return mr
+}
+
+// --- [ Chain ] ---------------------------------------------------------------
+
+// chain is a sequence of alias analyses.
+type chain struct {
+	aas []AliasAnalysis
+}
+
+// Chain returns an alias analysis which combines the results of the given alias
+// analyses; the most precise result of any alias analysis is used.
+func Chain(aas ...AliasAnalysis) AliasAnalysis {
+	return &chain{aas: aas}
+}
+
+// Alias returns the alias relation between the memory locations a and b.
+func (c *chain) Alias(a, b MemoryLocation) AliasResult {
+	res := MayAlias
+	for _, aa := range c.aas {
+		switch r := aa.Alias(a, b); r {
+		case NoAlias:
+			return NoAlias
+		case MustAlias, PartialAlias:
+			res = r
+		}
+	}
+	return res
+}
+
+// ModRef returns whether the given instruction may read or modify the memory
+// location loc.
+func (c *chain) ModRef(inst ir.Instruction, loc MemoryLocation) ModRefInfo {
+	mr := ModRef
+	for _, aa := range c.aas {
+		mr &= aa.ModRef(inst, loc)
+	}
+	return mr
+}
+
+// ### [ Helper functions ] ####################################################
+
+// attachment returns the metadata node of the given metadata attachment; or
+// nil if not present.
+func attachment(mds []*metadata.Attachment, name string) metadata.MDNode {
+	for _, md := range mds {
+		if md.Name == name {
+			return md.Node
+		}
+	}
+	return nil
+}
+
+// isFalse reports whether the given value is the boolean constant false.
+func isFalse(v value.Value) bool {
+	c, ok := irutil.Unwrap(v).(*constant.Int)
+	return ok && c.X.Sign() == 0
+}
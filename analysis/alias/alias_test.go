@@ -0,0 +1,195 @@
+package alias
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/value"
+)
+
+func TestBasic(t *testing.T) {
+	const src = `
+@g = global [4 x i32] zeroinitializer
+
+declare noalias i8* @malloc(i64)
+
+declare void @llvm.memcpy.p0i8.p0i8.i64(i8*, i8*, i64, i1)
+
+declare void @llvm.memset.p0i8.i64(i8*, i8, i64, i1)
+
+define void @f(i32* noalias %p, i32* %q, i64 %i) {
+	%a = alloca [4 x i32]
+	%b = alloca { i32, i64 }
+	%a0 = getelementptr [4 x i32], [4 x i32]* %a, i64 0, i64 0
+	%a1 = getelementptr [4 x i32], [4 x i32]* %a, i64 0, i64 1
+	%ai = getelementptr [4 x i32], [4 x i32]* %a, i64 0, i64 %i
+	%a8 = bitcast [4 x i32]* %a to i64*
+	%b1 = getelementptr { i32, i64 }, { i32, i64 }* %b, i32 0, i32 1
+	%b1c = bitcast i64* %b1 to i8*
+	%b4 = getelementptr i8, i8* %b1c, i64 -4
+	%m = call i8* @malloc(i64 8)
+	%g1 = getelementptr [4 x i32], [4 x i32]* @g, i64 0, i64 1
+	call void @llvm.memcpy.p0i8.p0i8.i64(i8* %b1c, i8* %m, i64 8, i1 false)
+	call void @llvm.memset.p0i8.i64(i8* %m, i8 0, i64 8, i1 true)
+	store i32 0, i32* %a1
+	store i32 0, i32* %q
+	ret void
+}`
+	m, err := asm.ParseString("basic.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	f := m.Funcs[len(m.Funcs)-1]
+	vals := locals(f)
+	golden := []struct {
+		a, b         string
+		sizeA, sizeB int64
+		want         AliasResult
+	}{
+		{a: "a0", b: "a1", sizeA: 4, sizeB: 4, want: NoAlias},
+		{a: "a0", b: "a1", sizeA: 8, sizeB: 4, want: PartialAlias},
+		{a: "a0", b: "a8", sizeA: 4, sizeB: 4, want: MustAlias},
+		{a: "a0", b: "a8", sizeA: 4, sizeB: 8, want: PartialAlias},
+		{a: "a1", b: "ai", sizeA: 4, sizeB: 4, want: MayAlias},
+		{a: "a0", b: "b1", sizeA: 4, sizeB: 8, want: NoAlias},
+		{a: "b1", b: "b4", sizeA: 8, sizeB: 4, want: NoAlias},
+		{a: "b1c", b: "b4", sizeA: 8, sizeB: 8, want: PartialAlias},
+		{a: "p", b: "a0", sizeA: 4, sizeB: 4, want: NoAlias},
+		{a: "p", b: "q", sizeA: 4, sizeB: 4, want: NoAlias},
+		{a: "q", b: "a0", sizeA: 4, sizeB: 4, want: NoAlias},
+		{a: "q", b: "g1", sizeA: 4, sizeB: 4, want: MayAlias},
+		{a: "m", b: "g1", sizeA: 4, sizeB: 4, want: NoAlias},
+		{a: "g1", b: "a1", sizeA: 4, sizeB: 4, want: NoAlias},
+	}
+	aa := NewBasic(nil)
+	for _, g := range golden {
+		a := Location(vals[g.a], g.sizeA)
+		b := Location(vals[g.b], g.sizeB)
+		if got := aa.Alias(a, b); got != g.want {
+			t.Errorf("alias mismatch of %%%s and %%%s; expected %v, got %v", g.a, g.b, g.want, got)
+		}
+	}
+	// Mod/ref of memory intrinsics and stores.
+	block := f.Blocks[0]
+	memcpy := block.Insts[len(block.Insts)-4]
+	memset := block.Insts[len(block.Insts)-3]
+	storeA1 := block.Insts[len(block.Insts)-2]
+	storeQ := block.Insts[len(block.Insts)-1]
+	modRefs := []struct {
+		inst ir.Instruction
+		loc  string
+		want ModRefInfo
+	}{
+		{inst: memcpy, loc: "a0", want: NoModRef},
+		{inst: memcpy, loc: "b1", want: Mod},
+		{inst: memcpy, loc: "m", want: Ref},
+		// Volatile memory intrinsics.
+		{inst: memset, loc: "a0", want: ModRef},
+		{inst: storeA1, loc: "a0", want: NoModRef},
+		{inst: storeA1, loc: "ai", want: Mod},
+		{inst: storeQ, loc: "p", want: NoModRef},
+		{inst: storeQ, loc: "a0", want: NoModRef},
+		{inst: storeQ, loc: "g1", want: Mod},
+	}
+	for _, g := range modRefs {
+		if got := aa.ModRef(g.inst, Location(vals[g.loc], 4)); got != g.want {
+			t.Errorf("mod/ref mismatch of %q and %%%s; expected %v, got %v", g.inst.LLString(), g.loc, g.want, got)
+		}
+	}
+}
+
+func TestTBAA(t *testing.T) {
+	const src = `
+%struct.S = type { i32, float }
+
+define void @f(i32* %x, float* %y, %struct.S* %s, i8* %c) {
+	%sx = getelementptr %struct.S, %struct.S* %s, i32 0, i32 0
+	%sy = getelementptr %struct.S, %struct.S* %s, i32 0, i32 1
+	%l0 = load i32, i32* %x, !tbaa !5
+	%l1 = load float, float* %y, !tbaa !6
+	%l2 = load i32, i32* %sx, !tbaa !7
+	%l3 = load float, float* %sy, !tbaa !8
+	%l4 = load i8, i8* %c, !tbaa !9
+	%l5 = load i32, i32* %x
+	ret void
+}
+
+!0 = !{!"Simple C/C++ TBAA"}
+!1 = !{!"omnipotent char", !0, i64 0}
+!2 = !{!"int", !1, i64 0}
+!3 = !{!"float", !1, i64 0}
+!4 = !{!"S", !2, i64 0, !3, i64 4}
+!5 = !{!2, !2, i64 0}
+!6 = !{!3, !3, i64 0}
+!7 = !{!4, !2, i64 0}
+!8 = !{!4, !3, i64 4}
+!9 = !{!1, !1, i64 0}
+`
+	m, err := asm.ParseString("tbaa.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	f := m.Funcs[0]
+	aa := NewTBAA(nil)
+	var locs []MemoryLocation
+	for _, inst := range f.Blocks[0].Insts {
+		if loc, ok := LocationOf(aa.dl, inst); ok {
+			locs = append(locs, loc)
+		}
+	}
+	const (
+		intAccess = iota
+		floatAccess
+		sxAccess
+		syAccess
+		charAccess
+		untagged
+	)
+	golden := []struct {
+		a, b int
+		want AliasResult
+	}{
+		{a: intAccess, b: floatAccess, want: NoAlias},
+		{a: intAccess, b: intAccess, want: MayAlias},
+		{a: intAccess, b: sxAccess, want: MayAlias},
+		{a: intAccess, b: syAccess, want: NoAlias},
+		{a: floatAccess, b: syAccess, want: MayAlias},
+		{a: sxAccess, b: syAccess, want: NoAlias},
+		{a: charAccess, b: intAccess, want: MayAlias},
+		{a: charAccess, b: syAccess, want: MayAlias},
+		{a: untagged, b: floatAccess, want: MayAlias},
+	}
+	for _, g := range golden {
+		if got := aa.Alias(locs[g.a], locs[g.b]); got != g.want {
+			t.Errorf("alias mismatch of access %d and %d; expected %v, got %v", g.a, g.b, g.want, got)
+		}
+		if got := aa.Alias(locs[g.b], locs[g.a]); got != g.want {
+			t.Errorf("alias mismatch of access %d and %d; expected %v, got %v", g.b, g.a, g.want, got)
+		}
+	}
+	// Combined with basic alias analysis.
+	chain := Chain(NewBasic(nil), aa)
+	if got := chain.Alias(locs[intAccess], locs[untagged]); got != MustAlias {
+		t.Errorf("alias mismatch of chain; expected %v, got %v", MustAlias, got)
+	}
+	if got := chain.Alias(locs[intAccess], locs[floatAccess]); got != NoAlias {
+		t.Errorf("alias mismatch of chain; expected %v, got %v", NoAlias, got)
+	}
+}
+
+// locals returns the named parameters and instructions of f.
+func locals(f *ir.Func) map[string]value.Value {
+	vals := make(map[string]value.Value)
+	for _, param := range f.Params {
+		vals[param.Name()] = param
+	}
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			if v, ok := inst.(value.Named); ok {
+				vals[v.Name()] = v
+			}
+		}
+	}
+	return vals
+}
@@ -0,0 +1,222 @@
+package alias
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/datalayout"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/llir/llvm/irutil"
+)
+
+// Basic is a basic alias analysis, which reasons about the underlying objects
+// of pointers and their constant offsets.
+//
+// Pointers are decomposed into an underlying object and an offset by looking
+// through pointer casts and getelementptr instructions and constant
+// expressions. Memory locations of distinct identified objects (allocas, global
+// variables, noalias parameters and the results of noalias calls) never alias,
+// and parameters never alias function-local identified objects. Memory
+// locations of the same underlying object alias based on their offsets
+// and sizes, if known.
+type Basic struct {
+	// Data layout used to compute offsets of getelementptr instructions.
+	dl *datalayout.Layout
+}
+
+// NewBasic returns a new basic alias analysis based on the given data layout.
+// The default data layout is used if dl is nil.
+func NewBasic(dl *datalayout.Layout) *Basic {
+	if dl == nil {
+		dl = datalayout.Default()
+	}
+	return &Basic{dl: dl}
+}
+
+// Alias returns the alias relation between the memory locations a and b.
+func (aa *Basic) Alias(a, b MemoryLocation) AliasResult {
+	if a.Size == 0 || b.Size == 0 {
+		return NoAlias
+	}
+	baseA, offA, okA := aa.Decompose(a.Ptr)
+	baseB, offB, okB := aa.Decompose(b.Ptr)
+	if baseA != baseB {
+		if isIdentifiedObject(baseA) && isIdentifiedObject(baseB) {
+			return NoAlias
+		}
+		// Parameters may not point to function-local identified objects, as
+		// they are created after the function is invoked.
+		if isParam(baseA) && isFunctionLocal(baseB) || isFunctionLocal(baseA) && isParam(baseB) {
+			return NoAlias
+		}
+		return MayAlias
+	}
+	if !okA || !okB {
+		// Variable offsets from the same underlying object.
+		return MayAlias
+	}
+	if offA == offB {
+		if a.Size == b.Size {
+			return MustAlias
+		}
+		if a.Size == UnknownSize || b.Size == UnknownSize {
+			return MayAlias
+		}
+		return PartialAlias
+	}
+	// Order the memory locations by offset.
+	if offA > offB {
+		a, b = b, a
+		offA, offB = offB, offA
+	}
+	if a.Size == UnknownSize {
+		return MayAlias
+	}
+	if offA+a.Size <= offB {
+		return NoAlias
+	}
+	return PartialAlias
+}
+
+// ModRef returns whether the given instruction may read or modify the memory
+// location loc.
+func (aa *Basic) ModRef(inst ir.Instruction, loc MemoryLocation) ModRefInfo {
+	return GetModRef(aa, aa.dl, inst, loc)
+}
+
+// UnderlyingObject returns the underlying object of the given pointer, looking
+// through pointer casts and getelementptr instructions and constant
+// expressions.
+func (aa *Basic) UnderlyingObject(ptr value.Value) value.Value {
+	base, _, _ := aa.Decompose(ptr)
+	return base
+}
+
+// Decompose decomposes the given pointer into an underlying object and a byte
+// offset, and reports whether the offset is known.
+func (aa *Basic) Decompose(ptr value.Value) (value.Value, int64, bool) {
+	var offset int64
+	known := true
+	// Guard against (invalid) cyclic pointer definitions.
+	for i := 0; i < 64; i++ {
+		ptr = irutil.Unwrap(ptr)
+		switch p := ptr.(type) {
+		case *ir.InstBitCast:
+			ptr = p.From
+		case *ir.InstAddrSpaceCast:
+			ptr = p.From
+		case *constant.ExprBitCast:
+			ptr = p.From
+		case *constant.ExprAddrSpaceCast:
+			ptr = p.From
+		case *ir.InstGetElementPtr:
+			if !types.IsPointer(p.Typ) {
+				return ptr, offset, known
+			}
+			off, ok := aa.indexedOffset(p.ElemType, p.Indices)
+			offset += off
+			known = known && ok
+			ptr = p.Src
+		case *constant.ExprGetElementPtr:
+			if !types.IsPointer(p.Typ) {
+				return ptr, offset, known
+			}
+			indices := make([]value.Value, len(p.Indices))
+			for i, index := range p.Indices {
+				indices[i] = index
+			}
+			off, ok := aa.indexedOffset(p.ElemType, indices)
+			offset += off
+			known = known && ok
+			ptr = p.Src
+		case *ir.Alias:
+			ptr = p.Aliasee
+		default:
+			return ptr, offset, known
+		}
+	}
+	return ptr, offset, false
+}
+
+// indexedOffset returns the byte offset of the given getelementptr indices,
+// and reports whether the indices are constant.
+func (aa *Basic) indexedOffset(elemType types.Type, indices []value.Value) (int64, bool) {
+	is := make([]int64, len(indices))
+	for i, index := range indices {
+		if idx, ok := index.(*constant.Index); ok {
+			index = idx.Constant
+		}
+		c, ok := index.(*constant.Int)
+		if !ok || !c.X.IsInt64() {
+			return 0, false
+		}
+		is[i] = c.X.Int64()
+	}
+	offset, err := aa.dl.IndexedOffset(elemType, is)
+	if err != nil {
+		return 0, false
+	}
+	return offset, true
+}
+
+// isIdentifiedObject reports whether the given underlying object is an
+// identified object; i.e. an object which does not alias other identified
+// objects.
+func isIdentifiedObject(v value.Value) bool {
+	switch v := v.(type) {
+	case *ir.InstAlloca:
+		return true
+	case *ir.Global:
+		return true
+	case *ir.Func:
+		return true
+	case *ir.Param:
+		for _, attr := range v.Attrs {
+			if attr == enum.ParamAttrNoAlias {
+				return true
+			}
+		}
+	case *ir.InstCall:
+		return hasNoAliasReturn(v.ReturnAttrs) || hasNoAliasCallee(v.Callee)
+	case *ir.TermInvoke:
+		return hasNoAliasReturn(v.ReturnAttrs) || hasNoAliasCallee(v.Invokee)
+	}
+	return false
+}
+
+// isFunctionLocal reports whether the given underlying object is a
+// function-local identified object; i.e. an alloca, a noalias parameter or the
+// result of a noalias call.
+func isFunctionLocal(v value.Value) bool {
+	switch v.(type) {
+	case *ir.Global, *ir.Func:
+		return false
+	}
+	return isIdentifiedObject(v)
+}
+
+// isParam reports whether the given underlying object is a function parameter.
+func isParam(v value.Value) bool {
+	_, ok := v.(*ir.Param)
+	return ok
+}
+
+// hasNoAliasCallee reports whether the given callee returns noalias pointers.
+func hasNoAliasCallee(callee value.Value) bool {
+	if f := irutil.Callee(callee); f != nil {
+		return hasNoAliasReturn(f.ReturnAttrs)
+	}
+	return false
+}
+
+// hasNoAliasReturn reports whether the given return attributes contain
+// noalias.
+func hasNoAliasReturn(attrs []ir.ReturnAttribute) bool {
+	for _, attr := range attrs {
+		if attr == enum.ReturnAttrNoAlias {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+package alias
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/datalayout"
+	"github.com/llir/llvm/ir/metadata"
+)
+
+// TBAA is a type-based alias analysis, which reasons about the type-based alias
+// analysis access tags (!tbaa metadata) of memory locations.
+//
+// Both the struct-path format and the (older) scalar format of access tags are
+// supported. In the struct-path format, an access tag is a tuple of a base
+// type, an access type and an offset
+//
+//	!{!base, !access, i64 offset}
+//
+// where scalar type nodes have the form !{!"name", !parent, i64 0} and struct
+// type nodes have the form !{!"name", !field1, i64 offset1, ...}. In the scalar
+// format, the access tag is a scalar type node.
+//
+// Two memory locations with access tags never alias if neither access may be
+// to a subobject of the other; memory locations without access tags may alias.
+type TBAA struct {
+	// Data layout used to compute the size of memory accesses.
+	dl *datalayout.Layout
+}
+
+// NewTBAA returns a new type-based alias analysis based on the given data
+// layout. The default data layout is used if dl is nil.
+func NewTBAA(dl *datalayout.Layout) *TBAA {
+	if dl == nil {
+		dl = datalayout.Default()
+	}
+	return &TBAA{dl: dl}
+}
+
+// Alias returns the alias relation between the memory locations a and b.
+func (aa *TBAA) Alias(a, b MemoryLocation) AliasResult {
+	if a.TBAA == nil || b.TBAA == nil {
+		return MayAlias
+	}
+	tagA, okA := newTag(a.TBAA)
+	tagB, okB := newTag(b.TBAA)
+	if !okA || !okB {
+		return MayAlias
+	}
+	if mayAliasTags(tagA, tagB) {
+		return MayAlias
+	}
+	return NoAlias
+}
+
+// ModRef returns whether the given instruction may read or modify the memory
+// location loc.
+func (aa *TBAA) ModRef(inst ir.Instruction, loc MemoryLocation) ModRefInfo {
+	return GetModRef(aa, aa.dl, inst, loc)
+}
+
+// tag is a type-based alias analysis access tag.
+type tag struct {
+	// Base type, access type and offset of the access.
+	base, access *metadata.Tuple
+	offset       int64
+}
+
+// newTag returns the access tag of the given metadata node, and reports whether
+// the node is a valid access tag.
+func newTag(node metadata.MDNode) (tag, bool) {
+	t, ok := node.(*metadata.Tuple)
+	if !ok || len(t.Fields) == 0 {
+		return tag{}, false
+	}
+	if _, ok := t.Fields[0].(*metadata.String); ok {
+		// Scalar format; the access tag is the access type.
+		return tag{base: t, access: t}, true
+	}
+	if len(t.Fields) < 3 {
+		return tag{}, false
+	}
+	base, ok1 := t.Fields[0].(*metadata.Tuple)
+	access, ok2 := t.Fields[1].(*metadata.Tuple)
+	offset, ok3 := intField(t.Fields[2])
+	if !ok1 || !ok2 || !ok3 {
+		return tag{}, false
+	}
+	return tag{base: base, access: access, offset: offset}, true
+}
+
+// mayAliasTags reports whether memory accesses with the given access tags may
+// alias.
+func mayAliasTags(a, b tag) bool {
+	if a == b {
+		return true
+	}
+	common := leastCommonType(a.access, b.access)
+	if common == nil {
+		// Type nodes of different type systems (roots).
+		return true
+	}
+	if mayAlias, ok := subobjectOf(a, b, common); ok {
+		return mayAlias
+	}
+	if mayAlias, ok := subobjectOf(b, a, common); ok {
+		return mayAlias
+	}
+	return false
+}
+
+// subobjectOf reports whether the access with tag sub may be to a subobject of
+// the object accessed with tag base. The boolean result ok reports whether the
+// subobject relation could be determined; if so, mayAlias reports whether the
+// accesses may alias.
+func subobjectOf(base, sub tag, common *metadata.Tuple) (mayAlias, ok bool) {
+	// An access of the least common type may be to any of its subobjects.
+	if base.access == base.base && base.access == common {
+		return true, true
+	}
+	// Follow the fields of the base type at the offset of the access, until
+	// reaching the base type of sub.
+	t, offset := base.base, base.offset
+	// Guard against (invalid) cyclic type nodes.
+	for i := 0; t != nil && i < 64; i++ {
+		if t == sub.base {
+			return offset == sub.offset, true
+		}
+		t, offset = fieldAt(t, offset)
+	}
+	return false, false
+}
+
+// leastCommonType returns the least common ancestor of the given type nodes;
+// or nil if the type nodes have different roots.
+func leastCommonType(a, b *metadata.Tuple) *metadata.Tuple {
+	if a == b {
+		return a
+	}
+	pathA, pathB := ancestors(a), ancestors(b)
+	// Compare the paths from the root.
+	var common *metadata.Tuple
+	for i, j := len(pathA)-1, len(pathB)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		if pathA[i] != pathB[j] {
+			break
+		}
+		common = pathA[i]
+	}
+	return common
+}
+
+// ancestors returns the given type node followed by its ancestors, ending with
+// the root type node.
+func ancestors(t *metadata.Tuple) []*metadata.Tuple {
+	var path []*metadata.Tuple
+	seen := make(map[*metadata.Tuple]bool)
+	for t != nil && !seen[t] {
+		seen[t] = true
+		path = append(path, t)
+		t = parent(t)
+	}
+	return path
+}
+
+// parent returns the parent type node of the given type node; or nil if root.
+func parent(t *metadata.Tuple) *metadata.Tuple {
+	if len(t.Fields) < 2 {
+		return nil
+	}
+	p, _ := t.Fields[1].(*metadata.Tuple)
+	return p
+}
+
+// fieldAt returns the type node of the field containing the given offset of
+// the given type node, and the offset relative to the field. For scalar type
+// nodes, the parent type node is returned.
+func fieldAt(t *metadata.Tuple, offset int64) (*metadata.Tuple, int64) {
+	var field *metadata.Tuple
+	var fieldOffset int64
+	// Fields are pairs of type node and offset, following the name.
+	for i := 1; i+1 < len(t.Fields); i += 2 {
+		off, ok := intField(t.Fields[i+1])
+		if !ok || off > offset {
+			break
+		}
+		f, ok := t.Fields[i].(*metadata.Tuple)
+		if !ok {
+			break
+		}
+		field, fieldOffset = f, off
+	}
+	if field == nil {
+		if len(t.Fields) == 2 {
+			// Scalar type node without offset (scalar format).
+			return parent(t), offset
+		}
+		return nil, 0
+	}
+	return field, offset - fieldOffset
+}
+
+// intField returns the integer value of the given metadata field.
+func intField(field metadata.Field) (int64, bool) {
+	if v, ok := field.(*metadata.Value); ok {
+		field = v.Value
+	}
+	c, ok := field.(*constant.Int)
+	if !ok || !c.X.IsInt64() {
+		return 0, false
+	}
+	return c.X.Int64(), true
+}
@@ -0,0 +1,469 @@
+// Package callgraph implements call graph construction of LLVM IR modules.
+//
+// The call graph has a node for each function (definition or declaration) of
+// a module, and an edge from caller to callee for each call site (call
+// instruction, or invoke and callbr terminator) of the caller. The callee of a
+// call site is resolved through aliases, bitcast and addrspacecast constant
+// expressions and indirect functions; the implementations of an indirect
+// function are the functions returned by its resolver.
+//
+// The callees of indirect call sites (e.g. calls through function pointers) are
+// resolved by a user-provided resolver (e.g. based on points-to analysis), or
+// by default by signature matching; i.e. an indirect call site may call any
+// address-taken function of the module with the same function signature as the
+// call site.
+//
+// Strongly connected components of the call graph are computed using Tarjan's
+// algorithm [1], and are provided in bottom-up order (callees before callers).
+//
+// [1]: https://doi.org/10.1137/0201010
+package callgraph
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/llir/llvm/irutil"
+)
+
+// Graph is the call graph of a module.
+type Graph struct {
+	// Module of the call graph.
+	Module *ir.Module
+	// Nodes of the call graph, in order of the functions of the module.
+	Nodes []*Node
+
+	// Node of each function.
+	nodes map[*ir.Func]*Node
+	// Strongly connected components in bottom-up order; computed on demand.
+	sccs [][]*Node
+	// Strongly connected component of each node.
+	sccOf map[*Node]int
+}
+
+// Node is a function of a call graph.
+type Node struct {
+	// Function of the node.
+	Func *ir.Func
+	// Outgoing edges of the node, in order of call sites.
+	Out []*Edge
+	// Incoming edges of the node.
+	In []*Edge
+	// Indirect call sites of the function without resolved callees.
+	Unresolved []*CallSite
+}
+
+// Edge is a call graph edge from caller to callee.
+type Edge struct {
+	// Calling and called function.
+	Caller, Callee *Node
+	// Call site of the edge.
+	Site *CallSite
+	// Indirect call, with the callee resolved by a resolver.
+	Indirect bool
+}
+
+// CallSite is a call site of a function.
+type CallSite struct {
+	// Call instruction or terminator of the call site.
+	//
+	// Inst has one of the following underlying types:
+	//
+	//	*ir.InstCall
+	//	*ir.TermInvoke
+	//	*ir.TermCallBr
+	Inst value.Value
+	// Basic block of the call site.
+	Block *ir.Block
+	// Callee operand of the call site.
+	Callee value.Value
+	// Function signature of the call site.
+	Sig *types.FuncType
+}
+
+// Resolver returns the possible callees of an indirect call site.
+type Resolver func(site *CallSite) []*ir.Func
+
+// New returns the call graph of the given module, resolving indirect call
+// sites by signature matching (see MatchSignature).
+func New(m *ir.Module) *Graph {
+	return NewWithResolver(m, MatchSignature(m))
+}
+
+// NewWithResolver returns the call graph of the given module, resolving
+// indirect call sites using resolve.
+func NewWithResolver(m *ir.Module, resolve Resolver) *Graph {
+	g := &Graph{
+		Module: m,
+		nodes:  make(map[*ir.Func]*Node),
+	}
+	for _, f := range m.Funcs {
+		n := &Node{Func: f}
+		g.Nodes = append(g.Nodes, n)
+		g.nodes[f] = n
+	}
+	for _, caller := range g.Nodes {
+		for _, site := range CallSites(caller.Func) {
+			if callees, ok := Callees(site.Callee); ok {
+				for _, callee := range callees {
+					g.addEdge(caller, callee, site, false)
+				}
+				continue
+			}
+			if _, ok := irutil.Unwrap(site.Callee).(*ir.InlineAsm); ok {
+				continue
+			}
+			var callees []*ir.Func
+			if resolve != nil {
+				callees = resolve(site)
+			}
+			if len(callees) == 0 {
+				caller.Unresolved = append(caller.Unresolved, site)
+			}
+			for _, callee := range callees {
+				g.addEdge(caller, callee, site, true)
+			}
+		}
+	}
+	return g
+}
+
+// Node returns the call graph node of the given function; or nil if not
+// present.
+func (g *Graph) Node(f *ir.Func) *Node {
+	return g.nodes[f]
+}
+
+// addEdge adds a call graph edge from caller to callee.
+func (g *Graph) addEdge(caller *Node, callee *ir.Func, site *CallSite, indirect bool) {
+	n, ok := g.nodes[callee]
+	if !ok {
+		// Function not part of the module.
+		return
+	}
+	e := &Edge{Caller: caller, Callee: n, Site: site, Indirect: indirect}
+	caller.Out = append(caller.Out, e)
+	n.In = append(n.In, e)
+}
+
+// Callees returns the callees of the given node, in order of first call.
+func (n *Node) Callees() []*Node {
+	var callees []*Node
+	seen := make(map[*Node]bool)
+	for _, e := range n.Out {
+		if !seen[e.Callee] {
+			seen[e.Callee] = true
+			callees = append(callees, e.Callee)
+		}
+	}
+	return callees
+}
+
+// Callers returns the callers of the given node, in order of incoming edges.
+func (n *Node) Callers() []*Node {
+	var callers []*Node
+	seen := make(map[*Node]bool)
+	for _, e := range n.In {
+		if !seen[e.Caller] {
+			seen[e.Caller] = true
+			callers = append(callers, e.Caller)
+		}
+	}
+	return callers
+}
+
+// --- [ Strongly connected components ] ---------------------------------------
+
+// SCCs returns the strongly connected components of the call graph in
+// bottom-up order; i.e. each component is preceded by the components of its
+// callees (except for callees within the component itself).
+func (g *Graph) SCCs() [][]*Node {
+	if g.sccs == nil {
+		g.tarjan()
+	}
+	return g.sccs
+}
+
+// BottomUp returns the nodes of the call graph in bottom-up order; i.e. callees
+// precede callers, except for calls within strongly connected components.
+func (g *Graph) BottomUp() []*Node {
+	var nodes []*Node
+	for _, scc := range g.SCCs() {
+		nodes = append(nodes, scc...)
+	}
+	return nodes
+}
+
+// SCC returns the strongly connected component containing the given node.
+func (g *Graph) SCC(n *Node) []*Node {
+	g.SCCs()
+	return g.sccs[g.sccOf[n]]
+}
+
+// IsRecursive reports whether the given function may call itself, directly or
+// through other functions.
+func (g *Graph) IsRecursive(f *ir.Func) bool {
+	n := g.Node(f)
+	if n == nil {
+		return false
+	}
+	if len(g.SCC(n)) > 1 {
+		return true
+	}
+	for _, e := range n.Out {
+		if e.Callee == n {
+			return true
+		}
+	}
+	return false
+}
+
+// tarjan computes the strongly connected components of the call graph using
+// Tarjan's algorithm. Components are emitted when their root is finished, and
+// thus in bottom-up order.
+func (g *Graph) tarjan() {
+	g.sccs = [][]*Node{}
+	g.sccOf = make(map[*Node]int)
+	index := make(map[*Node]int)
+	lowlink := make(map[*Node]int)
+	onStack := make(map[*Node]bool)
+	var stack []*Node
+	var visit func(n *Node)
+	visit = func(n *Node) {
+		index[n] = len(index)
+		lowlink[n] = index[n]
+		stack = append(stack, n)
+		onStack[n] = true
+		for _, callee := range n.Callees() {
+			if _, ok := index[callee]; !ok {
+				visit(callee)
+				if lowlink[callee] < lowlink[n] {
+					lowlink[n] = lowlink[callee]
+				}
+			} else if onStack[callee] && index[callee] < lowlink[n] {
+				lowlink[n] = index[callee]
+			}
+		}
+		if lowlink[n] != index[n] {
+			return
+		}
+		var scc []*Node
+		for {
+			m := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[m] = false
+			g.sccOf[m] = len(g.sccs)
+			scc = append(scc, m)
+			if m == n {
+				break
+			}
+		}
+		// Order the nodes of the component by discovery.
+		for i, j := 0, len(scc)-1; i < j; i, j = i+1, j-1 {
+			scc[i], scc[j] = scc[j], scc[i]
+		}
+		g.sccs = append(g.sccs, scc)
+	}
+	for _, n := range g.Nodes {
+		if _, ok := index[n]; !ok {
+			visit(n)
+		}
+	}
+}
+
+// --- [ Call sites ] ----------------------------------------------------------
+
+// CallSites returns the call sites of the given function, in program order.
+func CallSites(f *ir.Func) []*CallSite {
+	var sites []*CallSite
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			if call, ok := inst.(*ir.InstCall); ok {
+				sites = append(sites, &CallSite{Inst: call, Block: block, Callee: call.Callee, Sig: call.Sig()})
+			}
+		}
+		switch term := block.Term.(type) {
+		case *ir.TermInvoke:
+			sites = append(sites, &CallSite{Inst: term, Block: block, Callee: term.Invokee, Sig: term.Sig()})
+		case *ir.TermCallBr:
+			sites = append(sites, &CallSite{Inst: term, Block: block, Callee: term.Callee, Sig: term.Sig()})
+		}
+	}
+	return sites
+}
+
+// Callees returns the functions called through the given callee operand, and
+// reports whether the callee was resolved statically. Callees are resolved
+// through aliases, bitcast and addrspacecast constant expressions and indirect
+// functions.
+func Callees(callee value.Value) ([]*ir.Func, bool) {
+	return callees(irutil.Unwrap(callee), make(map[value.Value]bool))
+}
+
+// callees returns the functions called through the given callee operand, and
+// reports whether the callee was resolved statically.
+func callees(callee value.Value, seen map[value.Value]bool) ([]*ir.Func, bool) {
+	if seen[callee] {
+		// Cyclic aliases or indirect functions.
+		return nil, false
+	}
+	seen[callee] = true
+	defer delete(seen, callee)
+	switch callee := callee.(type) {
+	case *ir.Func:
+		return []*ir.Func{callee}, true
+	case *ir.Alias:
+		return callees(callee.Aliasee, seen)
+	case *constant.ExprBitCast:
+		return callees(callee.From, seen)
+	case *constant.ExprAddrSpaceCast:
+		return callees(callee.From, seen)
+	case *ir.IFunc:
+		resolvers, ok := callees(callee.Resolver, seen)
+		if !ok || len(resolvers) != 1 || len(resolvers[0].Blocks) == 0 {
+			return nil, false
+		}
+		return implementations(resolvers[0], seen)
+	}
+	return nil, false
+}
+
+// implementations returns the functions returned by the given resolver of an
+// indirect function, and reports whether all returned values were resolved
+// statically.
+func implementations(resolver *ir.Func, seen map[value.Value]bool) ([]*ir.Func, bool) {
+	var fs []*ir.Func
+	phis := make(map[*ir.InstPhi]bool)
+	var returned func(v value.Value) bool
+	returned = func(v value.Value) bool {
+		switch v := v.(type) {
+		case *ir.InstPhi:
+			if phis[v] {
+				return true
+			}
+			phis[v] = true
+			for _, inc := range v.Incs {
+				if !returned(inc.X) {
+					return false
+				}
+			}
+			return true
+		case *ir.InstSelect:
+			return returned(v.ValueTrue) && returned(v.ValueFalse)
+		case *ir.InstBitCast:
+			return returned(v.From)
+		}
+		impls, ok := callees(v, seen)
+		fs = append(fs, impls...)
+		return ok
+	}
+	for _, block := range resolver.Blocks {
+		if ret, ok := block.Term.(*ir.TermRet); ok {
+			if ret.X == nil || !returned(ret.X) {
+				return nil, false
+			}
+		}
+	}
+	return uniqueFuncs(fs), len(fs) > 0
+}
+
+// --- [ Resolvers ] -----------------------------------------------------------
+
+// MatchSignature returns a resolver which resolves indirect call sites to the
+// address-taken functions of the given module with the same function signature
+// as the call site. A function is address-taken if it is used other than as
+// the callee of a call site (e.g. stored to memory, or part of the initializer
+// of a global variable).
+func MatchSignature(m *ir.Module) Resolver {
+	taken := addressTaken(m)
+	return func(site *CallSite) []*ir.Func {
+		var fs []*ir.Func
+		for _, f := range taken {
+			if f.Sig.Equal(site.Sig) {
+				fs = append(fs, f)
+			}
+		}
+		return fs
+	}
+}
+
+// addressTaken returns the address-taken functions of the given module, in
+// order of the functions of the module.
+func addressTaken(m *ir.Module) []*ir.Func {
+	taken := make(map[*ir.Func]bool)
+	markConst := func(c constant.Constant) {
+		irutil.WalkConst(c, func(c constant.Constant) bool {
+			switch c := c.(type) {
+			case *ir.Func:
+				taken[c] = true
+			case *ir.Alias, *ir.IFunc:
+				if fs, ok := Callees(c); ok {
+					for _, f := range fs {
+						taken[f] = true
+					}
+				}
+			}
+			return true
+		})
+	}
+	for _, g := range m.Globals {
+		markConst(g.Init)
+	}
+	for _, f := range m.Funcs {
+		for _, block := range f.Blocks {
+			for _, inst := range block.Insts {
+				if user, ok := inst.(value.User); ok {
+					markOperands(user, markConst)
+				}
+			}
+			if block.Term != nil {
+				markOperands(block.Term, markConst)
+			}
+		}
+	}
+	var fs []*ir.Func
+	for _, f := range m.Funcs {
+		if taken[f] {
+			fs = append(fs, f)
+		}
+	}
+	return fs
+}
+
+// ### [ Helper functions ] ####################################################
+
+// markOperands marks the constant operands of the given instruction or
+// terminator, except for the callee operand of call sites.
+func markOperands(user value.User, markConst func(c constant.Constant)) {
+	var callee *value.Value
+	switch user := user.(type) {
+	case *ir.InstCall:
+		callee = &user.Callee
+	case *ir.TermInvoke:
+		callee = &user.Invokee
+	case *ir.TermCallBr:
+		callee = &user.Callee
+	}
+	for _, op := range user.Operands() {
+		if op == callee {
+			continue
+		}
+		if c, ok := irutil.Unwrap(*op).(constant.Constant); ok {
+			markConst(c)
+		}
+	}
+}
+
+// uniqueFuncs returns the given functions, including duplicates only once.
+func uniqueFuncs(fs []*ir.Func) []*ir.Func {
+	var unique []*ir.Func
+	seen := make(map[*ir.Func]bool)
+	for _, f := range fs {
+		if !seen[f] {
+			seen[f] = true
+			unique = append(unique, f)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,162 @@
+package callgraph
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+)
+
+const src = `
+@fp = global i32 (i32)* @inc
+
+@inc.alias = alias i32 (i32), i32 (i32)* @inc
+
+@dispatch = ifunc i32 (i32), i32 (i32)* ()* @resolve
+
+define i32 @inc(i32 %x) {
+	%y = add i32 %x, 1
+	ret i32 %y
+}
+
+define i32 @dec(i32 %x) {
+	%y = sub i32 %x, 1
+	ret i32 %y
+}
+
+define i32 @neg(i32 %x) {
+	%y = sub i32 0, %x
+	ret i32 %y
+}
+
+define i32 (i32)* @resolve() {
+	ret i32 (i32)* @dec
+}
+
+define i1 @even(i32 %n) {
+entry:
+	%z = icmp eq i32 %n, 0
+	br i1 %z, label %done, label %rec
+
+rec:
+	%m = sub i32 %n, 1
+	%r = call i1 @odd(i32 %m)
+	ret i1 %r
+
+done:
+	ret i1 true
+}
+
+define i1 @odd(i32 %n) {
+entry:
+	%z = icmp eq i32 %n, 0
+	br i1 %z, label %done, label %rec
+
+rec:
+	%m = sub i32 %n, 1
+	%r = call i1 @even(i32 %m)
+	ret i1 %r
+
+done:
+	ret i1 false
+}
+
+define i32 @main(i32 %x) {
+	%a = call i32 @inc.alias(i32 %x)
+	%b = call i32 @dispatch(i32 %a)
+	%c = call i32 bitcast (i32 (i32)* @neg to i32 (i32)*)(i32 %b)
+	%f = load i32 (i32)*, i32 (i32)** @fp
+	%d = call i32 %f(i32 %c)
+	%e = call i1 @even(i32 %d)
+	ret i32 %d
+}
+`
+
+func TestNew(t *testing.T) {
+	m, err := asm.ParseString("callgraph.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	g := New(m)
+	var edges []string
+	for _, n := range g.Nodes {
+		for _, e := range n.Out {
+			kind := "direct"
+			if e.Indirect {
+				kind = "indirect"
+			}
+			edges = append(edges, fmt.Sprintf("%s -> %s (%s)", e.Caller.Func.Ident(), e.Callee.Func.Ident(), kind))
+		}
+	}
+	wantEdges := []string{
+		"@even -> @odd (direct)",
+		"@odd -> @even (direct)",
+		"@main -> @inc (direct)",
+		"@main -> @dec (direct)",
+		"@main -> @neg (direct)",
+		"@main -> @inc (indirect)",
+		// @dec is address-taken, as returned by the resolver of @dispatch.
+		"@main -> @dec (indirect)",
+		"@main -> @even (direct)",
+	}
+	if !reflect.DeepEqual(edges, wantEdges) {
+		t.Errorf("call graph edges mismatch; expected %q, got %q", wantEdges, edges)
+	}
+	var sccs [][]string
+	for _, scc := range g.SCCs() {
+		sccs = append(sccs, names(scc))
+	}
+	wantSCCs := [][]string{{"@inc"}, {"@dec"}, {"@neg"}, {"@resolve"}, {"@even", "@odd"}, {"@main"}}
+	if !reflect.DeepEqual(sccs, wantSCCs) {
+		t.Errorf("strongly connected components mismatch; expected %q, got %q", wantSCCs, sccs)
+	}
+	for _, f := range m.Funcs {
+		want := f.Name() == "even" || f.Name() == "odd"
+		if got := g.IsRecursive(f); got != want {
+			t.Errorf("recursion mismatch of %q; expected %v, got %v", f.Ident(), want, got)
+		}
+	}
+}
+
+func TestNewWithResolver(t *testing.T) {
+	m, err := asm.ParseString("callgraph.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	// Resolve every indirect call to @neg.
+	var neg *ir.Func
+	for _, f := range m.Funcs {
+		if f.Name() == "neg" {
+			neg = f
+		}
+	}
+	g := NewWithResolver(m, func(site *CallSite) []*ir.Func {
+		return []*ir.Func{neg}
+	})
+	main := g.Node(m.Funcs[len(m.Funcs)-1])
+	var indirect []string
+	for _, e := range main.Out {
+		if e.Indirect {
+			indirect = append(indirect, e.Callee.Func.Ident())
+		}
+	}
+	if want := []string{"@neg"}; !reflect.DeepEqual(indirect, want) {
+		t.Errorf("indirect callees mismatch; expected %q, got %q", want, indirect)
+	}
+	// Unresolved indirect call sites.
+	g = NewWithResolver(m, nil)
+	main = g.Node(m.Funcs[len(m.Funcs)-1])
+	if len(main.Unresolved) != 1 {
+		t.Errorf("number of unresolved call sites mismatch; expected 1, got %d", len(main.Unresolved))
+	}
+}
+
+func names(nodes []*Node) []string {
+	var ns []string
+	for _, n := range nodes {
+		ns = append(ns, n.Func.Ident())
+	}
+	return ns
+}
@@ -0,0 +1,122 @@
+// Package cfg provides control flow graph analyses of LLVM IR functions, such
+// as predecessor maps, depth-first block orderings and dominator trees.
+package cfg
+
+import (
+	"github.com/llir/llvm/ir"
+)
+
+// Succs returns the successor basic blocks of the given basic block. Duplicate
+// successors (e.g. switch cases with the same target) are only included once.
+func Succs(block *ir.Block) []*ir.Block {
+	if block.Term == nil {
+		return nil
+	}
+	succs := block.Term.Succs()
+	for i, succ := range succs {
+		for _, prev := range succs[:i] {
+			if prev == succ {
+				return uniqueBlocks(succs)
+			}
+		}
+	}
+	return succs
+}
+
+// Preds returns a map from each basic block of f to its predecessor basic
+// blocks. Predecessors are listed in the order of the basic blocks of f, and
+// are only included once per successor, even if a predecessor has several
+// edges to the successor.
+func Preds(f *ir.Func) map[*ir.Block][]*ir.Block {
+	preds := make(map[*ir.Block][]*ir.Block, len(f.Blocks))
+	for _, block := range f.Blocks {
+		if _, ok := preds[block]; !ok {
+			preds[block] = nil
+		}
+		for _, succ := range Succs(block) {
+			preds[succ] = append(preds[succ], block)
+		}
+	}
+	return preds
+}
+
+// PostOrder returns the basic blocks of f reachable from the entry basic block,
+// in depth-first post-order.
+func PostOrder(f *ir.Func) []*ir.Block {
+	if len(f.Blocks) == 0 {
+		return nil
+	}
+	return postOrder(f.Blocks[0], Succs)
+}
+
+// ReversePostOrder returns the basic blocks of f reachable from the entry basic
+// block, in reverse depth-first post-order. In reverse post-order, each basic
+// block is visited before its successors, except along back edges.
+func ReversePostOrder(f *ir.Func) []*ir.Block {
+	blocks := PostOrder(f)
+	reverse(blocks)
+	return blocks
+}
+
+// Reachable returns the set of basic blocks of f reachable from the entry basic
+// block.
+func Reachable(f *ir.Func) map[*ir.Block]bool {
+	reachable := make(map[*ir.Block]bool, len(f.Blocks))
+	for _, block := range PostOrder(f) {
+		reachable[block] = true
+	}
+	return reachable
+}
+
+// ### [ Helper functions ] ####################################################
+
+// postOrder returns the basic blocks reachable from entry (following the edges
+// given by succs) in depth-first post-order.
+func postOrder(entry *ir.Block, succs func(*ir.Block) []*ir.Block) []*ir.Block {
+	// Iterative depth-first search, to handle large functions without
+	// exhausting the stack.
+	type frame struct {
+		block *ir.Block
+		succs []*ir.Block
+	}
+	var order []*ir.Block
+	visited := map[*ir.Block]bool{entry: true}
+	stack := []frame{{block: entry, succs: succs(entry)}}
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if len(top.succs) == 0 {
+			order = append(order, top.block)
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		succ := top.succs[0]
+		top.succs = top.succs[1:]
+		if visited[succ] {
+			continue
+		}
+		visited[succ] = true
+		stack = append(stack, frame{block: succ, succs: succs(succ)})
+	}
+	return order
+}
+
+// uniqueBlocks returns the given basic blocks with duplicates removed,
+// retaining the order of first occurrence.
+func uniqueBlocks(blocks []*ir.Block) []*ir.Block {
+	seen := make(map[*ir.Block]bool, len(blocks))
+	var unique []*ir.Block
+	for _, block := range blocks {
+		if !seen[block] {
+			seen[block] = true
+			unique = append(unique, block)
+		}
+	}
+	return unique
+}
+
+// reverse reverses the given list of basic blocks in place.
+func reverse(blocks []*ir.Block) {
+	for i, j := 0, len(blocks)-1; i < j; i, j = i+1, j-1 {
+		blocks[i], blocks[j] = blocks[j], blocks[i]
+	}
+}
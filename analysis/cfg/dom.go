@@ -0,0 +1,200 @@
+package cfg
+
+import (
+	"github.com/llir/llvm/ir"
+)
+
+// === [ Dominator tree ] ======================================================
+
+// DomTree is the dominator tree of a function. A basic block A dominates a
+// basic block B if every path from the entry basic block to B passes through A.
+//
+// Only basic blocks reachable from the entry basic block are part of the
+// dominator tree.
+type DomTree struct {
+	// Root of the dominator tree (i.e. entry basic block).
+	root *ir.Block
+	// Immediate dominator of each basic block; nil for the root.
+	idom map[*ir.Block]*ir.Block
+	// Children of each basic block in the dominator tree, in reverse
+	// post-order of the control flow graph.
+	children map[*ir.Block][]*ir.Block
+	// Depth-first numbering of the dominator tree, for constant time dominance
+	// queries.
+	in, out map[*ir.Block]int
+}
+
+// NewDomTree returns the dominator tree of the given function definition.
+//
+// The dominator tree is computed using the iterative algorithm of Cooper,
+// Harvey and Kennedy; "A Simple, Fast Dominance Algorithm".
+func NewDomTree(f *ir.Func) *DomTree {
+	if len(f.Blocks) == 0 {
+		return &DomTree{
+			idom:     make(map[*ir.Block]*ir.Block),
+			children: make(map[*ir.Block][]*ir.Block),
+			in:       make(map[*ir.Block]int),
+			out:      make(map[*ir.Block]int),
+		}
+	}
+	preds := Preds(f)
+	rpo := ReversePostOrder(f)
+	return newDomTree(rpo, func(block *ir.Block) []*ir.Block {
+		return preds[block]
+	})
+}
+
+// Root returns the root of the dominator tree; i.e. the entry basic block.
+func (t *DomTree) Root() *ir.Block {
+	return t.root
+}
+
+// IDom returns the immediate dominator of the given basic block, or nil if
+// block is the root of the dominator tree or unreachable.
+func (t *DomTree) IDom(block *ir.Block) *ir.Block {
+	return t.idom[block]
+}
+
+// Children returns the basic blocks immediately dominated by the given basic
+// block.
+func (t *DomTree) Children(block *ir.Block) []*ir.Block {
+	return t.children[block]
+}
+
+// IsReachable reports whether the given basic block is reachable from the
+// entry basic block, and thus part of the dominator tree.
+func (t *DomTree) IsReachable(block *ir.Block) bool {
+	_, ok := t.in[block]
+	return ok
+}
+
+// Dominates reports whether the basic block a dominates the basic block b.
+// Every basic block dominates itself. Unreachable basic blocks neither dominate
+// nor are dominated by any basic block.
+func (t *DomTree) Dominates(a, b *ir.Block) bool {
+	ain, ok := t.in[a]
+	if !ok {
+		return false
+	}
+	bin, ok := t.in[b]
+	if !ok {
+		return false
+	}
+	return ain <= bin && t.out[b] <= t.out[a]
+}
+
+// StrictlyDominates reports whether the basic block a dominates the basic block
+// b, and a is not b.
+func (t *DomTree) StrictlyDominates(a, b *ir.Block) bool {
+	return a != b && t.Dominates(a, b)
+}
+
+// PreOrder returns the basic blocks of the dominator tree in depth-first
+// pre-order; i.e. each basic block is visited before the basic blocks it
+// dominates.
+func (t *DomTree) PreOrder() []*ir.Block {
+	if t.root == nil {
+		return nil
+	}
+	var order []*ir.Block
+	stack := []*ir.Block{t.root}
+	for len(stack) > 0 {
+		block := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		order = append(order, block)
+		children := t.children[block]
+		for i := len(children) - 1; i >= 0; i-- {
+			stack = append(stack, children[i])
+		}
+	}
+	return order
+}
+
+// ### [ Helper functions ] ####################################################
+
+// newDomTree returns the dominator tree of the graph with the given nodes in
+// reverse post-order (starting with the root) and predecessor function.
+func newDomTree(rpo []*ir.Block, preds func(*ir.Block) []*ir.Block) *DomTree {
+	t := &DomTree{
+		root:     rpo[0],
+		idom:     make(map[*ir.Block]*ir.Block, len(rpo)),
+		children: make(map[*ir.Block][]*ir.Block, len(rpo)),
+		in:       make(map[*ir.Block]int, len(rpo)),
+		out:      make(map[*ir.Block]int, len(rpo)),
+	}
+	// Index of each node in reverse post-order.
+	index := make(map[*ir.Block]int, len(rpo))
+	for i, block := range rpo {
+		index[block] = i
+	}
+	// Immediate dominators by reverse post-order index; -1 if not yet
+	// computed.
+	doms := make([]int, len(rpo))
+	for i := range doms {
+		doms[i] = -1
+	}
+	doms[0] = 0
+	intersect := func(a, b int) int {
+		for a != b {
+			for a > b {
+				a = doms[a]
+			}
+			for b > a {
+				b = doms[b]
+			}
+		}
+		return a
+	}
+	for changed := true; changed; {
+		changed = false
+		for i := 1; i < len(rpo); i++ {
+			newIDom := -1
+			for _, pred := range preds(rpo[i]) {
+				j, ok := index[pred]
+				if !ok || doms[j] == -1 {
+					// Skip unreachable and not yet processed predecessors.
+					continue
+				}
+				if newIDom == -1 {
+					newIDom = j
+				} else {
+					newIDom = intersect(j, newIDom)
+				}
+			}
+			if doms[i] != newIDom {
+				doms[i] = newIDom
+				changed = true
+			}
+		}
+	}
+	for i := 1; i < len(rpo); i++ {
+		idom := rpo[doms[i]]
+		t.idom[rpo[i]] = idom
+		t.children[idom] = append(t.children[idom], rpo[i])
+	}
+	// Number the nodes of the dominator tree.
+	n := 0
+	type frame struct {
+		block *ir.Block
+		next  int
+	}
+	stack := []frame{{block: t.root}}
+	t.in[t.root] = n
+	n++
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		children := t.children[top.block]
+		if top.next < len(children) {
+			child := children[top.next]
+			top.next++
+			t.in[child] = n
+			n++
+			stack = append(stack, frame{block: child})
+			continue
+		}
+		t.out[top.block] = n
+		n++
+		stack = stack[:len(stack)-1]
+	}
+	return t
+}
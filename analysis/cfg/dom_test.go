@@ -0,0 +1,79 @@
+package cfg
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+)
+
+func TestDomTree(t *testing.T) {
+	const src = `
+define void @f(i1 %c) {
+entry:
+	br i1 %c, label %loop, label %exit
+
+loop:
+	br i1 %c, label %then, label %else
+
+then:
+	br label %latch
+
+else:
+	br label %latch
+
+latch:
+	br i1 %c, label %loop, label %exit
+
+exit:
+	ret void
+
+dead:
+	br label %exit
+}`
+	m, err := asm.ParseString("dom.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	f := m.Funcs[0]
+	blocks := make(map[string]*ir.Block)
+	for _, block := range f.Blocks {
+		blocks[block.Name()] = block
+	}
+	dt := NewDomTree(f)
+	idoms := map[string]string{
+		"entry": "",
+		"loop":  "entry",
+		"then":  "loop",
+		"else":  "loop",
+		"latch": "loop",
+		"exit":  "entry",
+		"dead":  "",
+	}
+	for name, want := range idoms {
+		got := ""
+		if idom := dt.IDom(blocks[name]); idom != nil {
+			got = idom.Name()
+		}
+		if got != want {
+			t.Errorf("immediate dominator mismatch of %q; expected %q, got %q", name, want, got)
+		}
+	}
+	golden := []struct {
+		a, b string
+		want bool
+	}{
+		{a: "entry", b: "exit", want: true},
+		{a: "loop", b: "latch", want: true},
+		{a: "loop", b: "loop", want: true},
+		{a: "then", b: "latch", want: false},
+		{a: "loop", b: "exit", want: false},
+		{a: "entry", b: "dead", want: false},
+	}
+	for _, g := range golden {
+		got := dt.Dominates(blocks[g.a], blocks[g.b])
+		if got != g.want {
+			t.Errorf("dominance mismatch of %q over %q; expected %v, got %v", g.a, g.b, g.want, got)
+		}
+	}
+}
@@ -0,0 +1,376 @@
+// Package dataflow implements a generic monotone data-flow analysis framework
+// over the control flow graph of LLVM IR functions.
+//
+// A data-flow problem is specified by a direction (forward or backward), a
+// lattice of data-flow facts (with a bottom element, a join operation and an
+// equality relation), and transfer functions of instructions, terminators and
+// control flow edges. The framework computes the least fixpoint of the problem
+// using a worklist of basic blocks, and provides the data-flow facts at the
+// start and end of each basic block, as well as before and after each
+// instruction.
+//
+// Exceptional control flow edges (from invoke, catchswitch and cleanupret
+// terminators to their unwind destination, and from catchret terminators to
+// their target) are distinguished from normal edges. Along exceptional edges,
+// the fact before the terminator is propagated rather than the fact after it,
+// since e.g. an invoke does not complete (and defines no result) when
+// unwinding. In the backward direction, the facts of exceptional successors
+// are likewise joined before the terminator.
+package dataflow
+
+import (
+	"github.com/llir/llvm/analysis/cfg"
+	"github.com/llir/llvm/ir"
+)
+
+// Fact is a data-flow fact; i.e. an element of the lattice of a data-flow
+// problem. Facts are treated as immutable values by the framework; transfer
+// functions and join operations must return new facts rather than modifying
+// their arguments.
+type Fact interface{}
+
+// Lattice is a join semi-lattice of data-flow facts.
+type Lattice interface {
+	// Bottom returns the least element of the lattice.
+	Bottom() Fact
+	// Join returns the least upper bound of x and y.
+	Join(x, y Fact) Fact
+	// Equal reports whether x and y are equal.
+	Equal(x, y Fact) bool
+}
+
+// Direction is the direction of a data-flow problem.
+type Direction uint8
+
+// Data-flow directions.
+const (
+	// Forward data-flow; facts flow from the entry basic block along control
+	// flow edges.
+	Forward Direction = iota
+	// Backward data-flow; facts flow from exit basic blocks against control
+	// flow edges.
+	Backward
+)
+
+// Edge is a control flow edge between two basic blocks.
+type Edge struct {
+	// Source and target basic block.
+	From, To *ir.Block
+	// Exceptional edge of exception handling control flow; e.g. from an invoke
+	// terminator to its unwind destination.
+	Exceptional bool
+}
+
+// Problem is a monotone data-flow problem.
+type Problem struct {
+	// Direction of the data-flow problem.
+	Direction Direction
+	// Lattice of data-flow facts.
+	Lattice Lattice
+	// (optional) Fact at the boundary of the function; i.e. at the start of
+	// the entry basic block for forward problems, and at the end of exit basic
+	// blocks (without successors) for backward problems. The bottom element of
+	// the lattice is used if nil.
+	Boundary Fact
+	// (optional) Transfer function of instructions. The given fact holds before
+	// inst for forward problems, and after inst for backward problems. The
+	// identity function is used if nil.
+	Inst func(inst ir.Instruction, fact Fact) Fact
+	// (optional) Transfer function of terminators, as for Inst.
+	Term func(term ir.Terminator, fact Fact) Fact
+	// (optional) Transfer function of control flow edges. The given fact holds
+	// at the end of e.From for forward problems, and at the start of e.To for
+	// backward problems. The identity function is used if nil.
+	Edge func(e Edge, fact Fact) Fact
+}
+
+// --- [ Solver ] --------------------------------------------------------------
+
+// Solve computes the fixpoint of the given data-flow problem on f.
+func Solve(f *ir.Func, p *Problem) *Result {
+	s := &solver{
+		p:     p,
+		succs: make(map[*ir.Block][]Edge),
+		preds: make(map[*ir.Block][]Edge),
+		r: &Result{
+			p:      p,
+			In:     make(map[*ir.Block]Fact),
+			Out:    make(map[*ir.Block]Fact),
+			term:   make(map[*ir.Block]Fact),
+			insts:  make(map[ir.Instruction]instFacts),
+			blocks: make(map[*ir.Block]bool),
+		},
+	}
+	for _, block := range f.Blocks {
+		for _, e := range edges(block) {
+			s.succs[block] = append(s.succs[block], e)
+			s.preds[e.To] = append(s.preds[e.To], e)
+		}
+	}
+	bottom := p.Lattice.Bottom()
+	for _, block := range f.Blocks {
+		s.r.In[block] = bottom
+		s.r.Out[block] = bottom
+		s.r.term[block] = bottom
+	}
+	if len(f.Blocks) > 0 {
+		s.solve(f)
+	}
+	return s.r
+}
+
+// solver solves a data-flow problem.
+type solver struct {
+	// Data-flow problem.
+	p *Problem
+	// Outgoing and incoming control flow edges of each basic block.
+	succs, preds map[*ir.Block][]Edge
+	// Data-flow results.
+	r *Result
+}
+
+// solve computes the fixpoint of the data-flow problem on f.
+func (s *solver) solve(f *ir.Func) {
+	// Visit basic blocks in reverse post-order for forward problems, and in
+	// post-order for backward problems, followed by unreachable basic blocks.
+	order := cfg.ReversePostOrder(f)
+	if s.p.Direction == Backward {
+		order = cfg.PostOrder(f)
+	}
+	seen := make(map[*ir.Block]bool)
+	for _, block := range order {
+		seen[block] = true
+	}
+	for _, block := range f.Blocks {
+		if !seen[block] {
+			order = append(order, block)
+		}
+	}
+	queued := make(map[*ir.Block]bool)
+	work := make([]*ir.Block, 0, len(order))
+	for _, block := range order {
+		work = append(work, block)
+		queued[block] = true
+	}
+	entry := f.Blocks[0]
+	for len(work) > 0 {
+		block := work[0]
+		work = work[1:]
+		delete(queued, block)
+		var changed bool
+		var next []Edge
+		if s.p.Direction == Forward {
+			changed = s.forward(block, block == entry)
+			next = s.succs[block]
+		} else {
+			changed = s.backward(block)
+			next = s.preds[block]
+		}
+		if !changed {
+			continue
+		}
+		for _, e := range next {
+			b := e.To
+			if s.p.Direction == Backward {
+				b = e.From
+			}
+			if !queued[b] {
+				work = append(work, b)
+				queued[b] = true
+			}
+		}
+	}
+}
+
+// forward recomputes the facts of the given basic block of a forward problem,
+// and reports whether the fact at the end of the basic block changed.
+func (s *solver) forward(block *ir.Block, entry bool) bool {
+	l := s.p.Lattice
+	in := l.Bottom()
+	if entry {
+		in = s.boundary()
+	}
+	for _, e := range s.preds[block] {
+		fact := s.r.Out[e.From]
+		if e.Exceptional {
+			fact = s.r.term[e.From]
+		}
+		in = l.Join(in, s.edge(e, fact))
+	}
+	s.r.In[block] = in
+	fact := in
+	for _, inst := range block.Insts {
+		fact = s.inst(inst, fact)
+	}
+	oldTerm := s.r.term[block]
+	s.r.term[block] = fact
+	out := s.termFact(block.Term, fact)
+	old := s.r.Out[block]
+	s.r.Out[block] = out
+	// Exceptional successors depend on the fact before the terminator.
+	return !l.Equal(old, out) || !l.Equal(oldTerm, fact)
+}
+
+// backward recomputes the facts of the given basic block of a backward
+// problem, and reports whether the fact at the start of the basic block
+// changed.
+func (s *solver) backward(block *ir.Block) bool {
+	l := s.p.Lattice
+	out := l.Bottom()
+	exc := l.Bottom()
+	if len(s.succs[block]) == 0 {
+		out = s.boundary()
+	}
+	for _, e := range s.succs[block] {
+		fact := s.edge(e, s.r.In[e.To])
+		if e.Exceptional {
+			exc = l.Join(exc, fact)
+		} else {
+			out = l.Join(out, fact)
+		}
+	}
+	s.r.Out[block] = out
+	fact := l.Join(s.termFact(block.Term, out), exc)
+	s.r.term[block] = fact
+	for i := len(block.Insts) - 1; i >= 0; i-- {
+		fact = s.inst(block.Insts[i], fact)
+	}
+	old := s.r.In[block]
+	s.r.In[block] = fact
+	return !l.Equal(old, fact)
+}
+
+// boundary returns the boundary fact of the problem.
+func (s *solver) boundary() Fact {
+	if s.p.Boundary != nil {
+		return s.p.Boundary
+	}
+	return s.p.Lattice.Bottom()
+}
+
+// inst applies the transfer function of the given instruction.
+func (s *solver) inst(inst ir.Instruction, fact Fact) Fact {
+	if s.p.Inst == nil {
+		return fact
+	}
+	return s.p.Inst(inst, fact)
+}
+
+// termFact applies the transfer function of the given terminator.
+func (s *solver) termFact(term ir.Terminator, fact Fact) Fact {
+	if s.p.Term == nil || term == nil {
+		return fact
+	}
+	return s.p.Term(term, fact)
+}
+
+// edge applies the transfer function of the given control flow edge.
+func (s *solver) edge(e Edge, fact Fact) Fact {
+	if s.p.Edge == nil {
+		return fact
+	}
+	return s.p.Edge(e, fact)
+}
+
+// --- [ Result ] --------------------------------------------------------------
+
+// Result is the fixpoint of a data-flow problem. Facts are given in program
+// order, regardless of the direction of the problem.
+type Result struct {
+	// Data-flow problem.
+	p *Problem
+	// Fact at the start of each basic block.
+	In map[*ir.Block]Fact
+	// Fact at the end of each basic block (after its terminator).
+	Out map[*ir.Block]Fact
+	// Fact before the terminator of each basic block.
+	term map[*ir.Block]Fact
+	// Facts before and after each instruction, computed on demand.
+	insts map[ir.Instruction]instFacts
+	// Basic blocks whose instruction facts have been computed.
+	blocks map[*ir.Block]bool
+}
+
+// instFacts holds the facts before and after an instruction.
+type instFacts struct {
+	before, after Fact
+}
+
+// BeforeTerm returns the fact before the terminator of the given basic block
+// (i.e. after its last instruction).
+func (r *Result) BeforeTerm(block *ir.Block) Fact {
+	return r.term[block]
+}
+
+// Before returns the fact immediately before the given instruction of block.
+func (r *Result) Before(block *ir.Block, inst ir.Instruction) Fact {
+	r.computeInsts(block)
+	return r.insts[inst].before
+}
+
+// After returns the fact immediately after the given instruction of block.
+func (r *Result) After(block *ir.Block, inst ir.Instruction) Fact {
+	r.computeInsts(block)
+	return r.insts[inst].after
+}
+
+// computeInsts computes the facts before and after each instruction of the
+// given basic block.
+func (r *Result) computeInsts(block *ir.Block) {
+	if r.blocks[block] {
+		return
+	}
+	r.blocks[block] = true
+	s := &solver{p: r.p}
+	if r.p.Direction == Forward {
+		fact := r.In[block]
+		for _, inst := range block.Insts {
+			after := s.inst(inst, fact)
+			r.insts[inst] = instFacts{before: fact, after: after}
+			fact = after
+		}
+		return
+	}
+	fact := r.term[block]
+	for i := len(block.Insts) - 1; i >= 0; i-- {
+		inst := block.Insts[i]
+		before := s.inst(inst, fact)
+		r.insts[inst] = instFacts{before: before, after: fact}
+		fact = before
+	}
+}
+
+// ### [ Helper functions ] ####################################################
+
+// edges returns the outgoing control flow edges of the given basic block. Each
+// successor is included once, even if the terminator has several edges to the
+// successor.
+func edges(block *ir.Block) []Edge {
+	unwind := unwindTarget(block.Term)
+	var es []Edge
+	for _, succ := range cfg.Succs(block) {
+		es = append(es, Edge{From: block, To: succ, Exceptional: succ == unwind})
+	}
+	return es
+}
+
+// unwindTarget returns the successor of the given terminator reached by
+// exception handling control flow; i.e. the unwind destination of invoke,
+// catchswitch and cleanupret terminators, and the target of catchret
+// terminators, which leave a catch funclet. Nil is returned if the terminator
+// has no such successor (or unwinds to the caller).
+func unwindTarget(term ir.Terminator) *ir.Block {
+	var target interface{}
+	switch term := term.(type) {
+	case *ir.TermInvoke:
+		target = term.ExceptionRetTarget
+	case *ir.TermCatchSwitch:
+		target = term.DefaultUnwindTarget
+	case *ir.TermCatchRet:
+		target = term.Target
+	case *ir.TermCleanupRet:
+		target = term.UnwindTarget
+	}
+	block, _ := target.(*ir.Block)
+	return block
+}
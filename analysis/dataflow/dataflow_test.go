@@ -0,0 +1,289 @@
+package dataflow
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/value"
+)
+
+func TestSolveBackward(t *testing.T) {
+	const src = `
+define i32 @f(i32 %n) {
+entry:
+	br label %loop
+
+loop:
+	%i = phi i32 [ 0, %entry ], [ %i.next, %loop ]
+	%sum = phi i32 [ 0, %entry ], [ %sum.next, %loop ]
+	%sum.next = add i32 %sum, %i
+	%i.next = add i32 %i, 1
+	%cond = icmp slt i32 %i.next, %n
+	br i1 %cond, label %loop, label %exit
+
+exit:
+	ret i32 %sum.next
+}`
+	m, err := asm.ParseString("liveness.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	f := m.Funcs[0]
+	p := &Problem{
+		Direction: Backward,
+		Lattice:   setLattice{},
+		Inst: func(inst ir.Instruction, fact Fact) Fact {
+			live := fact.(set).clone()
+			if v, ok := inst.(value.Named); ok {
+				delete(live, v.Name())
+			}
+			// Incoming values of phi instructions are live on the edge from
+			// their predecessor.
+			if _, ok := inst.(*ir.InstPhi); ok {
+				return live
+			}
+			return live.addOperands(inst.(value.User))
+		},
+		Term: func(term ir.Terminator, fact Fact) Fact {
+			return fact.(set).clone().addOperands(term)
+		},
+		Edge: func(e Edge, fact Fact) Fact {
+			live := fact.(set).clone()
+			for _, inst := range e.To.Insts {
+				if phi, ok := inst.(*ir.InstPhi); ok {
+					for _, inc := range phi.Incs {
+						if inc.Pred == e.From {
+							live.add(inc.X)
+						}
+					}
+				}
+			}
+			return live
+		},
+	}
+	r := Solve(f, p)
+	golden := []struct {
+		block   string
+		in, out []string
+	}{
+		{block: "entry", in: []string{"n"}, out: []string{"n"}},
+		{block: "loop", in: []string{"n"}, out: []string{"i.next", "n", "sum.next"}},
+		{block: "exit", in: []string{"sum.next"}, out: nil},
+	}
+	for i, g := range golden {
+		block := f.Blocks[i]
+		if got := r.In[block].(set).names(); !reflect.DeepEqual(got, g.in) {
+			t.Errorf("live-in mismatch of block %q; expected %v, got %v", g.block, g.in, got)
+		}
+		if got := r.Out[block].(set).names(); !reflect.DeepEqual(got, g.out) {
+			t.Errorf("live-out mismatch of block %q; expected %v, got %v", g.block, g.out, got)
+		}
+	}
+	// Instruction-level facts.
+	loop := f.Blocks[1]
+	add := loop.Insts[3]
+	if got, want := r.Before(loop, add).(set).names(), []string{"i", "n", "sum.next"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("live before %%i.next mismatch; expected %v, got %v", want, got)
+	}
+	if got, want := r.After(loop, add).(set).names(), []string{"i.next", "n", "sum.next"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("live after %%i.next mismatch; expected %v, got %v", want, got)
+	}
+}
+
+func TestSolveForwardInvoke(t *testing.T) {
+	const src = `
+declare i32 @g()
+
+declare i32 @__gxx_personality_v0(...)
+
+define i32 @f() personality i32 (...)* @__gxx_personality_v0 {
+entry:
+	%x = add i32 1, 2
+	%r = invoke i32 @g()
+		to label %cont unwind label %lpad
+
+cont:
+	ret i32 %r
+
+lpad:
+	%lp = landingpad { i8*, i32 }
+		cleanup
+	ret i32 %x
+}`
+	m, err := asm.ParseString("invoke.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	f := m.Funcs[len(m.Funcs)-1]
+	// Values defined along some path to each program point.
+	p := &Problem{
+		Direction: Forward,
+		Lattice:   setLattice{},
+		Inst: func(inst ir.Instruction, fact Fact) Fact {
+			return fact.(set).clone().add(inst.(value.Value))
+		},
+		Term: func(term ir.Terminator, fact Fact) Fact {
+			if v, ok := term.(value.Value); ok {
+				return fact.(set).clone().add(v)
+			}
+			return fact
+		},
+	}
+	r := Solve(f, p)
+	golden := []struct {
+		block string
+		in    []string
+	}{
+		{block: "entry", in: nil},
+		{block: "cont", in: []string{"r", "x"}},
+		{block: "lpad", in: []string{"x"}},
+	}
+	for i, g := range golden {
+		block := f.Blocks[i]
+		if got := r.In[block].(set).names(); !reflect.DeepEqual(got, g.in) {
+			t.Errorf("defined values mismatch of block %q; expected %v, got %v", g.block, g.in, got)
+		}
+	}
+}
+
+func TestSolveExceptionalEdges(t *testing.T) {
+	const src = `
+declare void @g()
+
+declare i32 @__CxxFrameHandler3(...)
+
+define void @f() personality i32 (...)* @__CxxFrameHandler3 {
+entry:
+	invoke void @g()
+		to label %cont unwind label %cleanup
+
+cont:
+	ret void
+
+cleanup:
+	%cp = cleanuppad within none []
+	cleanupret from %cp unwind label %dispatch
+
+dispatch:
+	%cs = catchswitch within none [label %handler] unwind to caller
+
+handler:
+	%c = catchpad within %cs [i8* null]
+	catchret from %c to label %cont
+}`
+	m, err := asm.ParseString("eh.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	f := m.Funcs[len(m.Funcs)-1]
+	// Values defined along some path to each program point, and the
+	// exceptional edges of the function.
+	exceptional := make(map[string]bool)
+	p := &Problem{
+		Direction: Forward,
+		Lattice:   setLattice{},
+		Inst: func(inst ir.Instruction, fact Fact) Fact {
+			return fact.(set).clone().add(inst.(value.Value))
+		},
+		Term: func(term ir.Terminator, fact Fact) Fact {
+			if v, ok := term.(value.Value); ok {
+				return fact.(set).clone().add(v)
+			}
+			return fact
+		},
+		Edge: func(e Edge, fact Fact) Fact {
+			if e.Exceptional {
+				exceptional[e.From.Name()+" -> "+e.To.Name()] = true
+			}
+			return fact
+		},
+	}
+	r := Solve(f, p)
+	want := map[string]bool{
+		"entry -> cleanup":    true,
+		"cleanup -> dispatch": true,
+		"handler -> cont":     true,
+	}
+	if !reflect.DeepEqual(exceptional, want) {
+		t.Errorf("exceptional edges mismatch; expected %v, got %v", want, exceptional)
+	}
+	// The catchswitch token is defined on the edge to the handler, which is
+	// not exceptional.
+	golden := []struct {
+		block string
+		in    []string
+	}{
+		{block: "dispatch", in: []string{"cp"}},
+		{block: "handler", in: []string{"cp", "cs"}},
+	}
+	for _, g := range golden {
+		var block *ir.Block
+		for _, b := range f.Blocks {
+			if b.Name() == g.block {
+				block = b
+			}
+		}
+		if got := r.In[block].(set).names(); !reflect.DeepEqual(got, g.in) {
+			t.Errorf("defined values mismatch of block %q; expected %v, got %v", g.block, g.in, got)
+		}
+	}
+}
+
+// setLattice is the lattice of sets of named values ordered by inclusion.
+type setLattice struct{}
+
+func (setLattice) Bottom() Fact {
+	return set{}
+}
+
+func (setLattice) Join(x, y Fact) Fact {
+	s := x.(set).clone()
+	for name := range y.(set) {
+		s[name] = true
+	}
+	return s
+}
+
+func (setLattice) Equal(x, y Fact) bool {
+	return reflect.DeepEqual(x, y)
+}
+
+// set is a set of value names.
+type set map[string]bool
+
+func (s set) clone() set {
+	c := make(set)
+	for name := range s {
+		c[name] = true
+	}
+	return c
+}
+
+func (s set) add(v value.Value) set {
+	switch v.(type) {
+	case *ir.Param, ir.Instruction, ir.Terminator:
+		if v, ok := v.(value.Named); ok && len(v.Name()) > 0 {
+			s[v.Name()] = true
+		}
+	}
+	return s
+}
+
+func (s set) addOperands(user value.User) set {
+	for _, op := range user.Operands() {
+		s.add(*op)
+	}
+	return s
+}
+
+func (s set) names() []string {
+	var names []string
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,409 @@
+// Package escape implements escape (capture) analysis of pointers in LLVM IR
+// functions.
+//
+// A pointer escapes a function if its address may be observed outside the
+// function, or may outlive it; i.e. if the pointer, or a pointer derived from
+// it, is stored to memory, returned, passed to a call which may capture it, or
+// converted to an integer with ptrtoint. Pointers derived from a pointer are the
+// results of bitcast, addrspacecast, getelementptr, phi, select and freeze
+// instructions, the aggregates and vectors containing the pointer, and the
+// results of calls passing the pointer to a parameter with the returned
+// attribute.
+//
+// A call does not capture a pointer argument if the argument has the nocapture
+// parameter attribute, either at the call site (ir.Arg) or at the parameter of
+// the callee (ir.Param), if the callee only reads memory and returns no value,
+// or if the callee is a deallocation function or a memory intrinsic (e.g.
+// llvm.memcpy and llvm.lifetime.start). Loads and stores through a pointer do
+// not capture it, unless they are volatile. Comparisons of pointers are not
+// considered to capture them.
+//
+// The allocations of a function analyzed for escape are its alloca
+// instructions and the calls to heap allocation functions (e.g. malloc).
+// Allocations which do not escape may be promoted to registers or moved from
+// the heap to the stack.
+package escape
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/llir/llvm/analysis/pointsto"
+	"github.com/llir/llvm/intrinsics"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/llir/llvm/irutil"
+)
+
+// DefaultDeallocators are the names of the heap deallocation functions
+// recognized by default.
+var DefaultDeallocators = []string{
+	"free", "_ZdlPv", "_ZdaPv", "_ZdlPvm", "_ZdaPvm",
+}
+
+// Config is the configuration of the escape analysis.
+type Config struct {
+	// (optional) Names of heap allocation functions (global names without '@'
+	// prefix); pointsto.DefaultAllocators is used if nil.
+	Allocators []string
+	// (optional) Names of heap deallocation functions, which do not capture
+	// their arguments; DefaultDeallocators is used if nil.
+	Deallocators []string
+}
+
+// Reason specifies how a pointer escapes.
+type Reason uint8
+
+// Escape reasons.
+const (
+	// Stored to memory; by a store, cmpxchg or atomicrmw instruction.
+	Stored Reason = iota + 1
+	// Returned from the function.
+	Returned
+	// Passed to a call which may capture it.
+	Passed
+	// Converted to an integer by a ptrtoint instruction.
+	PtrToInt
+	// Accessed by a volatile memory operation.
+	Volatile
+	// Used by an instruction which may capture it in an unknown way.
+	Unknown
+)
+
+// String returns the string representation of the escape reason.
+func (r Reason) String() string {
+	switch r {
+	case Stored:
+		return "stored"
+	case Returned:
+		return "returned"
+	case Passed:
+		return "passed"
+	case PtrToInt:
+		return "ptrtoint"
+	case Volatile:
+		return "volatile"
+	case Unknown:
+		return "unknown"
+	}
+	return fmt.Sprintf("Reason(%d)", uint8(r))
+}
+
+// Escape describes how a pointer escapes.
+type Escape struct {
+	// Reason of the escape.
+	Reason Reason
+	// Instruction or terminator through which the pointer escapes
+	// (ir.Instruction or ir.Terminator).
+	Use value.User
+}
+
+// String returns the string representation of the escape; e.g.
+// "stored: store i8* %p, i8** @g".
+func (e *Escape) String() string {
+	return fmt.Sprintf("%s: %s", e.Reason, e.Use.(ir.LLStringer).LLString())
+}
+
+// Info is the escape analysis result of a function.
+type Info struct {
+	// Configuration of the analysis.
+	allocators   map[string]bool
+	deallocators map[string]bool
+	// Users of each value of the function, in program order.
+	users map[value.Value][]value.User
+	// Allocations of the function, in program order.
+	allocs []value.Value
+	// Escape of each pointer queried or analyzed; nil if it does not escape.
+	escapes map[value.Value]*Escape
+}
+
+// Analyze performs escape analysis of the allocations of the given function,
+// based on the given configuration (or the default configuration if nil).
+func Analyze(f *ir.Func, config *Config) *Info {
+	if config == nil {
+		config = &Config{}
+	}
+	allocators := config.Allocators
+	if allocators == nil {
+		allocators = pointsto.DefaultAllocators
+	}
+	deallocators := config.Deallocators
+	if deallocators == nil {
+		deallocators = DefaultDeallocators
+	}
+	info := &Info{
+		allocators:   stringSet(allocators),
+		deallocators: stringSet(deallocators),
+		users:        make(map[value.Value][]value.User),
+		escapes:      make(map[value.Value]*Escape),
+	}
+	addUser := func(user value.User) {
+		for _, op := range user.Operands() {
+			v := irutil.Unwrap(*op)
+			if us := info.users[v]; len(us) > 0 && us[len(us)-1] == user {
+				// Skip repeated operands.
+				continue
+			}
+			info.users[v] = append(info.users[v], user)
+		}
+	}
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			addUser(inst)
+			if info.isAllocation(inst) {
+				info.allocs = append(info.allocs, inst.(value.Value))
+			}
+		}
+		if block.Term != nil {
+			addUser(block.Term)
+			if info.isAllocation(block.Term) {
+				info.allocs = append(info.allocs, block.Term.(value.Value))
+			}
+		}
+	}
+	for _, alloc := range info.allocs {
+		info.Escape(alloc)
+	}
+	return info
+}
+
+// Allocations returns the allocations of the function (*ir.InstAlloca, and
+// *ir.InstCall or *ir.TermInvoke of heap allocation functions), in program
+// order.
+func (info *Info) Allocations() []value.Value {
+	return info.allocs
+}
+
+// Escapes reports whether the given pointer may escape the function.
+func (info *Info) Escapes(ptr value.Value) bool {
+	return info.Escape(ptr) != nil
+}
+
+// Escape returns how the given pointer (e.g. an allocation or a parameter of the
+// function) may escape the function; or nil if it does not escape. The first
+// escaping use found is returned, in breadth-first order of derived pointers.
+func (info *Info) Escape(ptr value.Value) *Escape {
+	if e, ok := info.escapes[ptr]; ok {
+		return e
+	}
+	e := info.track(ptr)
+	info.escapes[ptr] = e
+	return e
+}
+
+// NonEscaping returns the allocations of the function which do not escape, in
+// program order.
+func (info *Info) NonEscaping() []value.Value {
+	var allocs []value.Value
+	for _, alloc := range info.allocs {
+		if !info.Escapes(alloc) {
+			allocs = append(allocs, alloc)
+		}
+	}
+	return allocs
+}
+
+// String returns the string representation of the escape analysis result; one
+// line per allocation.
+func (info *Info) String() string {
+	buf := &strings.Builder{}
+	for _, alloc := range info.allocs {
+		if e := info.Escape(alloc); e != nil {
+			fmt.Fprintf(buf, "%s escapes (%s)\n", alloc.Ident(), e)
+		} else {
+			fmt.Fprintf(buf, "%s does not escape\n", alloc.Ident())
+		}
+	}
+	return buf.String()
+}
+
+// track tracks the uses of the given pointer and the pointers derived from it,
+// and returns the first escaping use; or nil if the pointer does not escape.
+func (info *Info) track(ptr value.Value) *Escape {
+	visited := map[value.Value]bool{ptr: true}
+	queue := []value.Value{ptr}
+	derive := func(v value.Value) {
+		if !visited[v] {
+			visited[v] = true
+			queue = append(queue, v)
+		}
+	}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		for _, user := range info.users[v] {
+			if reason := info.capture(user, v, derive); reason != 0 {
+				return &Escape{Reason: reason, Use: user}
+			}
+		}
+	}
+	return nil
+}
+
+// capture reports how the given user may capture the pointer v; or 0 if it does
+// not capture v. Values derived from v by the user are passed to derive.
+func (info *Info) capture(user value.User, v value.Value, derive func(v value.Value)) Reason {
+	switch user := user.(type) {
+	// Memory instructions.
+	case *ir.InstLoad:
+		if user.Volatile {
+			return Volatile
+		}
+		return 0
+	case *ir.InstStore:
+		if user.Src == v {
+			return Stored
+		}
+		if user.Volatile {
+			return Volatile
+		}
+		return 0
+	case *ir.InstCmpXchg:
+		if user.Cmp == v || user.New == v {
+			return Stored
+		}
+		if user.Volatile {
+			return Volatile
+		}
+		return 0
+	case *ir.InstAtomicRMW:
+		if user.X == v {
+			return Stored
+		}
+		if user.Volatile {
+			return Volatile
+		}
+		return 0
+	// Derived pointers.
+	case *ir.InstSelect:
+		if user.ValueTrue == v || user.ValueFalse == v {
+			derive(user)
+		}
+		return 0
+	case *ir.InstBitCast, *ir.InstAddrSpaceCast, *ir.InstGetElementPtr, *ir.InstPhi, *ir.InstFreeze:
+		derive(user.(value.Value))
+		return 0
+	case *ir.InstInsertValue, *ir.InstExtractValue, *ir.InstInsertElement, *ir.InstExtractElement, *ir.InstShuffleVector:
+		derive(user.(value.Value))
+		return 0
+	case *ir.InstPtrToInt:
+		return PtrToInt
+	case *ir.InstICmp:
+		return 0
+	// Calls.
+	case *ir.InstCall:
+		return info.captureCall(user, user.Callee, user.Args, v, derive)
+	case *ir.TermInvoke:
+		return info.captureCall(user, user.Invokee, user.Args, v, derive)
+	case *ir.TermCallBr:
+		return info.captureCall(user, user.Callee, user.Args, v, derive)
+	// Terminators.
+	case *ir.TermRet:
+		return Returned
+	case *ir.TermCondBr, *ir.TermSwitch:
+		// Only integer operands; reached through ptrtoint.
+		return 0
+	}
+	return Unknown
+}
+
+// captureCall reports how the given call site (*ir.InstCall, *ir.TermInvoke or
+// *ir.TermCallBr) may capture the pointer v; or 0 if it does not capture v.
+func (info *Info) captureCall(site value.Value, callee value.Value, args []value.Value, v value.Value, derive func(v value.Value)) Reason {
+	f := irutil.Callee(callee)
+	var reason Reason
+	for i, arg := range args {
+		if irutil.Unwrap(arg) != v {
+			continue
+		}
+		var attrs []ir.ParamAttribute
+		if arg, ok := arg.(*ir.Arg); ok {
+			attrs = arg.Attrs
+		}
+		if f != nil && i < len(f.Params) {
+			attrs = append(attrs[:len(attrs):len(attrs)], f.Params[i].Attrs...)
+		}
+		if irutil.HasParamAttr(attrs, enum.ParamAttrReturned) {
+			// The result of the call aliases the argument.
+			derive(site)
+		}
+		if irutil.HasParamAttr(attrs, enum.ParamAttrNoCapture) || info.isNoCaptureCallee(site, f) {
+			continue
+		}
+		if reason == 0 {
+			reason = Passed
+		}
+	}
+	return reason
+}
+
+// isNoCaptureCallee reports whether the given callee (nil if not statically
+// known) of the call site does not capture any of its arguments.
+func (info *Info) isNoCaptureCallee(site value.Value, f *ir.Func) bool {
+	if f == nil {
+		return false
+	}
+	name := f.Name()
+	if info.deallocators[name] || isMemIntrinsic(f) {
+		return true
+	}
+	// Functions only reading memory, returning no value and not unwinding (e.g.
+	// throwing an argument as exception), cannot capture their arguments.
+	var attrs []ir.FuncAttribute
+	switch site := site.(type) {
+	case *ir.InstCall:
+		attrs = irutil.CallFuncAttrs(site)
+	case *ir.TermInvoke:
+		attrs = append(site.FuncAttrs[:len(site.FuncAttrs):len(site.FuncAttrs)], f.FuncAttrs...)
+	case *ir.TermCallBr:
+		attrs = append(site.FuncAttrs[:len(site.FuncAttrs):len(site.FuncAttrs)], f.FuncAttrs...)
+	}
+	readOnly := irutil.HasFuncAttr(attrs, enum.FuncAttrReadNone) || irutil.HasFuncAttr(attrs, enum.FuncAttrReadOnly)
+	noUnwind := irutil.HasFuncAttr(attrs, enum.FuncAttrNoUnwind)
+	return readOnly && noUnwind && types.IsVoid(f.Sig.RetType)
+}
+
+// isAllocation reports whether the given instruction or terminator is an
+// allocation.
+func (info *Info) isAllocation(inst value.User) bool {
+	var callee value.Value
+	switch inst := inst.(type) {
+	case *ir.InstAlloca:
+		return true
+	case *ir.InstCall:
+		callee = inst.Callee
+	case *ir.TermInvoke:
+		callee = inst.Invokee
+	default:
+		return false
+	}
+	f := irutil.Callee(callee)
+	return f != nil && info.allocators[f.Name()]
+}
+
+// ### [ Helper functions ] ####################################################
+
+// isMemIntrinsic reports whether the given function is a memory intrinsic
+// which does not capture its pointer arguments.
+func isMemIntrinsic(f *ir.Func) bool {
+	switch id, _ := intrinsics.Lookup(f); id {
+	case intrinsics.Memcpy, intrinsics.Memmove, intrinsics.Memset:
+		return true
+	case intrinsics.LifetimeStart, intrinsics.LifetimeEnd:
+		return true
+	case intrinsics.InvariantStart, intrinsics.InvariantEnd:
+		return true
+	}
+	return false
+}
+
+// stringSet returns a set of the given strings.
+func stringSet(ss []string) map[string]bool {
+	m := make(map[string]bool, len(ss))
+	for _, s := range ss {
+		m[s] = true
+	}
+	return m
+}
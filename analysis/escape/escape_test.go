@@ -0,0 +1,94 @@
+package escape
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/asm"
+)
+
+func TestAnalyze(t *testing.T) {
+	const src = `
+@g = global i8* null
+
+declare i8* @malloc(i64)
+
+declare void @free(i8*)
+
+declare void @use(i8*)
+
+declare void @use.nocapture(i8* nocapture)
+
+declare void @peek(i8*) nounwind readonly
+
+declare void @peek.unwind(i8*) readonly
+
+declare i8* @self(i8* returned)
+
+declare void @llvm.lifetime.start.p0i8(i64, i8*)
+
+declare {}* @llvm.invariant.start.p0i8(i64, i8*)
+
+declare void @llvm.memset.p0i8.i64(i8*)
+
+define i8* @f(i1 %c, i32* %p) {
+entry:
+	%x = load i32, i32* %p
+	%local = alloca i32
+	store i32 1, i32* %local
+	%stored = alloca i8
+	store i8* %stored, i8** @g
+	%derived = alloca [4 x i32]
+	%elem = getelementptr [4 x i32], [4 x i32]* %derived, i64 0, i64 1
+	%cast = bitcast i32* %elem to i8*
+	%sel = select i1 %c, i8* %cast, i8* null
+	call void @use(i8* %sel)
+	%site = alloca i8
+	call void @use(i8* nocapture %site)
+	call void @llvm.lifetime.start.p0i8(i64 1, i8* %site)
+	%inv = call {}* @llvm.invariant.start.p0i8(i64 1, i8* %site)
+	%fake = alloca i8
+	call void @llvm.memset.p0i8.i64(i8* %fake)
+	%callee = alloca i8
+	call void @use.nocapture(i8* %callee)
+	call void @peek(i8* %callee)
+	%thrown = alloca i8
+	call void @peek.unwind(i8* %thrown)
+	%int = alloca i8
+	%i = ptrtoint i8* %int to i64
+	%heap = call i8* @malloc(i64 8)
+	store i8 0, i8* %heap
+	call void @free(i8* %heap)
+	%ret = call i8* @malloc(i64 8)
+	%alias = call i8* @self(i8* nocapture %ret)
+	ret i8* %alias
+}
+`
+	m, err := asm.ParseString("escape.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	info := Analyze(m.Funcs[len(m.Funcs)-1], nil)
+	const want = `%local does not escape
+%stored escapes (stored: store i8* %stored, i8** @g)
+%derived escapes (passed: call void @use(i8* %sel))
+%site does not escape
+%fake escapes (passed: call void @llvm.memset.p0i8.i64(i8* %fake))
+%callee does not escape
+%thrown escapes (passed: call void @peek.unwind(i8* %thrown))
+%int escapes (ptrtoint: %i = ptrtoint i8* %int to i64)
+%heap does not escape
+%ret escapes (returned: ret i8* %alias)
+`
+	if got := info.String(); got != want {
+		t.Errorf("escape mismatch; expected\n%s\ngot\n%s", want, got)
+	}
+	if got, want := len(info.NonEscaping()), 4; got != want {
+		t.Errorf("number of non-escaping allocations mismatch; expected %d, got %d", want, got)
+	}
+	// Parameters may be queried as well.
+	for _, param := range m.Funcs[len(m.Funcs)-1].Params {
+		if got := info.Escape(param); got != nil {
+			t.Errorf("escape mismatch of %s; expected nil, got %v", param.Ident(), got)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+// Package liveness implements liveness analysis of the SSA values of LLVM IR
+// functions, and register pressure estimation based on liveness.
+//
+// An SSA value (a function parameter, or the result of an instruction or
+// terminator) is live at a program point if it may be used along some path from
+// the program point without an intervening definition. Liveness is computed as
+// a backward data-flow problem (see package dataflow), in which the uses of phi
+// instructions are attributed to the control flow edges from their incoming
+// basic blocks; an incoming value of a phi instruction is thus live-out of the
+// corresponding predecessor only, and not live-in of the basic block of the phi
+// instruction. The results of phi instructions are defined at the start of
+// their basic block, and are thus not live-in either.
+//
+// Uses in metadata arguments (e.g. of @llvm.dbg.value) do not keep values live.
+package liveness
+
+import (
+	"sort"
+
+	"github.com/llir/llvm/analysis/dataflow"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/llir/llvm/irutil"
+)
+
+// Info is the liveness information of a function.
+type Info struct {
+	// Function of the liveness information.
+	f *ir.Func
+	// Data-flow results of liveness; facts are of type valueSet.
+	result *dataflow.Result
+	// Index of each SSA value of the function, in order of definition.
+	index map[value.Value]int
+	// Basic block of each instruction and terminator.
+	blockOf map[value.Value]*ir.Block
+}
+
+// NewInfo computes the liveness information of the given function.
+func NewInfo(f *ir.Func) *Info {
+	info := &Info{
+		f:       f,
+		index:   make(map[value.Value]int),
+		blockOf: make(map[value.Value]*ir.Block),
+	}
+	for _, param := range f.Params {
+		info.index[param] = len(info.index)
+	}
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			if v, ok := inst.(value.Value); ok && isSSA(v) {
+				info.index[v] = len(info.index)
+				info.blockOf[v] = block
+			}
+		}
+		if v, ok := block.Term.(value.Value); ok && isSSA(v) {
+			info.index[v] = len(info.index)
+			info.blockOf[v] = block
+		}
+	}
+	p := &dataflow.Problem{
+		Direction: dataflow.Backward,
+		Lattice:   lattice{},
+		Inst: func(inst ir.Instruction, fact dataflow.Fact) dataflow.Fact {
+			live := fact.(valueSet).clone()
+			if v, ok := inst.(value.Value); ok {
+				delete(live, v)
+			}
+			// Uses of phi instructions are attributed to incoming edges.
+			if _, ok := inst.(*ir.InstPhi); ok {
+				return live
+			}
+			if user, ok := inst.(value.User); ok {
+				info.addUses(live, user)
+			}
+			return live
+		},
+		Term: func(term ir.Terminator, fact dataflow.Fact) dataflow.Fact {
+			live := fact.(valueSet).clone()
+			if v, ok := term.(value.Value); ok {
+				delete(live, v)
+			}
+			info.addUses(live, term)
+			return live
+		},
+		Edge: func(e dataflow.Edge, fact dataflow.Fact) dataflow.Fact {
+			phis := irutil.Phis(e.To)
+			if len(phis) == 0 {
+				return fact
+			}
+			live := fact.(valueSet).clone()
+			for _, phi := range phis {
+				for _, inc := range phi.Incs {
+					if inc.Pred == e.From {
+						info.addUse(live, inc.X)
+					}
+				}
+			}
+			return live
+		},
+	}
+	info.result = dataflow.Solve(f, p)
+	return info
+}
+
+// LiveIn returns the SSA values live at the start of the given basic block, in
+// order of definition.
+func (info *Info) LiveIn(block *ir.Block) []value.Value {
+	return info.values(info.result.In[block])
+}
+
+// LiveOut returns the SSA values live at the end of the given basic block, in
+// order of definition. The live-out values include the incoming values of phi
+// instructions of successors along the edges from block, and the result of an
+// invoke or callbr terminator of block if used by a successor.
+func (info *Info) LiveOut(block *ir.Block) []value.Value {
+	return info.values(info.result.Out[block])
+}
+
+// IsLiveIn reports whether the given SSA value is live at the start of block.
+func (info *Info) IsLiveIn(v value.Value, block *ir.Block) bool {
+	return asSet(info.result.In[block])[v]
+}
+
+// IsLiveOut reports whether the given SSA value is live at the end of block.
+func (info *Info) IsLiveOut(v value.Value, block *ir.Block) bool {
+	return asSet(info.result.Out[block])[v]
+}
+
+// LiveBefore returns the SSA values live immediately before the given
+// instruction of block, in order of definition.
+func (info *Info) LiveBefore(block *ir.Block, inst ir.Instruction) []value.Value {
+	return info.values(info.result.Before(block, inst))
+}
+
+// LiveAfter returns the SSA values live immediately after the given
+// instruction of block, in order of definition.
+func (info *Info) LiveAfter(block *ir.Block, inst ir.Instruction) []value.Value {
+	return info.values(info.result.After(block, inst))
+}
+
+// --- [ Live ranges ] ---------------------------------------------------------
+
+// Range is the live range of an SSA value.
+type Range struct {
+	// SSA value of the live range.
+	Value value.Value
+	// Segments of the live range, one per basic block in which the value is
+	// live, in order of the basic blocks of the function.
+	Segments []Segment
+}
+
+// Segment is the part of a live range within a basic block. Program points of
+// the basic block are identified by instruction index, where the terminator
+// has index len(Block.Insts).
+type Segment struct {
+	// Basic block of the segment.
+	Block *ir.Block
+	// Index of the defining instruction of the value; or -1 if the value is
+	// live-in of the basic block.
+	Start int
+	// Index of the last use of the value in the basic block (or of the
+	// definition if unused); or len(Block.Insts)+1 if the value is live-out of
+	// the basic block.
+	End int
+}
+
+// LiveIn reports whether the value is live at the start of the basic block of
+// the segment.
+func (seg Segment) LiveIn() bool {
+	return seg.Start == -1
+}
+
+// LiveOut reports whether the value is live at the end of the basic block of
+// the segment.
+func (seg Segment) LiveOut() bool {
+	return seg.End == len(seg.Block.Insts)+1
+}
+
+// Range returns the live range of the given SSA value of the function; or nil
+// if v is not an SSA value of the function.
+func (info *Info) Range(v value.Value) *Range {
+	if _, ok := info.index[v]; !ok {
+		return nil
+	}
+	r := &Range{Value: v}
+	for _, block := range info.f.Blocks {
+		start := -1
+		if !info.IsLiveIn(v, block) {
+			if info.blockOf[v] != block {
+				continue
+			}
+			start = defIndex(block, v)
+		}
+		end := start
+		if info.IsLiveOut(v, block) {
+			end = len(block.Insts) + 1
+		} else {
+			for i, inst := range block.Insts {
+				if _, ok := inst.(*ir.InstPhi); ok {
+					continue
+				}
+				if user, ok := inst.(value.User); ok && uses(user, v) {
+					end = i
+				}
+			}
+			if uses(block.Term, v) {
+				end = len(block.Insts)
+			}
+		}
+		r.Segments = append(r.Segments, Segment{Block: block, Start: start, End: end})
+	}
+	return r
+}
+
+// --- [ Register pressure ] ---------------------------------------------------
+
+// MaxPressure returns the maximum number of simultaneously live SSA values in
+// the given basic block. The result of an instruction is counted as live at the
+// instruction, even if unused.
+func (info *Info) MaxPressure(block *ir.Block) int {
+	max := len(asSet(info.result.In[block]))
+	for _, inst := range block.Insts {
+		after := asSet(info.result.After(block, inst))
+		n := len(after)
+		if v, ok := inst.(value.Value); ok && isSSA(v) && !after[v] {
+			// Unused result.
+			n++
+		}
+		if n > max {
+			max = n
+		}
+	}
+	// Live values at the terminator, and its result.
+	n := len(asSet(info.result.BeforeTerm(block)))
+	if v, ok := block.Term.(value.Value); ok && isSSA(v) {
+		n++
+	}
+	if n > max {
+		max = n
+	}
+	if n := len(asSet(info.result.Out[block])); n > max {
+		max = n
+	}
+	return max
+}
+
+// ### [ Helper functions ] ####################################################
+
+// valueSet is a set of SSA values.
+type valueSet map[value.Value]bool
+
+// clone returns a copy of the set.
+func (s valueSet) clone() valueSet {
+	c := make(valueSet, len(s))
+	for v := range s {
+		c[v] = true
+	}
+	return c
+}
+
+// lattice is the lattice of sets of SSA values ordered by inclusion.
+type lattice struct{}
+
+// Bottom returns the empty set.
+func (lattice) Bottom() dataflow.Fact {
+	return valueSet{}
+}
+
+// Join returns the union of x and y.
+func (lattice) Join(x, y dataflow.Fact) dataflow.Fact {
+	xs, ys := x.(valueSet), y.(valueSet)
+	if len(ys) == 0 {
+		return xs
+	}
+	if len(xs) == 0 {
+		return ys
+	}
+	s := xs.clone()
+	for v := range ys {
+		s[v] = true
+	}
+	return s
+}
+
+// Equal reports whether x and y contain the same SSA values.
+func (lattice) Equal(x, y dataflow.Fact) bool {
+	xs, ys := x.(valueSet), y.(valueSet)
+	if len(xs) != len(ys) {
+		return false
+	}
+	for v := range xs {
+		if !ys[v] {
+			return false
+		}
+	}
+	return true
+}
+
+// addUses adds the SSA values used by the operands of the given instruction or
+// terminator to live.
+func (info *Info) addUses(live valueSet, user value.User) {
+	for _, op := range user.Operands() {
+		info.addUse(live, *op)
+	}
+}
+
+// addUse adds v to live if it is an SSA value of the function.
+func (info *Info) addUse(live valueSet, v value.Value) {
+	v = irutil.Unwrap(v)
+	if _, ok := info.index[v]; ok {
+		live[v] = true
+	}
+}
+
+// asSet returns the set of SSA values of the given data-flow fact.
+func asSet(fact dataflow.Fact) valueSet {
+	s, _ := fact.(valueSet)
+	return s
+}
+
+// values returns the SSA values of the given data-flow fact, in order of
+// definition.
+func (info *Info) values(fact dataflow.Fact) []value.Value {
+	var vs []value.Value
+	for v := range asSet(fact) {
+		vs = append(vs, v)
+	}
+	sort.Slice(vs, func(i, j int) bool {
+		return info.index[vs[i]] < info.index[vs[j]]
+	})
+	return vs
+}
+
+// isSSA reports whether the given value (an instruction or terminator) is an
+// SSA value; i.e. whether it has a non-void result.
+func isSSA(v value.Value) bool {
+	return !v.Type().Equal(types.Void)
+}
+
+// defIndex returns the index of the instruction or terminator defining v in
+// the given basic block, where the terminator has index len(block.Insts).
+func defIndex(block *ir.Block, v value.Value) int {
+	for i, inst := range block.Insts {
+		if iv, ok := inst.(value.Value); ok && iv == v {
+			return i
+		}
+	}
+	return len(block.Insts)
+}
+
+// uses reports whether the given instruction or terminator uses v.
+func uses(user value.User, v value.Value) bool {
+	for _, op := range user.Operands() {
+		if irutil.Unwrap(*op) == v {
+			return true
+		}
+	}
+	return false
+}
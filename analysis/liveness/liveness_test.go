@@ -0,0 +1,81 @@
+package liveness
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir/value"
+)
+
+func TestNewInfo(t *testing.T) {
+	const src = `
+define i32 @f(i32 %n, i32 %k) {
+entry:
+	%a = add i32 %n, 1
+	br label %loop
+
+loop:
+	%i = phi i32 [ 0, %entry ], [ %i.next, %body ]
+	%sum = phi i32 [ %a, %entry ], [ %sum.next, %body ]
+	%cond = icmp slt i32 %i, %n
+	br i1 %cond, label %body, label %exit
+
+body:
+	%t = mul i32 %i, %k
+	%sum.next = add i32 %sum, %t
+	%i.next = add i32 %i, 1
+	br label %loop
+
+exit:
+	ret i32 %sum
+}`
+	m, err := asm.ParseString("liveness.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	f := m.Funcs[0]
+	info := NewInfo(f)
+	golden := []struct {
+		block    string
+		in, out  []string
+		pressure int
+	}{
+		// %a is live-out of entry only, as the incoming value of %sum.
+		{block: "entry", in: []string{"n", "k"}, out: []string{"n", "k", "a"}, pressure: 3},
+		{block: "loop", in: []string{"n", "k"}, out: []string{"n", "k", "i", "sum"}, pressure: 5},
+		{block: "body", in: []string{"n", "k", "i", "sum"}, out: []string{"n", "k", "sum.next", "i.next"}, pressure: 5},
+		{block: "exit", in: []string{"sum"}, out: nil, pressure: 1},
+	}
+	for i, g := range golden {
+		block := f.Blocks[i]
+		if got := names(info.LiveIn(block)); !reflect.DeepEqual(got, g.in) {
+			t.Errorf("live-in mismatch of block %q; expected %v, got %v", g.block, g.in, got)
+		}
+		if got := names(info.LiveOut(block)); !reflect.DeepEqual(got, g.out) {
+			t.Errorf("live-out mismatch of block %q; expected %v, got %v", g.block, g.out, got)
+		}
+		if got := info.MaxPressure(block); got != g.pressure {
+			t.Errorf("register pressure mismatch of block %q; expected %d, got %d", g.block, g.pressure, got)
+		}
+	}
+	// Live range of %sum; defined in loop, live-out of loop (to body), last
+	// used by the add of body and the ret of exit.
+	sum := f.Blocks[1].Insts[1].(value.Value)
+	want := []Segment{
+		{Block: f.Blocks[1], Start: 1, End: 4},
+		{Block: f.Blocks[2], Start: -1, End: 1},
+		{Block: f.Blocks[3], Start: -1, End: 0},
+	}
+	if got := info.Range(sum).Segments; !reflect.DeepEqual(got, want) {
+		t.Errorf("live range mismatch of %%sum; expected %v, got %v", want, got)
+	}
+}
+
+func names(vs []value.Value) []string {
+	var ns []string
+	for _, v := range vs {
+		ns = append(ns, v.(value.Named).Name())
+	}
+	return ns
+}
@@ -0,0 +1,224 @@
+// Package loop implements natural loop analysis of LLVM IR functions.
+//
+// A natural loop is identified by a back edge from a basic block (the latch) to
+// a basic block which dominates it (the header). The body of the loop consists
+// of the basic blocks which may reach a latch without passing through the
+// header. Loops sharing a header are merged into one loop, and loops are nested
+// according to the containment of their bodies.
+package loop
+
+import (
+	"sort"
+
+	"github.com/llir/llvm/analysis/cfg"
+	"github.com/llir/llvm/ir"
+)
+
+// === [ Loops ] ===============================================================
+
+// Loop is a natural loop of a function.
+type Loop struct {
+	// Header of the loop; dominates every basic block of the loop.
+	Header *ir.Block
+	// Basic blocks of the loop (including the basic blocks of nested loops), in
+	// reverse post-order. The header is the first basic block of the loop.
+	Blocks []*ir.Block
+	// Parent loop; or nil if top-level loop.
+	Parent *Loop
+	// Nested loops, in reverse post-order of their headers.
+	Children []*Loop
+
+	// Set of basic blocks of the loop.
+	contains map[*ir.Block]bool
+	// Predecessors of each basic block of the function.
+	preds map[*ir.Block][]*ir.Block
+}
+
+// Contains reports whether the given basic block is part of the loop (or any
+// of its nested loops).
+func (l *Loop) Contains(block *ir.Block) bool {
+	return l.contains[block]
+}
+
+// Depth returns the nesting depth of the loop. Top-level loops have depth 1.
+func (l *Loop) Depth() int {
+	depth := 0
+	for ; l != nil; l = l.Parent {
+		depth++
+	}
+	return depth
+}
+
+// Latches returns the basic blocks of the loop with a back edge to the header.
+func (l *Loop) Latches() []*ir.Block {
+	var latches []*ir.Block
+	for _, pred := range l.preds[l.Header] {
+		if l.Contains(pred) {
+			latches = append(latches, pred)
+		}
+	}
+	return latches
+}
+
+// Preheader returns the preheader of the loop; i.e. the single predecessor of
+// the header outside of the loop, which has the header as its only successor.
+// A nil basic block is returned if the loop has no preheader.
+func (l *Loop) Preheader() *ir.Block {
+	var preheader *ir.Block
+	for _, pred := range l.preds[l.Header] {
+		if l.Contains(pred) {
+			continue
+		}
+		if preheader != nil {
+			// Multiple entering basic blocks.
+			return nil
+		}
+		preheader = pred
+	}
+	if preheader == nil || len(cfg.Succs(preheader)) != 1 {
+		return nil
+	}
+	return preheader
+}
+
+// ExitingBlocks returns the basic blocks of the loop with a successor outside of
+// the loop.
+func (l *Loop) ExitingBlocks() []*ir.Block {
+	var exiting []*ir.Block
+	for _, block := range l.Blocks {
+		for _, succ := range cfg.Succs(block) {
+			if !l.Contains(succ) {
+				exiting = append(exiting, block)
+				break
+			}
+		}
+	}
+	return exiting
+}
+
+// ExitBlocks returns the basic blocks outside of the loop with a predecessor in
+// the loop.
+func (l *Loop) ExitBlocks() []*ir.Block {
+	var exits []*ir.Block
+	seen := make(map[*ir.Block]bool)
+	for _, block := range l.Blocks {
+		for _, succ := range cfg.Succs(block) {
+			if !l.Contains(succ) && !seen[succ] {
+				seen[succ] = true
+				exits = append(exits, succ)
+			}
+		}
+	}
+	return exits
+}
+
+// === [ Loop info ] ===========================================================
+
+// Info is the loop nesting forest of a function.
+type Info struct {
+	// Top-level loops, in reverse post-order of their headers.
+	Loops []*Loop
+
+	// Innermost loop of each basic block.
+	loopOf map[*ir.Block]*Loop
+}
+
+// NewInfo returns the loop nesting forest of the given function, based on its
+// dominator tree.
+func NewInfo(f *ir.Func, dt *cfg.DomTree) *Info {
+	info := &Info{loopOf: make(map[*ir.Block]*Loop)}
+	preds := cfg.Preds(f)
+	rpo := cfg.ReversePostOrder(f)
+	index := make(map[*ir.Block]int, len(rpo))
+	for i, block := range rpo {
+		index[block] = i
+	}
+	// Discover loops in post-order, so that nested loops are discovered before
+	// their parent loops.
+	var loops []*Loop
+	for i := len(rpo) - 1; i >= 0; i-- {
+		header := rpo[i]
+		var work []*ir.Block
+		for _, pred := range preds[header] {
+			if dt.Dominates(header, pred) {
+				work = append(work, pred)
+			}
+		}
+		if len(work) == 0 {
+			continue
+		}
+		l := &Loop{Header: header, preds: preds, contains: map[*ir.Block]bool{header: true}}
+		info.loopOf[header] = l
+		for len(work) > 0 {
+			block := work[len(work)-1]
+			work = work[:len(work)-1]
+			if !dt.IsReachable(block) || l.contains[block] {
+				continue
+			}
+			if inner := info.loopOf[block]; inner != nil {
+				// Add outermost discovered loop containing block as nested loop.
+				for inner.Parent != nil {
+					inner = inner.Parent
+				}
+				if inner == l {
+					continue
+				}
+				inner.Parent = l
+				l.Children = append(l.Children, inner)
+				for b := range inner.contains {
+					l.contains[b] = true
+				}
+				work = append(work, preds[inner.Header]...)
+				continue
+			}
+			info.loopOf[block] = l
+			l.contains[block] = true
+			work = append(work, preds[block]...)
+		}
+		loops = append(loops, l)
+	}
+	byRPO := func(blocks []*ir.Block) {
+		sort.Slice(blocks, func(i, j int) bool {
+			return index[blocks[i]] < index[blocks[j]]
+		})
+	}
+	for _, l := range loops {
+		for block := range l.contains {
+			l.Blocks = append(l.Blocks, block)
+		}
+		byRPO(l.Blocks)
+		sort.Slice(l.Children, func(i, j int) bool {
+			return index[l.Children[i].Header] < index[l.Children[j].Header]
+		})
+		if l.Parent == nil {
+			info.Loops = append(info.Loops, l)
+		}
+	}
+	sort.Slice(info.Loops, func(i, j int) bool {
+		return index[info.Loops[i].Header] < index[info.Loops[j].Header]
+	})
+	return info
+}
+
+// LoopOf returns the innermost loop containing the given basic block, or nil if
+// the basic block is not part of any loop.
+func (info *Info) LoopOf(block *ir.Block) *Loop {
+	return info.loopOf[block]
+}
+
+// PostOrder returns the loops of the function in post-order of the loop
+// nesting forest; i.e. nested loops are listed before their parent loops.
+func (info *Info) PostOrder() []*Loop {
+	var order []*Loop
+	var visit func(l *Loop)
+	visit = func(l *Loop) {
+		for _, child := range l.Children {
+			visit(child)
+		}
+		order = append(order, l)
+	}
+	for _, l := range info.Loops {
+		visit(l)
+	}
+	return order
+}
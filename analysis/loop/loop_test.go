@@ -0,0 +1,79 @@
+package loop
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/llir/llvm/analysis/cfg"
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+)
+
+func TestNewInfo(t *testing.T) {
+	const src = `
+define void @f(i1 %c) {
+entry:
+	br label %outer
+
+outer:
+	br label %inner
+
+inner:
+	br i1 %c, label %inner, label %latch
+
+latch:
+	br i1 %c, label %outer, label %exit
+
+exit:
+	ret void
+}`
+	m, err := asm.ParseString("loop.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	f := m.Funcs[0]
+	info := NewInfo(f, cfg.NewDomTree(f))
+	names := func(blocks []*ir.Block) []string {
+		var ns []string
+		for _, block := range blocks {
+			ns = append(ns, block.Name())
+		}
+		return ns
+	}
+	golden := []struct {
+		header    string
+		depth     int
+		blocks    []string
+		preheader string
+		exits     []string
+	}{
+		{header: "inner", depth: 2, blocks: []string{"inner"}, preheader: "outer", exits: []string{"latch"}},
+		{header: "outer", depth: 1, blocks: []string{"outer", "inner", "latch"}, preheader: "entry", exits: []string{"exit"}},
+	}
+	loops := info.PostOrder()
+	if len(loops) != len(golden) {
+		t.Fatalf("number of loops mismatch; expected %d, got %d", len(golden), len(loops))
+	}
+	for i, g := range golden {
+		l := loops[i]
+		if got := l.Header.Name(); got != g.header {
+			t.Errorf("header mismatch of loop %d; expected %q, got %q", i, g.header, got)
+			continue
+		}
+		if got := l.Depth(); got != g.depth {
+			t.Errorf("depth mismatch of loop %q; expected %d, got %d", g.header, g.depth, got)
+		}
+		if got := names(l.Blocks); !reflect.DeepEqual(got, g.blocks) {
+			t.Errorf("blocks mismatch of loop %q; expected %q, got %q", g.header, g.blocks, got)
+		}
+		if got := l.Preheader(); got == nil || got.Name() != g.preheader {
+			t.Errorf("preheader mismatch of loop %q; expected %q, got %v", g.header, g.preheader, got)
+		}
+		if got := names(l.ExitBlocks()); !reflect.DeepEqual(got, g.exits) {
+			t.Errorf("exit blocks mismatch of loop %q; expected %q, got %q", g.header, g.exits, got)
+		}
+	}
+	if got := info.LoopOf(f.Blocks[2]); got != loops[0] {
+		t.Errorf("innermost loop mismatch of %q", f.Blocks[2].Name())
+	}
+}
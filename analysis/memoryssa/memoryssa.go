@@ -0,0 +1,424 @@
+// Package memoryssa implements Memory SSA of LLVM IR functions.
+//
+// Memory SSA is a factored representation of the dependencies between memory
+// accesses, in which memory as a whole is treated as a single SSA variable.
+// Each instruction which may write memory (stores, calls, atomic and volatile
+// accesses, fences, etc) is a MemoryDef, defining a new version of memory, and
+// each instruction which may only read memory (e.g. loads) is a MemoryUse of
+// the current version of memory. Versions of memory are merged by MemoryPhis at
+// the join points of the control flow graph, which are placed at the iterated
+// dominance frontier of the basic blocks containing MemoryDefs. The version of
+// memory on entry of the function is the LiveOnEntry MemoryDef.
+//
+// Each MemoryUse and MemoryDef refers to its defining access; i.e. the nearest
+// dominating MemoryDef or MemoryPhi. As the defining access may not alias the
+// accessed memory location, the clobbering access of a memory access is
+// queried using a Walker based on an alias analysis, which walks the defining
+// accesses upwards, skipping accesses that do not modify the memory location.
+package memoryssa
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/llir/llvm/analysis/cfg"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/irutil"
+)
+
+// === [ Memory accesses ] =====================================================
+
+// Access is a memory access of Memory SSA.
+//
+// An Access has one of the following underlying types.
+//
+//	*memoryssa.MemoryDef
+//	*memoryssa.MemoryUse
+//	*memoryssa.MemoryPhi
+type Access interface {
+	fmt.Stringer
+	// Block returns the basic block of the memory access; or nil for the
+	// LiveOnEntry MemoryDef.
+	Block() *ir.Block
+	// isAccess ensures that only memory accesses can be assigned to the
+	// memoryssa.Access interface.
+	isAccess()
+}
+
+// MemoryDef is a memory access which may write memory, and thus defines a new
+// version of memory.
+type MemoryDef struct {
+	// Instruction of the memory access; or nil for the LiveOnEntry MemoryDef and
+	// terminators.
+	Inst ir.Instruction
+	// Terminator of the memory access (invoke or callbr); or nil.
+	Term ir.Terminator
+	// Defining access; i.e. the previous version of memory, or nil for the
+	// LiveOnEntry MemoryDef.
+	Defining Access
+	// ID of the memory access, unique within the function; 0 for the
+	// LiveOnEntry MemoryDef.
+	ID int
+
+	// Basic block of the memory access.
+	block *ir.Block
+}
+
+// String returns the string representation of the memory access.
+func (a *MemoryDef) String() string {
+	if a.Defining == nil {
+		return "liveOnEntry"
+	}
+	return fmt.Sprintf("%d = MemoryDef(%s)", a.ID, ref(a.Defining))
+}
+
+// Block returns the basic block of the memory access; or nil for the
+// LiveOnEntry MemoryDef.
+func (a *MemoryDef) Block() *ir.Block {
+	return a.block
+}
+
+// MemoryUse is a memory access which may read but not write memory.
+type MemoryUse struct {
+	// Instruction of the memory access.
+	Inst ir.Instruction
+	// Defining access; i.e. the version of memory read.
+	Defining Access
+
+	// Basic block of the memory access.
+	block *ir.Block
+}
+
+// String returns the string representation of the memory access.
+func (a *MemoryUse) String() string {
+	return fmt.Sprintf("MemoryUse(%s)", ref(a.Defining))
+}
+
+// Block returns the basic block of the memory access.
+func (a *MemoryUse) Block() *ir.Block {
+	return a.block
+}
+
+// MemoryPhi merges the versions of memory of the predecessors of a basic block.
+type MemoryPhi struct {
+	// Incoming versions of memory, in order of predecessors.
+	Incs []*Incoming
+	// ID of the memory access, unique within the function.
+	ID int
+
+	// Basic block of the memory access.
+	block *ir.Block
+}
+
+// Incoming is an incoming version of memory of a MemoryPhi.
+type Incoming struct {
+	// Incoming version of memory.
+	Access Access
+	// Predecessor basic block of the incoming version of memory.
+	Pred *ir.Block
+}
+
+// String returns the string representation of the memory access.
+func (a *MemoryPhi) String() string {
+	incs := make([]string, len(a.Incs))
+	for i, inc := range a.Incs {
+		incs[i] = fmt.Sprintf("{%s,%s}", inc.Pred.Name(), ref(inc.Access))
+	}
+	return fmt.Sprintf("%d = MemoryPhi(%s)", a.ID, strings.Join(incs, ","))
+}
+
+// Block returns the basic block of the memory access.
+func (a *MemoryPhi) Block() *ir.Block {
+	return a.block
+}
+
+// isAccess ensures that only memory accesses can be assigned to the
+// memoryssa.Access interface.
+func (*MemoryDef) isAccess() {}
+func (*MemoryUse) isAccess() {}
+func (*MemoryPhi) isAccess() {}
+
+// Defining returns the defining access of the given MemoryUse or MemoryDef; or
+// nil for the LiveOnEntry MemoryDef and MemoryPhis.
+func Defining(a Access) Access {
+	switch a := a.(type) {
+	case *MemoryDef:
+		return a.Defining
+	case *MemoryUse:
+		return a.Defining
+	}
+	return nil
+}
+
+// === [ Memory SSA ] ==========================================================
+
+// SSA is the Memory SSA of a function. Only the instructions of basic blocks
+// reachable from the entry basic block are given memory accesses.
+type SSA struct {
+	// Version of memory on entry of the function.
+	LiveOnEntry *MemoryDef
+
+	// Function of the Memory SSA.
+	f *ir.Func
+	// Memory access of each instruction.
+	accesses map[ir.Instruction]Access
+	// MemoryDef of each basic block with a terminator accessing memory.
+	termDefs map[*ir.Block]*MemoryDef
+	// MemoryPhi of each basic block.
+	phis map[*ir.Block]*MemoryPhi
+	// Memory accesses of each basic block, in order; starting with the
+	// MemoryPhi if present.
+	blockAccesses map[*ir.Block][]Access
+	// Users of each memory access.
+	users map[Access][]Access
+}
+
+// New returns the Memory SSA of the given function.
+func New(f *ir.Func) *SSA {
+	s := &SSA{
+		LiveOnEntry:   &MemoryDef{},
+		f:             f,
+		accesses:      make(map[ir.Instruction]Access),
+		termDefs:      make(map[*ir.Block]*MemoryDef),
+		phis:          make(map[*ir.Block]*MemoryPhi),
+		blockAccesses: make(map[*ir.Block][]Access),
+		users:         make(map[Access][]Access),
+	}
+	if len(f.Blocks) == 0 {
+		return s
+	}
+	dt := cfg.NewDomTree(f)
+	preds := cfg.Preds(f)
+	order := dt.PreOrder()
+	// Place MemoryPhis at the iterated dominance frontier of the basic blocks
+	// containing MemoryDefs.
+	df := frontiers(order, preds, dt)
+	hasPhi := make(map[*ir.Block]bool)
+	var work []*ir.Block
+	for _, block := range order {
+		if hasDef(block) {
+			work = append(work, block)
+		}
+	}
+	for len(work) > 0 {
+		block := work[len(work)-1]
+		work = work[:len(work)-1]
+		for _, join := range df[block] {
+			if !hasPhi[join] {
+				hasPhi[join] = true
+				work = append(work, join)
+			}
+		}
+	}
+	// Create memory accesses in dominator tree pre-order, for deterministic IDs.
+	id := 0
+	for _, block := range order {
+		if hasPhi[block] {
+			id++
+			phi := &MemoryPhi{ID: id, block: block}
+			s.phis[block] = phi
+			s.blockAccesses[block] = append(s.blockAccesses[block], phi)
+		}
+		for _, inst := range block.Insts {
+			isDef, isUse := classify(inst)
+			var access Access
+			switch {
+			case isDef:
+				id++
+				access = &MemoryDef{Inst: inst, ID: id, block: block}
+			case isUse:
+				access = &MemoryUse{Inst: inst, block: block}
+			default:
+				continue
+			}
+			s.accesses[inst] = access
+			s.blockAccesses[block] = append(s.blockAccesses[block], access)
+		}
+		switch block.Term.(type) {
+		case *ir.TermInvoke, *ir.TermCallBr:
+			id++
+			def := &MemoryDef{Term: block.Term, ID: id, block: block}
+			s.termDefs[block] = def
+			s.blockAccesses[block] = append(s.blockAccesses[block], def)
+		}
+	}
+	// Rename versions of memory by a walk of the dominator tree.
+	incs := make(map[*MemoryPhi]map[*ir.Block]Access)
+	var rename func(block *ir.Block, cur Access)
+	rename = func(block *ir.Block, cur Access) {
+		for _, access := range s.blockAccesses[block] {
+			switch access := access.(type) {
+			case *MemoryPhi:
+				cur = access
+			case *MemoryUse:
+				access.Defining = cur
+				s.addUser(cur, access)
+			case *MemoryDef:
+				access.Defining = cur
+				s.addUser(cur, access)
+				cur = access
+			}
+		}
+		for _, succ := range cfg.Succs(block) {
+			if phi, ok := s.phis[succ]; ok {
+				if incs[phi] == nil {
+					incs[phi] = make(map[*ir.Block]Access)
+				}
+				incs[phi][block] = cur
+			}
+		}
+		for _, child := range dt.Children(block) {
+			rename(child, cur)
+		}
+	}
+	rename(order[0], s.LiveOnEntry)
+	for _, block := range order {
+		phi, ok := s.phis[block]
+		if !ok {
+			continue
+		}
+		for _, pred := range preds[block] {
+			if access, ok := incs[phi][pred]; ok {
+				phi.Incs = append(phi.Incs, &Incoming{Access: access, Pred: pred})
+				s.addUser(access, phi)
+			}
+		}
+	}
+	return s
+}
+
+// Access returns the memory access of the given instruction; or nil if the
+// instruction does not access memory.
+func (s *SSA) Access(inst ir.Instruction) Access {
+	return s.accesses[inst]
+}
+
+// TermDef returns the MemoryDef of the terminator (invoke or callbr) of the
+// given basic block; or nil if the terminator does not access memory.
+func (s *SSA) TermDef(block *ir.Block) *MemoryDef {
+	return s.termDefs[block]
+}
+
+// Phi returns the MemoryPhi of the given basic block; or nil if not present.
+func (s *SSA) Phi(block *ir.Block) *MemoryPhi {
+	return s.phis[block]
+}
+
+// BlockAccesses returns the memory accesses of the given basic block, in
+// order; starting with the MemoryPhi of the basic block if present.
+func (s *SSA) BlockAccesses(block *ir.Block) []Access {
+	return s.blockAccesses[block]
+}
+
+// Users returns the memory accesses using the version of memory defined by the
+// given MemoryDef or MemoryPhi; i.e. the MemoryUses and MemoryDefs with a as
+// defining access, and the MemoryPhis with a as incoming version of memory.
+func (s *SSA) Users(a Access) []Access {
+	return s.users[a]
+}
+
+// String returns the string representation of the Memory SSA, as the function
+// annotated with the memory accesses of its instructions.
+func (s *SSA) String() string {
+	buf := &strings.Builder{}
+	for _, block := range s.f.Blocks {
+		fmt.Fprintf(buf, "%s:\n", block.Name())
+		if phi, ok := s.phis[block]; ok {
+			fmt.Fprintf(buf, "; %s\n", phi)
+		}
+		for _, inst := range block.Insts {
+			if access, ok := s.accesses[inst]; ok {
+				fmt.Fprintf(buf, "; %s\n", access)
+			}
+			fmt.Fprintf(buf, "\t%s\n", inst.LLString())
+		}
+		if def, ok := s.termDefs[block]; ok {
+			fmt.Fprintf(buf, "; %s\n", def)
+		}
+		fmt.Fprintf(buf, "\t%s\n", block.Term.LLString())
+	}
+	return buf.String()
+}
+
+// addUser records user as a user of the memory access a.
+func (s *SSA) addUser(a, user Access) {
+	s.users[a] = append(s.users[a], user)
+}
+
+// ### [ Helper functions ] ####################################################
+
+// classify reports whether the given instruction is a MemoryDef or a
+// MemoryUse.
+func classify(inst ir.Instruction) (isDef, isUse bool) {
+	if call, ok := inst.(*ir.InstCall); ok && irutil.IsDebugIntrinsic(call) {
+		return false, false
+	}
+	if irutil.MayWriteMemory(inst) {
+		return true, false
+	}
+	return false, irutil.MayReadMemory(inst)
+}
+
+// hasDef reports whether the given basic block contains a MemoryDef.
+func hasDef(block *ir.Block) bool {
+	for _, inst := range block.Insts {
+		if isDef, _ := classify(inst); isDef {
+			return true
+		}
+	}
+	switch block.Term.(type) {
+	case *ir.TermInvoke, *ir.TermCallBr:
+		return true
+	}
+	return false
+}
+
+// frontiers returns the dominance frontier of each basic block of the given
+// dominator tree order.
+//
+// The dominance frontiers are computed using the algorithm of Cooper, Harvey
+// and Kennedy; "A Simple, Fast Dominance Algorithm".
+func frontiers(order []*ir.Block, preds map[*ir.Block][]*ir.Block, dt *cfg.DomTree) map[*ir.Block][]*ir.Block {
+	df := make(map[*ir.Block][]*ir.Block)
+	for _, block := range order {
+		if len(preds[block]) < 2 {
+			continue
+		}
+		for _, pred := range preds[block] {
+			if !dt.IsReachable(pred) {
+				continue
+			}
+			for runner := pred; runner != nil && runner != dt.IDom(block); runner = dt.IDom(runner) {
+				if !containsBlock(df[runner], block) {
+					df[runner] = append(df[runner], block)
+				}
+			}
+		}
+	}
+	return df
+}
+
+// containsBlock reports whether the given basic blocks contain block.
+func containsBlock(blocks []*ir.Block, block *ir.Block) bool {
+	for _, b := range blocks {
+		if b == block {
+			return true
+		}
+	}
+	return false
+}
+
+// ref returns the string representation of a reference to the given memory
+// access.
+func ref(a Access) string {
+	switch a := a.(type) {
+	case *MemoryDef:
+		if a.Defining == nil {
+			return "liveOnEntry"
+		}
+		return fmt.Sprint(a.ID)
+	case *MemoryPhi:
+		return fmt.Sprint(a.ID)
+	}
+	return "?"
+}
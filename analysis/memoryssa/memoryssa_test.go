@@ -0,0 +1,109 @@
+package memoryssa
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/analysis/alias"
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+)
+
+func TestNew(t *testing.T) {
+	const src = `
+declare void @g() readonly
+
+define i32 @f(i32* noalias %p, i32* noalias %q, i1 %c) {
+entry:
+	store i32 1, i32* %p
+	store i32 2, i32* %q
+	br i1 %c, label %then, label %join
+
+then:
+	store i32 3, i32* %q
+	br label %join
+
+join:
+	%v = load i32, i32* %p
+	%w = load i32, i32* %q
+	call void @g()
+	br label %loop
+
+loop:
+	%x = load i32, i32* %p
+	store i32 %x, i32* %q
+	br i1 %c, label %loop, label %exit
+
+exit:
+	ret i32 %x
+}`
+	m, err := asm.ParseString("memoryssa.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	f := m.Funcs[1]
+	s := New(f)
+	const want = `entry:
+; 1 = MemoryDef(liveOnEntry)
+	store i32 1, i32* %p
+; 2 = MemoryDef(1)
+	store i32 2, i32* %q
+	br i1 %c, label %then, label %join
+then:
+; 3 = MemoryDef(2)
+	store i32 3, i32* %q
+	br label %join
+join:
+; 4 = MemoryPhi({entry,2},{then,3})
+; MemoryUse(4)
+	%v = load i32, i32* %p
+; MemoryUse(4)
+	%w = load i32, i32* %q
+; MemoryUse(4)
+	call void @g()
+	br label %loop
+loop:
+; 5 = MemoryPhi({join,4},{loop,6})
+; MemoryUse(5)
+	%x = load i32, i32* %p
+; 6 = MemoryDef(5)
+	store i32 %x, i32* %q
+	br i1 %c, label %loop, label %exit
+exit:
+	ret i32 %x
+`
+	if got := s.String(); got != want {
+		t.Errorf("Memory SSA mismatch; expected\n%s\ngot\n%s", want, got)
+	}
+	// Users.
+	phi := s.Phi(f.Blocks[2])
+	if got, want := len(s.Users(phi)), 4; got != want {
+		t.Errorf("number of users mismatch of %v; expected %d, got %d", phi, want, got)
+	}
+	// Clobber queries.
+	w := NewWalker(s, alias.NewBasic(nil), nil)
+	insts := func(block int) []ir.Instruction {
+		return f.Blocks[block].Insts
+	}
+	golden := []struct {
+		inst ir.Instruction
+		want string
+	}{
+		// %v is clobbered by the store to %p, past the stores to %q.
+		{inst: insts(2)[0], want: "1 = MemoryDef(liveOnEntry)"},
+		// %w is clobbered by different stores along different paths.
+		{inst: insts(2)[1], want: "4 = MemoryPhi({entry,2},{then,3})"},
+		// %x is not clobbered in the loop.
+		{inst: insts(3)[0], want: "1 = MemoryDef(liveOnEntry)"},
+		// The store to %q in the loop is clobbered by itself, along the back edge.
+		{inst: insts(3)[1], want: "5 = MemoryPhi({join,4},{loop,6})"},
+	}
+	for _, g := range golden {
+		if got := w.Clobber(g.inst); got == nil || got.String() != g.want {
+			t.Errorf("clobber mismatch of %q; expected %q, got %v", g.inst.LLString(), g.want, got)
+		}
+	}
+	// Without alias analysis, the defining access is the clobber.
+	if got, want := NewWalker(s, nil, nil).Clobber(insts(2)[0]), s.Phi(f.Blocks[2]); got != want {
+		t.Errorf("clobber mismatch without alias analysis; expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,117 @@
+package memoryssa
+
+import (
+	"github.com/llir/llvm/analysis/alias"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/datalayout"
+)
+
+// maxSteps is the maximum number of MemoryDefs visited by a clobber query,
+// after which the query gives up and returns the nearest visited access.
+const maxSteps = 1000
+
+// === [ Clobber walker ] ======================================================
+
+// Walker answers clobber queries of Memory SSA, using an alias analysis to skip
+// MemoryDefs which do not modify the queried memory location.
+type Walker struct {
+	// Memory SSA of the function.
+	ssa *SSA
+	// Alias analysis used to determine whether MemoryDefs may modify memory
+	// locations; or nil to consider every MemoryDef a clobber.
+	aa alias.AliasAnalysis
+	// Data layout used to compute the memory locations of instructions.
+	dl *datalayout.Layout
+}
+
+// NewWalker returns a new clobber walker of the given Memory SSA, based on the
+// alias analysis aa and data layout dl. Every MemoryDef is considered to clobber
+// every memory location if aa is nil, and the default data layout is used if dl
+// is nil.
+func NewWalker(s *SSA, aa alias.AliasAnalysis, dl *datalayout.Layout) *Walker {
+	if dl == nil {
+		dl = datalayout.Default()
+	}
+	return &Walker{ssa: s, aa: aa, dl: dl}
+}
+
+// Clobber returns the clobbering access of the memory location accessed by the
+// given instruction; i.e. the nearest MemoryDef above the instruction which may
+// modify the memory location, LiveOnEntry if there is none, or a MemoryPhi if
+// different clobbering accesses reach the instruction along different paths.
+//
+// For instructions which do not access a single memory location (e.g. calls),
+// the defining access of the instruction is returned. Clobber returns nil if
+// the instruction does not access memory.
+func (w *Walker) Clobber(inst ir.Instruction) Access {
+	access := w.ssa.Access(inst)
+	if access == nil {
+		return nil
+	}
+	start := Defining(access)
+	loc, ok := alias.LocationOf(w.dl, inst)
+	if !ok {
+		return start
+	}
+	return w.ClobberOf(start, loc)
+}
+
+// ClobberOf returns the clobbering access of the memory location loc, starting
+// the walk at the memory access start (inclusive).
+func (w *Walker) ClobberOf(start Access, loc alias.MemoryLocation) Access {
+	steps := maxSteps
+	if clobber := w.walk(start, loc, make(map[*MemoryPhi]bool), &steps); clobber != nil {
+		return clobber
+	}
+	return start
+}
+
+// walk returns the clobbering access of the memory location loc, starting the
+// walk at the memory access a (inclusive). MemoryPhis of the active set are
+// currently being walked; walks reaching them return nil as they pass no
+// clobbering access.
+func (w *Walker) walk(a Access, loc alias.MemoryLocation, active map[*MemoryPhi]bool, steps *int) Access {
+	for {
+		switch access := a.(type) {
+		case *MemoryDef:
+			if access == w.ssa.LiveOnEntry || *steps <= 0 || w.clobbers(access, loc) {
+				return access
+			}
+			*steps--
+			a = access.Defining
+		case *MemoryPhi:
+			if active[access] {
+				return nil
+			}
+			active[access] = true
+			defer delete(active, access)
+			// The clobbering access of each incoming path; the MemoryPhi itself
+			// if they differ.
+			var clobber Access
+			for _, inc := range access.Incs {
+				c := w.walk(inc.Access, loc, active, steps)
+				switch {
+				case c == nil:
+					continue
+				case clobber == nil:
+					clobber = c
+				case clobber != c:
+					return access
+				}
+			}
+			return clobber
+		default:
+			// MemoryUses are not versions of memory.
+			return a
+		}
+	}
+}
+
+// clobbers reports whether the given MemoryDef may modify the memory location
+// loc.
+func (w *Walker) clobbers(def *MemoryDef, loc alias.MemoryLocation) bool {
+	if w.aa == nil || def.Inst == nil {
+		return true
+	}
+	return w.aa.ModRef(def.Inst, loc).IsMod()
+}
@@ -0,0 +1,753 @@
+// Package pointsto implements an inclusion-based (Andersen-style), field-
+// sensitive points-to analysis of whole LLVM IR modules.
+//
+// The analysis computes, for each pointer value of a module, the set of
+// abstract memory locations it may point to. An abstract memory location is a
+// byte offset into an abstract object, where abstract objects are the allocas,
+// global variables and functions of the module, and one heap object per call
+// site of a heap allocation function (e.g. malloc and calloc).
+//
+// The analysis generates inclusion constraints from the instructions of each
+// function definition, and solves them using a worklist algorithm:
+//
+//	p = &o            (alloca, global, heap allocation)   o ∈ pts(p)
+//	p = q             (casts, phi, select, arguments)      pts(q) ⊆ pts(p)
+//	p = gep q, off    (getelementptr)                      pts(q)+off ⊆ pts(p)
+//	p = *q            (load)                               pts(*l) ⊆ pts(p) for l ∈ pts(q)
+//	*p = q            (store)                              pts(q) ⊆ pts(*l) for l ∈ pts(p)
+//
+// Field sensitivity is achieved by tracking the contents of each byte offset of
+// an abstract object separately, where offsets are computed from the constant
+// indices of getelementptr instructions. Pointers with a variable offset (e.g.
+// array indexing with a variable index) point to an unknown offset of their
+// object; loads through such pointers read every offset of the object, and
+// stores through such pointers write to the unknown offset of the object,
+// which is read by loads of any offset. Calls to memcpy and memmove are modelled
+// the same way, copying the contents of the source object to the unknown offset
+// of the destination object.
+//
+// Indirect calls are resolved on the fly, as the points-to sets of function
+// pointers grow, binding arguments to parameters and return values to call
+// results. The effects of calls to external functions (declarations), other
+// than heap allocation and memory copy functions, are not modelled, nor are
+// integer to pointer conversions.
+package pointsto
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/llir/llvm/analysis/callgraph"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/datalayout"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/llir/llvm/irutil"
+)
+
+// DefaultAllocators are the names of the heap allocation functions recognized
+// by default.
+var DefaultAllocators = []string{
+	"malloc", "calloc", "realloc", "aligned_alloc", "strdup", "strndup",
+	"_Znwm", "_Znam", "_Znwj", "_Znaj",
+}
+
+// Config is the configuration of the points-to analysis.
+type Config struct {
+	// (optional) Data layout used to compute field offsets; the default data
+	// layout is used if nil.
+	DataLayout *datalayout.Layout
+	// (optional) Names of heap allocation functions (global names without '@'
+	// prefix); DefaultAllocators is used if nil.
+	Allocators []string
+}
+
+// === [ Abstract memory ] =====================================================
+
+// ObjectKind specifies the kind of an abstract object.
+type ObjectKind uint8
+
+// Abstract object kinds.
+const (
+	// Stack object; allocated by an alloca instruction.
+	Stack ObjectKind = iota
+	// Global variable.
+	Global
+	// Function.
+	Function
+	// Heap object; allocated by a call to a heap allocation function.
+	Heap
+)
+
+// Object is an abstract object.
+type Object struct {
+	// Kind of the abstract object.
+	Kind ObjectKind
+	// Allocation site of the abstract object.
+	//
+	// Value has one of the following underlying types:
+	//
+	//	*ir.InstAlloca  (Stack)
+	//	*ir.Global      (Global)
+	//	*ir.Func        (Function)
+	//	*ir.InstCall    (Heap)
+	//	*ir.TermInvoke  (Heap)
+	Value value.Value
+	// Function of the allocation site; or nil for global variables and
+	// functions.
+	Func *ir.Func
+
+	// Index of the object, in order of creation.
+	id int
+}
+
+// String returns the string representation of the abstract object.
+func (obj *Object) String() string {
+	if obj.Func == nil {
+		return obj.Value.Ident()
+	}
+	s := fmt.Sprintf("%s:%s", obj.Func.Ident(), obj.Value.Ident())
+	if obj.Kind == Heap {
+		return "heap " + s
+	}
+	return s
+}
+
+// UnknownOffset is the offset of locations at an unknown offset of an object.
+const UnknownOffset = -1
+
+// Location is an abstract memory location; i.e. a byte offset into an abstract
+// object.
+type Location struct {
+	// Abstract object of the location.
+	Object *Object
+	// Byte offset of the location; or UnknownOffset if unknown.
+	Offset int64
+}
+
+// String returns the string representation of the abstract memory location.
+func (loc Location) String() string {
+	switch loc.Offset {
+	case 0:
+		return loc.Object.String()
+	case UnknownOffset:
+		return loc.Object.String() + "+?"
+	}
+	return fmt.Sprintf("%s+%d", loc.Object, loc.Offset)
+}
+
+// === [ Analysis ] ============================================================
+
+// Result is the result of the points-to analysis of a module.
+type Result struct {
+	// Constraint solver of the analysis.
+	s *solver
+}
+
+// Analyze performs the points-to analysis of the given module, based on the
+// configuration config (or the default configuration if nil).
+func Analyze(m *ir.Module, config *Config) *Result {
+	if config == nil {
+		config = &Config{}
+	}
+	s := newSolver(config)
+	s.generate(m)
+	s.solve()
+	return &Result{s: s}
+}
+
+// PointsTo returns the abstract memory locations the given pointer value may
+// point to, in order of object creation and offset.
+func (r *Result) PointsTo(v value.Value) []Location {
+	return sortLocs(r.s.pointsTo(v))
+}
+
+// Contents returns the abstract memory locations which may be stored at the
+// given abstract memory location, in order of object creation and offset.
+func (r *Result) Contents(loc Location) []Location {
+	pts := make(map[Location]bool)
+	for _, offset := range r.s.offsets[loc.Object] {
+		if loc.Offset != UnknownOffset && offset != loc.Offset && offset != UnknownOffset {
+			continue
+		}
+		cell := r.s.cells[Location{Object: loc.Object, Offset: offset}]
+		for l := range r.s.nodes[cell].pts {
+			pts[l] = true
+		}
+	}
+	return sortLocs(pts)
+}
+
+// MayAlias reports whether the pointer values a and b may point to the same
+// abstract memory location.
+func (r *Result) MayAlias(a, b value.Value) bool {
+	ptsB := r.s.pointsTo(b)
+	for la := range r.s.pointsTo(a) {
+		for lb := range ptsB {
+			if la.Object != lb.Object {
+				continue
+			}
+			if la.Offset == lb.Offset || la.Offset == UnknownOffset || lb.Offset == UnknownOffset {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Callees returns the functions which may be invoked by the given call site
+// (*ir.InstCall, *ir.TermInvoke or *ir.TermCallBr), in order of the functions
+// of the module.
+func (r *Result) Callees(site value.Value) []*ir.Func {
+	var fs []*ir.Func
+	for f := range r.s.callees[site] {
+		fs = append(fs, f)
+	}
+	sort.Slice(fs, func(i, j int) bool {
+		return r.s.objects[fs[i]].id < r.s.objects[fs[j]].id
+	})
+	return fs
+}
+
+// Resolver returns a call graph resolver which resolves indirect call sites
+// based on the points-to analysis.
+func (r *Result) Resolver() callgraph.Resolver {
+	return func(site *callgraph.CallSite) []*ir.Func {
+		return r.Callees(site.Inst)
+	}
+}
+
+// --- [ Constraint solver ] ---------------------------------------------------
+
+// solver is an inclusion constraint solver.
+type solver struct {
+	// Data layout used to compute field offsets.
+	dl *datalayout.Layout
+	// Set of heap allocation function names.
+	allocators map[string]bool
+
+	// Constraint graph nodes.
+	nodes []*node
+	// Node of each value.
+	valueNodes map[value.Value]int
+	// Node of the return value of each function.
+	retNodes map[*ir.Func]int
+	// Node of the contents of each abstract memory location.
+	cells map[Location]int
+	// Abstract objects of allocation sites.
+	objects map[value.Value]*Object
+	// Offsets of each object with a node of contents.
+	offsets map[*Object][]int64
+	// Nodes reading every offset of each object.
+	readers map[*Object][]int
+	// Functions bound to each call site.
+	callees map[value.Value]map[*ir.Func]bool
+	// Function of each call site.
+	callers map[value.Value]*ir.Func
+	// Worklist of nodes with new points-to locations.
+	work []int
+}
+
+// node is a constraint graph node; i.e. a pointer variable or the contents of
+// an abstract memory location.
+type node struct {
+	// Points-to set of the node.
+	pts map[Location]bool
+	// Locations added to the points-to set not yet propagated.
+	delta map[Location]bool
+	// Successor nodes of copy edges; pts(n) ⊆ pts(succ).
+	succs map[int]bool
+	// Successor nodes of copy edges, in order of addition.
+	ordered []int
+	// Complex constraints of the node, triggered by new locations.
+	loads  []load
+	stores []store
+	geps   []gep
+	calls  []*callgraph.CallSite
+	// Node in worklist.
+	queued bool
+}
+
+// load is a load constraint; pts(*l) ⊆ pts(dst) for l ∈ pts(n).
+type load struct {
+	dst int
+	// Read every offset of the object.
+	all bool
+}
+
+// store is a store constraint; pts(src) ⊆ pts(*l) for l ∈ pts(n).
+type store struct {
+	src int
+	// Write to the unknown offset of the object.
+	unknown bool
+}
+
+// gep is an offset constraint; pts(n)+offset ⊆ pts(dst).
+type gep struct {
+	dst    int
+	offset int64
+}
+
+// newSolver returns a new constraint solver based on the given configuration.
+func newSolver(config *Config) *solver {
+	dl := config.DataLayout
+	if dl == nil {
+		dl = datalayout.Default()
+	}
+	allocators := config.Allocators
+	if allocators == nil {
+		allocators = DefaultAllocators
+	}
+	s := &solver{
+		dl:         dl,
+		allocators: make(map[string]bool),
+		valueNodes: make(map[value.Value]int),
+		retNodes:   make(map[*ir.Func]int),
+		cells:      make(map[Location]int),
+		objects:    make(map[value.Value]*Object),
+		offsets:    make(map[*Object][]int64),
+		readers:    make(map[*Object][]int),
+		callees:    make(map[value.Value]map[*ir.Func]bool),
+		callers:    make(map[value.Value]*ir.Func),
+	}
+	for _, name := range allocators {
+		s.allocators[name] = true
+	}
+	return s
+}
+
+// newNode returns a new constraint graph node.
+func (s *solver) newNode() int {
+	s.nodes = append(s.nodes, &node{
+		pts:   make(map[Location]bool),
+		delta: make(map[Location]bool),
+		succs: make(map[int]bool),
+	})
+	return len(s.nodes) - 1
+}
+
+// object returns the abstract object of the given allocation site.
+func (s *solver) object(kind ObjectKind, v value.Value, f *ir.Func) *Object {
+	if obj, ok := s.objects[v]; ok {
+		return obj
+	}
+	obj := &Object{Kind: kind, Value: v, Func: f, id: len(s.objects)}
+	s.objects[v] = obj
+	return obj
+}
+
+// nodeOf returns the constraint graph node of the given value. The nodes of
+// constants are initialized with the locations of the constant.
+func (s *solver) nodeOf(v value.Value) int {
+	v = irutil.Unwrap(v)
+	if n, ok := s.valueNodes[v]; ok {
+		return n
+	}
+	n := s.newNode()
+	s.valueNodes[v] = n
+	if c, ok := v.(constant.Constant); ok {
+		for _, loc := range s.constLocs(c) {
+			s.addLoc(n, loc)
+		}
+	}
+	return n
+}
+
+// retNode returns the constraint graph node of the return value of f.
+func (s *solver) retNode(f *ir.Func) int {
+	if n, ok := s.retNodes[f]; ok {
+		return n
+	}
+	n := s.newNode()
+	s.retNodes[f] = n
+	return n
+}
+
+// cell returns the constraint graph node of the contents of the given abstract
+// memory location.
+func (s *solver) cell(loc Location) int {
+	if n, ok := s.cells[loc]; ok {
+		return n
+	}
+	n := s.newNode()
+	s.cells[loc] = n
+	s.offsets[loc.Object] = append(s.offsets[loc.Object], loc.Offset)
+	// Nodes reading every offset of the object.
+	for _, r := range s.readers[loc.Object] {
+		s.addEdge(n, r)
+	}
+	return n
+}
+
+// readCells returns the nodes of contents which may be read through a pointer
+// to the given abstract memory location.
+func (s *solver) readCells(loc Location) []int {
+	if loc.Offset == UnknownOffset {
+		var cells []int
+		for _, offset := range s.offsets[loc.Object] {
+			cells = append(cells, s.cells[Location{Object: loc.Object, Offset: offset}])
+		}
+		return cells
+	}
+	return []int{s.cell(loc), s.cell(Location{Object: loc.Object, Offset: UnknownOffset})}
+}
+
+// addLoc adds loc to the points-to set of node n.
+func (s *solver) addLoc(n int, loc Location) {
+	nd := s.nodes[n]
+	if nd.pts[loc] {
+		return
+	}
+	nd.pts[loc] = true
+	nd.delta[loc] = true
+	if !nd.queued {
+		nd.queued = true
+		s.work = append(s.work, n)
+	}
+}
+
+// addEdge adds a copy edge from node from to node to; pts(from) ⊆ pts(to).
+func (s *solver) addEdge(from, to int) {
+	if from == to || s.nodes[from].succs[to] {
+		return
+	}
+	s.nodes[from].succs[to] = true
+	s.nodes[from].ordered = append(s.nodes[from].ordered, to)
+	for loc := range s.nodes[from].pts {
+		s.addLoc(to, loc)
+	}
+}
+
+// solve solves the constraints.
+func (s *solver) solve() {
+	for len(s.work) > 0 {
+		n := s.work[0]
+		s.work = s.work[1:]
+		nd := s.nodes[n]
+		nd.queued = false
+		delta := nd.delta
+		nd.delta = make(map[Location]bool)
+		for _, loc := range sortLocs(delta) {
+			s.apply(n, loc)
+		}
+		for _, succ := range nd.ordered {
+			for loc := range delta {
+				s.addLoc(succ, loc)
+			}
+		}
+	}
+}
+
+// apply applies the complex constraints of node n to the new location loc of
+// its points-to set.
+func (s *solver) apply(n int, loc Location) {
+	nd := s.nodes[n]
+	for _, c := range nd.loads {
+		if c.all || loc.Offset == UnknownOffset {
+			s.readAll(loc.Object, c.dst)
+			continue
+		}
+		for _, cell := range s.readCells(loc) {
+			s.addEdge(cell, c.dst)
+		}
+	}
+	for _, c := range nd.stores {
+		dst := loc
+		if c.unknown {
+			dst.Offset = UnknownOffset
+		}
+		s.addEdge(c.src, s.cell(dst))
+	}
+	for _, c := range nd.geps {
+		s.addLoc(c.dst, shift(loc, c.offset))
+	}
+	for _, site := range nd.calls {
+		if f, ok := loc.Object.Value.(*ir.Func); ok && loc.Offset == 0 {
+			s.bindCall(site, f)
+		}
+	}
+}
+
+// readAll adds copy edges from every offset of the given object to dst,
+// including offsets created later on.
+func (s *solver) readAll(obj *Object, dst int) {
+	for _, r := range s.readers[obj] {
+		if r == dst {
+			return
+		}
+	}
+	s.readers[obj] = append(s.readers[obj], dst)
+	for _, offset := range s.offsets[obj] {
+		s.addEdge(s.cells[Location{Object: obj, Offset: offset}], dst)
+	}
+}
+
+// pointsTo returns the points-to set of the given value.
+func (s *solver) pointsTo(v value.Value) map[Location]bool {
+	v = irutil.Unwrap(v)
+	if n, ok := s.valueNodes[v]; ok {
+		return s.nodes[n].pts
+	}
+	pts := make(map[Location]bool)
+	if c, ok := v.(constant.Constant); ok {
+		for _, loc := range s.constLocs(c) {
+			pts[loc] = true
+		}
+	}
+	return pts
+}
+
+// --- [ Constraint generation ] -----------------------------------------------
+
+// generate generates the constraints of the given module.
+func (s *solver) generate(m *ir.Module) {
+	for _, g := range m.Globals {
+		s.object(Global, g, nil)
+	}
+	for _, f := range m.Funcs {
+		s.object(Function, f, nil)
+	}
+	for _, g := range m.Globals {
+		if g.Init != nil {
+			s.initGlobal(s.objects[g], g.Init, 0)
+		}
+	}
+	for _, f := range m.Funcs {
+		for _, block := range f.Blocks {
+			for _, inst := range block.Insts {
+				s.genInst(f, inst)
+			}
+			if ret, ok := block.Term.(*ir.TermRet); ok && ret.X != nil {
+				s.addEdge(s.nodeOf(ret.X), s.retNode(f))
+			}
+		}
+		for _, site := range callgraph.CallSites(f) {
+			s.genCall(f, site)
+		}
+	}
+}
+
+// initGlobal adds the locations of the pointers of the given initializer at
+// the given offset of the global variable object obj.
+func (s *solver) initGlobal(obj *Object, init constant.Constant, offset int64) {
+	switch init := init.(type) {
+	case *constant.Struct:
+		layout := s.dl.StructLayout(init.Typ)
+		for i, field := range init.Fields {
+			s.initGlobal(obj, field, offset+int64(layout.Offsets[i]))
+		}
+	case *constant.Array:
+		size := int64(s.dl.AllocSize(init.Typ.ElemType))
+		for i, elem := range init.Elems {
+			s.initGlobal(obj, elem, offset+int64(i)*size)
+		}
+	default:
+		locs := s.constLocs(init)
+		if len(locs) == 0 {
+			return
+		}
+		cell := s.cell(Location{Object: obj, Offset: offset})
+		for _, loc := range locs {
+			s.addLoc(cell, loc)
+		}
+	}
+}
+
+// genInst generates the constraints of the given instruction of f.
+func (s *solver) genInst(f *ir.Func, inst ir.Instruction) {
+	switch inst := inst.(type) {
+	case *ir.InstAlloca:
+		s.addLoc(s.nodeOf(inst), Location{Object: s.object(Stack, inst, f)})
+	case *ir.InstLoad:
+		src := s.nodes[s.nodeOf(inst.Src)]
+		src.loads = append(src.loads, load{dst: s.nodeOf(inst)})
+	case *ir.InstStore:
+		dst := s.nodes[s.nodeOf(inst.Dst)]
+		dst.stores = append(dst.stores, store{src: s.nodeOf(inst.Src)})
+	case *ir.InstCmpXchg:
+		ptr := s.nodes[s.nodeOf(inst.Ptr)]
+		ptr.loads = append(ptr.loads, load{dst: s.nodeOf(inst)})
+		ptr.stores = append(ptr.stores, store{src: s.nodeOf(inst.New)})
+	case *ir.InstAtomicRMW:
+		dst := s.nodes[s.nodeOf(inst.Dst)]
+		dst.loads = append(dst.loads, load{dst: s.nodeOf(inst)})
+		dst.stores = append(dst.stores, store{src: s.nodeOf(inst.X)})
+	case *ir.InstGetElementPtr:
+		src := s.nodes[s.nodeOf(inst.Src)]
+		src.geps = append(src.geps, gep{dst: s.nodeOf(inst), offset: s.offset(inst.ElemType, inst.Indices)})
+	case *ir.InstBitCast:
+		s.addEdge(s.nodeOf(inst.From), s.nodeOf(inst))
+	case *ir.InstAddrSpaceCast:
+		s.addEdge(s.nodeOf(inst.From), s.nodeOf(inst))
+	case *ir.InstPhi:
+		for _, inc := range inst.Incs {
+			s.addEdge(s.nodeOf(inc.X), s.nodeOf(inst))
+		}
+	case *ir.InstSelect:
+		s.addEdge(s.nodeOf(inst.ValueTrue), s.nodeOf(inst))
+		s.addEdge(s.nodeOf(inst.ValueFalse), s.nodeOf(inst))
+	case *ir.InstExtractValue:
+		// Aggregate values are field-insensitive.
+		s.addEdge(s.nodeOf(inst.X), s.nodeOf(inst))
+	case *ir.InstInsertValue:
+		s.addEdge(s.nodeOf(inst.X), s.nodeOf(inst))
+		s.addEdge(s.nodeOf(inst.Elem), s.nodeOf(inst))
+	case *ir.InstFreeze:
+		s.addEdge(s.nodeOf(inst.X), s.nodeOf(inst))
+	}
+}
+
+// genCall generates the constraints of the given call site of f.
+func (s *solver) genCall(f *ir.Func, site *callgraph.CallSite) {
+	s.callers[site.Inst] = f
+	if callees, ok := callgraph.Callees(site.Callee); ok {
+		for _, callee := range callees {
+			s.bindCall(site, callee)
+		}
+		return
+	}
+	callee := s.nodes[s.nodeOf(site.Callee)]
+	callee.calls = append(callee.calls, site)
+}
+
+// bindCall binds the given call site to the callee f.
+func (s *solver) bindCall(site *callgraph.CallSite, f *ir.Func) {
+	if s.callees[site.Inst] == nil {
+		s.callees[site.Inst] = make(map[*ir.Func]bool)
+	}
+	if s.callees[site.Inst][f] {
+		return
+	}
+	s.callees[site.Inst][f] = true
+	args := siteArgs(site)
+	name := f.Name()
+	switch {
+	case s.allocators[name]:
+		obj := s.object(Heap, site.Inst, s.callers[site.Inst])
+		s.addLoc(s.nodeOf(site.Inst), Location{Object: obj})
+		return
+	case isMemCopy(name) && len(args) >= 2:
+		// Copy the contents of the source object to the unknown offset of the
+		// destination object.
+		tmp := s.newNode()
+		src := s.nodes[s.nodeOf(args[1])]
+		src.loads = append(src.loads, load{dst: tmp, all: true})
+		dst := s.nodes[s.nodeOf(args[0])]
+		dst.stores = append(dst.stores, store{src: tmp, unknown: true})
+		if !site.Sig.RetType.Equal(types.Void) {
+			// memcpy returns its destination.
+			s.addEdge(s.nodeOf(args[0]), s.nodeOf(site.Inst))
+		}
+		return
+	}
+	for i, param := range f.Params {
+		if i < len(args) {
+			s.addEdge(s.nodeOf(args[i]), s.nodeOf(param))
+		}
+	}
+	if !f.Sig.RetType.Equal(types.Void) {
+		s.addEdge(s.retNode(f), s.nodeOf(site.Inst))
+	}
+}
+
+// offset returns the byte offset of the given getelementptr indices; or
+// UnknownOffset if an index is not constant.
+func (s *solver) offset(elemType types.Type, indices []value.Value) int64 {
+	is := make([]int64, len(indices))
+	for i, index := range indices {
+		index = irutil.Unwrap(index)
+		if idx, ok := index.(*constant.Index); ok {
+			index = idx.Constant
+		}
+		c, ok := index.(*constant.Int)
+		if !ok || !c.X.IsInt64() {
+			return UnknownOffset
+		}
+		is[i] = c.X.Int64()
+	}
+	offset, err := s.dl.IndexedOffset(elemType, is)
+	if err != nil {
+		return UnknownOffset
+	}
+	return offset
+}
+
+// constLocs returns the abstract memory locations the given constant may point
+// to.
+func (s *solver) constLocs(c constant.Constant) []Location {
+	switch c := c.(type) {
+	case *ir.Global, *ir.Func:
+		if obj, ok := s.objects[c]; ok {
+			return []Location{{Object: obj}}
+		}
+	case *ir.Alias:
+		return s.constLocs(c.Aliasee)
+	case *constant.ExprBitCast:
+		return s.constLocs(c.From)
+	case *constant.ExprAddrSpaceCast:
+		return s.constLocs(c.From)
+	case *constant.ExprGetElementPtr:
+		indices := make([]value.Value, len(c.Indices))
+		for i, index := range c.Indices {
+			indices[i] = index
+		}
+		offset := s.offset(c.ElemType, indices)
+		var locs []Location
+		for _, loc := range s.constLocs(c.Src) {
+			locs = append(locs, shift(loc, offset))
+		}
+		return locs
+	}
+	return nil
+}
+
+// ### [ Helper functions ] ####################################################
+
+// shift returns the location at the given offset from loc.
+func shift(loc Location, offset int64) Location {
+	if loc.Offset == UnknownOffset || offset == UnknownOffset {
+		return Location{Object: loc.Object, Offset: UnknownOffset}
+	}
+	return Location{Object: loc.Object, Offset: loc.Offset + offset}
+}
+
+// siteArgs returns the arguments of the given call site.
+func siteArgs(site *callgraph.CallSite) []value.Value {
+	switch inst := site.Inst.(type) {
+	case *ir.InstCall:
+		return inst.Args
+	case *ir.TermInvoke:
+		return inst.Args
+	case *ir.TermCallBr:
+		return inst.Args
+	}
+	return nil
+}
+
+// isMemCopy reports whether the function of the given name copies memory from
+// its second argument to its first argument.
+func isMemCopy(name string) bool {
+	switch name {
+	case "memcpy", "memmove":
+		return true
+	}
+	return strings.HasPrefix(name, "llvm.memcpy.") || strings.HasPrefix(name, "llvm.memmove.")
+}
+
+// sortLocs returns the given set of locations, in order of object creation and
+// offset.
+func sortLocs(set map[Location]bool) []Location {
+	var locs []Location
+	for loc := range set {
+		locs = append(locs, loc)
+	}
+	sort.Slice(locs, func(i, j int) bool {
+		if locs[i].Object.id != locs[j].Object.id {
+			return locs[i].Object.id < locs[j].Object.id
+		}
+		return locs[i].Offset < locs[j].Offset
+	})
+	return locs
+}
@@ -0,0 +1,150 @@
+package pointsto
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/llir/llvm/analysis/callgraph"
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/value"
+)
+
+func TestAnalyze(t *testing.T) {
+	const src = `
+%struct.S = type { i32*, void (i32*)* }
+
+@g = global i32 0
+@h = global i32 0
+@gp = global i32* @h
+
+declare i8* @malloc(i64)
+
+declare void @llvm.memcpy.p0i8.p0i8.i64(i8*, i8*, i64, i1)
+
+define void @sink(i32* %x) {
+	ret void
+}
+
+define void @other(i32* %x) {
+	ret void
+}
+
+define void @main() {
+	%s = alloca %struct.S
+	%f0 = getelementptr %struct.S, %struct.S* %s, i32 0, i32 0
+	store i32* @g, i32** %f0
+	%f1 = getelementptr %struct.S, %struct.S* %s, i32 0, i32 1
+	store void (i32*)* @sink, void (i32*)** %f1
+	%q = load i32*, i32** %f0
+	%fp = load void (i32*)*, void (i32*)** %f1
+	call void %fp(i32* %q)
+	%m = call i8* @malloc(i64 16)
+	%s8 = bitcast %struct.S* %s to i8*
+	call void @llvm.memcpy.p0i8.p0i8.i64(i8* %m, i8* %s8, i64 16, i1 false)
+	%t = bitcast i8* %m to %struct.S*
+	%t0 = getelementptr %struct.S, %struct.S* %t, i32 0, i32 0
+	%p = load i32*, i32** %t0
+	%r = load i32*, i32** @gp
+	call void @other(i32* %r)
+	ret void
+}
+`
+	m, err := asm.ParseString("pointsto.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	r := Analyze(m, nil)
+	main := m.Funcs[len(m.Funcs)-1]
+	vals := make(map[string]value.Value)
+	for _, f := range m.Funcs {
+		for _, param := range f.Params {
+			vals[f.Name()+"."+param.Name()] = param
+		}
+	}
+	for _, inst := range main.Blocks[0].Insts {
+		if v, ok := inst.(value.Named); ok && len(v.Name()) > 0 {
+			vals[v.Name()] = v
+		}
+	}
+	golden := []struct {
+		v    string
+		want []string
+	}{
+		{v: "s", want: []string{"@main:%s"}},
+		{v: "f1", want: []string{"@main:%s+8"}},
+		{v: "q", want: []string{"@g"}},
+		{v: "fp", want: []string{"@sink"}},
+		{v: "sink.x", want: []string{"@g"}},
+		{v: "m", want: []string{"heap @main:%m"}},
+		// The contents of the heap object are copied by memcpy, and are thus
+		// read field-insensitively.
+		{v: "p", want: []string{"@g", "@sink"}},
+		{v: "r", want: []string{"@h"}},
+		{v: "other.x", want: []string{"@h"}},
+	}
+	for _, g := range golden {
+		if got := locs(r.PointsTo(vals[g.v])); !reflect.DeepEqual(got, g.want) {
+			t.Errorf("points-to set mismatch of %q; expected %q, got %q", g.v, g.want, got)
+		}
+	}
+	// Field-sensitivity.
+	if r.MayAlias(vals["f0"], vals["f1"]) {
+		t.Errorf("expected %%f0 and %%f1 not to alias")
+	}
+	if !r.MayAlias(vals["f0"], vals["s8"]) {
+		t.Errorf("expected %%f0 and %%s8 to alias")
+	}
+	obj := r.PointsTo(vals["s"])[0].Object
+	if got, want := locs(r.Contents(Location{Object: obj, Offset: 8})), []string{"@sink"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("contents mismatch of %%s+8; expected %q, got %q", want, got)
+	}
+	// Indirect calls.
+	g := callgraph.NewWithResolver(m, r.Resolver())
+	var callees []string
+	for _, e := range g.Node(main).Out {
+		callees = append(callees, fmt.Sprintf("%s (indirect: %v)", e.Callee.Func.Ident(), e.Indirect))
+	}
+	want := []string{
+		"@sink (indirect: true)",
+		"@malloc (indirect: false)",
+		"@llvm.memcpy.p0i8.p0i8.i64 (indirect: false)",
+		"@other (indirect: false)",
+	}
+	if !reflect.DeepEqual(callees, want) {
+		t.Errorf("callees mismatch; expected %q, got %q", want, callees)
+	}
+}
+
+func TestAnalyzeAllocators(t *testing.T) {
+	const src = `
+declare i8* @my_alloc(i64)
+
+define i8* @f() {
+	%p = call i8* @my_alloc(i64 8)
+	ret i8* %p
+}
+`
+	m, err := asm.ParseString("allocators.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	f := m.Funcs[len(m.Funcs)-1]
+	p := f.Blocks[0].Insts[0].(*ir.InstCall)
+	if got := Analyze(m, nil).PointsTo(p); len(got) != 0 {
+		t.Errorf("expected empty points-to set without allocator, got %q", locs(got))
+	}
+	config := &Config{Allocators: []string{"my_alloc"}}
+	if got, want := locs(Analyze(m, config).PointsTo(p)), []string{"heap @f:%p"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("points-to set mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func locs(ls []Location) []string {
+	var ss []string
+	for _, l := range ls {
+		ss = append(ss, l.String())
+	}
+	return ss
+}
@@ -0,0 +1,498 @@
+// Package reachdef implements reaching definitions analysis of the memory of
+// local variables (allocas) of LLVM IR functions.
+//
+// Before promotion to SSA form (mem2reg), local variables are kept in memory
+// allocated by alloca instructions, and are read and written by load and store
+// instructions. The reaching definitions of a load are the instructions which
+// may have written the loaded memory, along some path to the load without an
+// intervening overwrite.
+//
+// The memory of each alloca is tracked at byte granularity, so that stores to
+// distinct fields of a struct (or elements of an array) at constant offsets do
+// not overwrite each other. Definitions are:
+//
+//	store             writes the stored bytes; overwrites earlier definitions
+//	                  of the bytes, unless the store is volatile or the offset
+//	                  of the store is not constant
+//	cmpxchg/atomicrmw may write the accessed bytes
+//	call/invoke       may write every byte of the allocas passed as arguments,
+//	                  and of the allocas escaping the function (see package
+//	                  escape); unless the callee only reads memory
+//
+// Stores through pointers which are not derived from an alloca may write the
+// allocas escaping the function; or every alloca, if the pointer is a phi or
+// select instruction, which may be derived from any alloca. Each alloca is
+// itself a definition of the uninitialized memory of the alloca.
+//
+// Reaching definitions are computed as a forward data-flow problem (see package
+// dataflow), where the facts are the bytes of each alloca defined by each
+// definition.
+package reachdef
+
+import (
+	"math"
+	"sort"
+
+	"github.com/llir/llvm/analysis/alias"
+	"github.com/llir/llvm/analysis/dataflow"
+	"github.com/llir/llvm/analysis/escape"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/datalayout"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/value"
+	"github.com/llir/llvm/irutil"
+)
+
+// Reaching is the reaching definitions of a load instruction.
+type Reaching struct {
+	// Definitions which may have written the loaded memory, in program order
+	// (*ir.InstStore, *ir.InstCmpXchg, *ir.InstAtomicRMW, *ir.InstCall or
+	// *ir.TermInvoke).
+	Defs []value.User
+	// The loaded memory may be uninitialized along some path to the load.
+	Uninit bool
+	// The load is volatile; the loaded memory may have been modified outside of
+	// the function, and not only by the reaching definitions.
+	Volatile bool
+}
+
+// Info is the reaching definitions information of a function.
+type Info struct {
+	// Data layout used to compute the sizes and offsets of memory accesses.
+	dl *datalayout.Layout
+	// Alias analysis used to decompose pointers into allocas and offsets.
+	aa *alias.Basic
+	// Allocas of the function, in program order.
+	allocas []*ir.InstAlloca
+	// Size in bytes of each alloca; or math.MaxInt64 if not constant.
+	size map[*ir.InstAlloca]int64
+	// Escape analysis of the allocas of the function.
+	escapes *escape.Info
+	// Definitions of the function (including allocas), in program order.
+	defs []value.User
+	// Memory effects of each definition.
+	effects map[value.User][]effect
+	// Index of each definition in defs.
+	index map[value.User]int
+	// Basic block of each instruction.
+	blockOf map[value.Value]*ir.Block
+	// Data-flow results; facts are of type fact.
+	result *dataflow.Result
+}
+
+// effect is a write to the memory of an alloca.
+type effect struct {
+	// Written alloca.
+	alloca *ir.InstAlloca
+	// Written bytes of the alloca.
+	span span
+	// The bytes are definitely written, overwriting earlier definitions.
+	must bool
+}
+
+// NewInfo computes the reaching definitions information of the given function,
+// based on the data layout dl (or the default data layout if nil).
+func NewInfo(f *ir.Func, dl *datalayout.Layout) *Info {
+	if dl == nil {
+		dl = datalayout.Default()
+	}
+	info := &Info{
+		dl:      dl,
+		aa:      alias.NewBasic(dl),
+		size:    make(map[*ir.InstAlloca]int64),
+		escapes: escape.Analyze(f, nil),
+		effects: make(map[value.User][]effect),
+		index:   make(map[value.User]int),
+		blockOf: irutil.DefBlocks(f),
+	}
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			if alloca, ok := inst.(*ir.InstAlloca); ok {
+				info.allocas = append(info.allocas, alloca)
+				info.size[alloca] = info.allocaSize(alloca)
+			}
+		}
+	}
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			info.addDef(inst)
+		}
+		if block.Term != nil {
+			info.addDef(block.Term)
+		}
+	}
+	p := &dataflow.Problem{
+		Direction: dataflow.Forward,
+		Lattice:   lattice{},
+		Inst: func(inst ir.Instruction, f dataflow.Fact) dataflow.Fact {
+			return info.transfer(inst, f.(fact))
+		},
+		Term: func(term ir.Terminator, f dataflow.Fact) dataflow.Fact {
+			return info.transfer(term, f.(fact))
+		},
+	}
+	info.result = dataflow.Solve(f, p)
+	return info
+}
+
+// Defs returns the definitions of the function, in program order (excluding
+// allocas).
+func (info *Info) Defs() []value.User {
+	var defs []value.User
+	for _, def := range info.defs {
+		if _, ok := def.(*ir.InstAlloca); !ok {
+			defs = append(defs, def)
+		}
+	}
+	return defs
+}
+
+// Reaching returns the reaching definitions of the given load instruction; or
+// nil if the load does not read the memory of an alloca of the function.
+func (info *Info) Reaching(load *ir.InstLoad) *Reaching {
+	block, ok := info.blockOf[load]
+	if !ok {
+		return nil
+	}
+	alloca, s, _, ok := info.locate(load.Src, int64(info.dl.StoreSize(load.ElemType)))
+	if !ok {
+		return nil
+	}
+	r := &Reaching{Volatile: load.Volatile}
+	f := info.result.Before(block, load).(fact)
+	var indices []int
+	for k, spans := range f {
+		if k.alloca == alloca && spans.overlaps(s) {
+			indices = append(indices, k.def)
+		}
+	}
+	sort.Ints(indices)
+	for _, i := range indices {
+		def := info.defs[i]
+		if _, ok := def.(*ir.InstAlloca); ok {
+			r.Uninit = true
+			continue
+		}
+		r.Defs = append(r.Defs, def)
+	}
+	return r
+}
+
+// addDef records the memory effects of the given instruction or terminator, if
+// it is a definition.
+func (info *Info) addDef(user value.User) {
+	effects := info.effectsOf(user)
+	if len(effects) == 0 {
+		return
+	}
+	info.index[user] = len(info.defs)
+	info.defs = append(info.defs, user)
+	info.effects[user] = effects
+}
+
+// effectsOf returns the memory effects on allocas of the given instruction or
+// terminator.
+func (info *Info) effectsOf(user value.User) []effect {
+	switch user := user.(type) {
+	case *ir.InstAlloca:
+		return []effect{{alloca: user, span: info.whole(user), must: true}}
+	case *ir.InstStore:
+		return info.writeEffects(user.Dst, int64(info.dl.StoreSize(user.Src.Type())), !user.Volatile)
+	case *ir.InstCmpXchg:
+		return info.writeEffects(user.Ptr, int64(info.dl.StoreSize(user.New.Type())), false)
+	case *ir.InstAtomicRMW:
+		return info.writeEffects(user.Dst, int64(info.dl.StoreSize(user.X.Type())), false)
+	case *ir.InstCall:
+		if irutil.IsDebugIntrinsic(user) || !irutil.MayWriteMemory(user) {
+			return nil
+		}
+		return info.callEffects(user.Callee, user.Args, irutil.CallFuncAttrs(user))
+	case *ir.TermInvoke:
+		attrs := user.FuncAttrs
+		if f := irutil.Callee(user.Invokee); f != nil {
+			attrs = append(attrs[:len(attrs):len(attrs)], f.FuncAttrs...)
+		}
+		if irutil.HasFuncAttr(attrs, enum.FuncAttrReadNone) || irutil.HasFuncAttr(attrs, enum.FuncAttrReadOnly) {
+			return nil
+		}
+		return info.callEffects(user.Invokee, user.Args, attrs)
+	}
+	return nil
+}
+
+// writeEffects returns the memory effects of a write of size bytes through the
+// given pointer. The write overwrites earlier definitions if strong is true and
+// the written bytes are known.
+func (info *Info) writeEffects(ptr value.Value, size int64, strong bool) []effect {
+	alloca, s, exact, ok := info.locate(ptr, size)
+	if !ok {
+		var effects []effect
+		for _, alloca := range info.mayPointTo(ptr) {
+			effects = append(effects, effect{alloca: alloca, span: info.whole(alloca)})
+		}
+		return effects
+	}
+	return []effect{{alloca: alloca, span: s, must: strong && exact}}
+}
+
+// callEffects returns the memory effects of a call with the given callee,
+// arguments and function attributes, which may write memory.
+func (info *Info) callEffects(callee value.Value, args []value.Value, attrs []ir.FuncAttribute) []effect {
+	written := make(map[*ir.InstAlloca]bool)
+	if !irutil.HasFuncAttr(attrs, enum.FuncAttrArgMemOnly) {
+		for _, alloca := range info.allocas {
+			if info.escapes.Escapes(alloca) {
+				written[alloca] = true
+			}
+		}
+	}
+	f := irutil.Callee(callee)
+	for i, arg := range args {
+		var paramAttrs []ir.ParamAttribute
+		if arg, ok := arg.(*ir.Arg); ok {
+			paramAttrs = arg.Attrs
+		}
+		if f != nil && i < len(f.Params) {
+			paramAttrs = append(paramAttrs[:len(paramAttrs):len(paramAttrs)], f.Params[i].Attrs...)
+		}
+		if irutil.HasParamAttr(paramAttrs, enum.ParamAttrReadOnly) || irutil.HasParamAttr(paramAttrs, enum.ParamAttrReadNone) {
+			continue
+		}
+		base, _, _ := info.aa.Decompose(arg)
+		if alloca, ok := base.(*ir.InstAlloca); ok {
+			written[alloca] = true
+		}
+	}
+	var effects []effect
+	for _, alloca := range info.allocas {
+		if written[alloca] {
+			effects = append(effects, effect{alloca: alloca, span: info.whole(alloca)})
+		}
+	}
+	return effects
+}
+
+// transfer returns the fact after the given instruction or terminator, based on
+// the fact before it.
+func (info *Info) transfer(user value.User, before fact) fact {
+	effects := info.effects[user]
+	if len(effects) == 0 {
+		return before
+	}
+	after := before.clone()
+	def := info.index[user]
+	for _, e := range effects {
+		if e.must {
+			for k, spans := range after {
+				if k.alloca != e.alloca {
+					continue
+				}
+				if spans = spans.subtract(e.span); len(spans) > 0 {
+					after[k] = spans
+				} else {
+					delete(after, k)
+				}
+			}
+		}
+		k := key{def: def, alloca: e.alloca}
+		after[k] = after[k].union(spanSet{e.span})
+	}
+	return after
+}
+
+// locate returns the alloca and the bytes of the alloca accessed by a memory
+// access of size bytes through the given pointer, and reports whether the
+// accessed bytes are exact and whether the pointer is derived from an alloca of
+// the function. Every byte of the alloca may be accessed if the offset of the
+// pointer is not constant.
+func (info *Info) locate(ptr value.Value, size int64) (alloca *ir.InstAlloca, s span, exact, ok bool) {
+	base, offset, known := info.aa.Decompose(ptr)
+	alloca, ok = base.(*ir.InstAlloca)
+	if !ok {
+		return nil, span{}, false, false
+	}
+	if _, ok := info.size[alloca]; !ok {
+		return nil, span{}, false, false
+	}
+	whole := info.whole(alloca)
+	if !known || offset < 0 || offset > whole.hi-size {
+		return alloca, whole, false, true
+	}
+	return alloca, span{lo: offset, hi: offset + size}, true, true
+}
+
+// mayPointTo returns the allocas the given pointer, which is not derived from
+// an alloca through casts and getelementptr instructions, may point to.
+func (info *Info) mayPointTo(ptr value.Value) []*ir.InstAlloca {
+	base, _, _ := info.aa.Decompose(ptr)
+	switch base.(type) {
+	case *ir.InstPhi, *ir.InstSelect:
+		// Possibly derived from any alloca.
+		return info.allocas
+	}
+	// Pointers loaded from memory, returned by calls, passed as arguments, etc.
+	var allocas []*ir.InstAlloca
+	for _, alloca := range info.allocas {
+		if info.escapes.Escapes(alloca) {
+			allocas = append(allocas, alloca)
+		}
+	}
+	return allocas
+}
+
+// whole returns the span of every byte of the given alloca.
+func (info *Info) whole(alloca *ir.InstAlloca) span {
+	return span{lo: 0, hi: info.size[alloca]}
+}
+
+// allocaSize returns the size in bytes of the given alloca; or math.MaxInt64 if
+// not constant.
+func (info *Info) allocaSize(alloca *ir.InstAlloca) int64 {
+	size := int64(info.dl.AllocSize(alloca.ElemType))
+	if alloca.NElems == nil {
+		return size
+	}
+	n, ok := alloca.NElems.(*constant.Int)
+	if !ok || !n.X.IsInt64() || n.X.Int64() < 0 {
+		return math.MaxInt64
+	}
+	if n.X.Int64() != 0 && size > math.MaxInt64/n.X.Int64() {
+		return math.MaxInt64
+	}
+	return size * n.X.Int64()
+}
+
+// --- [ Data-flow facts ] -----------------------------------------------------
+
+// key identifies the bytes of an alloca defined by a definition.
+type key struct {
+	// Index of the definition.
+	def int
+	// Defined alloca.
+	alloca *ir.InstAlloca
+}
+
+// fact is a data-flow fact of reaching definitions, mapping from definitions
+// and allocas to the bytes defined.
+type fact map[key]spanSet
+
+// clone returns a copy of the fact.
+func (f fact) clone() fact {
+	g := make(fact, len(f))
+	for k, spans := range f {
+		g[k] = spans
+	}
+	return g
+}
+
+// lattice is the lattice of reaching definitions facts.
+type lattice struct{}
+
+// Bottom returns the empty fact.
+func (lattice) Bottom() dataflow.Fact {
+	return fact{}
+}
+
+// Join returns the union of the facts x and y.
+func (lattice) Join(x, y dataflow.Fact) dataflow.Fact {
+	a, b := x.(fact), y.(fact)
+	if len(b) == 0 {
+		return a
+	}
+	if len(a) == 0 {
+		return b
+	}
+	c := a.clone()
+	for k, spans := range b {
+		c[k] = c[k].union(spans)
+	}
+	return c
+}
+
+// Equal reports whether the facts x and y are equal.
+func (lattice) Equal(x, y dataflow.Fact) bool {
+	a, b := x.(fact), y.(fact)
+	if len(a) != len(b) {
+		return false
+	}
+	for k, spans := range a {
+		if !spans.equal(b[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+// --- [ Byte spans ] ----------------------------------------------------------
+
+// span is a half-open range [lo, hi) of bytes.
+type span struct {
+	lo, hi int64
+}
+
+// spanSet is a set of bytes, represented as sorted, disjoint and non-adjacent
+// spans. Span sets are immutable.
+type spanSet []span
+
+// union returns the union of the span sets s and t.
+func (s spanSet) union(t spanSet) spanSet {
+	all := make(spanSet, 0, len(s)+len(t))
+	all = append(all, s...)
+	all = append(all, t...)
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].lo < all[j].lo
+	})
+	var u spanSet
+	for _, x := range all {
+		if n := len(u); n > 0 && x.lo <= u[n-1].hi {
+			if x.hi > u[n-1].hi {
+				u[n-1].hi = x.hi
+			}
+			continue
+		}
+		u = append(u, x)
+	}
+	return u
+}
+
+// subtract returns the span set s without the bytes of x.
+func (s spanSet) subtract(x span) spanSet {
+	var t spanSet
+	for _, y := range s {
+		if y.hi <= x.lo || x.hi <= y.lo {
+			t = append(t, y)
+			continue
+		}
+		if y.lo < x.lo {
+			t = append(t, span{lo: y.lo, hi: x.lo})
+		}
+		if x.hi < y.hi {
+			t = append(t, span{lo: x.hi, hi: y.hi})
+		}
+	}
+	return t
+}
+
+// overlaps reports whether the span set s contains any byte of x.
+func (s spanSet) overlaps(x span) bool {
+	for _, y := range s {
+		if y.lo < x.hi && x.lo < y.hi {
+			return true
+		}
+	}
+	return false
+}
+
+// equal reports whether the span sets s and t are equal.
+func (s spanSet) equal(t spanSet) bool {
+	if len(s) != len(t) {
+		return false
+	}
+	for i := range s {
+		if s[i] != t[i] {
+			return false
+		}
+	}
+	return true
+}
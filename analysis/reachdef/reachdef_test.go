@@ -0,0 +1,117 @@
+package reachdef
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/value"
+)
+
+func TestNewInfo(t *testing.T) {
+	const src = `
+%pair = type { i32, i32 }
+
+declare void @init(i32*)
+
+declare void @use(i32*)
+
+declare void @opaque()
+
+define void @f(i1 %c) {
+entry:
+	%x = alloca i32
+	%p = alloca %pair
+	%v = alloca i32
+	%e = alloca i32
+	%a = getelementptr %pair, %pair* %p, i32 0, i32 0
+	%b = getelementptr %pair, %pair* %p, i32 0, i32 1
+	store i32 1, i32* %a
+	br i1 %c, label %then, label %join
+
+then:
+	store i32 2, i32* %x
+	store i32 3, i32* %b
+	br label %join
+
+join:
+	%x1 = load i32, i32* %x
+	%a1 = load i32, i32* %a
+	%b1 = load i32, i32* %b
+	%p1 = load %pair, %pair* %p
+	store i32 4, i32* %x
+	store volatile i32 5, i32* %x
+	%x2 = load i32, i32* %x
+	call void @init(i32* %v)
+	%v1 = load volatile i32, i32* %v
+	call void @use(i32* %e)
+	store i32 6, i32* %e
+	call void @opaque()
+	%e1 = load i32, i32* %e
+	ret void
+}
+`
+	m, err := asm.ParseString("reachdef.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	f := m.Funcs[3]
+	info := NewInfo(f, nil)
+	loads := make(map[string]*ir.InstLoad)
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			if load, ok := inst.(*ir.InstLoad); ok {
+				loads[load.Name()] = load
+			}
+		}
+	}
+	golden := []struct {
+		load     string
+		defs     []string
+		uninit   bool
+		volatile bool
+	}{
+		// Defined along one path only.
+		{load: "x1", defs: []string{"store i32 2, i32* %x"}, uninit: true},
+		// Distinct fields of a struct.
+		{load: "a1", defs: []string{"store i32 1, i32* %a"}},
+		{load: "b1", defs: []string{"store i32 3, i32* %b"}, uninit: true},
+		// Loads of the entire struct read every field.
+		{load: "p1", defs: []string{"store i32 1, i32* %a", "store i32 3, i32* %b"}, uninit: true},
+		// Volatile stores do not overwrite earlier definitions.
+		{load: "x2", defs: []string{"store i32 4, i32* %x", "store volatile i32 5, i32* %x"}},
+		// Calls define the allocas passed as arguments.
+		{load: "v1", defs: []string{"call void @init(i32* %v)"}, uninit: true, volatile: true},
+		// Calls define escaping allocas.
+		{load: "e1", defs: []string{"store i32 6, i32* %e", "call void @opaque()"}},
+	}
+	for _, g := range golden {
+		r := info.Reaching(loads[g.load])
+		if r == nil {
+			t.Errorf("reaching definitions mismatch of %%%s; expected non-nil", g.load)
+			continue
+		}
+		if got, want := defStrings(r.Defs), strings.Join(g.defs, "; "); got != want {
+			t.Errorf("reaching definitions mismatch of %%%s; expected %q, got %q", g.load, want, got)
+		}
+		if r.Uninit != g.uninit {
+			t.Errorf("uninitialized mismatch of %%%s; expected %v, got %v", g.load, g.uninit, r.Uninit)
+		}
+		if r.Volatile != g.volatile {
+			t.Errorf("volatile mismatch of %%%s; expected %v, got %v", g.load, g.volatile, r.Volatile)
+		}
+	}
+	if got, want := len(info.Defs()), 9; got != want {
+		t.Errorf("number of definitions mismatch; expected %d, got %d", want, got)
+	}
+}
+
+// defStrings returns the string representation of the given definitions.
+func defStrings(defs []value.User) string {
+	var ss []string
+	for _, def := range defs {
+		ss = append(ss, def.(ir.LLStringer).LLString())
+	}
+	return strings.Join(ss, "; ")
+}
@@ -0,0 +1,289 @@
+package scev
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/llir/llvm/analysis/loop"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/llir/llvm/irutil"
+)
+
+// Expr is a scalar evolution expression.
+//
+// Expressions are created in canonical form by Info, and should be treated as
+// immutable. Two expressions denote the same value if Equal reports so.
+//
+// An Expr has one of the following underlying types.
+//
+//	*scev.Constant
+//	*scev.Unknown
+//	*scev.Add
+//	*scev.Mul
+//	*scev.UDiv
+//	*scev.Max
+//	*scev.AddRec
+type Expr interface {
+	fmt.Stringer
+	// Type returns the type of the expression.
+	Type() types.Type
+	// isExpr ensures that only scalar evolution expressions can be assigned to
+	// the scev.Expr interface.
+	isExpr()
+}
+
+// --- [ Constant ] ------------------------------------------------------------
+
+// Constant is an integer constant.
+type Constant struct {
+	// Integer constant.
+	X *constant.Int
+}
+
+// String returns the string representation of the expression.
+func (e *Constant) String() string {
+	return e.X.X.String()
+}
+
+// Type returns the type of the expression.
+func (e *Constant) Type() types.Type {
+	return e.X.Typ
+}
+
+// --- [ Unknown ] -------------------------------------------------------------
+
+// Unknown is a value whose evolution is not analyzable; e.g. a function
+// parameter, a load or a phi instruction which is not an induction variable.
+type Unknown struct {
+	// Opaque value.
+	Value value.Value
+}
+
+// String returns the string representation of the expression.
+func (e *Unknown) String() string {
+	return e.Value.Ident()
+}
+
+// Type returns the type of the expression.
+func (e *Unknown) Type() types.Type {
+	return e.Value.Type()
+}
+
+// --- [ Add ] -----------------------------------------------------------------
+
+// Add is the sum of two or more operands, using wrapping arithmetic.
+type Add struct {
+	// Operands; constant operands first.
+	Ops []Expr
+}
+
+// String returns the string representation of the expression.
+func (e *Add) String() string {
+	return joinOps(e.Ops, " + ")
+}
+
+// Type returns the type of the expression.
+func (e *Add) Type() types.Type {
+	return e.Ops[0].Type()
+}
+
+// --- [ Mul ] -----------------------------------------------------------------
+
+// Mul is the product of two or more operands, using wrapping arithmetic.
+type Mul struct {
+	// Operands; constant operands first.
+	Ops []Expr
+}
+
+// String returns the string representation of the expression.
+func (e *Mul) String() string {
+	return joinOps(e.Ops, " * ")
+}
+
+// Type returns the type of the expression.
+func (e *Mul) Type() types.Type {
+	return e.Ops[0].Type()
+}
+
+// --- [ UDiv ] ----------------------------------------------------------------
+
+// UDiv is the unsigned quotient of two operands.
+type UDiv struct {
+	// Dividend and divisor.
+	X, Y Expr
+}
+
+// String returns the string representation of the expression.
+func (e *UDiv) String() string {
+	return fmt.Sprintf("(%s /u %s)", e.X, e.Y)
+}
+
+// Type returns the type of the expression.
+func (e *UDiv) Type() types.Type {
+	return e.X.Type()
+}
+
+// --- [ Max ] -----------------------------------------------------------------
+
+// Max is the maximum of two operands.
+type Max struct {
+	// Operands.
+	X, Y Expr
+	// Signed maximum if true, unsigned maximum otherwise.
+	Signed bool
+}
+
+// String returns the string representation of the expression.
+func (e *Max) String() string {
+	op := "umax"
+	if e.Signed {
+		op = "smax"
+	}
+	return fmt.Sprintf("(%s %s %s)", e.X, op, e.Y)
+}
+
+// Type returns the type of the expression.
+func (e *Max) Type() types.Type {
+	return e.X.Type()
+}
+
+// --- [ AddRec ] --------------------------------------------------------------
+
+// AddRec is an add recurrence {Start,+,Step}<Loop>; i.e. the value Start +
+// i*Step on the i:th iteration of Loop (starting at 0). Start and Step are
+// invariant in Loop.
+type AddRec struct {
+	// Value on the first iteration of the loop.
+	Start Expr
+	// Increment of the value on each iteration of the loop.
+	Step Expr
+	// Loop of the add recurrence.
+	Loop *loop.Loop
+}
+
+// String returns the string representation of the expression.
+func (e *AddRec) String() string {
+	return fmt.Sprintf("{%s,+,%s}<%s>", e.Start, e.Step, e.Loop.Header.Ident())
+}
+
+// Type returns the type of the expression.
+func (e *AddRec) Type() types.Type {
+	return e.Start.Type()
+}
+
+// isExpr ensures that only scalar evolution expressions can be assigned to the
+// scev.Expr interface.
+func (*Constant) isExpr() {}
+func (*Unknown) isExpr()  {}
+func (*Add) isExpr()      {}
+func (*Mul) isExpr()      {}
+func (*UDiv) isExpr()     {}
+func (*Max) isExpr()      {}
+func (*AddRec) isExpr()   {}
+
+// Equal reports whether the expressions x and y are structurally equal, and
+// thus denote the same value.
+func Equal(x, y Expr) bool {
+	if x == y {
+		return true
+	}
+	switch x := x.(type) {
+	case *Constant:
+		y, ok := y.(*Constant)
+		return ok && irutil.ConstEqual(x.X, y.X)
+	case *Unknown:
+		y, ok := y.(*Unknown)
+		return ok && x.Value == y.Value
+	case *Add:
+		y, ok := y.(*Add)
+		return ok && equalOps(x.Ops, y.Ops)
+	case *Mul:
+		y, ok := y.(*Mul)
+		return ok && equalOps(x.Ops, y.Ops)
+	case *UDiv:
+		y, ok := y.(*UDiv)
+		return ok && Equal(x.X, y.X) && Equal(x.Y, y.Y)
+	case *Max:
+		y, ok := y.(*Max)
+		return ok && x.Signed == y.Signed && Equal(x.X, y.X) && Equal(x.Y, y.Y)
+	case *AddRec:
+		y, ok := y.(*AddRec)
+		return ok && x.Loop == y.Loop && Equal(x.Start, y.Start) && Equal(x.Step, y.Step)
+	}
+	return false
+}
+
+// ### [ Helper functions ] ####################################################
+
+// equalOps reports whether the given operands are pairwise equal.
+func equalOps(xs, ys []Expr) bool {
+	if len(xs) != len(ys) {
+		return false
+	}
+	for i := range xs {
+		if !Equal(xs[i], ys[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// joinOps returns the parenthesized string representation of the given
+// operands, separated by sep.
+func joinOps(ops []Expr, sep string) string {
+	ss := make([]string, len(ops))
+	for i, op := range ops {
+		ss[i] = op.String()
+	}
+	return "(" + strings.Join(ss, sep) + ")"
+}
+
+// rank returns the rank of the given expression kind, which determines the
+// canonical order of operands.
+func rank(e Expr) int {
+	switch e.(type) {
+	case *Constant:
+		return 0
+	case *Unknown:
+		return 1
+	case *Mul:
+		return 2
+	case *UDiv:
+		return 3
+	case *Max:
+		return 4
+	case *Add:
+		return 5
+	case *AddRec:
+		return 6
+	}
+	panic(fmt.Errorf("support for scalar evolution expression %T not yet implemented", e))
+}
+
+// less reports whether x is ordered before y in the canonical order of
+// operands.
+func less(x, y Expr) bool {
+	if rx, ry := rank(x), rank(y); rx != ry {
+		return rx < ry
+	}
+	return x.String() < y.String()
+}
+
+// intValue returns the signed value of the given constant expression, and
+// reports whether e is a constant.
+func intValue(e Expr) (*big.Int, bool) {
+	c, ok := e.(*Constant)
+	if !ok {
+		return nil, false
+	}
+	return irutil.Signed(c.X), true
+}
+
+// isConst reports whether e is the integer constant x.
+func isConst(e Expr, x int64) bool {
+	v, ok := intValue(e)
+	return ok && v.Cmp(big.NewInt(x)) == 0
+}
@@ -0,0 +1,270 @@
+package scev
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/llir/llvm/analysis/loop"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/irutil"
+)
+
+// --- [ Canonical expressions ] -----------------------------------------------
+
+// newConst returns a constant expression of the given integer type with the
+// value x, truncated to the bit size of the type.
+func newConst(typ types.Type, x *big.Int) *Constant {
+	return &Constant{X: irutil.NewIntValue(typ.(*types.IntType), x)}
+}
+
+// add returns the canonical sum of the given operands.
+//
+// Nested sums are flattened, constants are folded and like terms are combined
+// by their coefficients (e.g. x + 2*x is 3*x). Operands which are invariant in
+// the loop of an add recurrence are folded into its start value (e.g. 1 +
+// {0,+,1}<L> is {1,+,1}<L>), and add recurrences of the same loop are added
+// component-wise.
+func (info *Info) add(ops ...Expr) Expr {
+	typ := ops[0].Type()
+	var flat []Expr
+	for _, op := range ops {
+		if sum, ok := op.(*Add); ok {
+			flat = append(flat, sum.Ops...)
+		} else {
+			flat = append(flat, op)
+		}
+	}
+	sum := new(big.Int)
+	var terms []Expr
+	var coeffs []*big.Int
+	var recs []*AddRec
+	for _, op := range flat {
+		if x, ok := intValue(op); ok {
+			sum.Add(sum, x)
+			continue
+		}
+		if rec, ok := op.(*AddRec); ok {
+			recs = append(recs, rec)
+			continue
+		}
+		coeff, term := splitCoeff(op)
+		found := false
+		for i, t := range terms {
+			if Equal(t, term) {
+				coeffs[i].Add(coeffs[i], coeff)
+				found = true
+				break
+			}
+		}
+		if !found {
+			terms = append(terms, term)
+			coeffs = append(coeffs, coeff)
+		}
+	}
+	var rest []Expr
+	if c := newConst(typ, sum); c.X.X.Sign() != 0 {
+		rest = append(rest, c)
+	}
+	for i, term := range terms {
+		c := newConst(typ, coeffs[i])
+		if c.X.X.Sign() == 0 {
+			continue
+		}
+		rest = append(rest, info.mul(c, term))
+	}
+	if len(recs) > 0 {
+		// Add the add recurrences of the innermost loop component-wise, and fold
+		// invariant operands into the start value.
+		inner := recs[0].Loop
+		for _, rec := range recs[1:] {
+			if rec.Loop.Depth() > inner.Depth() {
+				inner = rec.Loop
+			}
+		}
+		var starts, steps, variant []Expr
+		for _, rec := range recs {
+			if rec.Loop == inner {
+				starts = append(starts, rec.Start)
+				steps = append(steps, rec.Step)
+			} else {
+				rest = append(rest, rec)
+			}
+		}
+		for _, op := range rest {
+			if info.IsLoopInvariant(op, inner) {
+				starts = append(starts, op)
+			} else {
+				variant = append(variant, op)
+			}
+		}
+		rec := info.addRec(info.add(starts...), info.add(steps...), inner)
+		if _, ok := rec.(*AddRec); !ok {
+			// The steps cancel out.
+			return info.add(append(variant, rec)...)
+		}
+		rest = append(variant, rec)
+	}
+	switch len(rest) {
+	case 0:
+		return newConst(typ, sum)
+	case 1:
+		return rest[0]
+	}
+	sortOps(rest)
+	return &Add{Ops: rest}
+}
+
+// sub returns the canonical difference of x and y.
+func (info *Info) sub(x, y Expr) Expr {
+	return info.add(x, info.neg(y))
+}
+
+// neg returns the canonical negation of x.
+func (info *Info) neg(x Expr) Expr {
+	return info.mul(newConst(x.Type(), big.NewInt(-1)), x)
+}
+
+// mul returns the canonical product of the given operands.
+//
+// Nested products are flattened and constants are folded. Constant factors are
+// distributed over sums and add recurrences, and loop invariant factors are
+// distributed over add recurrences (e.g. 2*{1,+,1}<L> is {2,+,2}<L>).
+func (info *Info) mul(ops ...Expr) Expr {
+	typ := ops[0].Type()
+	var flat []Expr
+	for _, op := range ops {
+		if prod, ok := op.(*Mul); ok {
+			flat = append(flat, prod.Ops...)
+		} else {
+			flat = append(flat, op)
+		}
+	}
+	prod := big.NewInt(1)
+	var rest []Expr
+	for _, op := range flat {
+		if x, ok := intValue(op); ok {
+			prod.Mul(prod, x)
+			continue
+		}
+		rest = append(rest, op)
+	}
+	c := newConst(typ, prod)
+	if c.X.X.Sign() == 0 || len(rest) == 0 {
+		return c
+	}
+	one := isConst(c, 1)
+	if len(rest) == 1 {
+		if one {
+			return rest[0]
+		}
+		switch op := rest[0].(type) {
+		case *Add:
+			var terms []Expr
+			for _, term := range op.Ops {
+				terms = append(terms, info.mul(c, term))
+			}
+			return info.add(terms...)
+		case *AddRec:
+			return info.addRec(info.mul(c, op.Start), info.mul(c, op.Step), op.Loop)
+		}
+	} else {
+		// Distribute loop invariant factors over an add recurrence.
+		var rec *AddRec
+		var factors []Expr
+		for _, op := range rest {
+			if r, ok := op.(*AddRec); ok && rec == nil {
+				rec = r
+				continue
+			}
+			factors = append(factors, op)
+		}
+		if rec != nil {
+			invariant := true
+			for _, factor := range factors {
+				if !info.IsLoopInvariant(factor, rec.Loop) {
+					invariant = false
+					break
+				}
+			}
+			if invariant {
+				factor := info.mul(append(factors, c)...)
+				return info.addRec(info.mul(factor, rec.Start), info.mul(factor, rec.Step), rec.Loop)
+			}
+		}
+	}
+	sortOps(rest)
+	if !one {
+		rest = append([]Expr{c}, rest...)
+	}
+	return &Mul{Ops: rest}
+}
+
+// udiv returns the canonical unsigned quotient of x and y.
+func (info *Info) udiv(x, y Expr) Expr {
+	if isConst(y, 1) || isConst(x, 0) {
+		return x
+	}
+	cx, okx := x.(*Constant)
+	cy, oky := y.(*Constant)
+	if okx && oky && cy.X.X.Sign() != 0 {
+		q := new(big.Int).Quo(irutil.Unsigned(cx.X), irutil.Unsigned(cy.X))
+		return newConst(x.Type(), q)
+	}
+	return &UDiv{X: x, Y: y}
+}
+
+// max returns the canonical signed or unsigned maximum of x and y.
+func (info *Info) max(signed bool, x, y Expr) Expr {
+	if Equal(x, y) {
+		return x
+	}
+	cx, okx := x.(*Constant)
+	cy, oky := y.(*Constant)
+	if okx && oky {
+		var vx, vy *big.Int
+		if signed {
+			vx, vy = irutil.Signed(cx.X), irutil.Signed(cy.X)
+		} else {
+			vx, vy = irutil.Unsigned(cx.X), irutil.Unsigned(cy.X)
+		}
+		if vx.Cmp(vy) >= 0 {
+			return x
+		}
+		return y
+	}
+	if less(y, x) {
+		x, y = y, x
+	}
+	return &Max{X: x, Y: y, Signed: signed}
+}
+
+// addRec returns the canonical add recurrence {start,+,step}<l>.
+func (info *Info) addRec(start, step Expr, l *loop.Loop) Expr {
+	if isConst(step, 0) {
+		return start
+	}
+	return &AddRec{Start: start, Step: step, Loop: l}
+}
+
+// ### [ Helper functions ] ####################################################
+
+// splitCoeff splits the given expression into a constant coefficient and a
+// term (e.g. 2*x is split into 2 and x).
+func splitCoeff(e Expr) (*big.Int, Expr) {
+	if prod, ok := e.(*Mul); ok {
+		if x, ok := intValue(prod.Ops[0]); ok {
+			if len(prod.Ops) == 2 {
+				return x, prod.Ops[1]
+			}
+			return x, &Mul{Ops: prod.Ops[1:]}
+		}
+	}
+	return big.NewInt(1), e
+}
+
+// sortOps sorts the given operands in canonical order.
+func sortOps(ops []Expr) {
+	sort.SliceStable(ops, func(i, j int) bool {
+		return less(ops[i], ops[j])
+	})
+}
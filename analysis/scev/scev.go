@@ -0,0 +1,220 @@
+// Package scev implements scalar evolution analysis of LLVM IR functions.
+//
+// Scalar evolution describes how the integer values of a function evolve over
+// the iterations of its loops. The value of an integer instruction is expressed
+// as a symbolic expression (see Expr) over constants, opaque values (e.g.
+// function parameters and loads) and add recurrences. An add recurrence
+// {start,+,step}<L> is the value start + i*step on the i:th iteration of loop
+// L, and is recognized from phi instructions in loop headers which are
+// incremented by a loop invariant step on the back edge of the loop; e.g. the
+// induction variable %i of
+//
+//	loop:
+//		%i = phi i32 [ 0, %entry ], [ %i.next, %loop ]
+//		%i.next = add i32 %i, 1
+//
+// is {0,+,1}<%loop>, and %i.next is {1,+,1}<%loop>.
+//
+// The number of times the back edge of a loop is taken is computed from the
+// integer comparison controlling the exit of the loop, if the comparison is
+// between an add recurrence of the loop and a loop invariant bound. Trip counts
+// are only computed if the add recurrence is known not to wrap before the loop
+// exits; e.g. based on the nuw and nsw flags of its increment.
+package scev
+
+import (
+	"math/big"
+
+	"github.com/llir/llvm/analysis/loop"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/llir/llvm/irutil"
+)
+
+// Info is the scalar evolution information of a function. Expressions are
+// computed on demand, and cached.
+type Info struct {
+	// Loop nesting forest of the function.
+	loops *loop.Info
+	// Basic block of each instruction and terminator.
+	blockOf map[value.Value]*ir.Block
+	// Cached expression of each value.
+	exprs map[value.Value]Expr
+	// Values in order of caching; used to forget expressions computed in terms
+	// of symbolic phi instructions.
+	trail []value.Value
+}
+
+// NewInfo returns the scalar evolution information of the given function, based
+// on its loop nesting forest.
+func NewInfo(f *ir.Func, loops *loop.Info) *Info {
+	return &Info{
+		loops:   loops,
+		blockOf: irutil.DefBlocks(f),
+		exprs:   make(map[value.Value]Expr),
+	}
+}
+
+// SCEV returns the scalar evolution expression of the given value. Values
+// which are not of integer type, or whose evolution is not analyzable, are
+// represented by *Unknown expressions.
+func (info *Info) SCEV(v value.Value) Expr {
+	if e, ok := info.exprs[v]; ok {
+		return e
+	}
+	e := info.create(v)
+	info.exprs[v] = e
+	info.trail = append(info.trail, v)
+	return e
+}
+
+// IsLoopInvariant reports whether the value of the given expression is the same
+// on every iteration of the loop l.
+func (info *Info) IsLoopInvariant(e Expr, l *loop.Loop) bool {
+	switch e := e.(type) {
+	case *Constant:
+		return true
+	case *Unknown:
+		// Values defined outside of the loop (including parameters and global
+		// values) are invariant.
+		block, ok := info.blockOf[e.Value]
+		return !ok || !l.Contains(block)
+	case *Add:
+		return info.invariantOps(e.Ops, l)
+	case *Mul:
+		return info.invariantOps(e.Ops, l)
+	case *UDiv:
+		return info.IsLoopInvariant(e.X, l) && info.IsLoopInvariant(e.Y, l)
+	case *Max:
+		return info.IsLoopInvariant(e.X, l) && info.IsLoopInvariant(e.Y, l)
+	case *AddRec:
+		// Add recurrences vary in their own loop and in loops containing it.
+		if e.Loop == l || l.Contains(e.Loop.Header) {
+			return false
+		}
+		return info.IsLoopInvariant(e.Start, l) && info.IsLoopInvariant(e.Step, l)
+	}
+	return false
+}
+
+// LoopInvariants returns the integer instructions of the loop l whose value is
+// the same on every iteration of the loop, in order of occurrence.
+func (info *Info) LoopInvariants(l *loop.Loop) []value.Value {
+	var invariants []value.Value
+	for _, block := range l.Blocks {
+		for _, inst := range block.Insts {
+			v, ok := inst.(value.Value)
+			if !ok || !types.IsInt(v.Type()) {
+				continue
+			}
+			if e := info.SCEV(v); info.IsLoopInvariant(e, l) {
+				invariants = append(invariants, v)
+			}
+		}
+	}
+	return invariants
+}
+
+// create returns the scalar evolution expression of the given value.
+func (info *Info) create(v value.Value) Expr {
+	if c, ok := v.(*constant.Int); ok {
+		return newConst(c.Typ, c.X)
+	}
+	typ, ok := v.Type().(*types.IntType)
+	if !ok {
+		return &Unknown{Value: v}
+	}
+	switch v := v.(type) {
+	case *ir.InstAdd:
+		return info.add(info.SCEV(v.X), info.SCEV(v.Y))
+	case *ir.InstSub:
+		return info.sub(info.SCEV(v.X), info.SCEV(v.Y))
+	case *ir.InstMul:
+		return info.mul(info.SCEV(v.X), info.SCEV(v.Y))
+	case *ir.InstShl:
+		// x << c is x * 2^c.
+		if c, ok := v.Y.(*constant.Int); ok && c.X.Sign() >= 0 && c.X.Cmp(big.NewInt(int64(typ.BitSize))) < 0 {
+			pow := new(big.Int).Lsh(big.NewInt(1), uint(c.X.Uint64()))
+			return info.mul(info.SCEV(v.X), newConst(typ, pow))
+		}
+	case *ir.InstUDiv:
+		return info.udiv(info.SCEV(v.X), info.SCEV(v.Y))
+	case *ir.InstPhi:
+		return info.createPhi(v)
+	}
+	return &Unknown{Value: v}
+}
+
+// createPhi returns the scalar evolution expression of the given phi
+// instruction.
+//
+// A phi instruction in the header of a loop, whose value on the back edge is
+// the phi instruction plus a loop invariant step, is the add recurrence
+// {start,+,step}<L> where start is its value on entry of the loop.
+func (info *Info) createPhi(phi *ir.InstPhi) Expr {
+	unknown := &Unknown{Value: phi}
+	block := info.blockOf[phi]
+	l := info.loops.LoopOf(block)
+	if l == nil || l.Header != block || len(phi.Incs) != 2 {
+		return unknown
+	}
+	var init, next value.Value
+	for _, inc := range phi.Incs {
+		pred, ok := inc.Pred.(*ir.Block)
+		if !ok {
+			return unknown
+		}
+		if l.Contains(pred) {
+			next = inc.X
+		} else {
+			init = inc.X
+		}
+	}
+	if init == nil || next == nil {
+		return unknown
+	}
+	start := info.SCEV(init)
+	// Evaluate the value on the back edge with the phi instruction as a
+	// symbolic value, and forget the expressions computed in terms of it.
+	info.exprs[phi] = unknown
+	mark := len(info.trail)
+	backedge := info.SCEV(next)
+	for _, v := range info.trail[mark:] {
+		delete(info.exprs, v)
+	}
+	info.trail = info.trail[:mark]
+	delete(info.exprs, phi)
+	sum, ok := backedge.(*Add)
+	if !ok {
+		return unknown
+	}
+	var steps []Expr
+	found := false
+	for _, op := range sum.Ops {
+		if !found && Equal(op, unknown) {
+			found = true
+			continue
+		}
+		steps = append(steps, op)
+	}
+	if !found {
+		return unknown
+	}
+	step := info.add(steps...)
+	if !info.IsLoopInvariant(step, l) {
+		return unknown
+	}
+	return info.addRec(start, step, l)
+}
+
+// invariantOps reports whether the given operands are invariant in the loop l.
+func (info *Info) invariantOps(ops []Expr, l *loop.Loop) bool {
+	for _, op := range ops {
+		if !info.IsLoopInvariant(op, l) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,183 @@
+package scev
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/llir/llvm/analysis/cfg"
+	"github.com/llir/llvm/analysis/loop"
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/value"
+)
+
+func TestSCEV(t *testing.T) {
+	const src = `
+define void @f(i32 %n, i32 %s) {
+entry:
+	br label %outer
+
+outer:
+	%i = phi i32 [ 0, %entry ], [ %i.next, %outer.latch ]
+	%cmp.i = icmp slt i32 %i, %n
+	br i1 %cmp.i, label %inner.ph, label %exit
+
+inner.ph:
+	%base = mul i32 %i, %n
+	br label %inner
+
+inner:
+	%j = phi i32 [ 10, %inner.ph ], [ %j.next, %inner ]
+	%k = phi i32 [ 0, %inner.ph ], [ %k.next, %inner ]
+	%idx = add i32 %base, %j
+	%x = shl i32 %j, 2
+	%y = sub i32 %x, %j
+	%k.next = add i32 %k, %k
+	%inv = mul i32 %s, %n
+	%j.next = add i32 %j, -2
+	%cmp.j = icmp sgt i32 %j.next, 0
+	br i1 %cmp.j, label %inner, label %outer.latch
+
+outer.latch:
+	%i.next = add nsw i32 %i, 1
+	br label %outer
+
+exit:
+	ret void
+}`
+	m, err := asm.ParseString("scev.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	f := m.Funcs[0]
+	loops := loop.NewInfo(f, cfg.NewDomTree(f))
+	info := NewInfo(f, loops)
+	vals := locals(f)
+	golden := []struct {
+		v    string
+		want string
+	}{
+		{v: "i", want: "{0,+,1}<%outer>"},
+		{v: "i.next", want: "{1,+,1}<%outer>"},
+		{v: "base", want: "{0,+,%n}<%outer>"},
+		{v: "j", want: "{10,+,-2}<%inner>"},
+		{v: "idx", want: "{{10,+,%n}<%outer>,+,-2}<%inner>"},
+		{v: "x", want: "{40,+,-8}<%inner>"},
+		{v: "y", want: "{30,+,-6}<%inner>"},
+		// Not an add recurrence, as the step is not loop invariant.
+		{v: "k", want: "%k"},
+		{v: "k.next", want: "(2 * %k)"},
+		{v: "inv", want: "(%n * %s)"},
+	}
+	for _, g := range golden {
+		if got := info.SCEV(vals[g.v]).String(); got != g.want {
+			t.Errorf("scalar evolution mismatch of %%%s; expected %q, got %q", g.v, g.want, got)
+		}
+	}
+	outer, inner := loops.Loops[0], loops.Loops[0].Children[0]
+	// Trip counts.
+	if got, ok := info.ConstantTripCount(inner); !ok || got != 5 {
+		t.Errorf("trip count mismatch of %%inner; expected 5, got %d (ok: %v)", got, ok)
+	}
+	btc, ok := info.BackedgeTakenCount(outer)
+	if !ok {
+		t.Fatalf("unable to compute backedge-taken count of %%outer")
+	}
+	if got, want := btc.String(), "(0 smax %n)"; got != want {
+		t.Errorf("backedge-taken count mismatch of %%outer; expected %q, got %q", want, got)
+	}
+	if _, ok := info.ConstantTripCount(outer); ok {
+		t.Errorf("expected non-constant trip count of %%outer")
+	}
+	// Loop invariants.
+	var invs []string
+	for _, v := range info.LoopInvariants(inner) {
+		invs = append(invs, v.Ident())
+	}
+	if want := []string{"%inv"}; !reflect.DeepEqual(invs, want) {
+		t.Errorf("loop invariants mismatch of %%inner; expected %q, got %q", want, invs)
+	}
+	if !info.IsLoopInvariant(info.SCEV(vals["base"]), inner) {
+		t.Errorf("expected %%base to be invariant in %%inner")
+	}
+	if info.IsLoopInvariant(info.SCEV(vals["base"]), outer) {
+		t.Errorf("expected %%base to vary in %%outer")
+	}
+}
+
+func TestTripCount(t *testing.T) {
+	golden := []struct {
+		// Type and increment of the induction variable.
+		typ, inc string
+		cond     string
+		// Trip count; or 0 if not computable.
+		want uint64
+	}{
+		// for (i = 0; i != 10; i++)
+		{typ: "i32", inc: "add i32 %i, 1", cond: "icmp ne i32 %i.next, 10", want: 10},
+		// for (i = 0; i < 10; i++), with swapped operands.
+		{typ: "i32", inc: "add i32 %i, 1", cond: "icmp ugt i32 10, %i.next", want: 10},
+		// for (i = 0; i <= 10; i++)
+		{typ: "i32", inc: "add i32 %i, 1", cond: "icmp sle i32 %i.next, 10", want: 11},
+		// for (i = 0; i < 0; i++) executes once, as the loop is rotated.
+		{typ: "i32", inc: "add i32 %i, 1", cond: "icmp slt i32 %i.next, 0", want: 1},
+		// for (i = 0; i < 9; i += 2)
+		{typ: "i8", inc: "add i8 %i, 2", cond: "icmp ult i8 %i.next, 9", want: 5},
+		// for (i = 0; i < 255; i += 2), where i may not wrap.
+		{typ: "i8", inc: "add nuw i8 %i, 2", cond: "icmp ult i8 %i.next, 255", want: 128},
+		// Infinite loop; i <= 255 always holds.
+		{typ: "i8", inc: "add i8 %i, 1", cond: "icmp ule i8 %i, 255", want: 0},
+		// Infinite loop; i.next wraps from 254 to 0.
+		{typ: "i8", inc: "add i8 %i, 2", cond: "icmp ult i8 %i.next, 255", want: 0},
+		// Infinite loop; i >= -128 always holds.
+		{typ: "i8", inc: "add i8 %i, -1", cond: "icmp sge i8 %i, -128", want: 0},
+		// Signed comparison of an increment without nsw.
+		{typ: "i8", inc: "add nuw i8 %i, 2", cond: "icmp slt i8 %i.next, 127", want: 0},
+	}
+	for _, g := range golden {
+		src := `
+define void @f() {
+entry:
+	br label %loop
+
+loop:
+	%i = phi ` + g.typ + ` [ 0, %entry ], [ %i.next, %loop ]
+	%i.next = ` + g.inc + `
+	%cond = ` + g.cond + `
+	br i1 %cond, label %loop, label %exit
+
+exit:
+	ret void
+}`
+		m, err := asm.ParseString("trip.ll", src)
+		if err != nil {
+			t.Fatalf("unable to parse module; %+v", err)
+		}
+		f := m.Funcs[0]
+		loops := loop.NewInfo(f, cfg.NewDomTree(f))
+		info := NewInfo(f, loops)
+		got, ok := info.ConstantTripCount(loops.Loops[0])
+		if g.want == 0 {
+			if ok {
+				t.Errorf("expected trip count of %q (%q) not to be computable, got %d", g.cond, g.inc, got)
+			}
+			continue
+		}
+		if !ok || got != g.want {
+			t.Errorf("trip count mismatch of %q (%q); expected %d, got %d (ok: %v)", g.cond, g.inc, g.want, got, ok)
+		}
+	}
+}
+
+// locals returns the named instructions of f.
+func locals(f *ir.Func) map[string]value.Value {
+	vals := make(map[string]value.Value)
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			if v, ok := inst.(value.Named); ok {
+				vals[v.Name()] = v
+			}
+		}
+	}
+	return vals
+}
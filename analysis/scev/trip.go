@@ -0,0 +1,256 @@
+package scev
+
+import (
+	"math/big"
+
+	"github.com/llir/llvm/analysis/loop"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/llir/llvm/irutil"
+)
+
+// --- [ Trip counts ] ---------------------------------------------------------
+
+// BackedgeTakenCount returns the number of times the back edge of the loop l is
+// taken before the loop exits, and reports whether the count is computable.
+//
+// The count is computable if the loop has a single latch and a single exiting
+// basic block, which is either the header or the latch, and the exit is
+// controlled by an integer comparison between an add recurrence of the loop
+// with a constant step and a loop invariant bound.
+func (info *Info) BackedgeTakenCount(l *loop.Loop) (Expr, bool) {
+	latches := l.Latches()
+	exiting := l.ExitingBlocks()
+	if len(latches) != 1 || len(exiting) != 1 {
+		return nil, false
+	}
+	block := exiting[0]
+	if block != l.Header && block != latches[0] {
+		return nil, false
+	}
+	term, ok := block.Term.(*ir.TermCondBr)
+	if !ok {
+		return nil, false
+	}
+	cond, ok := term.Cond.(*ir.InstICmp)
+	if !ok {
+		return nil, false
+	}
+	// Normalize the comparison to the condition for staying in the loop.
+	pred := cond.Pred
+	targetTrue, ok1 := term.TargetTrue.(*ir.Block)
+	targetFalse, ok2 := term.TargetFalse.(*ir.Block)
+	if !ok1 || !ok2 {
+		return nil, false
+	}
+	switch {
+	case l.Contains(targetTrue) && !l.Contains(targetFalse):
+	case !l.Contains(targetTrue) && l.Contains(targetFalse):
+		pred = irutil.InverseIPred(pred)
+	default:
+		return nil, false
+	}
+	xv := cond.X
+	x, y := info.SCEV(cond.X), info.SCEV(cond.Y)
+	if rec, ok := y.(*AddRec); ok && rec.Loop == l {
+		xv = cond.Y
+		x, y = y, x
+		pred = irutil.SwapIPred(pred)
+	}
+	rec, ok := x.(*AddRec)
+	if !ok || rec.Loop != l || !info.IsLoopInvariant(y, l) {
+		return nil, false
+	}
+	return info.countIterations(rec, pred, y, incFlags(xv, l))
+}
+
+// TripCount returns the number of times the header of the loop l is executed
+// (i.e. the backedge-taken count plus one), and reports whether the count is
+// computable.
+func (info *Info) TripCount(l *loop.Loop) (Expr, bool) {
+	btc, ok := info.BackedgeTakenCount(l)
+	if !ok {
+		return nil, false
+	}
+	return info.add(btc, newConst(btc.Type(), big.NewInt(1))), true
+}
+
+// ConstantTripCount returns the trip count of the loop l (see TripCount), and
+// reports whether the trip count is a known constant.
+func (info *Info) ConstantTripCount(l *loop.Loop) (uint64, bool) {
+	tc, ok := info.TripCount(l)
+	if !ok {
+		return 0, false
+	}
+	c, ok := tc.(*Constant)
+	if !ok {
+		return 0, false
+	}
+	n := irutil.Unsigned(c.X)
+	if !n.IsUint64() {
+		return 0, false
+	}
+	return n.Uint64(), true
+}
+
+// countIterations returns the number of iterations i (starting at 0) for which
+// the condition rec(i) pred bound holds, before it first fails, and reports
+// whether the count is computable. The overflow flags of the increment of the
+// add recurrence are given by flags.
+//
+// The count is only computable if the add recurrence is known not to wrap
+// before the condition fails; i.e. if the bound of a non-strict comparison is
+// below the maximum (or above the minimum) value of the type, and if the
+// increment of an add recurrence with a step other than 1 or -1 has the no wrap
+// flag of the signedness of the comparison, or a constant bound leaves room for
+// one more step.
+func (info *Info) countIterations(rec *AddRec, pred enum.IPred, bound Expr, flags []enum.OverflowFlag) (Expr, bool) {
+	step, ok := intValue(rec.Step)
+	if !ok {
+		return nil, false
+	}
+	typ := rec.Type()
+	one := newConst(typ, big.NewInt(1))
+	signed := irutil.IsSignedIPred(pred)
+	noWrap := hasOverflowFlag(flags, enum.OverflowFlagNUW)
+	if signed {
+		noWrap = hasOverflowFlag(flags, enum.OverflowFlagNSW)
+	}
+	min, max := typeRange(typ, signed)
+	switch pred {
+	case enum.IPredNE:
+		// Count until the bound is reached exactly.
+		switch {
+		case isConst(rec.Step, 1):
+			return info.sub(bound, rec.Start), true
+		case isConst(rec.Step, -1):
+			return info.sub(rec.Start, bound), true
+		}
+	case enum.IPredULT, enum.IPredSLT, enum.IPredULE, enum.IPredSLE:
+		// Increasing add recurrence; (max(bound, start) - start + step-1) / step.
+		if step.Sign() <= 0 {
+			return nil, false
+		}
+		if pred == enum.IPredULE || pred == enum.IPredSLE {
+			// rec <= bound always holds if bound is the maximum value.
+			b, ok := constValue(bound, signed)
+			if !ok || b.Cmp(max) >= 0 {
+				return nil, false
+			}
+			bound = info.add(bound, one)
+		}
+		// The first value of rec not below bound is at most bound+step-1, which
+		// must not wrap.
+		if step.Cmp(big.NewInt(1)) != 0 && !noWrap {
+			b, ok := constValue(bound, signed)
+			room := new(big.Int).Sub(max, step)
+			if !ok || b.Cmp(room.Add(room, big.NewInt(1))) > 0 {
+				return nil, false
+			}
+		}
+		dist := info.sub(info.max(signed, bound, rec.Start), rec.Start)
+		return info.ceilDiv(dist, step)
+	case enum.IPredUGT, enum.IPredSGT, enum.IPredUGE, enum.IPredSGE:
+		// Decreasing add recurrence; (max(start, bound) - bound + |step|-1) /
+		// |step|.
+		if step.Sign() >= 0 {
+			return nil, false
+		}
+		if pred == enum.IPredUGE || pred == enum.IPredSGE {
+			// rec >= bound always holds if bound is the minimum value.
+			b, ok := constValue(bound, signed)
+			if !ok || b.Cmp(min) <= 0 {
+				return nil, false
+			}
+			bound = info.sub(bound, one)
+		}
+		// The first value of rec not above bound is at least bound-|step|+1,
+		// which must not wrap.
+		if step.Cmp(big.NewInt(-1)) != 0 && !noWrap {
+			b, ok := constValue(bound, signed)
+			room := new(big.Int).Sub(min, step)
+			if !ok || b.Cmp(room.Sub(room, big.NewInt(1))) < 0 {
+				return nil, false
+			}
+		}
+		dist := info.sub(info.max(signed, rec.Start, bound), bound)
+		return info.ceilDiv(dist, new(big.Int).Neg(step))
+	}
+	return nil, false
+}
+
+// ceilDiv returns the unsigned quotient of x and the positive constant y,
+// rounded up, and reports whether the quotient is computable; i.e. if x is
+// constant or if y is 1, as x+y-1 may wrap otherwise.
+func (info *Info) ceilDiv(x Expr, y *big.Int) (Expr, bool) {
+	typ := x.Type()
+	if y.Cmp(big.NewInt(1)) == 0 {
+		return x, true
+	}
+	c, ok := x.(*Constant)
+	if !ok {
+		return nil, false
+	}
+	q, r := new(big.Int).QuoRem(irutil.Unsigned(c.X), y, new(big.Int))
+	if r.Sign() != 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	return newConst(typ, q), true
+}
+
+// ### [ Helper functions ] ####################################################
+
+// incFlags returns the overflow flags of the increment of the add recurrence v
+// of the loop l; i.e. of v itself if an add instruction, or of the value of v on
+// the back edge if a phi instruction.
+func incFlags(v value.Value, l *loop.Loop) []enum.OverflowFlag {
+	if phi, ok := v.(*ir.InstPhi); ok {
+		for _, inc := range phi.Incs {
+			if pred, ok := inc.Pred.(*ir.Block); ok && l.Contains(pred) {
+				v = inc.X
+			}
+		}
+	}
+	if add, ok := v.(*ir.InstAdd); ok {
+		return add.OverflowFlags
+	}
+	return nil
+}
+
+// hasOverflowFlag reports whether flags contains the given overflow flag.
+func hasOverflowFlag(flags []enum.OverflowFlag, flag enum.OverflowFlag) bool {
+	for _, f := range flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// constValue returns the signed or unsigned value of the given constant
+// expression, and reports whether e is a constant.
+func constValue(e Expr, signed bool) (*big.Int, bool) {
+	c, ok := e.(*Constant)
+	if !ok {
+		return nil, false
+	}
+	if signed {
+		return irutil.Signed(c.X), true
+	}
+	return irutil.Unsigned(c.X), true
+}
+
+// typeRange returns the minimum and maximum signed or unsigned value of the
+// given integer type.
+func typeRange(typ types.Type, signed bool) (min, max *big.Int) {
+	n := uint(typ.(*types.IntType).BitSize)
+	if signed {
+		max = new(big.Int).Lsh(big.NewInt(1), n-1)
+		min = new(big.Int).Neg(max)
+		return min, max.Sub(max, big.NewInt(1))
+	}
+	max = new(big.Int).Lsh(big.NewInt(1), n)
+	return big.NewInt(0), max.Sub(max, big.NewInt(1))
+}
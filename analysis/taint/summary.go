@@ -0,0 +1,196 @@
+package taint
+
+import (
+	"github.com/llir/llvm/analysis/pointsto"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/metadata"
+	"github.com/llir/llvm/ir/value"
+)
+
+// --- [ Function summaries ] --------------------------------------------------
+
+// summary is the summary of a function definition, describing the flow of taint
+// from its parameters.
+type summary struct {
+	// Taint labels of the return value; symbolic labels of parameters flowing to
+	// the return value, and concrete labels originating in the function.
+	ret *labelSet
+	// Abstract memory locations tainted by each parameter.
+	stores map[int]*effectSet
+	// Sinks reached by each parameter.
+	sinks map[int]*effectSet
+}
+
+// sinkKey identifies a sink argument of a call site.
+type sinkKey struct {
+	// Sink call site.
+	site value.Value
+	// Sink of the call site.
+	sink Endpoint
+}
+
+// effect is a memory location tainted by, or a sink reached by, a parameter.
+type effect struct {
+	// Tainted abstract memory location.
+	loc pointsto.Location
+	// Reached sink.
+	key sinkKey
+	// Trace from the parameter to the effect.
+	trace *trace
+}
+
+// effectSet is an ordered set of effects.
+type effectSet struct {
+	// Effects in order of addition.
+	list []effect
+	// Index of each effect, without trace.
+	index map[effect]bool
+}
+
+// add adds the given effect to the set, and reports whether the set has
+// changed. The first trace of an effect is kept.
+func (s *effectSet) add(e effect) bool {
+	if s.index == nil {
+		s.index = make(map[effect]bool)
+	}
+	k := e
+	k.trace = nil
+	if s.index[k] {
+		return false
+	}
+	s.index[k] = true
+	s.list = append(s.list, e)
+	return true
+}
+
+// effects returns the effects of the set, in order of addition.
+func (s *effectSet) effects() []effect {
+	if s == nil {
+		return nil
+	}
+	return s.list
+}
+
+// --- [ Taint label sets ] ----------------------------------------------------
+
+// labelSet is an ordered set of taint labels, each with the trace through which
+// it was first propagated.
+type labelSet struct {
+	// Labels in order of addition.
+	list []label
+	// Trace of each label.
+	traces map[label]*trace
+}
+
+// add adds the given taint label with trace t to the set, and reports whether
+// the set has changed. The first trace of a label is kept.
+func (s *labelSet) add(l label, t *trace) bool {
+	if s.traces == nil {
+		s.traces = make(map[label]*trace)
+	}
+	if _, ok := s.traces[l]; ok {
+		return false
+	}
+	s.traces[l] = t
+	s.list = append(s.list, l)
+	return true
+}
+
+// has reports whether the set contains the given taint label.
+func (s *labelSet) has(l label) bool {
+	if s == nil {
+		return false
+	}
+	_, ok := s.traces[l]
+	return ok
+}
+
+// trace returns the trace of the given taint label.
+func (s *labelSet) trace(l label) *trace {
+	if s == nil {
+		return nil
+	}
+	return s.traces[l]
+}
+
+// labels returns the taint labels of the set, in order of addition.
+func (s *labelSet) labels() []label {
+	if s == nil {
+		return nil
+	}
+	return s.list
+}
+
+// union returns the union of the sets s and t, which are left unmodified; the
+// traces of s take precedence. Either set may be nil, and the result may be
+// either of the sets if the other is empty.
+func (s *labelSet) union(t *labelSet) *labelSet {
+	switch {
+	case len(t.labels()) == 0:
+		return s
+	case len(s.labels()) == 0:
+		return t
+	}
+	u := &labelSet{}
+	for _, l := range s.list {
+		u.add(l, s.traces[l])
+	}
+	for _, l := range t.list {
+		u.add(l, t.traces[l])
+	}
+	return u
+}
+
+// --- [ Traces ] --------------------------------------------------------------
+
+// trace is an immutable backward linked list of instructions propagating taint.
+type trace struct {
+	// Instruction or terminator propagating taint.
+	inst value.User
+	// Function containing the instruction.
+	f *ir.Func
+	// Preceding trace; or nil if the trace starts at a source call or at a
+	// parameter.
+	prev *trace
+}
+
+// after returns the trace t, prefixed by the given trace; i.e. the trace of a
+// callee starting at a parameter is re-rooted at the trace of the argument in
+// the caller.
+func (t *trace) after(prefix *trace) *trace {
+	if t == nil {
+		return prefix
+	}
+	return &trace{inst: t.inst, f: t.f, prev: t.prev.after(prefix)}
+}
+
+// steps returns the steps of the trace, in order of propagation.
+func (t *trace) steps() []Step {
+	var steps []Step
+	for ; t != nil; t = t.prev {
+		steps = append(steps, Step{Inst: t.inst, Func: t.f, Loc: location(t.inst)})
+	}
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+	return steps
+}
+
+// location returns the debug location of the given instruction or terminator,
+// as specified by its !dbg metadata attachment; or nil if not present.
+func location(inst value.User) *metadata.DILocation {
+	md, ok := inst.(interface {
+		MDAttachments() []*metadata.Attachment
+	})
+	if !ok {
+		return nil
+	}
+	for _, a := range md.MDAttachments() {
+		if a.Name == "dbg" {
+			if loc, ok := a.Node.(*metadata.DILocation); ok {
+				return loc
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,655 @@
+// Package taint implements interprocedural taint analysis of LLVM IR modules.
+//
+// The analysis tracks untrusted data from sources to sinks, both of which are
+// given by function name and argument index (or return value); e.g. the data
+// received into the buffer of argument 1 of recv is tainted, and tainted data
+// must not reach argument 0 of system.
+//
+// Taint is propagated from the operands of instructions to their results, and
+// through memory based on points-to information (see package pointsto); a store
+// of a tainted value taints the abstract memory locations the destination may
+// point to, and a load from a tainted memory location is tainted. For a source
+// argument, every abstract memory location the argument may point to is
+// tainted. A sink argument is reached if its value is tainted or if it points
+// to tainted memory. Calls to external functions, other than sources and sinks,
+// propagate the taint of their arguments (and the memory they point to) to
+// their result, and calls to memcpy and memmove propagate the taint of the
+// source memory to the destination memory.
+//
+// Calls to defined functions are handled context-sensitively using function
+// summaries, which describe the flow of taint from each parameter to the return
+// value, to memory and to sinks. Summaries are computed bottom-up over the call
+// graph, iterating to a fixed point for recursive functions. A function called
+// with an untainted argument thus does not taint the result of other calls to
+// the same function.
+//
+// Note, taint through memory is based on context-insensitive points-to
+// information. A pointer returned by a function may therefore point to the
+// memory of the arguments of any call to the function; e.g. the result of
+// @id(i8* %s), where @id returns its argument, points to tainted memory if @id
+// is called elsewhere with a pointer to tainted memory, even though the memory
+// of %s is untainted.
+//
+// Each finding reports a path from a source call to a sink call, as the
+// sequence of instructions propagating the taint, with the debug locations of
+// their !dbg metadata attachments.
+package taint
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/llir/llvm/analysis/callgraph"
+	"github.com/llir/llvm/analysis/pointsto"
+	"github.com/llir/llvm/intrinsics"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/metadata"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/llir/llvm/irutil"
+)
+
+// Return is the index of the return value of a source or sink function.
+const Return = -1
+
+// Endpoint is a source or sink of taint, given by a function name and an
+// argument index.
+type Endpoint struct {
+	// Function name (without '@' prefix).
+	Func string
+	// Argument index; or Return for the return value.
+	Index int
+}
+
+// String returns the string representation of the endpoint; e.g. "recv(arg 1)"
+// or "getenv(ret)".
+func (e Endpoint) String() string {
+	if e.Index == Return {
+		return fmt.Sprintf("%s(ret)", e.Func)
+	}
+	return fmt.Sprintf("%s(arg %d)", e.Func, e.Index)
+}
+
+// Config is the configuration of the taint analysis.
+type Config struct {
+	// Sources of taint. For argument sources, the memory the argument points to
+	// is tainted by the call.
+	Sources []Endpoint
+	// Sinks of taint.
+	Sinks []Endpoint
+	// (optional) Points-to analysis result of the module; computed using the
+	// default configuration if nil.
+	PointsTo *pointsto.Result
+}
+
+// Finding is a path from a source to a sink of taint.
+type Finding struct {
+	// Source and sink of the path.
+	Source, Sink Endpoint
+	// Instructions propagating the taint, from the source call to the sink call.
+	Path []Step
+}
+
+// String returns the string representation of the finding.
+func (f *Finding) String() string {
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "%s -> %s", f.Source, f.Sink)
+	for _, step := range f.Path {
+		fmt.Fprintf(buf, "\n\t%s", step)
+	}
+	return buf.String()
+}
+
+// Step is a step of a taint path.
+type Step struct {
+	// Instruction or terminator of the step (ir.Instruction or ir.Terminator).
+	Inst value.User
+	// Function containing the instruction.
+	Func *ir.Func
+	// (optional) Debug location of the instruction; or nil if not present.
+	Loc *metadata.DILocation
+}
+
+// String returns the string representation of the step; e.g.
+// "foo.c:12:7: %1 = call i32 @recv(...)".
+func (s Step) String() string {
+	inst := s.Inst.(ir.LLStringer).LLString()
+	if s.Loc == nil {
+		return fmt.Sprintf("%s: %s", s.Func.Ident(), inst)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", filename(s.Loc.Scope), s.Loc.Line, s.Loc.Column, inst)
+}
+
+// Analyze performs the taint analysis of the given module, and returns the
+// paths from sources to sinks, in order of discovery.
+func Analyze(m *ir.Module, config *Config) []*Finding {
+	if config == nil {
+		config = &Config{}
+	}
+	pts := config.PointsTo
+	if pts == nil {
+		pts = pointsto.Analyze(m, nil)
+	}
+	a := &analyzer{
+		config:    config,
+		pts:       pts,
+		facts:     make(map[value.Value]*labelSet),
+		mem:       make(map[pointsto.Location]*labelSet),
+		offsets:   make(map[*pointsto.Object][]int64),
+		summaries: make(map[*ir.Func]*summary),
+		origins:   make(map[value.Value]Endpoint),
+		reported:  make(map[findingKey]bool),
+	}
+	var funcs []*ir.Func
+	g := callgraph.NewWithResolver(m, pts.Resolver())
+	for _, n := range g.BottomUp() {
+		if len(n.Func.Blocks) > 0 {
+			funcs = append(funcs, n.Func)
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, f := range funcs {
+			if a.analyzeFunc(f) {
+				changed = true
+			}
+		}
+	}
+	return a.findings
+}
+
+// === [ Analyzer ] ============================================================
+
+// analyzer is the state of a taint analysis.
+type analyzer struct {
+	// Configuration of the analysis.
+	config *Config
+	// Points-to analysis result of the module.
+	pts *pointsto.Result
+	// Taint labels of each value.
+	facts map[value.Value]*labelSet
+	// Concrete taint labels of each abstract memory location.
+	mem map[pointsto.Location]*labelSet
+	// Tainted offsets of each abstract object, in order of tainting.
+	offsets map[*pointsto.Object][]int64
+	// Summary of each function definition.
+	summaries map[*ir.Func]*summary
+	// Source of each source call site.
+	origins map[value.Value]Endpoint
+	// Findings, in order of discovery.
+	findings []*Finding
+	// Reported findings.
+	reported map[findingKey]bool
+}
+
+// label is a taint label.
+type label struct {
+	// Parameter index of symbolic taint, originating from a parameter of the
+	// function being analyzed; or -1 for concrete taint.
+	param int
+	// Source call site of concrete taint.
+	origin value.Value
+}
+
+// isConcrete reports whether the label is a concrete taint label.
+func (l label) isConcrete() bool {
+	return l.param < 0
+}
+
+// findingKey identifies a finding by its source and sink.
+type findingKey struct {
+	// Source call site.
+	origin value.Value
+	// Sink call site and argument index.
+	site value.Value
+	arg  int
+}
+
+// analyzeFunc propagates taint through the instructions of the given function
+// until a fixed point is reached, and reports whether any summary or taint of
+// memory has changed.
+func (a *analyzer) analyzeFunc(f *ir.Func) bool {
+	changed := false
+	for i, param := range f.Params {
+		a.add(param, label{param: i}, nil)
+	}
+	for local := true; local; {
+		local = false
+		for _, block := range f.Blocks {
+			for _, inst := range block.Insts {
+				l, g := a.analyzeInst(f, inst)
+				local = local || l
+				changed = changed || g
+			}
+			l, g := a.analyzeTerm(f, block.Term)
+			local = local || l
+			changed = changed || g
+		}
+	}
+	return changed
+}
+
+// analyzeInst propagates taint through the given instruction, and reports
+// whether the taint of any local value and any summary or taint of memory has
+// changed respectively.
+func (a *analyzer) analyzeInst(f *ir.Func, inst ir.Instruction) (local, global bool) {
+	switch inst := inst.(type) {
+	case *ir.InstStore:
+		return false, a.store(f, inst, inst.Src, a.pointsTo(inst.Dst), a.labels(inst.Src))
+	case *ir.InstLoad:
+		// Both the address and the loaded memory taint the result.
+		ls := a.labels(inst.Src).union(a.derefLabels(f, inst.Src))
+		return a.propagate(f, inst, inst, ls), false
+	case *ir.InstCall:
+		return a.call(f, inst, inst.Callee, inst.Args)
+	}
+	v, ok := inst.(value.Value)
+	if !ok {
+		return false, false
+	}
+	var ls *labelSet
+	for _, op := range inst.Operands() {
+		ls = ls.union(a.labels(*op))
+	}
+	return a.propagate(f, inst, v, ls), false
+}
+
+// analyzeTerm propagates taint through the given terminator, and reports whether
+// the taint of any local value and any summary or taint of memory has changed
+// respectively.
+func (a *analyzer) analyzeTerm(f *ir.Func, term ir.Terminator) (local, global bool) {
+	switch term := term.(type) {
+	case *ir.TermRet:
+		if term.X == nil {
+			return false, false
+		}
+		s := a.summary(f)
+		ls := a.labels(term.X)
+		for _, l := range ls.labels() {
+			if s.ret.add(l, &trace{inst: term, f: f, prev: ls.trace(l)}) {
+				global = true
+			}
+		}
+		return false, global
+	case *ir.TermInvoke:
+		return a.call(f, term, term.Invokee, term.Args)
+	case *ir.TermCallBr:
+		return a.call(f, term, term.Callee, term.Args)
+	}
+	return false, false
+}
+
+// call propagates taint through the given call site, and reports whether the
+// taint of any local value and any summary or taint of memory has changed
+// respectively.
+func (a *analyzer) call(f *ir.Func, site value.Value, callee value.Value, args []value.Value) (local, global bool) {
+	inst := site.(value.User)
+	callees := a.pts.Callees(site)
+	if len(callees) == 0 {
+		if c := irutil.Callee(callee); c != nil {
+			callees = []*ir.Func{c}
+		}
+	}
+	for _, c := range callees {
+		name := c.Name()
+		special := false
+		// Sources.
+		for _, src := range a.config.Sources {
+			if src.Func != name {
+				continue
+			}
+			special = true
+			if _, ok := a.origins[site]; !ok {
+				a.origins[site] = src
+			}
+			l := label{param: -1, origin: site}
+			t := &trace{inst: inst, f: f}
+			switch {
+			case src.Index == Return:
+				if a.add(site, l, t) {
+					local = true
+				}
+			case src.Index < len(args):
+				for _, loc := range a.pointsTo(args[src.Index]) {
+					// Taint the entire object.
+					loc.Offset = pointsto.UnknownOffset
+					if a.taintMem(loc, l, t) {
+						global = true
+					}
+				}
+			}
+		}
+		// Sinks.
+		for _, sink := range a.config.Sinks {
+			if sink.Func != name || sink.Index == Return || sink.Index >= len(args) {
+				continue
+			}
+			special = true
+			arg := args[sink.Index]
+			ls := a.labels(arg).union(a.derefLabels(f, arg))
+			for _, l := range ls.labels() {
+				t := &trace{inst: inst, f: f, prev: ls.trace(l)}
+				if a.sink(f, l, t, sinkKey{site: site, sink: sink}) {
+					global = true
+				}
+			}
+		}
+		switch {
+		case len(c.Blocks) > 0:
+			l, g := a.applySummary(f, site, c, args)
+			local, global = local || l, global || g
+		case special:
+		case isMemcpy(c) && len(args) >= 2:
+			ls := a.derefLabels(f, args[1])
+			if a.store(f, inst, nil, a.pointsTo(args[0]), ls) {
+				global = true
+			}
+		default:
+			// External function; the result depends on the arguments.
+			if isVoid(site) {
+				continue
+			}
+			var ls *labelSet
+			for _, arg := range args {
+				ls = ls.union(a.labels(arg)).union(a.derefLabels(f, arg))
+			}
+			if a.propagate(f, inst, site, ls) {
+				local = true
+			}
+		}
+	}
+	return local, global
+}
+
+// applySummary applies the summary of the callee to the given call site, and
+// reports whether the taint of any local value and any summary or taint of
+// memory has changed respectively.
+func (a *analyzer) applySummary(f *ir.Func, site value.Value, callee *ir.Func, args []value.Value) (local, global bool) {
+	inst := site.(value.User)
+	s := a.summary(callee)
+	// Concrete taint of the return value, originating in the callee.
+	for _, l := range s.ret.labels() {
+		if l.isConcrete() && !isVoid(site) {
+			if a.add(site, l, &trace{inst: inst, f: f, prev: s.ret.trace(l)}) {
+				local = true
+			}
+		}
+	}
+	for j, arg := range args {
+		if j >= len(callee.Params) {
+			break
+		}
+		ls := a.labels(arg)
+		for _, l := range ls.labels() {
+			// The trace of the argument, entering the callee at the call site.
+			prefix := &trace{inst: inst, f: f, prev: ls.trace(l)}
+			param := label{param: j}
+			if t := s.ret.trace(param); s.ret.has(param) && !isVoid(site) {
+				// The trace through the callee, returning at the call site.
+				if a.add(site, l, &trace{inst: inst, f: f, prev: t.after(ls.trace(l))}) {
+					local = true
+				}
+			}
+			for _, e := range s.stores[j].effects() {
+				if a.storeLabel(f, l, e.loc, e.trace.after(prefix)) {
+					global = true
+				}
+			}
+			for _, e := range s.sinks[j].effects() {
+				if a.sink(f, l, e.trace.after(prefix), e.key) {
+					global = true
+				}
+			}
+		}
+	}
+	return local, global
+}
+
+// store taints the given abstract memory locations with the given taint labels
+// of a store (or memory copy) instruction, and reports whether any summary or
+// taint of memory has changed.
+func (a *analyzer) store(f *ir.Func, inst value.User, src value.Value, locs []pointsto.Location, ls *labelSet) bool {
+	changed := false
+	for _, l := range ls.labels() {
+		t := &trace{inst: inst, f: f, prev: ls.trace(l)}
+		for _, loc := range locs {
+			if a.storeLabel(f, l, loc, t) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// storeLabel taints the given abstract memory location with the given taint
+// label in the function f; symbolic taint is recorded in the summary of f. The
+// boolean return value reports whether any summary or taint of memory has
+// changed.
+func (a *analyzer) storeLabel(f *ir.Func, l label, loc pointsto.Location, t *trace) bool {
+	if l.isConcrete() {
+		return a.taintMem(loc, l, t)
+	}
+	s := a.summary(f)
+	if s.stores[l.param] == nil {
+		s.stores[l.param] = &effectSet{}
+	}
+	return s.stores[l.param].add(effect{loc: loc, trace: t})
+}
+
+// sink records that the given taint label reaches a sink in the function f;
+// concrete taint is reported as a finding, and symbolic taint is recorded in
+// the summary of f. The boolean return value reports whether any summary has
+// changed.
+func (a *analyzer) sink(f *ir.Func, l label, t *trace, key sinkKey) bool {
+	if l.isConcrete() {
+		fk := findingKey{origin: l.origin, site: key.site, arg: key.sink.Index}
+		if !a.reported[fk] {
+			a.reported[fk] = true
+			a.findings = append(a.findings, &Finding{
+				Source: a.origins[l.origin],
+				Sink:   key.sink,
+				Path:   t.steps(),
+			})
+		}
+		return false
+	}
+	s := a.summary(f)
+	if s.sinks[l.param] == nil {
+		s.sinks[l.param] = &effectSet{}
+	}
+	return s.sinks[l.param].add(effect{key: key, trace: t})
+}
+
+// propagate adds the given taint labels to the result v of the given
+// instruction, and reports whether the taint of v has changed.
+func (a *analyzer) propagate(f *ir.Func, inst value.User, v value.Value, ls *labelSet) bool {
+	changed := false
+	for _, l := range ls.labels() {
+		if a.add(v, l, &trace{inst: inst, f: f, prev: ls.trace(l)}) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// add adds the given taint label to the value v, and reports whether the taint
+// of v has changed.
+func (a *analyzer) add(v value.Value, l label, t *trace) bool {
+	ls, ok := a.facts[v]
+	if !ok {
+		ls = &labelSet{}
+		a.facts[v] = ls
+	}
+	return ls.add(l, t)
+}
+
+// labels returns the taint labels of the value v.
+func (a *analyzer) labels(v value.Value) *labelSet {
+	return a.facts[v]
+}
+
+// taintMem adds the given concrete taint label to the abstract memory location
+// loc, and reports whether the taint of memory has changed.
+func (a *analyzer) taintMem(loc pointsto.Location, l label, t *trace) bool {
+	ls, ok := a.mem[loc]
+	if !ok {
+		ls = &labelSet{}
+		a.mem[loc] = ls
+		a.offsets[loc.Object] = append(a.offsets[loc.Object], loc.Offset)
+	}
+	return ls.add(l, t)
+}
+
+// memLabels returns the concrete taint labels of the given abstract memory
+// locations.
+func (a *analyzer) memLabels(locs []pointsto.Location) *labelSet {
+	var ls *labelSet
+	for _, loc := range locs {
+		for _, offset := range a.offsets[loc.Object] {
+			if offset != loc.Offset && offset != pointsto.UnknownOffset && loc.Offset != pointsto.UnknownOffset {
+				continue
+			}
+			ls = ls.union(a.mem[pointsto.Location{Object: loc.Object, Offset: offset}])
+		}
+	}
+	return ls
+}
+
+// derefLabels returns the concrete taint labels of the memory the pointer ptr
+// of the function f may point to.
+//
+// If ptr is based on the result of a call site (e.g. a pointer returned by a
+// function), the traces of the labels pass through the call site; and through
+// the callee, from an argument pointing to the tainted memory to the return
+// value, if the callee is defined.
+func (a *analyzer) derefLabels(f *ir.Func, ptr value.Value) *labelSet {
+	ls := a.memLabels(a.pointsTo(ptr))
+	site, callee, args := callBase(ptr)
+	if site == nil || len(ls.labels()) == 0 {
+		return ls
+	}
+	callees := a.pts.Callees(site)
+	if len(callees) == 0 {
+		if c := irutil.Callee(callee); c != nil {
+			callees = []*ir.Func{c}
+		}
+	}
+	res := &labelSet{}
+	for _, l := range ls.labels() {
+		t := ls.trace(l)
+	outer:
+		for _, c := range callees {
+			if len(c.Blocks) == 0 {
+				continue
+			}
+			s := a.summary(c)
+			for j, arg := range args {
+				param := label{param: j}
+				if j >= len(c.Params) || !s.ret.has(param) {
+					continue
+				}
+				if argLabels := a.memLabels(a.pointsTo(arg)); argLabels.has(l) {
+					t = s.ret.trace(param).after(argLabels.trace(l))
+					break outer
+				}
+			}
+		}
+		res.add(l, &trace{inst: site.(value.User), f: f, prev: t})
+	}
+	return res
+}
+
+// pointsTo returns the abstract memory locations the given pointer may point
+// to.
+func (a *analyzer) pointsTo(ptr value.Value) []pointsto.Location {
+	return a.pts.PointsTo(ptr)
+}
+
+// summary returns the summary of the given function definition.
+func (a *analyzer) summary(f *ir.Func) *summary {
+	s, ok := a.summaries[f]
+	if !ok {
+		s = &summary{
+			ret:    &labelSet{},
+			stores: make(map[int]*effectSet),
+			sinks:  make(map[int]*effectSet),
+		}
+		a.summaries[f] = s
+	}
+	return s
+}
+
+// ### [ Helper functions ] ####################################################
+
+// isMemcpy reports whether the given function is a memory copy function; i.e.
+// the memcpy or memmove C library functions or intrinsics.
+func isMemcpy(f *ir.Func) bool {
+	switch f.Name() {
+	case "memcpy", "memmove":
+		return true
+	}
+	switch id, _ := intrinsics.Lookup(f); id {
+	case intrinsics.Memcpy, intrinsics.Memmove:
+		return true
+	}
+	return false
+}
+
+// callBase returns the call site on whose result the given pointer is based
+// (through getelementptr and bitcast instructions), and the callee and
+// arguments of the call site; or nil if the pointer is not based on the result
+// of a call site.
+func callBase(ptr value.Value) (site, callee value.Value, args []value.Value) {
+	for {
+		switch v := ptr.(type) {
+		case *ir.InstGetElementPtr:
+			ptr = v.Src
+		case *ir.InstBitCast:
+			ptr = v.From
+		case *ir.InstCall:
+			return v, v.Callee, v.Args
+		case *ir.TermInvoke:
+			return v, v.Invokee, v.Args
+		case *ir.TermCallBr:
+			return v, v.Callee, v.Args
+		default:
+			return nil, nil, nil
+		}
+	}
+}
+
+// isVoid reports whether the given call site has no result.
+func isVoid(site value.Value) bool {
+	switch site := site.(type) {
+	case *ir.InstCall:
+		return types.IsVoid(site.Sig().RetType)
+	case *ir.TermInvoke:
+		return types.IsVoid(site.Sig().RetType)
+	case *ir.TermCallBr:
+		return types.IsVoid(site.Sig().RetType)
+	}
+	return true
+}
+
+// filename returns the file name of the given debug information scope.
+func filename(scope metadata.Field) string {
+	switch scope := scope.(type) {
+	case *metadata.DISubprogram:
+		if scope.File != nil {
+			return scope.File.Filename
+		}
+	case *metadata.DILexicalBlock:
+		if scope.File != nil {
+			return scope.File.Filename
+		}
+		return filename(scope.Scope)
+	case *metadata.DILexicalBlockFile:
+		if scope.File != nil {
+			return scope.File.Filename
+		}
+		return filename(scope.Scope)
+	case *metadata.DIFile:
+		return scope.Filename
+	}
+	return "?"
+}
@@ -0,0 +1,120 @@
+package taint
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/asm"
+)
+
+func TestAnalyze(t *testing.T) {
+	const src = `
+declare i64 @recv(i32, i8*, i64, i32)
+
+declare i8* @getenv(i8*)
+
+declare i32 @system(i8*)
+
+declare i32 @puts(i8*)
+
+declare void @llvm.memcpy.p0i8.p0i8.i64(i8*, i8*, i64, i1)
+
+@name = constant [5 x i8] c"PATH\00"
+
+define i8* @id(i8* %x) {
+entry:
+	ret i8* %x
+}
+
+define i8* @skip(i8* %x) {
+entry:
+	%y = getelementptr i8, i8* %x, i64 1
+	ret i8* %y
+}
+
+define void @run(i8* %cmd) !dbg !3 {
+entry:
+	%r = call i32 @system(i8* %cmd), !dbg !6
+	ret void
+}
+
+define void @f(i32 %fd) !dbg !7 {
+entry:
+	%buf = alloca [64 x i8]
+	%copy = alloca [64 x i8]
+	%p = getelementptr [64 x i8], [64 x i8]* %buf, i64 0, i64 0
+	%q = getelementptr [64 x i8], [64 x i8]* %copy, i64 0, i64 0
+	%n = call i64 @recv(i32 %fd, i8* %p, i64 64, i32 0), !dbg !8
+	call void @llvm.memcpy.p0i8.p0i8.i64(i8* %q, i8* %p, i64 64, i1 false), !dbg !9
+	call void @run(i8* %q), !dbg !10
+	ret void
+}
+
+define void @g() {
+entry:
+	%clean = call i8* @id(i8* getelementptr ([5 x i8], [5 x i8]* @name, i64 0, i64 0))
+	%s1 = call i32 @system(i8* %clean)
+	%env = call i8* @getenv(i8* getelementptr ([5 x i8], [5 x i8]* @name, i64 0, i64 0))
+	%dirty = call i8* @id(i8* %env)
+	%s2 = call i32 @puts(i8* %dirty)
+	ret void
+}
+
+define void @h(i32 %fd) {
+entry:
+	%buf = alloca [8 x i8]
+	%p = getelementptr [8 x i8], [8 x i8]* %buf, i64 0, i64 0
+	%n = call i64 @recv(i32 %fd, i8* %p, i64 8, i32 0)
+	%q = call i8* @skip(i8* %p)
+	%r = bitcast i8* %q to i8*
+	%s = call i32 @system(i8* %r)
+	ret void
+}
+
+!llvm.dbg.cu = !{!0}
+!llvm.module.flags = !{!2}
+
+!0 = distinct !DICompileUnit(language: DW_LANG_C99, file: !1, emissionKind: FullDebug)
+!1 = !DIFile(filename: "foo.c", directory: "/tmp")
+!2 = !{i32 2, !"Debug Info Version", i32 3}
+!3 = distinct !DISubprogram(name: "run", scope: !1, file: !1, line: 3, unit: !0)
+!6 = !DILocation(line: 4, column: 2, scope: !3)
+!7 = distinct !DISubprogram(name: "f", scope: !1, file: !1, line: 7, unit: !0)
+!8 = !DILocation(line: 10, column: 2, scope: !7)
+!9 = !DILocation(line: 11, column: 2, scope: !7)
+!10 = !DILocation(line: 12, column: 2, scope: !7)
+`
+	m, err := asm.ParseString("taint.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	config := &Config{
+		Sources: []Endpoint{{Func: "recv", Index: 1}, {Func: "getenv", Index: Return}},
+		Sinks:   []Endpoint{{Func: "system", Index: 0}, {Func: "puts", Index: 0}},
+	}
+	findings := Analyze(m, config)
+	want := []string{
+		`getenv(ret) -> puts(arg 0)
+	@g: %env = call i8* @getenv(i8* getelementptr ([5 x i8], [5 x i8]* @name, i64 0, i64 0))
+	@id: ret i8* %x
+	@g: %dirty = call i8* @id(i8* %env)
+	@g: %s2 = call i32 @puts(i8* %dirty)`,
+		`recv(arg 1) -> system(arg 0)
+	@h: %n = call i64 @recv(i32 %fd, i8* %p, i64 8, i32 0)
+	@skip: %y = getelementptr i8, i8* %x, i64 1
+	@skip: ret i8* %y
+	@h: %q = call i8* @skip(i8* %p)
+	@h: %s = call i32 @system(i8* %r)`,
+		`recv(arg 1) -> system(arg 0)
+	foo.c:10:2: %n = call i64 @recv(i32 %fd, i8* %p, i64 64, i32 0), !dbg !8
+	foo.c:11:2: call void @llvm.memcpy.p0i8.p0i8.i64(i8* %q, i8* %p, i64 64, i1 false), !dbg !9
+	foo.c:4:2: %r = call i32 @system(i8* %cmd), !dbg !6`,
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("number of findings mismatch; expected %d, got %d: %v", len(want), len(findings), findings)
+	}
+	for i, finding := range findings {
+		if got := finding.String(); got != want[i] {
+			t.Errorf("finding %d mismatch; expected\n%s\ngot\n%s", i, want[i], got)
+		}
+	}
+}
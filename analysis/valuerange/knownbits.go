@@ -0,0 +1,286 @@
+package valuerange
+
+import (
+	"math/big"
+	"strings"
+)
+
+// === [ Known bits ] ==========================================================
+
+// KnownBits records the bits of an integer which are known to be zero and the
+// bits which are known to be one. A bit is known to be neither zero nor one if
+// unknown; a bit is never known to be both.
+type KnownBits struct {
+	// Bits known to be zero, and bits known to be one.
+	Zero, One *big.Int
+	// Bit size of the integer.
+	BitSize uint64
+}
+
+// UnknownBits returns the known bits of an integer of the given bit size about
+// which nothing is known.
+func UnknownBits(bitSize uint64) KnownBits {
+	return KnownBits{Zero: new(big.Int), One: new(big.Int), BitSize: bitSize}
+}
+
+// ConstantBits returns the known bits of the integer x of the given bit size.
+func ConstantBits(x *big.Int, bitSize uint64) KnownBits {
+	v := truncate(x, bitSize)
+	return KnownBits{Zero: not(v, bitSize), One: v, BitSize: bitSize}
+}
+
+// RangeBits returns the known bits of the integers of the given range; i.e. the
+// leading bits which are common to every integer of the range.
+func RangeBits(r ConstantRange) KnownBits {
+	n := r.BitSize
+	if r.IsEmptySet() || r.IsWrapped() || r.IsFullSet() {
+		return UnknownBits(n)
+	}
+	lo, hi := r.UnsignedMin(), r.UnsignedMax()
+	// Bits above the most significant differing bit are common.
+	diff := new(big.Int).Xor(lo, hi)
+	common := new(big.Int).Lsh(maxUnsigned(n-uint64(diff.BitLen())), uint(diff.BitLen()))
+	return KnownBits{
+		Zero:    new(big.Int).And(common, not(lo, n)),
+		One:     new(big.Int).And(common, lo),
+		BitSize: n,
+	}
+}
+
+// String returns the string representation of the known bits, from the most
+// significant bit to the least significant bit, using '0' and '1' for known
+// bits and '?' for unknown bits; e.g. "????0000".
+func (k KnownBits) String() string {
+	var buf strings.Builder
+	for i := int(k.BitSize) - 1; i >= 0; i-- {
+		switch {
+		case k.Zero.Bit(i) == 1:
+			buf.WriteByte('0')
+		case k.One.Bit(i) == 1:
+			buf.WriteByte('1')
+		default:
+			buf.WriteByte('?')
+		}
+	}
+	return buf.String()
+}
+
+// Constant returns the integer of the known bits, and reports whether every bit
+// is known.
+func (k KnownBits) Constant() (*big.Int, bool) {
+	known := new(big.Int).Or(k.Zero, k.One)
+	if known.Cmp(maxUnsigned(k.BitSize)) != 0 {
+		return nil, false
+	}
+	return new(big.Int).Set(k.One), true
+}
+
+// Range returns the smallest unsigned range containing every integer with the
+// known bits.
+func (k KnownBits) Range() ConstantRange {
+	return boundsRange(k.One, not(k.Zero, k.BitSize), k.BitSize)
+}
+
+// Union returns the bits known in both k and other; i.e. the known bits of an
+// integer which is either described by k or by other.
+func (k KnownBits) Union(other KnownBits) KnownBits {
+	return KnownBits{
+		Zero:    new(big.Int).And(k.Zero, other.Zero),
+		One:     new(big.Int).And(k.One, other.One),
+		BitSize: k.BitSize,
+	}
+}
+
+// Merge returns the bits known in either k or other, which describe the same
+// integer. Conflicting bits are considered unknown.
+func (k KnownBits) Merge(other KnownBits) KnownBits {
+	zero := new(big.Int).Or(k.Zero, other.Zero)
+	one := new(big.Int).Or(k.One, other.One)
+	conflict := new(big.Int).And(zero, one)
+	return KnownBits{
+		Zero:    zero.AndNot(zero, conflict),
+		One:     one.AndNot(one, conflict),
+		BitSize: k.BitSize,
+	}
+}
+
+// --- [ Bitwise operations ] --------------------------------------------------
+
+// And returns the known bits of the bitwise and of integers with the known bits
+// k and other.
+func (k KnownBits) And(other KnownBits) KnownBits {
+	return KnownBits{
+		Zero:    new(big.Int).Or(k.Zero, other.Zero),
+		One:     new(big.Int).And(k.One, other.One),
+		BitSize: k.BitSize,
+	}
+}
+
+// Or returns the known bits of the bitwise or of integers with the known bits k
+// and other.
+func (k KnownBits) Or(other KnownBits) KnownBits {
+	return KnownBits{
+		Zero:    new(big.Int).And(k.Zero, other.Zero),
+		One:     new(big.Int).Or(k.One, other.One),
+		BitSize: k.BitSize,
+	}
+}
+
+// Xor returns the known bits of the bitwise exclusive or of integers with the
+// known bits k and other.
+func (k KnownBits) Xor(other KnownBits) KnownBits {
+	zero := new(big.Int).And(k.Zero, other.Zero)
+	zero.Or(zero, new(big.Int).And(k.One, other.One))
+	one := new(big.Int).And(k.Zero, other.One)
+	one.Or(one, new(big.Int).And(k.One, other.Zero))
+	return KnownBits{Zero: zero, One: one, BitSize: k.BitSize}
+}
+
+// Not returns the known bits of the bitwise complement of integers with the
+// known bits k.
+func (k KnownBits) Not() KnownBits {
+	return KnownBits{Zero: new(big.Int).Set(k.One), One: new(big.Int).Set(k.Zero), BitSize: k.BitSize}
+}
+
+// --- [ Arithmetic ] ----------------------------------------------------------
+
+// Add returns the known bits of the sum (with wrapping) of integers with the
+// known bits k and other.
+func (k KnownBits) Add(other KnownBits) KnownBits {
+	return addCarry(k, other, false)
+}
+
+// Sub returns the known bits of the difference (with wrapping) of integers with
+// the known bits k and other.
+func (k KnownBits) Sub(other KnownBits) KnownBits {
+	// x - y is x + ^y + 1.
+	return addCarry(k, other.Not(), true)
+}
+
+// Mul returns the known bits of the product (with wrapping) of integers with
+// the known bits k and other.
+func (k KnownBits) Mul(other KnownBits) KnownBits {
+	if x, ok := k.Constant(); ok {
+		if y, ok := other.Constant(); ok {
+			return ConstantBits(new(big.Int).Mul(x, y), k.BitSize)
+		}
+	}
+	// The trailing zeros of the operands are trailing zeros of the product.
+	tz := k.trailingZeros() + other.trailingZeros()
+	if tz > k.BitSize {
+		tz = k.BitSize
+	}
+	return KnownBits{Zero: maxUnsigned(tz), One: new(big.Int), BitSize: k.BitSize}
+}
+
+// Shl returns the known bits of integers with the known bits k shifted left by
+// the given amount (less than the bit size).
+func (k KnownBits) Shl(shift uint) KnownBits {
+	n := k.BitSize
+	zero := truncate(new(big.Int).Lsh(k.Zero, shift), n)
+	zero.Or(zero, maxUnsigned(uint64(shift)))
+	return KnownBits{Zero: zero, One: truncate(new(big.Int).Lsh(k.One, shift), n), BitSize: n}
+}
+
+// LShr returns the known bits of integers with the known bits k logically
+// shifted right by the given amount (less than the bit size).
+func (k KnownBits) LShr(shift uint) KnownBits {
+	n := k.BitSize
+	high := new(big.Int).Lsh(maxUnsigned(uint64(shift)), uint(n)-shift)
+	zero := new(big.Int).Rsh(k.Zero, shift)
+	zero.Or(zero, high)
+	return KnownBits{Zero: zero, One: new(big.Int).Rsh(k.One, shift), BitSize: n}
+}
+
+// AShr returns the known bits of integers with the known bits k arithmetically
+// shifted right by the given amount (less than the bit size).
+func (k KnownBits) AShr(shift uint) KnownBits {
+	n := k.BitSize
+	high := new(big.Int).Lsh(maxUnsigned(uint64(shift)), uint(n)-shift)
+	zero := new(big.Int).Rsh(k.Zero, shift)
+	one := new(big.Int).Rsh(k.One, shift)
+	// The shifted in bits are copies of the sign bit.
+	switch {
+	case k.Zero.Bit(int(n)-1) == 1:
+		zero.Or(zero, high)
+	case k.One.Bit(int(n)-1) == 1:
+		one.Or(one, high)
+	}
+	return KnownBits{Zero: zero, One: one, BitSize: n}
+}
+
+// --- [ Conversions ] ---------------------------------------------------------
+
+// Trunc returns the known bits of integers with the known bits k truncated to
+// the given bit size.
+func (k KnownBits) Trunc(bitSize uint64) KnownBits {
+	return KnownBits{Zero: truncate(k.Zero, bitSize), One: truncate(k.One, bitSize), BitSize: bitSize}
+}
+
+// ZExt returns the known bits of integers with the known bits k zero-extended
+// to the given bit size.
+func (k KnownBits) ZExt(bitSize uint64) KnownBits {
+	high := new(big.Int).Lsh(maxUnsigned(bitSize-k.BitSize), uint(k.BitSize))
+	return KnownBits{Zero: new(big.Int).Or(k.Zero, high), One: new(big.Int).Set(k.One), BitSize: bitSize}
+}
+
+// SExt returns the known bits of integers with the known bits k sign-extended
+// to the given bit size.
+func (k KnownBits) SExt(bitSize uint64) KnownBits {
+	high := new(big.Int).Lsh(maxUnsigned(bitSize-k.BitSize), uint(k.BitSize))
+	zero, one := new(big.Int).Set(k.Zero), new(big.Int).Set(k.One)
+	switch {
+	case k.Zero.Bit(int(k.BitSize)-1) == 1:
+		zero.Or(zero, high)
+	case k.One.Bit(int(k.BitSize)-1) == 1:
+		one.Or(one, high)
+	}
+	return KnownBits{Zero: zero, One: one, BitSize: bitSize}
+}
+
+// ### [ Helper functions ] ####################################################
+
+// addCarry returns the known bits of the sum of integers with the known bits x
+// and y and the given carry bit.
+//
+// Based on KnownBits::computeForAddCarry of LLVM.
+func addCarry(x, y KnownBits, carry bool) KnownBits {
+	n := x.BitSize
+	c := new(big.Int)
+	if carry {
+		c.SetInt64(1)
+	}
+	// Largest and smallest possible sums; bits where they agree with the
+	// operands indicate known carries.
+	possibleSumZero := new(big.Int).Add(not(x.Zero, n), not(y.Zero, n))
+	possibleSumZero = truncate(possibleSumZero.Add(possibleSumZero, c), n)
+	possibleSumOne := new(big.Int).Add(x.One, y.One)
+	possibleSumOne = truncate(possibleSumOne.Add(possibleSumOne, c), n)
+	carryKnownZero := new(big.Int).Xor(possibleSumZero, x.Zero)
+	carryKnownZero = not(carryKnownZero.Xor(carryKnownZero, y.Zero), n)
+	carryKnownOne := new(big.Int).Xor(possibleSumOne, x.One)
+	carryKnownOne.Xor(carryKnownOne, y.One)
+	known := new(big.Int).Or(x.Zero, x.One)
+	known.And(known, new(big.Int).Or(y.Zero, y.One))
+	known.And(known, new(big.Int).Or(carryKnownZero, carryKnownOne))
+	return KnownBits{
+		Zero:    new(big.Int).And(not(possibleSumOne, n), known),
+		One:     new(big.Int).And(possibleSumOne, known),
+		BitSize: n,
+	}
+}
+
+// trailingZeros returns the number of trailing bits known to be zero.
+func (k KnownBits) trailingZeros() uint64 {
+	var i uint64
+	for i < k.BitSize && k.Zero.Bit(int(i)) == 1 {
+		i++
+	}
+	return i
+}
+
+// not returns the bitwise complement of the unsigned integer x of the given bit
+// size.
+func not(x *big.Int, bitSize uint64) *big.Int {
+	return new(big.Int).Xor(truncate(x, bitSize), maxUnsigned(bitSize))
+}
@@ -0,0 +1,606 @@
+package valuerange
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/irutil"
+)
+
+// === [ Constant ranges ] =====================================================
+
+// ConstantRange is a range of integers of a given bit size, with wrapping
+// semantics. The range [Lower, Upper) contains the integers from Lower up to,
+// but not including, Upper, wrapping around from the maximum unsigned integer
+// to zero if Upper < Lower; e.g. the 8-bit range [250, 5) contains 250 through
+// 255 and 0 through 4.
+//
+// Lower and Upper are unsigned integers in [0, 2^BitSize). The full range is
+// represented by Lower == Upper == 2^BitSize-1, and the empty range by Lower ==
+// Upper == 0.
+type ConstantRange struct {
+	// Lower bound (inclusive) and upper bound (exclusive) of the range.
+	Lower, Upper *big.Int
+	// Bit size of the integers of the range.
+	BitSize uint64
+}
+
+// FullRange returns the range containing every integer of the given bit size.
+func FullRange(bitSize uint64) ConstantRange {
+	max := maxUnsigned(bitSize)
+	return ConstantRange{Lower: max, Upper: new(big.Int).Set(max), BitSize: bitSize}
+}
+
+// EmptyRange returns the range containing no integers of the given bit size.
+func EmptyRange(bitSize uint64) ConstantRange {
+	return ConstantRange{Lower: new(big.Int), Upper: new(big.Int), BitSize: bitSize}
+}
+
+// NewRange returns the range [lower, upper) of integers of the given bit size.
+// The bounds are truncated to the bit size, and the range is full if the
+// truncated bounds are equal.
+func NewRange(lower, upper *big.Int, bitSize uint64) ConstantRange {
+	l, u := truncate(lower, bitSize), truncate(upper, bitSize)
+	if l.Cmp(u) == 0 {
+		return FullRange(bitSize)
+	}
+	return ConstantRange{Lower: l, Upper: u, BitSize: bitSize}
+}
+
+// SingleRange returns the range containing only the integer x of the given bit
+// size.
+func SingleRange(x *big.Int, bitSize uint64) ConstantRange {
+	return NewRange(x, new(big.Int).Add(x, one), bitSize)
+}
+
+// boundsRange returns the range of integers from lo through hi (inclusive) of
+// the given bit size, where lo and hi are signed or unsigned integers with lo <=
+// hi; or the full range if the range is larger than the set of integers.
+func boundsRange(lo, hi *big.Int, bitSize uint64) ConstantRange {
+	if lo.Cmp(hi) > 0 {
+		return EmptyRange(bitSize)
+	}
+	size := new(big.Int).Sub(hi, lo)
+	if size.Cmp(maxUnsigned(bitSize)) >= 0 {
+		return FullRange(bitSize)
+	}
+	return NewRange(lo, new(big.Int).Add(hi, one), bitSize)
+}
+
+// String returns the string representation of the range, with bounds
+// interpreted as signed integers; e.g. "[0,16)".
+func (r ConstantRange) String() string {
+	switch {
+	case r.IsFullSet():
+		return "full-set"
+	case r.IsEmptySet():
+		return "empty-set"
+	}
+	return fmt.Sprintf("[%s,%s)", toSigned(r.Lower, r.BitSize), toSigned(r.Upper, r.BitSize))
+}
+
+// IsFullSet reports whether the range contains every integer.
+func (r ConstantRange) IsFullSet() bool {
+	return r.Lower.Cmp(r.Upper) == 0 && r.Lower.Sign() != 0
+}
+
+// IsEmptySet reports whether the range contains no integers.
+func (r ConstantRange) IsEmptySet() bool {
+	return r.Lower.Cmp(r.Upper) == 0 && r.Lower.Sign() == 0
+}
+
+// IsWrapped reports whether the range wraps around from the maximum unsigned
+// integer to zero (e.g. [250, 5) of 8-bit integers).
+func (r ConstantRange) IsWrapped() bool {
+	return r.Lower.Cmp(r.Upper) > 0 && r.Upper.Sign() != 0
+}
+
+// Size returns the number of integers in the range.
+func (r ConstantRange) Size() *big.Int {
+	if r.IsFullSet() {
+		return new(big.Int).Lsh(one, uint(r.BitSize))
+	}
+	return truncate(new(big.Int).Sub(r.Upper, r.Lower), r.BitSize)
+}
+
+// Contains reports whether the range contains the integer x (truncated to the
+// bit size of the range).
+func (r ConstantRange) Contains(x *big.Int) bool {
+	offset := truncate(new(big.Int).Sub(x, r.Lower), r.BitSize)
+	return offset.Cmp(r.Size()) < 0
+}
+
+// ContainsRange reports whether the range contains every integer of the range
+// other.
+func (r ConstantRange) ContainsRange(other ConstantRange) bool {
+	if other.IsEmptySet() || r.IsFullSet() {
+		return true
+	}
+	if r.IsEmptySet() || other.IsFullSet() {
+		return false
+	}
+	// other is contained if it starts and ends within r, without passing the
+	// upper bound of r.
+	offset := truncate(new(big.Int).Sub(other.Lower, r.Lower), r.BitSize)
+	return new(big.Int).Add(offset, other.Size()).Cmp(r.Size()) <= 0
+}
+
+// SingleElement returns the only integer of the range, and reports whether the
+// range contains exactly one integer.
+func (r ConstantRange) SingleElement() (*big.Int, bool) {
+	if r.Size().Cmp(one) != 0 {
+		return nil, false
+	}
+	return new(big.Int).Set(r.Lower), true
+}
+
+// UnsignedMin returns the smallest unsigned integer of the non-empty range.
+func (r ConstantRange) UnsignedMin() *big.Int {
+	if r.Contains(new(big.Int)) {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(r.Lower)
+}
+
+// UnsignedMax returns the largest unsigned integer of the non-empty range.
+func (r ConstantRange) UnsignedMax() *big.Int {
+	max := maxUnsigned(r.BitSize)
+	if r.Contains(max) {
+		return max
+	}
+	return truncate(new(big.Int).Sub(r.Upper, one), r.BitSize)
+}
+
+// SignedMin returns the smallest signed integer of the non-empty range.
+func (r ConstantRange) SignedMin() *big.Int {
+	min := minSigned(r.BitSize)
+	if r.Contains(min) {
+		return min
+	}
+	return toSigned(r.Lower, r.BitSize)
+}
+
+// SignedMax returns the largest signed integer of the non-empty range.
+func (r ConstantRange) SignedMax() *big.Int {
+	max := maxSigned(r.BitSize)
+	if r.Contains(max) {
+		return max
+	}
+	return toSigned(new(big.Int).Sub(r.Upper, one), r.BitSize)
+}
+
+// Inverse returns the range of integers not contained in the range.
+func (r ConstantRange) Inverse() ConstantRange {
+	switch {
+	case r.IsFullSet():
+		return EmptyRange(r.BitSize)
+	case r.IsEmptySet():
+		return FullRange(r.BitSize)
+	}
+	return NewRange(r.Upper, r.Lower, r.BitSize)
+}
+
+// Union returns the smallest range containing the integers of both ranges.
+func (r ConstantRange) Union(other ConstantRange) ConstantRange {
+	switch {
+	case r.IsEmptySet() || other.IsFullSet():
+		return other
+	case other.IsEmptySet() || r.IsFullSet():
+		return r
+	}
+	// The smallest range containing both ranges starts at the lower bound of
+	// one of them.
+	a := r.extendTo(other)
+	b := other.extendTo(r)
+	if b.Cmp(a) < 0 || b.Cmp(a) == 0 && !other.IsWrapped() && r.IsWrapped() {
+		return sizedRange(other.Lower, b, r.BitSize)
+	}
+	return sizedRange(r.Lower, a, r.BitSize)
+}
+
+// extendTo returns the size of the range starting at the lower bound of r which
+// contains both r and other.
+func (r ConstantRange) extendTo(other ConstantRange) *big.Int {
+	offset := truncate(new(big.Int).Sub(other.Lower, r.Lower), r.BitSize)
+	end := offset.Add(offset, other.Size())
+	if size := r.Size(); size.Cmp(end) > 0 {
+		return size
+	}
+	return end
+}
+
+// Intersect returns the smallest range containing the integers contained in
+// both ranges.
+func (r ConstantRange) Intersect(other ConstantRange) ConstantRange {
+	switch {
+	case r.IsEmptySet() || other.IsFullSet():
+		return r
+	case other.IsEmptySet() || r.IsFullSet():
+		return other
+	}
+	// The intersection consists of the part of other starting at its lower bound
+	// if contained in r, and the part of r starting at its lower bound if
+	// contained in other.
+	result := EmptyRange(r.BitSize)
+	if r.Contains(other.Lower) {
+		result = result.Union(other.prefixWithin(r))
+	}
+	if other.Contains(r.Lower) {
+		result = result.Union(r.prefixWithin(other))
+	}
+	return result
+}
+
+// prefixWithin returns the longest prefix of r which is contained in the range
+// other, where the lower bound of r is contained in other.
+func (r ConstantRange) prefixWithin(other ConstantRange) ConstantRange {
+	offset := truncate(new(big.Int).Sub(r.Lower, other.Lower), r.BitSize)
+	room := new(big.Int).Sub(other.Size(), offset)
+	if size := r.Size(); size.Cmp(room) < 0 {
+		room = size
+	}
+	return sizedRange(r.Lower, room, r.BitSize)
+}
+
+// --- [ Arithmetic ] ----------------------------------------------------------
+
+// Add returns the range of the sums (with wrapping) of integers of both ranges.
+func (r ConstantRange) Add(other ConstantRange) ConstantRange {
+	if r.IsEmptySet() || other.IsEmptySet() {
+		return EmptyRange(r.BitSize)
+	}
+	if r.IsFullSet() || other.IsFullSet() {
+		return FullRange(r.BitSize)
+	}
+	size := new(big.Int).Add(r.Size(), other.Size())
+	size.Sub(size, one)
+	return sizedRange(new(big.Int).Add(r.Lower, other.Lower), size, r.BitSize)
+}
+
+// Sub returns the range of the differences (with wrapping) of integers of both
+// ranges.
+func (r ConstantRange) Sub(other ConstantRange) ConstantRange {
+	if r.IsEmptySet() || other.IsEmptySet() {
+		return EmptyRange(r.BitSize)
+	}
+	if r.IsFullSet() || other.IsFullSet() {
+		return FullRange(r.BitSize)
+	}
+	size := new(big.Int).Add(r.Size(), other.Size())
+	size.Sub(size, one)
+	// The smallest difference is the lower bound of r minus the largest integer
+	// of other.
+	otherMax := new(big.Int).Add(other.Lower, other.Size())
+	otherMax.Sub(otherMax, one)
+	return sizedRange(new(big.Int).Sub(r.Lower, otherMax), size, r.BitSize)
+}
+
+// Mul returns the range of the products (with wrapping) of integers of both
+// ranges.
+func (r ConstantRange) Mul(other ConstantRange) ConstantRange {
+	if r.IsEmptySet() || other.IsEmptySet() {
+		return EmptyRange(r.BitSize)
+	}
+	// Unsigned products, if not wrapping.
+	lo := new(big.Int).Mul(r.UnsignedMin(), other.UnsignedMin())
+	hi := new(big.Int).Mul(r.UnsignedMax(), other.UnsignedMax())
+	result := FullRange(r.BitSize)
+	if hi.Cmp(maxUnsigned(r.BitSize)) <= 0 {
+		result = boundsRange(lo, hi, r.BitSize)
+	}
+	// Signed products, if not overflowing.
+	var products []*big.Int
+	for _, x := range []*big.Int{r.SignedMin(), r.SignedMax()} {
+		for _, y := range []*big.Int{other.SignedMin(), other.SignedMax()} {
+			products = append(products, new(big.Int).Mul(x, y))
+		}
+	}
+	smin, smax := minMax(products)
+	if smin.Cmp(minSigned(r.BitSize)) >= 0 && smax.Cmp(maxSigned(r.BitSize)) <= 0 {
+		if signed := boundsRange(smin, smax, r.BitSize); signed.Size().Cmp(result.Size()) < 0 {
+			result = signed
+		}
+	}
+	return result
+}
+
+// UDiv returns the range of the unsigned quotients of integers of both ranges.
+// Division by zero is undefined behaviour, and is thus disregarded.
+func (r ConstantRange) UDiv(other ConstantRange) ConstantRange {
+	if r.IsEmptySet() || other.IsEmptySet() || other.UnsignedMax().Sign() == 0 {
+		return EmptyRange(r.BitSize)
+	}
+	divMin := other.UnsignedMin()
+	if divMin.Sign() == 0 {
+		divMin = big.NewInt(1)
+	}
+	lo := new(big.Int).Quo(r.UnsignedMin(), other.UnsignedMax())
+	hi := new(big.Int).Quo(r.UnsignedMax(), divMin)
+	return boundsRange(lo, hi, r.BitSize)
+}
+
+// URem returns the range of the unsigned remainders of integers of both
+// ranges. Division by zero is undefined behaviour, and is thus disregarded.
+func (r ConstantRange) URem(other ConstantRange) ConstantRange {
+	if r.IsEmptySet() || other.IsEmptySet() || other.UnsignedMax().Sign() == 0 {
+		return EmptyRange(r.BitSize)
+	}
+	if r.UnsignedMax().Cmp(other.UnsignedMin()) < 0 {
+		// The remainder is the dividend itself.
+		return r
+	}
+	hi := new(big.Int).Sub(other.UnsignedMax(), one)
+	if max := r.UnsignedMax(); max.Cmp(hi) < 0 {
+		hi = max
+	}
+	return boundsRange(new(big.Int), hi, r.BitSize)
+}
+
+// And returns a range containing the bitwise and of integers of both ranges.
+func (r ConstantRange) And(other ConstantRange) ConstantRange {
+	if r.IsEmptySet() || other.IsEmptySet() {
+		return EmptyRange(r.BitSize)
+	}
+	// The result is no larger than either operand.
+	hi := r.UnsignedMax()
+	if max := other.UnsignedMax(); max.Cmp(hi) < 0 {
+		hi = max
+	}
+	return boundsRange(new(big.Int), hi, r.BitSize)
+}
+
+// Or returns a range containing the bitwise or of integers of both ranges.
+func (r ConstantRange) Or(other ConstantRange) ConstantRange {
+	if r.IsEmptySet() || other.IsEmptySet() {
+		return EmptyRange(r.BitSize)
+	}
+	// The result is no smaller than either operand, and has no bits set above
+	// the most significant set bit of the operands.
+	lo := r.UnsignedMin()
+	if min := other.UnsignedMin(); min.Cmp(lo) > 0 {
+		lo = min
+	}
+	return boundsRange(lo, bitMask(r.UnsignedMax(), other.UnsignedMax()), r.BitSize)
+}
+
+// Xor returns a range containing the bitwise exclusive or of integers of both
+// ranges.
+func (r ConstantRange) Xor(other ConstantRange) ConstantRange {
+	if r.IsEmptySet() || other.IsEmptySet() {
+		return EmptyRange(r.BitSize)
+	}
+	return boundsRange(new(big.Int), bitMask(r.UnsignedMax(), other.UnsignedMax()), r.BitSize)
+}
+
+// Shl returns the range of the left shifts of integers of r by integers of
+// other. Shift amounts larger than or equal to the bit size produce poison, and
+// are thus disregarded.
+func (r ConstantRange) Shl(other ConstantRange) ConstantRange {
+	if r.IsEmptySet() || other.IsEmptySet() {
+		return EmptyRange(r.BitSize)
+	}
+	shiftMin, shiftMax, ok := shiftAmounts(other)
+	if !ok {
+		return EmptyRange(r.BitSize)
+	}
+	hi := new(big.Int).Lsh(r.UnsignedMax(), shiftMax)
+	if hi.Cmp(maxUnsigned(r.BitSize)) > 0 {
+		return FullRange(r.BitSize)
+	}
+	return boundsRange(new(big.Int).Lsh(r.UnsignedMin(), shiftMin), hi, r.BitSize)
+}
+
+// LShr returns the range of the logical right shifts of integers of r by
+// integers of other. Shift amounts larger than or equal to the bit size produce
+// poison, and are thus disregarded.
+func (r ConstantRange) LShr(other ConstantRange) ConstantRange {
+	if r.IsEmptySet() || other.IsEmptySet() {
+		return EmptyRange(r.BitSize)
+	}
+	shiftMin, shiftMax, ok := shiftAmounts(other)
+	if !ok {
+		return EmptyRange(r.BitSize)
+	}
+	lo := new(big.Int).Rsh(r.UnsignedMin(), shiftMax)
+	hi := new(big.Int).Rsh(r.UnsignedMax(), shiftMin)
+	return boundsRange(lo, hi, r.BitSize)
+}
+
+// AShr returns the range of the arithmetic right shifts of integers of r by
+// integers of other. Shift amounts larger than or equal to the bit size produce
+// poison, and are thus disregarded.
+func (r ConstantRange) AShr(other ConstantRange) ConstantRange {
+	if r.IsEmptySet() || other.IsEmptySet() {
+		return EmptyRange(r.BitSize)
+	}
+	shiftMin, shiftMax, ok := shiftAmounts(other)
+	if !ok {
+		return EmptyRange(r.BitSize)
+	}
+	// Negative integers are largest when shifted the most, and non-negative
+	// integers are smallest when shifted the most.
+	smin, smax := r.SignedMin(), r.SignedMax()
+	var lo, hi *big.Int
+	if smin.Sign() < 0 {
+		lo = new(big.Int).Rsh(smin, shiftMin)
+	} else {
+		lo = new(big.Int).Rsh(smin, shiftMax)
+	}
+	if smax.Sign() < 0 {
+		hi = new(big.Int).Rsh(smax, shiftMax)
+	} else {
+		hi = new(big.Int).Rsh(smax, shiftMin)
+	}
+	return boundsRange(lo, hi, r.BitSize)
+}
+
+// --- [ Conversions ] ---------------------------------------------------------
+
+// Trunc returns the range of the integers of r truncated to the given bit size.
+func (r ConstantRange) Trunc(bitSize uint64) ConstantRange {
+	if r.IsEmptySet() {
+		return EmptyRange(bitSize)
+	}
+	if r.Size().Cmp(maxUnsigned(bitSize)) > 0 {
+		return FullRange(bitSize)
+	}
+	return sizedRange(r.Lower, r.Size(), bitSize)
+}
+
+// ZExt returns the range of the integers of r zero-extended to the given bit
+// size.
+func (r ConstantRange) ZExt(bitSize uint64) ConstantRange {
+	if r.IsEmptySet() {
+		return EmptyRange(bitSize)
+	}
+	return boundsRange(r.UnsignedMin(), r.UnsignedMax(), bitSize)
+}
+
+// SExt returns the range of the integers of r sign-extended to the given bit
+// size.
+func (r ConstantRange) SExt(bitSize uint64) ConstantRange {
+	if r.IsEmptySet() {
+		return EmptyRange(bitSize)
+	}
+	return boundsRange(r.SignedMin(), r.SignedMax(), bitSize)
+}
+
+// --- [ Comparisons ] ---------------------------------------------------------
+
+// ICmpRegion returns the range of integers x for which the integer comparison
+// x pred y holds for some integer y of the range other.
+func ICmpRegion(pred enum.IPred, other ConstantRange) ConstantRange {
+	n := other.BitSize
+	if other.IsEmptySet() {
+		return EmptyRange(n)
+	}
+	switch pred {
+	case enum.IPredEQ:
+		return other
+	case enum.IPredNE:
+		if x, ok := other.SingleElement(); ok {
+			return SingleRange(x, n).Inverse()
+		}
+		return FullRange(n)
+	case enum.IPredULT:
+		return boundsRange(new(big.Int), new(big.Int).Sub(other.UnsignedMax(), one), n)
+	case enum.IPredULE:
+		return boundsRange(new(big.Int), other.UnsignedMax(), n)
+	case enum.IPredUGT:
+		return boundsRange(new(big.Int).Add(other.UnsignedMin(), one), maxUnsigned(n), n)
+	case enum.IPredUGE:
+		return boundsRange(other.UnsignedMin(), maxUnsigned(n), n)
+	case enum.IPredSLT:
+		return boundsRange(minSigned(n), new(big.Int).Sub(other.SignedMax(), one), n)
+	case enum.IPredSLE:
+		return boundsRange(minSigned(n), other.SignedMax(), n)
+	case enum.IPredSGT:
+		return boundsRange(new(big.Int).Add(other.SignedMin(), one), maxSigned(n), n)
+	case enum.IPredSGE:
+		return boundsRange(other.SignedMin(), maxSigned(n), n)
+	}
+	panic(fmt.Errorf("support for integer comparison predicate %v not yet implemented", pred))
+}
+
+// ICmp returns whether the integer comparison x pred y holds for every pair of
+// integers of the ranges x and y; the boolean result ok reports whether the
+// comparison is known to either hold or not hold for every pair.
+func ICmp(pred enum.IPred, x, y ConstantRange) (result, ok bool) {
+	if x.IsEmptySet() || y.IsEmptySet() {
+		return false, false
+	}
+	if x.Intersect(ICmpRegion(irutil.InverseIPred(pred), y)).IsEmptySet() {
+		return true, true
+	}
+	if x.Intersect(ICmpRegion(pred, y)).IsEmptySet() {
+		return false, true
+	}
+	return false, false
+}
+
+// ### [ Helper functions ] ####################################################
+
+// one is the integer 1.
+var one = big.NewInt(1)
+
+// sizedRange returns the range of the given size starting at lower; or the full
+// range if the size is larger than or equal to the number of integers.
+func sizedRange(lower, size *big.Int, bitSize uint64) ConstantRange {
+	if size.Sign() == 0 {
+		return EmptyRange(bitSize)
+	}
+	if size.Cmp(maxUnsigned(bitSize)) > 0 {
+		return FullRange(bitSize)
+	}
+	return NewRange(lower, new(big.Int).Add(lower, size), bitSize)
+}
+
+// shiftAmounts returns the smallest and largest valid shift amounts of the
+// given range, and reports whether the range contains a valid shift amount.
+func shiftAmounts(r ConstantRange) (min, max uint, ok bool) {
+	bitSize := new(big.Int).SetUint64(r.BitSize)
+	lo, hi := r.UnsignedMin(), r.UnsignedMax()
+	if lo.Cmp(bitSize) >= 0 {
+		return 0, 0, false
+	}
+	if hi.Cmp(bitSize) >= 0 {
+		hi = new(big.Int).Sub(bitSize, one)
+	}
+	return uint(lo.Uint64()), uint(hi.Uint64()), true
+}
+
+// bitMask returns the integer with every bit set up to and including the most
+// significant set bit of x and y.
+func bitMask(x, y *big.Int) *big.Int {
+	n := x.BitLen()
+	if m := y.BitLen(); m > n {
+		n = m
+	}
+	return new(big.Int).Sub(new(big.Int).Lsh(one, uint(n)), one)
+}
+
+// minMax returns the minimum and maximum of the given integers.
+func minMax(xs []*big.Int) (min, max *big.Int) {
+	min, max = xs[0], xs[0]
+	for _, x := range xs[1:] {
+		if x.Cmp(min) < 0 {
+			min = x
+		}
+		if x.Cmp(max) > 0 {
+			max = x
+		}
+	}
+	return min, max
+}
+
+// truncate returns x truncated to an unsigned integer of the given bit size.
+func truncate(x *big.Int, bitSize uint64) *big.Int {
+	m := new(big.Int).Lsh(one, uint(bitSize))
+	return new(big.Int).Mod(x, m)
+}
+
+// toSigned returns the unsigned integer x of the given bit size interpreted as
+// a signed integer in two's complement.
+func toSigned(x *big.Int, bitSize uint64) *big.Int {
+	u := truncate(x, bitSize)
+	if u.Bit(int(bitSize)-1) == 1 {
+		u.Sub(u, new(big.Int).Lsh(one, uint(bitSize)))
+	}
+	return u
+}
+
+// maxUnsigned returns the maximum unsigned integer of the given bit size.
+func maxUnsigned(bitSize uint64) *big.Int {
+	return new(big.Int).Sub(new(big.Int).Lsh(one, uint(bitSize)), one)
+}
+
+// minSigned returns the minimum signed integer of the given bit size.
+func minSigned(bitSize uint64) *big.Int {
+	return new(big.Int).Neg(new(big.Int).Lsh(one, uint(bitSize-1)))
+}
+
+// maxSigned returns the maximum signed integer of the given bit size.
+func maxSigned(bitSize uint64) *big.Int {
+	return new(big.Int).Sub(new(big.Int).Lsh(one, uint(bitSize-1)), one)
+}
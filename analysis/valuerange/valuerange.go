@@ -0,0 +1,503 @@
+// Package valuerange implements value range and known bits analysis of the
+// integer values of LLVM IR functions.
+//
+// For each integer SSA value, the analysis computes a conservative range of the
+// integers it may hold (see ConstantRange), and the bits of the value which are
+// known to be zero or one (see KnownBits). Ranges and known bits are propagated
+// through arithmetic, bitwise and conversion instructions, and refine each
+// other; e.g. the result of
+//
+//	%y = and i32 %x, 15
+//
+// has the upper 28 bits known to be zero, and is thus in the range [0,16).
+// Loads and calls with !range metadata are in the given ranges.
+//
+// Ranges are refined along control flow edges by the conditions of branch and
+// switch terminators; e.g. %x is in the range [0,10) in the basic blocks which
+// are only reached when `icmp ult i32 %x, 10` holds. The ranges of phi
+// instructions are computed by iterating to a fixed point, widening the ranges
+// of phi instructions which keep growing (e.g. induction variables) to the
+// signed bounds of their type.
+package valuerange
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/llir/llvm/analysis/cfg"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/metadata"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/llir/llvm/irutil"
+)
+
+const (
+	// Number of updates of the range of a phi instruction before widening.
+	widenAfter = 2
+	// Number of updates of the range of a phi instruction before giving up.
+	giveUpAfter = 16
+)
+
+// Info is the value range information of a function.
+type Info struct {
+	// Dominator tree of the function.
+	dt *cfg.DomTree
+	// Predecessors of each basic block.
+	preds map[*ir.Block][]*ir.Block
+	// Basic block of each instruction and terminator.
+	blockOf map[value.Value]*ir.Block
+	// Range and known bits of each integer value defined in a reachable basic
+	// block; values without a range are not yet known to be reachable.
+	ranges map[value.Value]ConstantRange
+	bits   map[value.Value]KnownBits
+}
+
+// NewInfo computes the value range information of the given function.
+func NewInfo(f *ir.Func) *Info {
+	info := &Info{
+		dt:      cfg.NewDomTree(f),
+		preds:   cfg.Preds(f),
+		blockOf: irutil.DefBlocks(f),
+		ranges:  make(map[value.Value]ConstantRange),
+		bits:    make(map[value.Value]KnownBits),
+	}
+	info.solve(f)
+	return info
+}
+
+// Range returns the range of the given integer value at its definition.
+//
+// The range of a value which is only defined in unreachable code is empty.
+func (info *Info) Range(v value.Value) ConstantRange {
+	n := bitSize(v)
+	if c, ok := v.(*constant.Int); ok {
+		return SingleRange(c.X, n)
+	}
+	if r, ok := info.ranges[v]; ok {
+		return r
+	}
+	if _, ok := info.blockOf[v]; ok {
+		return EmptyRange(n)
+	}
+	return FullRange(n)
+}
+
+// RangeAt returns the range of the given integer value in the given basic
+// block, refined by the conditions of the branches dominating the basic block.
+func (info *Info) RangeAt(v value.Value, block *ir.Block) ConstantRange {
+	return info.refine(v, info.Range(v), block)
+}
+
+// KnownBits returns the known bits of the given integer value at its
+// definition.
+func (info *Info) KnownBits(v value.Value) KnownBits {
+	n := bitSize(v)
+	if c, ok := v.(*constant.Int); ok {
+		return ConstantBits(c.X, n)
+	}
+	if k, ok := info.bits[v]; ok {
+		return k
+	}
+	return UnknownBits(n)
+}
+
+// solve computes the ranges and known bits of the integer values of f.
+func (info *Info) solve(f *ir.Func) {
+	rpo := cfg.ReversePostOrder(f)
+	updates := make(map[value.Value]int)
+	for changed := true; changed; {
+		changed = false
+		for _, block := range rpo {
+			var vs []value.Value
+			for _, inst := range block.Insts {
+				if v, ok := inst.(value.Value); ok {
+					vs = append(vs, v)
+				}
+			}
+			if v, ok := block.Term.(value.Value); ok {
+				vs = append(vs, v)
+			}
+			for _, v := range vs {
+				if !types.IsInt(v.Type()) {
+					continue
+				}
+				r, k, ok := info.transfer(v, block)
+				if !ok {
+					continue
+				}
+				oldR, seen := info.ranges[v]
+				if _, ok := v.(*ir.InstPhi); ok && seen {
+					oldK := info.bits[v]
+					switch n := updates[v]; {
+					case n >= giveUpAfter:
+						r, k = FullRange(r.BitSize), UnknownBits(r.BitSize)
+					case n >= widenAfter:
+						r, k = widen(oldR, oldR.Union(r)), oldK.Union(k)
+					default:
+						r, k = oldR.Union(r), oldK.Union(k)
+					}
+				}
+				// Ranges and known bits refine each other.
+				r = r.Intersect(k.Range())
+				k = k.Merge(RangeBits(r))
+				if seen && equalRange(r, oldR) && equalBits(k, info.bits[v]) {
+					continue
+				}
+				info.ranges[v] = r
+				info.bits[v] = k
+				updates[v]++
+				changed = true
+			}
+		}
+	}
+}
+
+// transfer returns the range and known bits of the given integer value defined
+// in the given basic block, based on the ranges and known bits of its operands.
+// The boolean return value reports whether the operands are known to be
+// reachable.
+func (info *Info) transfer(v value.Value, block *ir.Block) (ConstantRange, KnownBits, bool) {
+	n := bitSize(v)
+	full, unknown := FullRange(n), UnknownBits(n)
+	switch v := v.(type) {
+	case *ir.InstPhi:
+		return info.transferPhi(v, block)
+	case *ir.InstSelect:
+		rx, kx, okx := info.operand(v.ValueTrue, block)
+		ry, ky, oky := info.operand(v.ValueFalse, block)
+		switch {
+		case okx && oky:
+			return rx.Union(ry), kx.Union(ky), true
+		case okx:
+			return rx, kx, true
+		case oky:
+			return ry, ky, true
+		}
+		return full, unknown, false
+	case *ir.InstICmp:
+		if !types.IsInt(v.X.Type()) {
+			return full, unknown, true
+		}
+		rx, _, okx := info.operand(v.X, block)
+		ry, _, oky := info.operand(v.Y, block)
+		if !okx || !oky {
+			return full, unknown, false
+		}
+		if result, ok := ICmp(v.Pred, rx, ry); ok {
+			x := big.NewInt(0)
+			if result {
+				x.SetInt64(1)
+			}
+			return SingleRange(x, n), ConstantBits(x, n), true
+		}
+		return full, unknown, true
+	case *ir.InstTrunc:
+		rx, kx, ok := info.operand(v.From, block)
+		return rx.Trunc(n), kx.Trunc(n), ok
+	case *ir.InstZExt:
+		rx, kx, ok := info.operand(v.From, block)
+		return rx.ZExt(n), kx.ZExt(n), ok
+	case *ir.InstSExt:
+		rx, kx, ok := info.operand(v.From, block)
+		return rx.SExt(n), kx.SExt(n), ok
+	case *ir.InstLoad:
+		return rangeMetadata(v.MDAttachments(), n), unknown, true
+	case *ir.InstCall:
+		return rangeMetadata(v.MDAttachments(), n), unknown, true
+	case *ir.TermInvoke:
+		return rangeMetadata(v.MDAttachments(), n), unknown, true
+	}
+	x, y, ok := binaryOperands(v)
+	if !ok {
+		return full, unknown, true
+	}
+	rx, kx, okx := info.operand(x, block)
+	ry, ky, oky := info.operand(y, block)
+	if !okx || !oky {
+		return full, unknown, false
+	}
+	switch v.(type) {
+	case *ir.InstAdd:
+		return rx.Add(ry), kx.Add(ky), true
+	case *ir.InstSub:
+		return rx.Sub(ry), kx.Sub(ky), true
+	case *ir.InstMul:
+		return rx.Mul(ry), kx.Mul(ky), true
+	case *ir.InstUDiv:
+		return rx.UDiv(ry), unknown, true
+	case *ir.InstURem:
+		return rx.URem(ry), unknown, true
+	case *ir.InstAnd:
+		return rx.And(ry), kx.And(ky), true
+	case *ir.InstOr:
+		return rx.Or(ry), kx.Or(ky), true
+	case *ir.InstXor:
+		return rx.Xor(ry), kx.Xor(ky), true
+	case *ir.InstShl:
+		if shift, ok := constantShift(ry); ok {
+			return rx.Shl(ry), kx.Shl(shift), true
+		}
+		return rx.Shl(ry), unknown, true
+	case *ir.InstLShr:
+		if shift, ok := constantShift(ry); ok {
+			return rx.LShr(ry), kx.LShr(shift), true
+		}
+		return rx.LShr(ry), unknown, true
+	case *ir.InstAShr:
+		if shift, ok := constantShift(ry); ok {
+			return rx.AShr(ry), kx.AShr(shift), true
+		}
+		return rx.AShr(ry), unknown, true
+	}
+	return full, unknown, true
+}
+
+// transferPhi returns the range and known bits of the given phi instruction,
+// as the union of its incoming values refined by the conditions of the
+// incoming control flow edges.
+func (info *Info) transferPhi(phi *ir.InstPhi, block *ir.Block) (ConstantRange, KnownBits, bool) {
+	n := bitSize(phi)
+	r, k := EmptyRange(n), UnknownBits(n)
+	found := false
+	for _, inc := range phi.Incs {
+		pred, ok := inc.Pred.(*ir.Block)
+		if !ok || !info.dt.IsReachable(pred) {
+			continue
+		}
+		rx, kx, ok := info.operand(inc.X, pred)
+		if !ok {
+			continue
+		}
+		if region, ok := info.edgeRegion(inc.X, pred, block); ok {
+			rx = rx.Intersect(region)
+		}
+		if !found {
+			r, k = rx, kx
+			found = true
+			continue
+		}
+		r, k = r.Union(rx), k.Union(kx)
+	}
+	return r, k, found
+}
+
+// operand returns the range and known bits of the given integer operand used in
+// the given basic block. The boolean return value reports whether the operand
+// is known to be reachable.
+func (info *Info) operand(v value.Value, block *ir.Block) (ConstantRange, KnownBits, bool) {
+	n := bitSize(v)
+	if c, ok := v.(*constant.Int); ok {
+		return SingleRange(c.X, n), ConstantBits(c.X, n), true
+	}
+	r, ok := info.ranges[v]
+	k := info.bits[v]
+	if !ok {
+		if _, ok := info.blockOf[v]; ok {
+			return EmptyRange(n), UnknownBits(n), false
+		}
+		// Parameters, global values and constant expressions.
+		r, k = FullRange(n), UnknownBits(n)
+	}
+	refined := info.refine(v, r, block)
+	return refined, k.Merge(RangeBits(refined)), true
+}
+
+// refine returns the range r of the given value refined by the conditions of
+// the branches dominating the given basic block.
+func (info *Info) refine(v value.Value, r ConstantRange, block *ir.Block) ConstantRange {
+	if _, ok := v.(constant.Constant); ok {
+		return r
+	}
+	// A basic block with a single predecessor is only reached along the edge
+	// from its predecessor, and so are the basic blocks it dominates.
+	for b := block; b != nil; b = info.dt.IDom(b) {
+		if preds := info.preds[b]; len(preds) == 1 {
+			if region, ok := info.edgeRegion(v, preds[0], b); ok {
+				r = r.Intersect(region)
+			}
+		}
+	}
+	return r
+}
+
+// edgeRegion returns the range of the given integer value implied by the
+// terminator of from when branching to to, and reports whether the terminator
+// implies a range.
+func (info *Info) edgeRegion(v value.Value, from, to *ir.Block) (ConstantRange, bool) {
+	n := bitSize(v)
+	switch term := from.Term.(type) {
+	case *ir.TermCondBr:
+		if term.TargetTrue == term.TargetFalse {
+			return ConstantRange{}, false
+		}
+		taken := term.TargetTrue == to
+		if term.Cond == v {
+			x := big.NewInt(0)
+			if taken {
+				x.SetInt64(1)
+			}
+			return SingleRange(x, n), true
+		}
+		cond, ok := term.Cond.(*ir.InstICmp)
+		if !ok {
+			return ConstantRange{}, false
+		}
+		pred := cond.Pred
+		if !taken {
+			pred = irutil.InverseIPred(pred)
+		}
+		switch v {
+		case cond.X:
+			return ICmpRegion(pred, info.Range(cond.Y)), true
+		case cond.Y:
+			return ICmpRegion(irutil.SwapIPred(pred), info.Range(cond.X)), true
+		}
+	case *ir.TermSwitch:
+		if term.X != v {
+			return ConstantRange{}, false
+		}
+		// The default target is taken if no case matches, and a case target if
+		// one of its cases match.
+		isDefault := term.TargetDefault == to
+		r := EmptyRange(n)
+		if isDefault {
+			r = FullRange(n)
+		}
+		for _, c := range term.Cases {
+			x, ok := c.X.(*constant.Int)
+			if !ok {
+				return ConstantRange{}, false
+			}
+			single := SingleRange(x.X, n)
+			switch {
+			case isDefault && c.Target != to:
+				r = r.Intersect(single.Inverse())
+			case !isDefault && c.Target == to:
+				r = r.Union(single)
+			}
+		}
+		return r, true
+	}
+	return ConstantRange{}, false
+}
+
+// ### [ Helper functions ] ####################################################
+
+// bitSize returns the bit size of the given integer value.
+func bitSize(v value.Value) uint64 {
+	typ, ok := v.Type().(*types.IntType)
+	if !ok {
+		panic(fmt.Errorf("invalid type of value %q; expected integer type, got %T", v.Ident(), v.Type()))
+	}
+	return typ.BitSize
+}
+
+// binaryOperands returns the operands of the given binary or bitwise
+// instruction, and reports whether v is such an instruction.
+func binaryOperands(v value.Value) (x, y value.Value, ok bool) {
+	switch v := v.(type) {
+	case *ir.InstAdd:
+		return v.X, v.Y, true
+	case *ir.InstSub:
+		return v.X, v.Y, true
+	case *ir.InstMul:
+		return v.X, v.Y, true
+	case *ir.InstUDiv:
+		return v.X, v.Y, true
+	case *ir.InstURem:
+		return v.X, v.Y, true
+	case *ir.InstAnd:
+		return v.X, v.Y, true
+	case *ir.InstOr:
+		return v.X, v.Y, true
+	case *ir.InstXor:
+		return v.X, v.Y, true
+	case *ir.InstShl:
+		return v.X, v.Y, true
+	case *ir.InstLShr:
+		return v.X, v.Y, true
+	case *ir.InstAShr:
+		return v.X, v.Y, true
+	}
+	return nil, nil, false
+}
+
+// constantShift returns the shift amount of the given range, and reports
+// whether the range contains a single valid shift amount.
+func constantShift(r ConstantRange) (uint, bool) {
+	x, ok := r.SingleElement()
+	if !ok || x.Cmp(new(big.Int).SetUint64(r.BitSize)) >= 0 {
+		return 0, false
+	}
+	return uint(x.Uint64()), true
+}
+
+// rangeMetadata returns the range given by the !range metadata attachment of
+// the given metadata attachments; or the full range if not present.
+//
+// The !range metadata node is a tuple of pairs of integer constants, each
+// specifying a range [lower, upper) of possible values.
+func rangeMetadata(mds []*metadata.Attachment, bitSize uint64) ConstantRange {
+	for _, md := range mds {
+		if md.Name != "range" {
+			continue
+		}
+		t, ok := md.Node.(*metadata.Tuple)
+		if !ok || len(t.Fields) == 0 || len(t.Fields)%2 != 0 {
+			break
+		}
+		r := EmptyRange(bitSize)
+		for i := 0; i < len(t.Fields); i += 2 {
+			lower, ok1 := intField(t.Fields[i])
+			upper, ok2 := intField(t.Fields[i+1])
+			if !ok1 || !ok2 {
+				return FullRange(bitSize)
+			}
+			r = r.Union(NewRange(lower, upper, bitSize))
+		}
+		return r
+	}
+	return FullRange(bitSize)
+}
+
+// intField returns the integer value of the given metadata field.
+func intField(field metadata.Field) (*big.Int, bool) {
+	if v, ok := field.(*metadata.Value); ok {
+		field = v.Value
+	}
+	c, ok := field.(*constant.Int)
+	if !ok {
+		return nil, false
+	}
+	return c.X, true
+}
+
+// widen returns the range r widened with regards to the previous range old;
+// the signed bounds of r which have grown beyond those of old are widened to
+// the signed bounds of the type.
+func widen(old, r ConstantRange) ConstantRange {
+	if old.IsEmptySet() || r.IsEmptySet() {
+		return r
+	}
+	n := r.BitSize
+	lo, hi := r.SignedMin(), r.SignedMax()
+	if lo.Cmp(old.SignedMin()) < 0 {
+		lo = minSigned(n)
+	}
+	if hi.Cmp(old.SignedMax()) > 0 {
+		hi = maxSigned(n)
+	}
+	return boundsRange(lo, hi, n)
+}
+
+// equalRange reports whether the ranges x and y are identical.
+func equalRange(x, y ConstantRange) bool {
+	return x.Lower.Cmp(y.Lower) == 0 && x.Upper.Cmp(y.Upper) == 0
+}
+
+// equalBits reports whether the known bits x and y are identical.
+func equalBits(x, y KnownBits) bool {
+	return x.Zero.Cmp(y.Zero) == 0 && x.One.Cmp(y.One) == 0
+}
@@ -0,0 +1,163 @@
+package valuerange
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/value"
+)
+
+func TestNewInfo(t *testing.T) {
+	const src = `
+define void @f(i32 %x, i32* %p) {
+entry:
+	%m = and i32 %x, 15
+	%t = icmp ult i32 %m, 16
+	%l = load i32, i32* %p, !range !0
+	%z = zext i32 %l to i64
+	%s = shl i64 %z, 2
+	%o = or i32 %m, 32
+	%d = sub i32 %m, 20
+	%c = icmp ult i32 %x, 10
+	br i1 %c, label %small, label %large
+
+small:
+	%a = add i32 %x, 5
+	br label %loop
+
+large:
+	switch i32 %x, label %exit [
+		i32 20, label %sw
+		i32 21, label %sw
+	]
+
+sw:
+	br label %exit
+
+loop:
+	%i = phi i32 [ 0, %small ], [ %i.next, %body ]
+	%cmp = icmp slt i32 %i, 100
+	br i1 %cmp, label %body, label %exit
+
+body:
+	%i.next = add i32 %i, 1
+	br label %loop
+
+exit:
+	ret void
+}
+
+!0 = !{i32 0, i32 8}
+`
+	m, err := asm.ParseString("valuerange.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	f := m.Funcs[0]
+	info := NewInfo(f)
+	vals, blocks := locals(f)
+	golden := []struct {
+		v     string
+		block string // basic block of use; or empty for definition
+		want  string
+	}{
+		{v: "m", want: "[0,16)"},
+		{v: "t", want: "[-1,0)"},
+		{v: "l", want: "[0,8)"},
+		{v: "z", want: "[0,8)"},
+		{v: "s", want: "[0,29)"},
+		{v: "o", want: "[32,48)"},
+		{v: "d", want: "[-20,-4)"},
+		{v: "x", want: "full-set"},
+		{v: "x", block: "small", want: "[0,10)"},
+		{v: "x", block: "large", want: "[10,0)"},
+		{v: "a", want: "[5,15)"},
+		{v: "x", block: "sw", want: "[20,22)"},
+		{v: "i", block: "body", want: "[0,100)"},
+		{v: "i.next", want: "[1,101)"},
+	}
+	for _, g := range golden {
+		var got ConstantRange
+		if len(g.block) > 0 {
+			got = info.RangeAt(vals[g.v], blocks[g.block])
+		} else {
+			got = info.Range(vals[g.v])
+		}
+		if got.String() != g.want {
+			t.Errorf("range mismatch of %%%s in %q; expected %q, got %q", g.v, g.block, g.want, got)
+		}
+	}
+	bits := []struct {
+		v    string
+		want string
+	}{
+		{v: "m", want: "0000000000000000000000000000????"},
+		{v: "s", want: "00000000000000000000000000000000000000000000000000000000000???00"},
+		{v: "o", want: "0000000000000000000000000010????"},
+	}
+	for _, g := range bits {
+		if got := info.KnownBits(vals[g.v]).String(); got != g.want {
+			t.Errorf("known bits mismatch of %%%s; expected %q, got %q", g.v, g.want, got)
+		}
+	}
+}
+
+func TestConstantRange(t *testing.T) {
+	r := func(lo, hi int64) ConstantRange {
+		return NewRange(big.NewInt(lo), big.NewInt(hi), 8)
+	}
+	golden := []struct {
+		name string
+		got  ConstantRange
+		want string
+	}{
+		{name: "union", got: r(0, 10).Union(r(20, 30)), want: "[0,30)"},
+		{name: "union wrapped", got: r(0, 10).Union(r(-10, -5)), want: "[-10,10)"},
+		{name: "intersect", got: r(0, 10).Intersect(r(5, 20)), want: "[5,10)"},
+		{name: "intersect wrapped", got: r(-10, 10).Intersect(r(5, 20)), want: "[5,10)"},
+		{name: "intersect disjoint", got: r(0, 10).Intersect(r(20, 30)), want: "empty-set"},
+		{name: "add", got: r(0, 10).Add(r(5, 6)), want: "[5,15)"},
+		{name: "add wrapping", got: r(120, 127).Add(r(10, 11)), want: "[-126,-119)"},
+		{name: "sub", got: r(10, 20).Sub(r(0, 5)), want: "[6,20)"},
+		{name: "mul", got: r(-2, 3).Mul(r(3, 4)), want: "[-6,7)"},
+		{name: "udiv", got: r(10, 20).UDiv(r(2, 5)), want: "[2,10)"},
+		{name: "urem", got: r(0, 100).URem(r(8, 9)), want: "[0,8)"},
+		{name: "lshr", got: r(0, 100).LShr(r(2, 3)), want: "[0,25)"},
+		{name: "ashr", got: r(-100, 100).AShr(r(2, 3)), want: "[-25,25)"},
+		{name: "trunc", got: r(0, 100).Trunc(4), want: "full-set"},
+		{name: "sext", got: r(-5, 5).SExt(16), want: "[-5,5)"},
+		{name: "zext", got: r(-5, 5).ZExt(16), want: "[0,256)"},
+		{name: "slt", got: ICmpRegion(enum.IPredSLT, r(10, 11)), want: "[-128,10)"},
+		{name: "uge", got: ICmpRegion(enum.IPredUGE, r(10, 20)), want: "[10,0)"},
+		{name: "ne", got: ICmpRegion(enum.IPredNE, r(10, 11)), want: "[11,10)"},
+	}
+	for _, g := range golden {
+		if got := g.got.String(); got != g.want {
+			t.Errorf("range mismatch of %s; expected %q, got %q", g.name, g.want, got)
+		}
+	}
+	if !r(-10, 10).Contains(big.NewInt(-1)) || r(-10, 10).Contains(big.NewInt(10)) {
+		t.Errorf("containment mismatch of %v", r(-10, 10))
+	}
+}
+
+// locals returns the named instructions and basic blocks of f.
+func locals(f *ir.Func) (map[string]value.Value, map[string]*ir.Block) {
+	vals := make(map[string]value.Value)
+	blocks := make(map[string]*ir.Block)
+	for _, param := range f.Params {
+		vals[param.Name()] = param
+	}
+	for _, block := range f.Blocks {
+		blocks[block.Name()] = block
+		for _, inst := range block.Insts {
+			if v, ok := inst.(value.Named); ok {
+				vals[v.Name()] = v
+			}
+		}
+	}
+	return vals, blocks
+}
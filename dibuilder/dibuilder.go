@@ -0,0 +1,517 @@
+// Package dibuilder implements a builder of debug information metadata (DWARF)
+// for LLVM IR modules.
+//
+// The builder creates the specialized metadata nodes of debug information (see
+// ir/metadata), and adds them as metadata definitions of its module. Nodes are
+// linked as follows:
+//
+//	Node                        Links to
+//
+//	DICompileUnit               file, global variable expressions, enum types
+//	DISubprogram                scope, file, subroutine type, compile unit
+//	DILexicalBlock              scope (subprogram or lexical block), file
+//	DILocalVariable             scope, file, type
+//	DIGlobalVariableExpression  global variable (scope, file, type), expression
+//	DILocation                  scope
+//
+// Debug information is attached to functions, global variables, instructions
+// and terminators as !dbg metadata attachments, and local variables are
+// described by calls to the llvm.dbg.declare and llvm.dbg.value intrinsics.
+// Finalize completes the compile unit and adds it to the !llvm.dbg.cu named
+// metadata of the module, along with the "Debug Info Version" module flag.
+//
+// ref: https://llvm.org/docs/SourceLevelDebugging.html
+package dibuilder
+
+import (
+	"fmt"
+
+	"github.com/llir/llvm/intrinsics"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/metadata"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// DebugInfoVersion is the version of the debug information metadata format, as
+// recorded by the "Debug Info Version" module flag.
+const DebugInfoVersion = 3
+
+// Builder is a builder of the debug information of one compile unit of a
+// module.
+type Builder struct {
+	// Module of the debug information.
+	m *ir.Module
+	// Compile unit; nil if not yet created.
+	cu *metadata.DICompileUnit
+	// Global variable expressions of the compile unit.
+	globals []metadata.Field
+	// Enumeration types of the compile unit.
+	enums []metadata.Field
+}
+
+// New returns a new builder of debug information in the given module.
+func New(m *ir.Module) *Builder {
+	return &Builder{m: m}
+}
+
+// CompileUnit returns the compile unit of the builder; or nil if not yet
+// created.
+func (b *Builder) CompileUnit() *metadata.DICompileUnit {
+	return b.cu
+}
+
+// --- [ Scopes ] --------------------------------------------------------------
+
+// NewFile returns a new file based on the given file name and directory.
+func (b *Builder) NewFile(filename, directory string) *metadata.DIFile {
+	file := &metadata.DIFile{Filename: filename, Directory: directory}
+	b.define(file)
+	return file
+}
+
+// NewCompileUnit returns the compile unit of the builder based on the given
+// source language, file, producer and optimization. NewCompileUnit may only be
+// invoked once per builder.
+func (b *Builder) NewCompileUnit(lang enum.DwarfLang, file *metadata.DIFile, producer string, isOptimized bool) *metadata.DICompileUnit {
+	if b.cu != nil {
+		panic(fmt.Errorf("compile unit %v already created by builder", b.cu.File.Filename))
+	}
+	b.cu = &metadata.DICompileUnit{
+		Distinct:     true,
+		Language:     lang,
+		File:         file,
+		Producer:     producer,
+		IsOptimized:  isOptimized,
+		EmissionKind: enum.EmissionKindFullDebug,
+	}
+	b.define(b.cu)
+	return b.cu
+}
+
+// NewFunction returns a new subprogram based on the given scope, name, linkage
+// name (empty if same as name), file, line, subroutine type, scope line, flags
+// and subprogram specific flags. Subprograms with the DISPFlagDefinition flag
+// are function definitions, which are distinct and belong to the compile unit
+// of the builder.
+func (b *Builder) NewFunction(scope metadata.Field, name, linkageName string, file *metadata.DIFile, line int64, typ *metadata.DISubroutineType, scopeLine int64, flags enum.DIFlag, spFlags enum.DISPFlag) *metadata.DISubprogram {
+	sp := &metadata.DISubprogram{
+		Scope:       scope,
+		Name:        name,
+		LinkageName: linkageName,
+		File:        file,
+		Line:        line,
+		Type:        typ,
+		ScopeLine:   scopeLine,
+		Flags:       flags,
+		SPFlags:     spFlags,
+	}
+	if spFlags&enum.DISPFlagDefinition != 0 {
+		sp.Distinct = true
+		sp.Unit = b.unit()
+	}
+	b.define(sp)
+	return sp
+}
+
+// NewLexicalBlock returns a new lexical block based on the given scope
+// (subprogram or lexical block), file, line and column.
+func (b *Builder) NewLexicalBlock(scope metadata.Field, file *metadata.DIFile, line, column int64) *metadata.DILexicalBlock {
+	block := &metadata.DILexicalBlock{
+		Distinct: true,
+		Scope:    scope,
+		File:     file,
+		Line:     line,
+		Column:   column,
+	}
+	b.define(block)
+	return block
+}
+
+// --- [ Types ] ---------------------------------------------------------------
+
+// NewBasicType returns a new basic type based on the given name, size in bits
+// and encoding.
+func (b *Builder) NewBasicType(name string, size uint64, encoding enum.DwarfAttEncoding) *metadata.DIBasicType {
+	t := &metadata.DIBasicType{Name: name, Size: size, Encoding: encoding}
+	b.define(t)
+	return t
+}
+
+// NewPointerType returns a new pointer type based on the given pointee type and
+// size in bits.
+func (b *Builder) NewPointerType(base metadata.Field, size uint64) *metadata.DIDerivedType {
+	t := &metadata.DIDerivedType{Tag: enum.DwarfTagPointerType, BaseType: base, Size: size}
+	b.define(t)
+	return t
+}
+
+// NewQualifiedType returns a new qualified type based on the given tag (e.g.
+// DwarfTagConstType or DwarfTagVolatileType) and base type.
+func (b *Builder) NewQualifiedType(tag enum.DwarfTag, base metadata.Field) *metadata.DIDerivedType {
+	t := &metadata.DIDerivedType{Tag: tag, BaseType: base}
+	b.define(t)
+	return t
+}
+
+// NewTypedef returns a new typedef based on the given base type, name, file,
+// line and scope.
+func (b *Builder) NewTypedef(base metadata.Field, name string, file *metadata.DIFile, line int64, scope metadata.Field) *metadata.DIDerivedType {
+	t := &metadata.DIDerivedType{
+		Tag:      enum.DwarfTagTypedef,
+		Name:     name,
+		Scope:    scope,
+		File:     file,
+		Line:     line,
+		BaseType: base,
+	}
+	b.define(t)
+	return t
+}
+
+// NewMemberType returns a new member of a struct or union type based on the
+// given scope (the struct or union type), name, file, line, size, alignment and
+// offset in bits, flags and member type.
+func (b *Builder) NewMemberType(scope metadata.Field, name string, file *metadata.DIFile, line int64, size, align, offset uint64, flags enum.DIFlag, base metadata.Field) *metadata.DIDerivedType {
+	t := &metadata.DIDerivedType{
+		Tag:      enum.DwarfTagMember,
+		Name:     name,
+		Scope:    scope,
+		File:     file,
+		Line:     line,
+		BaseType: base,
+		Size:     size,
+		Align:    align,
+		Offset:   offset,
+		Flags:    flags,
+	}
+	b.define(t)
+	return t
+}
+
+// NewStructType returns a new struct type based on the given scope, name, file,
+// line, size and alignment in bits, flags and members.
+//
+// Members are created by NewMemberType with the struct type as scope; as such,
+// the members of a struct type may be set by SetElements after creation.
+func (b *Builder) NewStructType(scope metadata.Field, name string, file *metadata.DIFile, line int64, size, align uint64, flags enum.DIFlag, elements ...metadata.Field) *metadata.DICompositeType {
+	return b.newCompositeType(enum.DwarfTagStructureType, scope, name, file, line, size, align, flags, elements)
+}
+
+// NewUnionType returns a new union type based on the given scope, name, file,
+// line, size and alignment in bits, flags and members.
+func (b *Builder) NewUnionType(scope metadata.Field, name string, file *metadata.DIFile, line int64, size, align uint64, flags enum.DIFlag, elements ...metadata.Field) *metadata.DICompositeType {
+	return b.newCompositeType(enum.DwarfTagUnionType, scope, name, file, line, size, align, flags, elements)
+}
+
+// NewArrayType returns a new array type based on the given size and alignment
+// in bits, element type and subscripts (as created by NewSubrange).
+func (b *Builder) NewArrayType(size, align uint64, elemType metadata.Field, subscripts ...metadata.Field) *metadata.DICompositeType {
+	t := &metadata.DICompositeType{
+		Tag:      enum.DwarfTagArrayType,
+		BaseType: elemType,
+		Size:     size,
+		Align:    align,
+		Elements: b.NewTuple(subscripts...),
+	}
+	b.define(t)
+	return t
+}
+
+// NewSubrange returns a new subrange of an array type based on the given element
+// count.
+func (b *Builder) NewSubrange(count int64) *metadata.DISubrange {
+	s := &metadata.DISubrange{Count: metadata.IntLit(count)}
+	b.define(s)
+	return s
+}
+
+// NewEnumerationType returns a new enumeration type based on the given scope,
+// name, file, line, size and alignment in bits, enumerators (as created by
+// NewEnumerator) and underlying type. Enumeration types are retained by the
+// compile unit of the builder.
+func (b *Builder) NewEnumerationType(scope metadata.Field, name string, file *metadata.DIFile, line int64, size, align uint64, enumerators []metadata.Field, base metadata.Field) *metadata.DICompositeType {
+	t := b.newCompositeType(enum.DwarfTagEnumerationType, scope, name, file, line, size, align, 0, enumerators)
+	t.BaseType = base
+	b.enums = append(b.enums, t)
+	return t
+}
+
+// NewEnumerator returns a new enumerator based on the given name and value.
+func (b *Builder) NewEnumerator(name string, val int64, isUnsigned bool) *metadata.DIEnumerator {
+	e := &metadata.DIEnumerator{Name: name, Value: val, IsUnsigned: isUnsigned}
+	b.define(e)
+	return e
+}
+
+// NewSubroutineType returns a new subroutine type based on the given return
+// type (nil for void) and parameter types.
+func (b *Builder) NewSubroutineType(retType metadata.Field, paramTypes ...metadata.Field) *metadata.DISubroutineType {
+	if retType == nil {
+		retType = metadata.Null
+	}
+	t := &metadata.DISubroutineType{
+		Types: b.NewTuple(append([]metadata.Field{retType}, paramTypes...)...),
+	}
+	b.define(t)
+	return t
+}
+
+// SetElements sets the elements (e.g. members) of the given composite type.
+func (b *Builder) SetElements(t *metadata.DICompositeType, elements ...metadata.Field) {
+	t.Elements = b.setTuple(t.Elements, elements)
+}
+
+// --- [ Variables ] -----------------------------------------------------------
+
+// NewAutoVariable returns a new local variable based on the given scope, name,
+// file, line and type.
+func (b *Builder) NewAutoVariable(scope metadata.Field, name string, file *metadata.DIFile, line int64, typ metadata.Field) *metadata.DILocalVariable {
+	v := &metadata.DILocalVariable{
+		Scope: scope,
+		Name:  name,
+		File:  file,
+		Line:  line,
+		Type:  typ,
+	}
+	b.define(v)
+	return v
+}
+
+// NewParameterVariable returns a new parameter variable based on the given
+// scope, name, argument number (1-based), file, line and type.
+func (b *Builder) NewParameterVariable(scope metadata.Field, name string, argNo uint64, file *metadata.DIFile, line int64, typ metadata.Field) *metadata.DILocalVariable {
+	v := b.NewAutoVariable(scope, name, file, line, typ)
+	v.Arg = argNo
+	return v
+}
+
+// NewGlobalVariableExpression returns a new global variable expression based on
+// the given scope, name, linkage name (empty if same as name), file, line,
+// type, local linkage and location expression (nil for the empty expression).
+// Global variable expressions are retained by the compile unit of the builder.
+func (b *Builder) NewGlobalVariableExpression(scope metadata.Field, name, linkageName string, file *metadata.DIFile, line int64, typ metadata.Field, isLocal bool, expr *metadata.DIExpression) *metadata.DIGlobalVariableExpression {
+	v := &metadata.DIGlobalVariable{
+		Distinct:     true,
+		Name:         name,
+		Scope:        scope,
+		LinkageName:  linkageName,
+		File:         file,
+		Line:         line,
+		Type:         typ,
+		IsLocal:      isLocal,
+		IsDefinition: true,
+	}
+	b.define(v)
+	if expr == nil {
+		expr = b.NewExpression()
+	}
+	gve := &metadata.DIGlobalVariableExpression{Var: v, Expr: expr}
+	b.define(gve)
+	b.globals = append(b.globals, gve)
+	return gve
+}
+
+// NewExpression returns a new location expression based on the given DWARF
+// operations and operands. Expressions are not metadata definitions, and are
+// printed inline.
+func (b *Builder) NewExpression(fields ...metadata.DIExpressionField) *metadata.DIExpression {
+	return &metadata.DIExpression{MetadataID: -1, Fields: fields}
+}
+
+// NewLocation returns a new source location based on the given line, column
+// and scope.
+func (b *Builder) NewLocation(line, column int64, scope metadata.Field) *metadata.DILocation {
+	loc := &metadata.DILocation{Line: line, Column: column, Scope: scope}
+	b.define(loc)
+	return loc
+}
+
+// NewTuple returns a new metadata tuple based on the given fields.
+func (b *Builder) NewTuple(fields ...metadata.Field) *metadata.Tuple {
+	tuple := &metadata.Tuple{Fields: fields}
+	b.define(tuple)
+	return tuple
+}
+
+// --- [ Attachments ] ---------------------------------------------------------
+
+// Attachable is an instruction or terminator which may have metadata
+// attachments.
+type Attachable interface {
+	// SetAttachment sets the metadata attachment of the given name.
+	SetAttachment(name string, node metadata.MDNode)
+}
+
+// AttachLocation attaches the given source location as !dbg metadata to inst.
+//
+// To attach source locations to every instruction created by an ir.Builder,
+// use ir.Builder.SetDebugLoc.
+func (b *Builder) AttachLocation(inst Attachable, loc *metadata.DILocation) {
+	inst.SetAttachment("dbg", loc)
+}
+
+// AttachSubprogram attaches the given subprogram as !dbg metadata to f.
+func (b *Builder) AttachSubprogram(f *ir.Func, sp *metadata.DISubprogram) {
+	f.SetAttachment("dbg", sp)
+}
+
+// AttachGlobalVariableExpression attaches the given global variable expression
+// as !dbg metadata to g. Global variables may have several !dbg attachments
+// (e.g. after merging of global variables).
+func (b *Builder) AttachGlobalVariableExpression(g *ir.Global, gve *metadata.DIGlobalVariableExpression) {
+	g.Metadata = append(g.Metadata, &metadata.Attachment{Name: "dbg", Node: gve})
+}
+
+// --- [ Intrinsic calls ] -----------------------------------------------------
+
+// InsertDeclare inserts a call to llvm.dbg.declare at the insertion point of ib,
+// describing the local variable v stored at the address storage (e.g. an
+// alloca) with the given location expression (nil for the empty expression)
+// and source location.
+func (b *Builder) InsertDeclare(ib *ir.Builder, storage value.Value, v *metadata.DILocalVariable, expr *metadata.DIExpression, loc *metadata.DILocation) (*ir.InstCall, error) {
+	return b.insertDbgCall(ib, intrinsics.DbgDeclare, storage, v, expr, loc)
+}
+
+// InsertValue inserts a call to llvm.dbg.value at the insertion point of ib,
+// describing that the local variable v has the value val from this point on,
+// with the given location expression (nil for the empty expression) and source
+// location.
+func (b *Builder) InsertValue(ib *ir.Builder, val value.Value, v *metadata.DILocalVariable, expr *metadata.DIExpression, loc *metadata.DILocation) (*ir.InstCall, error) {
+	return b.insertDbgCall(ib, intrinsics.DbgValue, val, v, expr, loc)
+}
+
+// --- [ Finalization ] --------------------------------------------------------
+
+// Finalize completes the compile unit of the builder with its global variable
+// expressions and enumeration types, and adds the compile unit to the
+// !llvm.dbg.cu named metadata of the module. The "Debug Info Version" module
+// flag is added to !llvm.module.flags unless already present.
+func (b *Builder) Finalize() {
+	cu := b.unit()
+	cu.Globals = b.setTuple(cu.Globals, b.globals)
+	cu.Enums = b.setTuple(cu.Enums, b.enums)
+	cus := b.namedDef("llvm.dbg.cu")
+	if !containsNode(cus.Nodes, cu) {
+		cus.Nodes = append(cus.Nodes, cu)
+	}
+	flags := b.namedDef("llvm.module.flags")
+	if !hasModuleFlag(flags, "Debug Info Version") {
+		// Behaviour 2 (Warning) emits a warning on mismatching versions when
+		// linking modules.
+		flag := b.NewTuple(constant.NewInt(types.I32, 2), &metadata.String{Value: "Debug Info Version"}, constant.NewInt(types.I32, DebugInfoVersion))
+		flags.Nodes = append(flags.Nodes, flag)
+	}
+}
+
+// ### [ Helper functions ] ####################################################
+
+// define adds the given metadata node as an unnamed metadata definition of the
+// module.
+func (b *Builder) define(md metadata.Definition) {
+	md.SetID(-1)
+	b.m.MetadataDefs = append(b.m.MetadataDefs, md)
+}
+
+// unit returns the compile unit of the builder, or panics if not yet created.
+func (b *Builder) unit() *metadata.DICompileUnit {
+	if b.cu == nil {
+		panic(fmt.Errorf("compile unit not yet created by builder"))
+	}
+	return b.cu
+}
+
+// newCompositeType returns a new composite type based on the given tag, scope,
+// name, file, line, size and alignment in bits, flags and elements.
+func (b *Builder) newCompositeType(tag enum.DwarfTag, scope metadata.Field, name string, file *metadata.DIFile, line int64, size, align uint64, flags enum.DIFlag, elements []metadata.Field) *metadata.DICompositeType {
+	t := &metadata.DICompositeType{
+		Tag:   tag,
+		Name:  name,
+		Scope: scope,
+		File:  file,
+		Line:  line,
+		Size:  size,
+		Align: align,
+		Flags: flags,
+	}
+	b.define(t)
+	// Define elements after the composite type, as elements typically refer
+	// back to the composite type.
+	t.Elements = b.setTuple(nil, elements)
+	return t
+}
+
+// insertDbgCall inserts a call to the given debug info intrinsic at the
+// insertion point of ib.
+func (b *Builder) insertDbgCall(ib *ir.Builder, id intrinsics.ID, x value.Value, v *metadata.DILocalVariable, expr *metadata.DIExpression, loc *metadata.DILocation) (*ir.InstCall, error) {
+	callee, err := intrinsics.Declare(b.m, id)
+	if err != nil {
+		return nil, err
+	}
+	if expr == nil {
+		expr = b.NewExpression()
+	}
+	args := []value.Value{
+		&metadata.Value{Value: x},
+		&metadata.Value{Value: v},
+		&metadata.Value{Value: expr},
+	}
+	call := ib.NewCall(callee, args...)
+	if call == nil {
+		// Invalid call in checking mode of the builder.
+		return nil, ib.Err()
+	}
+	call.SetAttachment("dbg", loc)
+	return call, nil
+}
+
+// setTuple sets the fields of the given tuple, creating the tuple if nil. A nil
+// tuple is left nil if no fields are given.
+func (b *Builder) setTuple(tuple *metadata.Tuple, fields []metadata.Field) *metadata.Tuple {
+	if tuple != nil {
+		tuple.Fields = fields
+		return tuple
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return b.NewTuple(fields...)
+}
+
+// namedDef returns the named metadata definition of the given name in the
+// module, creating it if not present.
+func (b *Builder) namedDef(name string) *metadata.NamedDef {
+	def, ok := b.m.NamedMetadataDefs[name]
+	if !ok {
+		def = &metadata.NamedDef{Name: name}
+		b.m.NamedMetadataDefs[name] = def
+	}
+	return def
+}
+
+// containsNode reports whether nodes contains node.
+func containsNode(nodes []metadata.Node, node metadata.Node) bool {
+	for _, n := range nodes {
+		if n == node {
+			return true
+		}
+	}
+	return false
+}
+
+// hasModuleFlag reports whether the given !llvm.module.flags named metadata
+// contains a module flag of the given name.
+func hasModuleFlag(flags *metadata.NamedDef, name string) bool {
+	for _, node := range flags.Nodes {
+		tuple, ok := node.(*metadata.Tuple)
+		if !ok || len(tuple.Fields) != 3 {
+			continue
+		}
+		if s, ok := tuple.Fields[1].(*metadata.String); ok && s.Value == name {
+			return true
+		}
+	}
+	return false
+}
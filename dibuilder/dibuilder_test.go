@@ -0,0 +1,125 @@
+package dibuilder
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/metadata"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestBuilder(t *testing.T) {
+	const want = `@n = global i32 42, !dbg !8
+
+define i32 @f(i32 %a) !dbg !11 {
+entry:
+	call void @llvm.dbg.value(metadata i32 %a, metadata !12, metadata !DIExpression()), !dbg !13
+	%0 = alloca { i32, i32 }
+	call void @llvm.dbg.declare(metadata { i32, i32 }* %0, metadata !14, metadata !DIExpression()), !dbg !15
+	call void @llvm.dbg.declare(metadata { i32, i32 }* %0, metadata !16, metadata !DIExpression(DW_OP_plus_uconst, 4)), !dbg !17
+	%1 = getelementptr { i32, i32 }, { i32, i32 }* %0, i32 0, i32 0, !dbg !18
+	store i32 %a, i32* %1, !dbg !18
+	%2 = load i32, i32* %1, !dbg !19
+	ret i32 %2, !dbg !19
+}
+
+declare void @llvm.dbg.value(metadata %0, metadata %1, metadata %2) nofree nosync nounwind readnone speculatable willreturn
+
+declare void @llvm.dbg.declare(metadata %0, metadata %1, metadata %2) nofree nosync nounwind readnone speculatable willreturn
+
+!llvm.dbg.cu = !{!1}
+!llvm.module.flags = !{!21}
+
+!0 = !DIFile(filename: "point.c", directory: "/tmp")
+!1 = distinct !DICompileUnit(language: DW_LANG_C99, file: !0, producer: "llir", emissionKind: FullDebug, globals: !20)
+!2 = !DIBasicType(name: "int", size: 32, encoding: DW_ATE_signed)
+!3 = !DICompositeType(tag: DW_TAG_structure_type, name: "point", scope: !0, file: !0, line: 1, size: 64, align: 32, elements: !6)
+!4 = !DIDerivedType(tag: DW_TAG_member, name: "x", scope: !3, file: !0, line: 1, baseType: !2, size: 32)
+!5 = !DIDerivedType(tag: DW_TAG_member, name: "y", scope: !3, file: !0, line: 1, baseType: !2, size: 32, offset: 32)
+!6 = !{!4, !5}
+!7 = distinct !DIGlobalVariable(name: "n", scope: !1, file: !0, line: 2, type: !2, isDefinition: true)
+!8 = !DIGlobalVariableExpression(var: !7, expr: !DIExpression())
+!9 = !{!2, !2}
+!10 = !DISubroutineType(types: !9)
+!11 = distinct !DISubprogram(name: "f", scope: !0, file: !0, line: 3, type: !10, scopeLine: 3, flags: DIFlagPrototyped, spFlags: DISPFlagDefinition, unit: !1)
+!12 = !DILocalVariable(name: "a", arg: 1, scope: !11, file: !0, line: 3, type: !2)
+!13 = !DILocation(line: 3, column: 11, scope: !11)
+!14 = !DILocalVariable(name: "p", scope: !11, file: !0, line: 4, type: !3)
+!15 = !DILocation(line: 4, column: 15, scope: !11)
+!16 = !DILocalVariable(name: "p.y", scope: !11, file: !0, line: 4, type: !2)
+!17 = !DILocation(line: 4, column: 15, scope: !11)
+!18 = !DILocation(line: 5, column: 6, scope: !11)
+!19 = !DILocation(line: 6, column: 2, scope: !11)
+!20 = !{!8}
+!21 = !{i32 2, !"Debug Info Version", i32 3}
+`
+
+	// Debug information of the following C program.
+	//
+	//	1  struct point { int x, y; };
+	//	2  int n = 42;
+	//	3  int f(int a) {
+	//	4  	struct point p;
+	//	5  	p.x = a;
+	//	6  	return p.x;
+	//	7  }
+	m := ir.NewModule()
+	db := New(m)
+	file := db.NewFile("point.c", "/tmp")
+	cu := db.NewCompileUnit(enum.DwarfLangC99, file, "llir", false)
+	intType := db.NewBasicType("int", 32, enum.DwarfAttEncodingSigned)
+	pointType := db.NewStructType(file, "point", file, 1, 64, 32, 0)
+	db.SetElements(pointType,
+		db.NewMemberType(pointType, "x", file, 1, 32, 0, 0, 0, intType),
+		db.NewMemberType(pointType, "y", file, 1, 32, 0, 32, 0, intType),
+	)
+	// Global variable.
+	n := m.NewGlobalDef("n", constant.NewInt(types.I32, 42))
+	gve := db.NewGlobalVariableExpression(cu, "n", "", file, 2, intType, false, nil)
+	db.AttachGlobalVariableExpression(n, gve)
+	// Function.
+	a := ir.NewParam("a", types.I32)
+	f := m.NewFunc("f", types.I32, a)
+	fType := db.NewSubroutineType(intType, intType)
+	sp := db.NewFunction(file, "f", "", file, 3, fType, 3, enum.DIFlagPrototyped, enum.DISPFlagDefinition)
+	db.AttachSubprogram(f, sp)
+	b := ir.NewBuilderAtEnd(f.NewBlock("entry"))
+	aVar := db.NewParameterVariable(sp, "a", 1, file, 3, intType)
+	if _, err := db.InsertValue(b, a, aVar, nil, db.NewLocation(3, 11, sp)); err != nil {
+		t.Fatalf("unable to insert llvm.dbg.value; %v", err)
+	}
+	p := b.NewAlloca(types.NewStruct(types.I32, types.I32))
+	pVar := db.NewAutoVariable(sp, "p", file, 4, pointType)
+	if _, err := db.InsertDeclare(b, p, pVar, nil, db.NewLocation(4, 15, sp)); err != nil {
+		t.Fatalf("unable to insert llvm.dbg.declare; %v", err)
+	}
+	// Field y of p, as described by a location expression.
+	yVar := db.NewAutoVariable(sp, "p.y", file, 4, intType)
+	expr := db.NewExpression(enum.DwarfOpPlusUconst, metadata.UintLit(4))
+	if _, err := db.InsertDeclare(b, p, yVar, expr, db.NewLocation(4, 15, sp)); err != nil {
+		t.Fatalf("unable to insert llvm.dbg.declare; %v", err)
+	}
+	b.SetDebugLoc(db.NewLocation(5, 6, sp))
+	x := b.NewGetElementPtr(p.ElemType, p, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, 0))
+	b.NewStore(a, x)
+	b.SetDebugLoc(db.NewLocation(6, 2, sp))
+	b.NewRet(b.NewLoad(types.I32, x))
+	db.Finalize()
+	// Finalize is idempotent with regards to named metadata.
+	db.Finalize()
+	got := m.String()
+	if got != want {
+		t.Errorf("module mismatch; expected %q, got %q", want, got)
+	}
+	// Round-trip through the parser.
+	m2, err := asm.ParseString("point.ll", got)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	if got := m2.String(); got != want {
+		t.Errorf("round-trip module mismatch; expected %q, got %q", want, got)
+	}
+}
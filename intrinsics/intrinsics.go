@@ -0,0 +1,377 @@
+// Package intrinsics provides a catalogue of LLVM intrinsic functions, with
+// helpers to declare intrinsics in modules and to recognize calls to intrinsics
+// in parsed LLVM IR.
+//
+// Each intrinsic of the catalogue is described by its name, the kinds of its
+// overloaded type parameters, its signature in terms of the overloaded types,
+// and its function attributes. Overloaded intrinsics are declared once per
+// combination of overloaded types, with the overloaded types mangled into the
+// name of the function; e.g.
+//
+//	llvm.memcpy.p0i8.p0i8.i64       ; memcpy with i8* dst, i8* src and i64 len
+//	llvm.sadd.with.overflow.i32     ; signed add with overflow of i32 operands
+//	llvm.ctpop.v4i32                ; population count of <4 x i32> operand
+//	llvm.dbg.value                  ; not overloaded
+//
+// The mangling of overloaded types follows LLVM (with typed pointers):
+//
+//	Type             Mangled   Example
+//
+//	iN               iN        i32
+//	float types      fN        f32, f64, f80, f128, ppcf128
+//	T addrspace(N)*  pN T      p0i8
+//	[N x T]          aN T      a4i32
+//	<N x T>          vN T      v4i32
+//	<vscale x N x T> nxvN T    nxv4i32
+//	named struct     s_name    s_struct.foo
+//	literal struct   sl_ T* s  sl_i32i8s
+//	function type    f_ R P* f f_i32i8f
+//
+// ref: https://llvm.org/docs/LangRef.html#intrinsic-functions
+package intrinsics
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/irutil"
+	"github.com/pkg/errors"
+)
+
+// Intrinsic is the description of an LLVM intrinsic function.
+type Intrinsic struct {
+	// Intrinsic ID.
+	ID ID
+	// Name of the intrinsic, without the mangled suffix of overloaded types;
+	// e.g. "llvm.memcpy".
+	Name string
+	// Kinds of the overloaded type parameters, in order of the mangled suffix.
+	Overloads []Kind
+	// Function attributes of declarations of the intrinsic.
+	FuncAttrs []enum.FuncAttr
+
+	// Return type in terms of the overloaded types.
+	ret pattern
+	// Parameter types in terms of the overloaded types.
+	params []pattern
+}
+
+// Get returns the description of the intrinsic with the given ID; or nil if not
+// present in the catalogue.
+func Get(id ID) *Intrinsic {
+	if id == NotIntrinsic || int(id) >= len(table) {
+		return nil
+	}
+	return table[id]
+}
+
+// Signature returns the function signature of the intrinsic given its
+// overloaded types.
+func (in *Intrinsic) Signature(overloadTypes ...types.Type) (*types.FuncType, error) {
+	if len(overloadTypes) != len(in.Overloads) {
+		return nil, errors.Errorf("overloaded type count mismatch of %s; expected %d, got %d", in.Name, len(in.Overloads), len(overloadTypes))
+	}
+	for i, t := range overloadTypes {
+		if !in.Overloads[i].match(t) {
+			return nil, errors.Errorf("invalid overloaded type %d of %s; expected %v, got %v", i, in.Name, in.Overloads[i], t)
+		}
+	}
+	var params []types.Type
+	for _, param := range in.params {
+		params = append(params, param.resolve(overloadTypes))
+	}
+	return types.NewFunc(in.ret.resolve(overloadTypes), params...), nil
+}
+
+// FuncName returns the function name of the intrinsic given its overloaded
+// types; i.e. the name of the intrinsic followed by the mangled overloaded
+// types.
+func (in *Intrinsic) FuncName(overloadTypes ...types.Type) string {
+	buf := &strings.Builder{}
+	buf.WriteString(in.Name)
+	for _, t := range overloadTypes {
+		buf.WriteString(".")
+		buf.WriteString(Mangle(t))
+	}
+	return buf.String()
+}
+
+// Declare returns the declaration of the intrinsic with the given ID and
+// overloaded types in m. An existing declaration is reused if present, and
+// otherwise a new function declaration is appended to m.
+func Declare(m *ir.Module, id ID, overloadTypes ...types.Type) (*ir.Func, error) {
+	in := Get(id)
+	if in == nil {
+		return nil, errors.Errorf("invalid intrinsic ID %d", id)
+	}
+	sig, err := in.Signature(overloadTypes...)
+	if err != nil {
+		return nil, err
+	}
+	name := in.FuncName(overloadTypes...)
+	for _, f := range m.Funcs {
+		if f.Name() != name {
+			continue
+		}
+		if !f.Sig.Equal(sig) {
+			return nil, errors.Errorf("signature mismatch of existing declaration %s; expected %v, got %v", f.Ident(), sig, f.Sig)
+		}
+		return f, nil
+	}
+	var params []*ir.Param
+	for _, paramType := range sig.Params {
+		params = append(params, ir.NewParam("", paramType))
+	}
+	f := m.NewFunc(name, sig.RetType, params...)
+	for _, attr := range in.FuncAttrs {
+		f.FuncAttrs = append(f.FuncAttrs, attr)
+	}
+	return f, nil
+}
+
+// Lookup returns the ID and overloaded types of the intrinsic declared by the
+// given function. NotIntrinsic is returned if the function is not a declaration
+// of an intrinsic of the catalogue with a matching name and signature.
+func Lookup(f *ir.Func) (ID, []types.Type) {
+	name := f.Name()
+	if !strings.HasPrefix(name, "llvm.") {
+		return NotIntrinsic, nil
+	}
+	for _, in := range table[1:] {
+		if name != in.Name && !strings.HasPrefix(name, in.Name+".") {
+			continue
+		}
+		if overloadTypes, ok := in.match(f.Sig); ok && in.FuncName(overloadTypes...) == name {
+			return in.ID, overloadTypes
+		}
+	}
+	return NotIntrinsic, nil
+}
+
+// LookupCall returns the ID and overloaded types of the intrinsic invoked by the
+// given call instruction, looking through pointer casts of the callee.
+// NotIntrinsic is returned if the callee is not an intrinsic of the catalogue.
+func LookupCall(inst *ir.InstCall) (ID, []types.Type) {
+	callee := irutil.Callee(inst.Callee)
+	if callee == nil {
+		return NotIntrinsic, nil
+	}
+	return Lookup(callee)
+}
+
+// Mangle returns the mangled representation of the given type, as used in the
+// function names of overloaded intrinsics.
+func Mangle(t types.Type) string {
+	switch t := t.(type) {
+	case *types.VoidType:
+		return "isVoid"
+	case *types.FuncType:
+		buf := &strings.Builder{}
+		buf.WriteString("f_")
+		buf.WriteString(Mangle(t.RetType))
+		for _, param := range t.Params {
+			buf.WriteString(Mangle(param))
+		}
+		if t.Variadic {
+			buf.WriteString("vararg")
+		}
+		buf.WriteString("f")
+		return buf.String()
+	case *types.IntType:
+		return fmt.Sprintf("i%d", t.BitSize)
+	case *types.FloatType:
+		switch t.Kind {
+		case types.FloatKindHalf:
+			return "f16"
+		case types.FloatKindFloat:
+			return "f32"
+		case types.FloatKindDouble:
+			return "f64"
+		case types.FloatKindX86_FP80:
+			return "f80"
+		case types.FloatKindFP128:
+			return "f128"
+		case types.FloatKindPPC_FP128:
+			return "ppcf128"
+		default:
+			panic(fmt.Errorf("support for floating-point kind %v not yet implemented", t.Kind))
+		}
+	case *types.MMXType:
+		return "x86mmx"
+	case *types.PointerType:
+		return fmt.Sprintf("p%d%s", uint64(t.AddrSpace), Mangle(t.ElemType))
+	case *types.VectorType:
+		if t.Scalable {
+			return fmt.Sprintf("nxv%d%s", t.Len, Mangle(t.ElemType))
+		}
+		return fmt.Sprintf("v%d%s", t.Len, Mangle(t.ElemType))
+	case *types.LabelType:
+		return "label"
+	case *types.TokenType:
+		return "token"
+	case *types.MetadataType:
+		return "Metadata"
+	case *types.ArrayType:
+		return fmt.Sprintf("a%d%s", t.Len, Mangle(t.ElemType))
+	case *types.StructType:
+		if len(t.TypeName) > 0 {
+			return "s_" + t.TypeName
+		}
+		buf := &strings.Builder{}
+		buf.WriteString("sl_")
+		for _, field := range t.Fields {
+			buf.WriteString(Mangle(field))
+		}
+		buf.WriteString("s")
+		return buf.String()
+	default:
+		panic(fmt.Errorf("support for type %T not yet implemented", t))
+	}
+}
+
+// --- [ Overloaded types ] ----------------------------------------------------
+
+// Kind is the kind of an overloaded type parameter of an intrinsic.
+type Kind uint8
+
+// Kinds of overloaded type parameters.
+const (
+	// Integer or vector of integers.
+	KindInt Kind = iota
+	// Floating-point or vector of floating-points.
+	KindFloat
+	// Pointer.
+	KindPointer
+)
+
+// String returns the string representation of the kind of overloaded type
+// parameter.
+func (kind Kind) String() string {
+	switch kind {
+	case KindInt:
+		return "integer or vector of integers"
+	case KindFloat:
+		return "floating-point or vector of floating-points"
+	case KindPointer:
+		return "pointer"
+	default:
+		return fmt.Sprintf("Kind(%d)", uint8(kind))
+	}
+}
+
+// match reports whether the given type is of the kind.
+func (kind Kind) match(t types.Type) bool {
+	if vt, ok := t.(*types.VectorType); ok && kind != KindPointer {
+		t = vt.ElemType
+	}
+	switch kind {
+	case KindInt:
+		return types.IsInt(t)
+	case KindFloat:
+		return types.IsFloat(t)
+	case KindPointer:
+		return types.IsPointer(t)
+	default:
+		panic(fmt.Errorf("support for overloaded type kind %v not yet implemented", kind))
+	}
+}
+
+// pattern is a type of the signature of an intrinsic, in terms of its
+// overloaded types.
+//
+// A pattern has one of the following underlying types.
+//
+//	concrete
+//	overload
+//	withOverflow
+type pattern interface {
+	// resolve returns the type of the pattern given the overloaded types.
+	resolve(overloadTypes []types.Type) types.Type
+	// bind matches the pattern against t, binding the overloaded types of the
+	// pattern not yet bound (nil), and reports whether t matches.
+	bind(t types.Type, overloadTypes []types.Type) bool
+}
+
+// concrete is a type which does not depend on the overloaded types.
+type concrete struct {
+	types.Type
+}
+
+// resolve returns the type of the pattern given the overloaded types.
+func (p concrete) resolve(overloadTypes []types.Type) types.Type {
+	return p.Type
+}
+
+// bind matches the pattern against t.
+func (p concrete) bind(t types.Type, overloadTypes []types.Type) bool {
+	return p.Type.Equal(t)
+}
+
+// overload is the overloaded type of the given index.
+type overload int
+
+// resolve returns the type of the pattern given the overloaded types.
+func (p overload) resolve(overloadTypes []types.Type) types.Type {
+	return overloadTypes[p]
+}
+
+// bind matches the pattern against t, binding the overloaded type of the
+// pattern.
+func (p overload) bind(t types.Type, overloadTypes []types.Type) bool {
+	if overloadTypes[p] == nil {
+		overloadTypes[p] = t
+		return true
+	}
+	return overloadTypes[p].Equal(t)
+}
+
+// withOverflow is the result type of arithmetic with overflow intrinsics on the
+// overloaded type T of the given index; i.e. { T, i1 } for scalars, and
+// { <N x T>, <N x i1> } for vectors.
+type withOverflow int
+
+// resolve returns the type of the pattern given the overloaded types.
+func (p withOverflow) resolve(overloadTypes []types.Type) types.Type {
+	t := overloadTypes[p]
+	var overflow types.Type = types.I1
+	if vt, ok := t.(*types.VectorType); ok {
+		overflow = &types.VectorType{Scalable: vt.Scalable, Len: vt.Len, ElemType: types.I1}
+	}
+	return types.NewStruct(t, overflow)
+}
+
+// bind matches the pattern against t, binding the overloaded type of the
+// pattern.
+func (p withOverflow) bind(t types.Type, overloadTypes []types.Type) bool {
+	st, ok := t.(*types.StructType)
+	if !ok || len(st.Fields) != 2 || !overload(p).bind(st.Fields[0], overloadTypes) {
+		return false
+	}
+	return p.resolve(overloadTypes).Equal(t)
+}
+
+// match matches the signature of the intrinsic against sig, and returns the
+// overloaded types of the intrinsic.
+func (in *Intrinsic) match(sig *types.FuncType) ([]types.Type, bool) {
+	if len(sig.Params) != len(in.params) || sig.Variadic {
+		return nil, false
+	}
+	overloadTypes := make([]types.Type, len(in.Overloads))
+	if !in.ret.bind(sig.RetType, overloadTypes) {
+		return nil, false
+	}
+	for i, param := range in.params {
+		if !param.bind(sig.Params[i], overloadTypes) {
+			return nil, false
+		}
+	}
+	for i, t := range overloadTypes {
+		if t == nil || !in.Overloads[i].match(t) {
+			return nil, false
+		}
+	}
+	return overloadTypes, true
+}
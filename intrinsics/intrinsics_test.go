@@ -0,0 +1,123 @@
+package intrinsics
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestDeclare(t *testing.T) {
+	m := ir.NewModule()
+	golden := []struct {
+		id            ID
+		overloadTypes []types.Type
+		want          string
+	}{
+		{id: Memcpy, overloadTypes: []types.Type{types.I8Ptr, types.I8Ptr, types.I64}, want: "declare void @llvm.memcpy.p0i8.p0i8.i64(i8* %0, i8* %1, i64 %2, i1 %3) argmemonly nofree nounwind willreturn"},
+		{id: SAddWithOverflow, overloadTypes: []types.Type{types.I32}, want: "declare { i32, i1 } @llvm.sadd.with.overflow.i32(i32 %0, i32 %1) nofree nosync nounwind readnone speculatable willreturn"},
+		{id: Ctpop, overloadTypes: []types.Type{types.NewVector(4, types.I32)}, want: "declare <4 x i32> @llvm.ctpop.v4i32(<4 x i32> %0) nofree nosync nounwind readnone speculatable willreturn"},
+		{id: InvariantStart, overloadTypes: []types.Type{types.I8Ptr}, want: "declare {}* @llvm.invariant.start.p0i8(i64 %0, i8* %1) argmemonly nofree nosync nounwind willreturn"},
+		{id: DbgValue, want: "declare void @llvm.dbg.value(metadata %0, metadata %1, metadata %2) nofree nosync nounwind readnone speculatable willreturn"},
+		{id: PowI, overloadTypes: []types.Type{types.Double, types.I32}, want: "declare double @llvm.powi.f64.i32(double %0, i32 %1) nofree nosync nounwind readnone speculatable willreturn"},
+	}
+	for _, g := range golden {
+		f, err := Declare(m, g.id, g.overloadTypes...)
+		if err != nil {
+			t.Errorf("unable to declare %v; %v", g.id, err)
+			continue
+		}
+		if err := f.AssignIDs(); err != nil {
+			t.Fatalf("unable to assign IDs of %s; %+v", f.Ident(), err)
+		}
+		if got := f.LLString(); got != g.want {
+			t.Errorf("declaration mismatch of %v; expected %q, got %q", g.id, g.want, got)
+		}
+		// Existing declarations are reused.
+		if f2, err := Declare(m, g.id, g.overloadTypes...); err != nil || f2 != f {
+			t.Errorf("declaration of %v not reused; %v", g.id, err)
+		}
+	}
+	if got, want := len(m.Funcs), len(golden); got != want {
+		t.Errorf("number of functions mismatch; expected %d, got %d", want, got)
+	}
+	// Invalid overloaded types.
+	if _, err := Declare(m, Ctpop, types.Float); err == nil {
+		t.Errorf("expected error for llvm.ctpop with floating-point overloaded type")
+	}
+	if _, err := Declare(m, Memset, types.I8Ptr); err == nil {
+		t.Errorf("expected error for llvm.memset with too few overloaded types")
+	}
+}
+
+func TestLookupCall(t *testing.T) {
+	const src = `
+declare void @llvm.memset.p0i8.i64(i8*, i8, i64, i1)
+
+declare double @llvm.exp2.f64(double)
+
+declare { <2 x i8>, <2 x i1> } @llvm.umul.with.overflow.v2i8(<2 x i8>, <2 x i8>)
+
+declare void @llvm.dbg.label(metadata)
+
+declare i32 @llvm.ctpop.i64(i32)
+
+declare void @llvm.unknown()
+
+declare void @memset(i8*, i8, i64, i1)
+
+define void @f(i8* %p, double %x, <2 x i8> %v) {
+entry:
+	call void @llvm.memset.p0i8.i64(i8* %p, i8 0, i64 8, i1 false)
+	%y = call double @llvm.exp2.f64(double %x)
+	%w = call { <2 x i8>, <2 x i1> } @llvm.umul.with.overflow.v2i8(<2 x i8> %v, <2 x i8> %v)
+	%n = call i32 @llvm.ctpop.i64(i32 1)
+	call void @llvm.unknown()
+	call void @memset(i8* %p, i8 0, i64 8, i1 false)
+	call void bitcast (double (double)* @llvm.exp2.f64 to void ()*)()
+	ret void
+}
+`
+	m, err := asm.ParseString("intrinsics.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	golden := []struct {
+		id   ID
+		name string
+	}{
+		{id: Memset, name: "llvm.memset.p0i8.i64"},
+		{id: Exp2, name: "llvm.exp2.f64"},
+		{id: UMulWithOverflow, name: "llvm.umul.with.overflow.v2i8"},
+		// Name mismatch of overloaded type.
+		{id: NotIntrinsic},
+		// Unknown intrinsic.
+		{id: NotIntrinsic},
+		// Not an intrinsic.
+		{id: NotIntrinsic},
+		// Calls through pointer casts.
+		{id: Exp2, name: "llvm.exp2.f64"},
+	}
+	f := m.Funcs[len(m.Funcs)-1]
+	insts := f.Blocks[0].Insts
+	if len(insts) != len(golden) {
+		t.Fatalf("number of instructions mismatch; expected %d, got %d", len(golden), len(insts))
+	}
+	for i, g := range golden {
+		id, overloadTypes := LookupCall(insts[i].(*ir.InstCall))
+		if id != g.id {
+			t.Errorf("intrinsic ID mismatch of call %d; expected %v, got %v", i, g.id, id)
+			continue
+		}
+		if id == NotIntrinsic {
+			continue
+		}
+		if got := Get(id).FuncName(overloadTypes...); got != g.name {
+			t.Errorf("function name mismatch of call %d; expected %q, got %q", i, g.name, got)
+		}
+	}
+	if id, _ := Lookup(m.Funcs[3]); id != DbgLabel {
+		t.Errorf("intrinsic ID mismatch of %s; expected %v, got %v", m.Funcs[3].Ident(), DbgLabel, id)
+	}
+}
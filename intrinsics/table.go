@@ -0,0 +1,257 @@
+package intrinsics
+
+import (
+	"fmt"
+
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+)
+
+// ID is the ID of an LLVM intrinsic of the catalogue.
+type ID uint16
+
+// Intrinsic IDs.
+const (
+	// Not an intrinsic of the catalogue.
+	NotIntrinsic ID = iota
+
+	// Standard C library intrinsics.
+	Memcpy  // llvm.memcpy
+	Memmove // llvm.memmove
+	Memset  // llvm.memset
+
+	// Memory use marker intrinsics.
+	LifetimeStart  // llvm.lifetime.start
+	LifetimeEnd    // llvm.lifetime.end
+	InvariantStart // llvm.invariant.start
+	InvariantEnd   // llvm.invariant.end
+
+	// Debugger intrinsics.
+	DbgDeclare // llvm.dbg.declare
+	DbgValue   // llvm.dbg.value
+	DbgLabel   // llvm.dbg.label
+
+	// Arithmetic with overflow intrinsics.
+	SAddWithOverflow // llvm.sadd.with.overflow
+	UAddWithOverflow // llvm.uadd.with.overflow
+	SSubWithOverflow // llvm.ssub.with.overflow
+	USubWithOverflow // llvm.usub.with.overflow
+	SMulWithOverflow // llvm.smul.with.overflow
+	UMulWithOverflow // llvm.umul.with.overflow
+
+	// Saturation arithmetic intrinsics.
+	SAddSat // llvm.sadd.sat
+	UAddSat // llvm.uadd.sat
+	SSubSat // llvm.ssub.sat
+	USubSat // llvm.usub.sat
+
+	// Integer intrinsics.
+	Abs  // llvm.abs
+	SMax // llvm.smax
+	SMin // llvm.smin
+	UMax // llvm.umax
+	UMin // llvm.umin
+
+	// Bit manipulation intrinsics.
+	Ctpop      // llvm.ctpop
+	Ctlz       // llvm.ctlz
+	Cttz       // llvm.cttz
+	BSwap      // llvm.bswap
+	BitReverse // llvm.bitreverse
+	FShl       // llvm.fshl
+	FShr       // llvm.fshr
+
+	// Floating-point math intrinsics.
+	Sqrt      // llvm.sqrt
+	FAbs      // llvm.fabs
+	Sin       // llvm.sin
+	Cos       // llvm.cos
+	Exp       // llvm.exp
+	Exp2      // llvm.exp2
+	Log       // llvm.log
+	Log2      // llvm.log2
+	Log10     // llvm.log10
+	Floor     // llvm.floor
+	Ceil      // llvm.ceil
+	Trunc     // llvm.trunc
+	Rint      // llvm.rint
+	NearbyInt // llvm.nearbyint
+	Round     // llvm.round
+	Pow       // llvm.pow
+	PowI      // llvm.powi
+	FMA       // llvm.fma
+	CopySign  // llvm.copysign
+	MinNum    // llvm.minnum
+	MaxNum    // llvm.maxnum
+
+	// Variable argument handling intrinsics.
+	VAStart // llvm.va_start
+	VAEnd   // llvm.va_end
+	VACopy  // llvm.va_copy
+
+	// General intrinsics.
+	Trap          // llvm.trap
+	DebugTrap     // llvm.debugtrap
+	Expect        // llvm.expect
+	Assume        // llvm.assume
+	StackSave     // llvm.stacksave
+	StackRestore  // llvm.stackrestore
+	ObjectSize    // llvm.objectsize
+	ReturnAddress // llvm.returnaddress
+	FrameAddress  // llvm.frameaddress
+	Prefetch      // llvm.prefetch
+)
+
+// String returns the name of the intrinsic with the given ID.
+func (id ID) String() string {
+	if in := Get(id); in != nil {
+		return in.Name
+	}
+	if id == NotIntrinsic {
+		return "NotIntrinsic"
+	}
+	return fmt.Sprintf("ID(%d)", uint16(id))
+}
+
+// Types of intrinsic signatures.
+var (
+	// Overloaded types.
+	t0 = overload(0)
+	t1 = overload(1)
+	t2 = overload(2)
+	// Concrete types.
+	void  = concrete{types.Void}
+	i1    = concrete{types.I1}
+	i8    = concrete{types.I8}
+	i32   = concrete{types.I32}
+	i64   = concrete{types.I64}
+	i8ptr = concrete{types.I8Ptr}
+	md    = concrete{types.Metadata}
+	// Pointer to empty struct ({}*), as used by invariant markers.
+	emptyPtr = concrete{types.NewPointer(types.NewStruct())}
+)
+
+// Function attributes of intrinsics.
+var (
+	// Pure intrinsics, without side effects.
+	pureAttrs = []enum.FuncAttr{enum.FuncAttrNoFree, enum.FuncAttrNoSync, enum.FuncAttrNoUnwind, enum.FuncAttrReadNone, enum.FuncAttrSpeculatable, enum.FuncAttrWillReturn}
+	// Pure intrinsics, which may not be speculatively executed.
+	readNoneAttrs = []enum.FuncAttr{enum.FuncAttrNoFree, enum.FuncAttrNoSync, enum.FuncAttrNoUnwind, enum.FuncAttrReadNone, enum.FuncAttrWillReturn}
+	// Memory transfer intrinsics, accessing memory through their arguments.
+	memTransferAttrs = []enum.FuncAttr{enum.FuncAttrArgMemOnly, enum.FuncAttrNoFree, enum.FuncAttrNoUnwind, enum.FuncAttrWillReturn}
+	// Memory set intrinsics, writing memory through their arguments.
+	memSetAttrs = []enum.FuncAttr{enum.FuncAttrArgMemOnly, enum.FuncAttrNoFree, enum.FuncAttrNoUnwind, enum.FuncAttrWillReturn, enum.FuncAttrWriteOnly}
+	// Memory use marker intrinsics.
+	lifetimeAttrs = []enum.FuncAttr{enum.FuncAttrArgMemOnly, enum.FuncAttrNoFree, enum.FuncAttrNoSync, enum.FuncAttrNoUnwind, enum.FuncAttrWillReturn}
+	// Intrinsics with side effects, which always return.
+	sideEffectAttrs = []enum.FuncAttr{enum.FuncAttrNoFree, enum.FuncAttrNoSync, enum.FuncAttrNoUnwind, enum.FuncAttrWillReturn}
+)
+
+// table is the catalogue of intrinsics, indexed by intrinsic ID.
+var table = []*Intrinsic{
+	NotIntrinsic: nil,
+	// Standard C library intrinsics.
+	Memcpy:  def("llvm.memcpy", kinds(KindPointer, KindPointer, KindInt), memTransferAttrs, void, t0, t1, t2, i1),
+	Memmove: def("llvm.memmove", kinds(KindPointer, KindPointer, KindInt), memTransferAttrs, void, t0, t1, t2, i1),
+	Memset:  def("llvm.memset", kinds(KindPointer, KindInt), memSetAttrs, void, t0, i8, t1, i1),
+	// Memory use marker intrinsics.
+	LifetimeStart:  def("llvm.lifetime.start", kinds(KindPointer), lifetimeAttrs, void, i64, t0),
+	LifetimeEnd:    def("llvm.lifetime.end", kinds(KindPointer), lifetimeAttrs, void, i64, t0),
+	InvariantStart: def("llvm.invariant.start", kinds(KindPointer), lifetimeAttrs, emptyPtr, i64, t0),
+	InvariantEnd:   def("llvm.invariant.end", kinds(KindPointer), lifetimeAttrs, void, emptyPtr, i64, t0),
+	// Debugger intrinsics.
+	DbgDeclare: def("llvm.dbg.declare", nil, pureAttrs, void, md, md, md),
+	DbgValue:   def("llvm.dbg.value", nil, pureAttrs, void, md, md, md),
+	DbgLabel:   def("llvm.dbg.label", nil, pureAttrs, void, md),
+	// Arithmetic with overflow intrinsics.
+	SAddWithOverflow: def("llvm.sadd.with.overflow", kinds(KindInt), pureAttrs, withOverflow(0), t0, t0),
+	UAddWithOverflow: def("llvm.uadd.with.overflow", kinds(KindInt), pureAttrs, withOverflow(0), t0, t0),
+	SSubWithOverflow: def("llvm.ssub.with.overflow", kinds(KindInt), pureAttrs, withOverflow(0), t0, t0),
+	USubWithOverflow: def("llvm.usub.with.overflow", kinds(KindInt), pureAttrs, withOverflow(0), t0, t0),
+	SMulWithOverflow: def("llvm.smul.with.overflow", kinds(KindInt), pureAttrs, withOverflow(0), t0, t0),
+	UMulWithOverflow: def("llvm.umul.with.overflow", kinds(KindInt), pureAttrs, withOverflow(0), t0, t0),
+	// Saturation arithmetic intrinsics.
+	SAddSat: def("llvm.sadd.sat", kinds(KindInt), pureAttrs, t0, t0, t0),
+	UAddSat: def("llvm.uadd.sat", kinds(KindInt), pureAttrs, t0, t0, t0),
+	SSubSat: def("llvm.ssub.sat", kinds(KindInt), pureAttrs, t0, t0, t0),
+	USubSat: def("llvm.usub.sat", kinds(KindInt), pureAttrs, t0, t0, t0),
+	// Integer intrinsics.
+	Abs:  def("llvm.abs", kinds(KindInt), pureAttrs, t0, t0, i1),
+	SMax: def("llvm.smax", kinds(KindInt), pureAttrs, t0, t0, t0),
+	SMin: def("llvm.smin", kinds(KindInt), pureAttrs, t0, t0, t0),
+	UMax: def("llvm.umax", kinds(KindInt), pureAttrs, t0, t0, t0),
+	UMin: def("llvm.umin", kinds(KindInt), pureAttrs, t0, t0, t0),
+	// Bit manipulation intrinsics.
+	Ctpop:      def("llvm.ctpop", kinds(KindInt), pureAttrs, t0, t0),
+	Ctlz:       def("llvm.ctlz", kinds(KindInt), pureAttrs, t0, t0, i1),
+	Cttz:       def("llvm.cttz", kinds(KindInt), pureAttrs, t0, t0, i1),
+	BSwap:      def("llvm.bswap", kinds(KindInt), pureAttrs, t0, t0),
+	BitReverse: def("llvm.bitreverse", kinds(KindInt), pureAttrs, t0, t0),
+	FShl:       def("llvm.fshl", kinds(KindInt), pureAttrs, t0, t0, t0, t0),
+	FShr:       def("llvm.fshr", kinds(KindInt), pureAttrs, t0, t0, t0, t0),
+	// Floating-point math intrinsics.
+	Sqrt:      def("llvm.sqrt", kinds(KindFloat), pureAttrs, t0, t0),
+	FAbs:      def("llvm.fabs", kinds(KindFloat), pureAttrs, t0, t0),
+	Sin:       def("llvm.sin", kinds(KindFloat), pureAttrs, t0, t0),
+	Cos:       def("llvm.cos", kinds(KindFloat), pureAttrs, t0, t0),
+	Exp:       def("llvm.exp", kinds(KindFloat), pureAttrs, t0, t0),
+	Exp2:      def("llvm.exp2", kinds(KindFloat), pureAttrs, t0, t0),
+	Log:       def("llvm.log", kinds(KindFloat), pureAttrs, t0, t0),
+	Log2:      def("llvm.log2", kinds(KindFloat), pureAttrs, t0, t0),
+	Log10:     def("llvm.log10", kinds(KindFloat), pureAttrs, t0, t0),
+	Floor:     def("llvm.floor", kinds(KindFloat), pureAttrs, t0, t0),
+	Ceil:      def("llvm.ceil", kinds(KindFloat), pureAttrs, t0, t0),
+	Trunc:     def("llvm.trunc", kinds(KindFloat), pureAttrs, t0, t0),
+	Rint:      def("llvm.rint", kinds(KindFloat), pureAttrs, t0, t0),
+	NearbyInt: def("llvm.nearbyint", kinds(KindFloat), pureAttrs, t0, t0),
+	Round:     def("llvm.round", kinds(KindFloat), pureAttrs, t0, t0),
+	Pow:       def("llvm.pow", kinds(KindFloat), pureAttrs, t0, t0, t0),
+	PowI:      def("llvm.powi", kinds(KindFloat, KindInt), pureAttrs, t0, t0, t1),
+	FMA:       def("llvm.fma", kinds(KindFloat), pureAttrs, t0, t0, t0, t0),
+	CopySign:  def("llvm.copysign", kinds(KindFloat), pureAttrs, t0, t0, t0),
+	MinNum:    def("llvm.minnum", kinds(KindFloat), pureAttrs, t0, t0, t0),
+	MaxNum:    def("llvm.maxnum", kinds(KindFloat), pureAttrs, t0, t0, t0),
+	// Variable argument handling intrinsics.
+	VAStart: def("llvm.va_start", nil, sideEffectAttrs, void, i8ptr),
+	VAEnd:   def("llvm.va_end", nil, sideEffectAttrs, void, i8ptr),
+	VACopy:  def("llvm.va_copy", nil, sideEffectAttrs, void, i8ptr, i8ptr),
+	// General intrinsics.
+	Trap:          def("llvm.trap", nil, []enum.FuncAttr{enum.FuncAttrCold, enum.FuncAttrNoReturn, enum.FuncAttrNoUnwind}, void),
+	DebugTrap:     def("llvm.debugtrap", nil, []enum.FuncAttr{enum.FuncAttrNoUnwind}, void),
+	Expect:        def("llvm.expect", kinds(KindInt), readNoneAttrs, t0, t0, t0),
+	Assume:        def("llvm.assume", nil, []enum.FuncAttr{enum.FuncAttrInaccessibleMemOnly, enum.FuncAttrNoFree, enum.FuncAttrNoSync, enum.FuncAttrNoUnwind, enum.FuncAttrWillReturn}, void, i1),
+	StackSave:     def("llvm.stacksave", nil, sideEffectAttrs, i8ptr),
+	StackRestore:  def("llvm.stackrestore", nil, sideEffectAttrs, void, i8ptr),
+	ObjectSize:    def("llvm.objectsize", kinds(KindInt, KindPointer), pureAttrs, t0, t1, i1, i1, i1),
+	ReturnAddress: def("llvm.returnaddress", nil, readNoneAttrs, i8ptr, i32),
+	FrameAddress:  def("llvm.frameaddress", kinds(KindPointer), readNoneAttrs, t0, i32),
+	Prefetch:      def("llvm.prefetch", kinds(KindPointer), []enum.FuncAttr{enum.FuncAttrInaccessibleMemOrArgMemOnly, enum.FuncAttrNoFree, enum.FuncAttrNoSync, enum.FuncAttrNoUnwind, enum.FuncAttrWillReturn}, void, t0, i32, i32, i32),
+}
+
+func init() {
+	for id, in := range table {
+		if in != nil {
+			in.ID = ID(id)
+		}
+	}
+}
+
+// ### [ Helper functions ] ####################################################
+
+// def returns a new intrinsic description based on the given name, kinds of
+// overloaded type parameters, function attributes, return type and parameter
+// types.
+func def(name string, overloads []Kind, attrs []enum.FuncAttr, ret pattern, params ...pattern) *Intrinsic {
+	return &Intrinsic{
+		Name:      name,
+		Overloads: overloads,
+		FuncAttrs: attrs,
+		ret:       ret,
+		params:    params,
+	}
+}
+
+// kinds returns the given kinds of overloaded type parameters.
+func kinds(kinds ...Kind) []Kind {
+	return kinds
+}
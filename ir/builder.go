@@ -0,0 +1,433 @@
+package ir
+
+import (
+	"fmt"
+
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/metadata"
+	"github.com/llir/llvm/ir/types"
+)
+
+// === [ Builder ] =============================================================
+
+// Builder creates instructions at an insertion point of a function; i.e. before
+// an instruction of a basic block, or at the end of a basic block (before its
+// terminator).
+//
+// In addition to the insertion point, a builder holds defaults applied to every
+// instruction and terminator created by the builder: a debug location, which
+// is attached as !dbg metadata, and fast-math flags, which are set on
+// floating-point instructions (and on phi, select and call instructions with a
+// floating-point result).
+//
+// In checking mode, instructions and terminators are type-checked (see CheckInst
+// and CheckTerm) as they are created. Instead of panicking or producing invalid
+// IR, the builder records the first type error, as returned by Err, and the
+// invalid instruction is neither inserted nor returned (i.e. nil is returned).
+//
+// Instructions of the ir package do not track their parent basic block. As
+// such, the builder locates instructions (e.g. in SetInsertPointBefore) by
+// scanning the basic blocks of its function.
+type Builder struct {
+	// Function of the builder; nil if not yet known.
+	f *Func
+	// Basic block of the insertion point; nil if not set.
+	block *Block
+	// Instruction before which instructions are inserted; nil to append to the
+	// end of block.
+	before Instruction
+	// Debug location attached to created instructions; nil if not set.
+	loc *metadata.DILocation
+	// Fast-math flags of created floating-point instructions.
+	fmf []enum.FastMathFlag
+	// Type-check created instructions and terminators.
+	checking bool
+	// First type error of the builder in checking mode; or nil if none.
+	err error
+	// Exits of the enclosing loops and switch-statements of the insertion point,
+	// innermost last.
+	exits []exit
+	// Names of the basic blocks created by the structured control flow helpers.
+	names map[string]bool
+}
+
+// NewBuilder returns a new builder of instructions in the given function,
+// without an insertion point.
+func NewBuilder(f *Func) *Builder {
+	return &Builder{f: f}
+}
+
+// NewBuilderAtEnd returns a new builder with an insertion point at the end of
+// the given basic block.
+func NewBuilderAtEnd(block *Block) *Builder {
+	b := &Builder{}
+	b.SetInsertPointAtEnd(block)
+	return b
+}
+
+// Func returns the function of the builder; or nil if not known.
+func (b *Builder) Func() *Func {
+	return b.f
+}
+
+// Block returns the basic block of the insertion point; or nil if not set.
+func (b *Builder) Block() *Block {
+	return b.block
+}
+
+// NewBlock appends a new basic block to the function of the builder, based on
+// the given label name. The insertion point is left unchanged.
+func (b *Builder) NewBlock(name string) *Block {
+	if b.f == nil {
+		panic(newBuilderError("unable to create basic block %q; builder has no function", name))
+	}
+	return b.f.NewBlock(name)
+}
+
+// --- [ Insertion points ] ----------------------------------------------------
+
+// InsertPoint is an insertion point of a builder.
+type InsertPoint struct {
+	// Basic block of the insertion point; nil if not set.
+	Block *Block
+	// Instruction before which instructions are inserted; nil to append to the
+	// end of Block.
+	Before Instruction
+}
+
+// InsertPoint returns the current insertion point of the builder.
+func (b *Builder) InsertPoint() InsertPoint {
+	return InsertPoint{Block: b.block, Before: b.before}
+}
+
+// SetInsertPoint sets the insertion point of the builder to ip, as returned by
+// InsertPoint.
+func (b *Builder) SetInsertPoint(ip InsertPoint) {
+	b.block, b.before = ip.Block, ip.Before
+	if ip.Block != nil && ip.Block.Parent != nil {
+		b.f = ip.Block.Parent
+	}
+}
+
+// SetInsertPointAtEnd sets the insertion point of the builder to the end of the
+// given basic block (before its terminator).
+func (b *Builder) SetInsertPointAtEnd(block *Block) {
+	b.SetInsertPoint(InsertPoint{Block: block})
+}
+
+// SetInsertPointBefore sets the insertion point of the builder to before the
+// given instruction.
+func (b *Builder) SetInsertPointBefore(inst Instruction) {
+	block, _ := b.find(inst)
+	b.SetInsertPoint(InsertPoint{Block: block, Before: inst})
+}
+
+// SetInsertPointAfter sets the insertion point of the builder to after the
+// given instruction.
+func (b *Builder) SetInsertPointAfter(inst Instruction) {
+	block, i := b.find(inst)
+	var before Instruction
+	if i+1 < len(block.Insts) {
+		before = block.Insts[i+1]
+	}
+	b.SetInsertPoint(InsertPoint{Block: block, Before: before})
+}
+
+// WithInsertPoint invokes fn with the insertion point of the builder set to ip,
+// and restores the insertion point afterwards.
+func (b *Builder) WithInsertPoint(ip InsertPoint, fn func()) {
+	old := b.InsertPoint()
+	defer b.SetInsertPoint(old)
+	b.SetInsertPoint(ip)
+	fn()
+}
+
+// --- [ Defaults ] ------------------------------------------------------------
+
+// DebugLoc returns the debug location attached to instructions created by the
+// builder; or nil if not set.
+func (b *Builder) DebugLoc() *metadata.DILocation {
+	return b.loc
+}
+
+// SetDebugLoc sets the debug location attached to instructions created by the
+// builder. A nil debug location indicates no debug location.
+func (b *Builder) SetDebugLoc(loc *metadata.DILocation) {
+	b.loc = loc
+}
+
+// WithDebugLoc invokes fn with the debug location of the builder set to loc, and
+// restores the debug location afterwards.
+func (b *Builder) WithDebugLoc(loc *metadata.DILocation, fn func()) {
+	old := b.loc
+	defer func() { b.loc = old }()
+	b.loc = loc
+	fn()
+}
+
+// FastMathFlags returns the fast-math flags of floating-point instructions
+// created by the builder.
+func (b *Builder) FastMathFlags() []enum.FastMathFlag {
+	return b.fmf
+}
+
+// SetFastMathFlags sets the fast-math flags of floating-point instructions
+// created by the builder.
+func (b *Builder) SetFastMathFlags(flags ...enum.FastMathFlag) {
+	b.fmf = flags
+}
+
+// WithFastMathFlags invokes fn with the fast-math flags of the builder set to
+// flags, and restores the fast-math flags afterwards.
+func (b *Builder) WithFastMathFlags(flags []enum.FastMathFlag, fn func()) {
+	old := b.fmf
+	defer func() { b.fmf = old }()
+	b.fmf = flags
+	fn()
+}
+
+// --- [ Checking mode ] -------------------------------------------------------
+
+// Checking reports whether the builder is in checking mode.
+func (b *Builder) Checking() bool {
+	return b.checking
+}
+
+// SetChecking enables or disables the checking mode of the builder.
+func (b *Builder) SetChecking(checking bool) {
+	b.checking = checking
+}
+
+// Err returns the first type error of instructions and terminators created by
+// the builder in checking mode; or nil if none.
+func (b *Builder) Err() error {
+	return b.err
+}
+
+// --- [ Removal and movement ] ------------------------------------------------
+
+// Remove removes the given instruction from its basic block. If the insertion
+// point of the builder is before the instruction, it is moved to before the
+// succeeding instruction.
+func (b *Builder) Remove(inst Instruction) {
+	block, i := b.find(inst)
+	if b.before == inst {
+		b.before = nil
+		if i+1 < len(block.Insts) {
+			b.before = block.Insts[i+1]
+		}
+	}
+	block.Insts = append(block.Insts[:i], block.Insts[i+1:]...)
+}
+
+// MoveBefore moves the given instruction to before the instruction before.
+func (b *Builder) MoveBefore(inst, before Instruction) {
+	if inst == before {
+		return
+	}
+	b.Remove(inst)
+	block, i := b.find(before)
+	insertAt(block, i, inst)
+}
+
+// MoveAfter moves the given instruction to after the instruction after.
+func (b *Builder) MoveAfter(inst, after Instruction) {
+	if inst == after {
+		return
+	}
+	b.Remove(inst)
+	block, i := b.find(after)
+	insertAt(block, i+1, inst)
+}
+
+// MoveToEnd moves the given instruction to the end of the given basic block
+// (before its terminator).
+func (b *Builder) MoveToEnd(inst Instruction, block *Block) {
+	b.Remove(inst)
+	block.Insts = append(block.Insts, inst)
+}
+
+// ### [ Helper functions ] ####################################################
+
+// insert inserts the given instruction at the insertion point, and applies the
+// defaults of the builder.
+func (b *Builder) insert(inst Instruction) {
+	if b.block == nil {
+		panic(newBuilderError("unable to insert instruction %q; builder has no insertion point", inst.LLString()))
+	}
+	if b.checking {
+		if err := CheckInst(inst); err != nil {
+			panic(err)
+		}
+	}
+	b.applyDefaults(inst)
+	if b.before == nil {
+		b.block.Insts = append(b.block.Insts, inst)
+		return
+	}
+	i := indexOf(b.block, b.before)
+	if i < 0 {
+		panic(newBuilderError("unable to insert instruction %q; insertion point %q not in basic block %s", inst.LLString(), b.before.LLString(), b.block.Ident()))
+	}
+	insertAt(b.block, i, inst)
+}
+
+// setTerm sets the terminator of the insertion block, and applies the defaults
+// of the builder.
+func (b *Builder) setTerm(term Terminator) {
+	if b.block == nil {
+		panic(newBuilderError("unable to set terminator %q; builder has no insertion point", term.LLString()))
+	}
+	if b.checking {
+		if err := CheckTerm(term); err != nil {
+			panic(err)
+		}
+		if ret, ok := term.(*TermRet); ok && b.f != nil {
+			if err := checkRet(ret, b.f.Sig.RetType); err != nil {
+				panic(err)
+			}
+		}
+	}
+	b.applyDefaults(term)
+	b.block.Term = term
+}
+
+// recoverCheck recovers from panics of the constructor of an instruction or
+// terminator with the given opcode in checking mode, and records the first type
+// error of the builder. Panics are propagated outside of checking mode, and
+// builder errors (i.e. misuse of the builder) are always propagated.
+//
+// recoverCheck must be deferred by each method of the builder which creates an
+// instruction or terminator.
+func (b *Builder) recoverCheck(op string) {
+	if !b.checking {
+		return
+	}
+	e := recover()
+	if e == nil {
+		return
+	}
+	if _, ok := e.(*builderError); ok {
+		// Misuse of the builder is not a type error.
+		panic(e)
+	}
+	err, ok := e.(*TypeError)
+	if !ok {
+		// Type errors detected by the constructor of the instruction.
+		err = &TypeError{Op: op, Msg: fmt.Sprint(e)}
+	}
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// builderError is an error caused by misuse of the builder (e.g. inserting an
+// instruction without an insertion point), as opposed to a type error of the
+// created instruction. Builder errors are propagated in checking mode.
+type builderError struct {
+	msg string
+}
+
+// newBuilderError returns a new builder error based on the given format
+// specifier and arguments.
+func newBuilderError(format string, args ...interface{}) *builderError {
+	return &builderError{msg: fmt.Sprintf(format, args...)}
+}
+
+// Error returns the string representation of the builder error.
+func (e *builderError) Error() string {
+	return e.msg
+}
+
+// applyDefaults applies the debug location and fast-math flags of the builder to
+// the given instruction or terminator.
+func (b *Builder) applyDefaults(inst interface{}) {
+	if b.loc != nil {
+		if md, ok := inst.(interface {
+			SetAttachment(name string, node metadata.MDNode)
+		}); ok {
+			md.SetAttachment("dbg", b.loc)
+		}
+	}
+	if len(b.fmf) == 0 {
+		return
+	}
+	flags := append([]enum.FastMathFlag(nil), b.fmf...)
+	switch inst := inst.(type) {
+	case *InstFNeg:
+		inst.FastMathFlags = flags
+	case *InstFAdd:
+		inst.FastMathFlags = flags
+	case *InstFSub:
+		inst.FastMathFlags = flags
+	case *InstFMul:
+		inst.FastMathFlags = flags
+	case *InstFDiv:
+		inst.FastMathFlags = flags
+	case *InstFRem:
+		inst.FastMathFlags = flags
+	case *InstFCmp:
+		inst.FastMathFlags = flags
+	case *InstPhi:
+		if isFloatingPoint(inst.Type()) {
+			inst.FastMathFlags = flags
+		}
+	case *InstSelect:
+		if isFloatingPoint(inst.Type()) {
+			inst.FastMathFlags = flags
+		}
+	case *InstCall:
+		if isFloatingPoint(inst.Type()) {
+			inst.FastMathFlags = flags
+		}
+	}
+}
+
+// find returns the basic block and index of the given instruction in the
+// function of the builder (or in the insertion block if the function is not
+// known).
+func (b *Builder) find(inst Instruction) (*Block, int) {
+	if b.f == nil {
+		if b.block != nil {
+			if i := indexOf(b.block, inst); i >= 0 {
+				return b.block, i
+			}
+		}
+		panic(newBuilderError("unable to locate instruction %q; builder has no function", inst.LLString()))
+	}
+	for _, block := range b.f.Blocks {
+		if i := indexOf(block, inst); i >= 0 {
+			return block, i
+		}
+	}
+	panic(newBuilderError("unable to locate instruction %q in function %s", inst.LLString(), b.f.Ident()))
+}
+
+// indexOf returns the index of the given instruction in block; or -1 if not
+// present.
+func indexOf(block *Block, inst Instruction) int {
+	for i, v := range block.Insts {
+		if v == inst {
+			return i
+		}
+	}
+	return -1
+}
+
+// insertAt inserts the given instruction at index i of the instructions of
+// block.
+func insertAt(block *Block, i int, inst Instruction) {
+	block.Insts = append(block.Insts, nil)
+	copy(block.Insts[i+1:], block.Insts[i:])
+	block.Insts[i] = inst
+}
+
+// isFloatingPoint reports whether the given type is a floating-point type or a
+// vector of floating-point types.
+func isFloatingPoint(t types.Type) bool {
+	if vt, ok := t.(*types.VectorType); ok {
+		t = vt.ElemType
+	}
+	_, ok := t.(*types.FloatType)
+	return ok
+}
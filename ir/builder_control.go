@@ -0,0 +1,323 @@
+package ir
+
+import (
+	"fmt"
+
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/value"
+)
+
+// --- [ Structured control flow ] ---------------------------------------------
+
+// The structured control flow helpers of the builder emit the basic blocks of
+// if-statements, loops and switch-statements, as used by frontends of
+// structured languages. The bodies of statements are given as functions, which
+// are invoked with the insertion point of the builder set to the basic block of
+// the body.
+//
+// Basic blocks are appended to the function of the builder in the order they
+// are emitted, and are given unique names based on the statement (e.g.
+// "if.then", "if.then1", "while.cond"). A body may terminate its basic block
+// (e.g. by Break, Continue or NewRet), in which case no branch to the join point
+// is emitted from the body. No further instructions should be emitted in the
+// body after its basic block has been terminated.
+//
+// Example (C source):
+//
+//	for (i = 0; i < n; i++) {
+//		if (a[i] == 0) {
+//			break;
+//		}
+//	}
+//
+// Example (Go source):
+//
+//	b.For(
+//		func() { b.NewStore(zero, i) },
+//		func() value.Value { return b.NewICmp(enum.IPredSLT, b.NewLoad(types.I32, i), n) },
+//		func() { b.NewStore(b.NewAdd(b.NewLoad(types.I32, i), one), i) },
+//		func() {
+//			elem := b.NewLoad(types.I32, b.NewGetElementPtr(types.I32, a, b.NewLoad(types.I32, i)))
+//			b.If(b.NewICmp(enum.IPredEQ, elem, zero), b.Break, nil)
+//		},
+//	)
+
+// exit is the exit of a loop or switch-statement, as targeted by break and
+// continue.
+type exit struct {
+	// Target basic block of break.
+	breakTarget *Block
+	// Target basic block of continue; nil for switch-statements.
+	continueTarget *Block
+}
+
+// SwitchCase is a case of a structured switch-statement.
+type SwitchCase struct {
+	// Case comparand.
+	X constant.Constant
+	// Body of the case.
+	Body func()
+}
+
+// If emits an if-statement with the given condition, then-body and optional
+// else-body (nil if not present). The insertion point is left at the join point
+// of the if-statement.
+func (b *Builder) If(cond value.Value, then, els func()) {
+	thenBlock := b.newBlock("if.then")
+	endBlock := b.newBlock("if.end")
+	elseBlock := endBlock
+	if els != nil {
+		elseBlock = b.newBlock("if.else")
+	}
+	b.NewCondBr(cond, thenBlock, elseBlock)
+	b.emitBlock(thenBlock)
+	then()
+	b.branchTo(endBlock)
+	if els != nil {
+		b.emitBlock(elseBlock)
+		els()
+		b.branchTo(endBlock)
+	}
+	b.emitBlock(endBlock)
+}
+
+// IfValue emits an if-statement with the given condition, then-body and
+// else-body, and returns the value of the body taken; as merged by a phi
+// instruction at the join point of the if-statement. Bodies which terminate
+// their basic block do not contribute to the merged value.
+//
+// IfValue returns nil if both bodies terminate their basic blocks.
+func (b *Builder) IfValue(cond value.Value, then, els func() value.Value) value.Value {
+	thenBlock := b.newBlock("if.then")
+	elseBlock := b.newBlock("if.else")
+	endBlock := b.newBlock("if.end")
+	b.NewCondBr(cond, thenBlock, elseBlock)
+	var incs []*Incoming
+	b.emitBlock(thenBlock)
+	if inc := b.branchValue(then(), endBlock); inc != nil {
+		incs = append(incs, inc)
+	}
+	b.emitBlock(elseBlock)
+	if inc := b.branchValue(els(), endBlock); inc != nil {
+		incs = append(incs, inc)
+	}
+	b.emitBlock(endBlock)
+	return b.merge(incs)
+}
+
+// And emits the short-circuit logical and of the boolean x and the boolean
+// computed by y; i.e. y is only evaluated if x is true. The result is merged by
+// a phi instruction at the join point.
+func (b *Builder) And(x value.Value, y func() value.Value) value.Value {
+	return b.shortCircuit("and", x, y, false)
+}
+
+// Or emits the short-circuit logical or of the boolean x and the boolean
+// computed by y; i.e. y is only evaluated if x is false. The result is merged by
+// a phi instruction at the join point.
+func (b *Builder) Or(x value.Value, y func() value.Value) value.Value {
+	return b.shortCircuit("or", x, y, true)
+}
+
+// While emits a while-loop with the condition computed by cond and the given
+// body. Break and Continue within the body target the loop. The insertion point
+// is left at the exit of the loop.
+func (b *Builder) While(cond func() value.Value, body func()) {
+	b.loop("while", nil, cond, nil, body)
+}
+
+// For emits a for-loop with the given optional initialization, condition, step
+// and body (nil if not present). A nil condition indicates an infinite loop.
+// Break and Continue within the body target the loop, where Continue branches to
+// the step of the loop (or to the condition if no step is present). The
+// insertion point is left at the exit of the loop.
+func (b *Builder) For(init func(), cond func() value.Value, step func(), body func()) {
+	b.loop("for", init, cond, step, body)
+}
+
+// Switch emits a switch-statement on the integer x, with the given cases and
+// optional default body (nil if not present). Cases do not fall through, and
+// Break within a case body exits the switch-statement. The insertion point is
+// left at the join point of the switch-statement.
+func (b *Builder) Switch(x value.Value, cases []SwitchCase, def func()) {
+	endBlock := b.newBlock("switch.end")
+	defaultBlock := endBlock
+	if def != nil {
+		defaultBlock = b.newBlock("switch.default")
+	}
+	var termCases []*Case
+	var caseBlocks []*Block
+	for _, c := range cases {
+		caseBlock := b.newBlock("switch.case")
+		termCases = append(termCases, NewCase(c.X, caseBlock))
+		caseBlocks = append(caseBlocks, caseBlock)
+	}
+	b.NewSwitch(x, defaultBlock, termCases...)
+	e := exit{breakTarget: endBlock}
+	for i, c := range cases {
+		b.emitBlock(caseBlocks[i])
+		b.withExit(e, c.Body)
+		b.branchTo(endBlock)
+	}
+	if def != nil {
+		b.emitBlock(defaultBlock)
+		b.withExit(e, def)
+		b.branchTo(endBlock)
+	}
+	b.emitBlock(endBlock)
+}
+
+// Break terminates the insertion block by a branch to the exit of the innermost
+// enclosing loop or switch-statement.
+func (b *Builder) Break() {
+	if len(b.exits) == 0 {
+		panic(newBuilderError("invalid break; not within loop or switch-statement"))
+	}
+	b.NewBr(b.exits[len(b.exits)-1].breakTarget)
+}
+
+// Continue terminates the insertion block by a branch to the next iteration of
+// the innermost enclosing loop.
+func (b *Builder) Continue() {
+	for i := len(b.exits) - 1; i >= 0; i-- {
+		if target := b.exits[i].continueTarget; target != nil {
+			b.NewBr(target)
+			return
+		}
+	}
+	panic(newBuilderError("invalid continue; not within loop"))
+}
+
+// ### [ Helper functions ] ####################################################
+
+// loop emits a loop with the given optional initialization, condition, step and
+// body, using the given prefix for the names of its basic blocks.
+func (b *Builder) loop(prefix string, init func(), cond func() value.Value, step func(), body func()) {
+	if init != nil {
+		init()
+	}
+	condBlock := b.newBlock(prefix + ".cond")
+	bodyBlock := b.newBlock(prefix + ".body")
+	endBlock := b.newBlock(prefix + ".end")
+	continueTarget := condBlock
+	var stepBlock *Block
+	if step != nil {
+		stepBlock = b.newBlock(prefix + ".inc")
+		continueTarget = stepBlock
+	}
+	b.branchTo(condBlock)
+	b.emitBlock(condBlock)
+	if cond != nil {
+		b.NewCondBr(cond(), bodyBlock, endBlock)
+	} else {
+		b.NewBr(bodyBlock)
+	}
+	b.emitBlock(bodyBlock)
+	b.withExit(exit{breakTarget: endBlock, continueTarget: continueTarget}, body)
+	b.branchTo(continueTarget)
+	if stepBlock != nil {
+		b.emitBlock(stepBlock)
+		step()
+		b.branchTo(condBlock)
+	}
+	b.emitBlock(endBlock)
+}
+
+// newBlock returns a new basic block with a unique name based on the given base
+// name; i.e. distinct from the names of the basic blocks of the function and of
+// the basic blocks not yet emitted. The basic block is appended to the function
+// of the builder by emitBlock.
+func (b *Builder) newBlock(base string) *Block {
+	if b.f == nil {
+		panic(newBuilderError("unable to create basic block %q; builder has no function", base))
+	}
+	if b.names == nil {
+		b.names = make(map[string]bool)
+	}
+	name := base
+	for i := 1; b.names[name] || b.f.hasBlock(name); i++ {
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+	b.names[name] = true
+	return NewBlock(name)
+}
+
+// emitBlock appends the given basic block to the function of the builder, and
+// sets the insertion point to the end of the basic block.
+func (b *Builder) emitBlock(block *Block) {
+	block.Parent = b.f
+	b.f.Blocks = append(b.f.Blocks, block)
+	b.SetInsertPointAtEnd(block)
+}
+
+// branchTo terminates the insertion block by a branch to target, unless already
+// terminated.
+func (b *Builder) branchTo(target *Block) {
+	if b.block.Term == nil {
+		b.NewBr(target)
+	}
+}
+
+// branchValue terminates the insertion block by a branch to target, and returns
+// the incoming value x of the insertion block. branchValue returns nil if the
+// insertion block is already terminated.
+func (b *Builder) branchValue(x value.Value, target *Block) *Incoming {
+	if b.block.Term != nil {
+		return nil
+	}
+	pred := b.block
+	b.NewBr(target)
+	return NewIncoming(x, pred)
+}
+
+// merge returns the value merged from the given incoming values at the
+// insertion block; a phi instruction if more than one incoming value is given.
+func (b *Builder) merge(incs []*Incoming) value.Value {
+	switch len(incs) {
+	case 0:
+		return nil
+	case 1:
+		return incs[0].X
+	default:
+		return b.NewPhi(incs...)
+	}
+}
+
+// shortCircuit emits the short-circuit logical operation of the given name,
+// where y is only evaluated if x is not equal to the short-circuit result
+// short.
+func (b *Builder) shortCircuit(name string, x value.Value, y func() value.Value, short bool) value.Value {
+	rhsBlock := b.newBlock(name + ".rhs")
+	endBlock := b.newBlock(name + ".end")
+	lhs := b.block
+	if short {
+		b.NewCondBr(x, endBlock, rhsBlock)
+	} else {
+		b.NewCondBr(x, rhsBlock, endBlock)
+	}
+	incs := []*Incoming{NewIncoming(constant.NewBool(short), lhs)}
+	b.emitBlock(rhsBlock)
+	if inc := b.branchValue(y(), endBlock); inc != nil {
+		incs = append(incs, inc)
+	}
+	b.emitBlock(endBlock)
+	return b.NewPhi(incs...)
+}
+
+// withExit invokes fn with e pushed onto the loop and switch exit stack of the
+// builder.
+func (b *Builder) withExit(e exit, fn func()) {
+	b.exits = append(b.exits, e)
+	defer func() { b.exits = b.exits[:len(b.exits)-1] }()
+	fn()
+}
+
+// hasBlock reports whether the function has a basic block of the given name.
+func (f *Func) hasBlock(name string) bool {
+	for _, block := range f.Blocks {
+		if block.Name() == name {
+			return true
+		}
+	}
+	return false
+}
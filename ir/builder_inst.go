@@ -0,0 +1,620 @@
+package ir
+
+import (
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// --- [ Unary instructions ] --------------------------------------------------
+
+// ~~~ [ fneg ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewFNeg inserts a new fneg instruction at the insertion point based on the
+// given operand.
+func (b *Builder) NewFNeg(x value.Value) *InstFNeg {
+	defer b.recoverCheck("fneg")
+	inst := NewFNeg(x)
+	b.insert(inst)
+	return inst
+}
+
+// --- [ Binary instructions ] -------------------------------------------------
+
+// ~~~ [ add ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewAdd inserts a new add instruction at the insertion point based on the
+// given operands.
+func (b *Builder) NewAdd(x, y value.Value) *InstAdd {
+	defer b.recoverCheck("add")
+	inst := NewAdd(x, y)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ fadd ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewFAdd inserts a new fadd instruction at the insertion point based on the
+// given operands.
+func (b *Builder) NewFAdd(x, y value.Value) *InstFAdd {
+	defer b.recoverCheck("fadd")
+	inst := NewFAdd(x, y)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ sub ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewSub inserts a new sub instruction at the insertion point based on the
+// given operands.
+func (b *Builder) NewSub(x, y value.Value) *InstSub {
+	defer b.recoverCheck("sub")
+	inst := NewSub(x, y)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ fsub ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewFSub inserts a new fsub instruction at the insertion point based on the
+// given operands.
+func (b *Builder) NewFSub(x, y value.Value) *InstFSub {
+	defer b.recoverCheck("fsub")
+	inst := NewFSub(x, y)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ mul ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewMul inserts a new mul instruction at the insertion point based on the
+// given operands.
+func (b *Builder) NewMul(x, y value.Value) *InstMul {
+	defer b.recoverCheck("mul")
+	inst := NewMul(x, y)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ fmul ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewFMul inserts a new fmul instruction at the insertion point based on the
+// given operands.
+func (b *Builder) NewFMul(x, y value.Value) *InstFMul {
+	defer b.recoverCheck("fmul")
+	inst := NewFMul(x, y)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ udiv ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewUDiv inserts a new udiv instruction at the insertion point based on the
+// given operands.
+func (b *Builder) NewUDiv(x, y value.Value) *InstUDiv {
+	defer b.recoverCheck("udiv")
+	inst := NewUDiv(x, y)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ sdiv ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewSDiv inserts a new sdiv instruction at the insertion point based on the
+// given operands.
+func (b *Builder) NewSDiv(x, y value.Value) *InstSDiv {
+	defer b.recoverCheck("sdiv")
+	inst := NewSDiv(x, y)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ fdiv ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewFDiv inserts a new fdiv instruction at the insertion point based on the
+// given operands.
+func (b *Builder) NewFDiv(x, y value.Value) *InstFDiv {
+	defer b.recoverCheck("fdiv")
+	inst := NewFDiv(x, y)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ urem ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewURem inserts a new urem instruction at the insertion point based on the
+// given operands.
+func (b *Builder) NewURem(x, y value.Value) *InstURem {
+	defer b.recoverCheck("urem")
+	inst := NewURem(x, y)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ srem ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewSRem inserts a new srem instruction at the insertion point based on the
+// given operands.
+func (b *Builder) NewSRem(x, y value.Value) *InstSRem {
+	defer b.recoverCheck("srem")
+	inst := NewSRem(x, y)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ frem ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewFRem inserts a new frem instruction at the insertion point based on the
+// given operands.
+func (b *Builder) NewFRem(x, y value.Value) *InstFRem {
+	defer b.recoverCheck("frem")
+	inst := NewFRem(x, y)
+	b.insert(inst)
+	return inst
+}
+
+// --- [ Bitwise instructions ] ------------------------------------------------
+
+// ~~~ [ shl ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewShl inserts a new shl instruction at the insertion point based on the
+// given operands.
+func (b *Builder) NewShl(x, y value.Value) *InstShl {
+	defer b.recoverCheck("shl")
+	inst := NewShl(x, y)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ lshr ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewLShr inserts a new lshr instruction at the insertion point based on the
+// given operands.
+func (b *Builder) NewLShr(x, y value.Value) *InstLShr {
+	defer b.recoverCheck("lshr")
+	inst := NewLShr(x, y)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ ashr ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewAShr inserts a new ashr instruction at the insertion point based on the
+// given operands.
+func (b *Builder) NewAShr(x, y value.Value) *InstAShr {
+	defer b.recoverCheck("ashr")
+	inst := NewAShr(x, y)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ and ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewAnd inserts a new and instruction at the insertion point based on the
+// given operands.
+func (b *Builder) NewAnd(x, y value.Value) *InstAnd {
+	defer b.recoverCheck("and")
+	inst := NewAnd(x, y)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ or ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewOr inserts a new or instruction at the insertion point based on the given
+// operands.
+func (b *Builder) NewOr(x, y value.Value) *InstOr {
+	defer b.recoverCheck("or")
+	inst := NewOr(x, y)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ xor ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewXor inserts a new xor instruction at the insertion point based on the
+// given operands.
+func (b *Builder) NewXor(x, y value.Value) *InstXor {
+	defer b.recoverCheck("xor")
+	inst := NewXor(x, y)
+	b.insert(inst)
+	return inst
+}
+
+// --- [ Vector instructions ] -------------------------------------------------
+
+// ~~~ [ extractelement ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewExtractElement inserts a new extractelement instruction at the insertion
+// point based on the given vector and element index.
+func (b *Builder) NewExtractElement(x, index value.Value) *InstExtractElement {
+	defer b.recoverCheck("extractelement")
+	inst := NewExtractElement(x, index)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ insertelement ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewInsertElement inserts a new insertelement instruction at the insertion
+// point based on the given vector, element and element index.
+func (b *Builder) NewInsertElement(x, elem, index value.Value) *InstInsertElement {
+	defer b.recoverCheck("insertelement")
+	inst := NewInsertElement(x, elem, index)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ shufflevector ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewShuffleVector inserts a new shufflevector instruction at the insertion
+// point based on the given vectors and shuffle mask.
+func (b *Builder) NewShuffleVector(x, y, mask value.Value) *InstShuffleVector {
+	defer b.recoverCheck("shufflevector")
+	inst := NewShuffleVector(x, y, mask)
+	b.insert(inst)
+	return inst
+}
+
+// --- [ Aggregate instructions ] ----------------------------------------------
+
+// ~~~ [ extractvalue ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewExtractValue inserts a new extractvalue instruction at the insertion point
+// based on the given aggregate value and indicies.
+func (b *Builder) NewExtractValue(x value.Value, indices ...uint64) *InstExtractValue {
+	defer b.recoverCheck("extractvalue")
+	inst := NewExtractValue(x, indices...)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ insertvalue ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewInsertValue inserts a new insertvalue instruction at the insertion point
+// based on the given aggregate value, element and indicies.
+func (b *Builder) NewInsertValue(x, elem value.Value, indices ...uint64) *InstInsertValue {
+	defer b.recoverCheck("insertvalue")
+	inst := NewInsertValue(x, elem, indices...)
+	b.insert(inst)
+	return inst
+}
+
+// --- [ Memory instructions ] -------------------------------------------------
+
+// ~~~ [ alloca ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewAlloca inserts a new alloca instruction at the insertion point based on
+// the given element type.
+func (b *Builder) NewAlloca(elemType types.Type) *InstAlloca {
+	defer b.recoverCheck("alloca")
+	inst := NewAlloca(elemType)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ load ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewLoad inserts a new load instruction at the insertion point based on the
+// given element type and source address.
+func (b *Builder) NewLoad(elemType types.Type, src value.Value) *InstLoad {
+	defer b.recoverCheck("load")
+	inst := NewLoad(elemType, src)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ store ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewStore inserts a new store instruction at the insertion point based on the
+// given source value and destination address.
+func (b *Builder) NewStore(src, dst value.Value) *InstStore {
+	defer b.recoverCheck("store")
+	inst := NewStore(src, dst)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ fence ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewFence inserts a new fence instruction at the insertion point based on the
+// given atomic ordering.
+func (b *Builder) NewFence(ordering enum.AtomicOrdering) *InstFence {
+	defer b.recoverCheck("fence")
+	inst := NewFence(ordering)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ cmpxchg ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewCmpXchg inserts a new cmpxchg instruction at the insertion point based on
+// the given address, value to compare against, new value to store, and atomic
+// orderings for success and failure.
+func (b *Builder) NewCmpXchg(ptr, cmp, new value.Value, successOrdering, failureOrdering enum.AtomicOrdering) *InstCmpXchg {
+	defer b.recoverCheck("cmpxchg")
+	inst := NewCmpXchg(ptr, cmp, new, successOrdering, failureOrdering)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ atomicrmw ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewAtomicRMW inserts a new atomicrmw instruction at the insertion point based
+// on the given atomic operation, destination address, operand and atomic
+// ordering.
+func (b *Builder) NewAtomicRMW(op enum.AtomicOp, dst, x value.Value, ordering enum.AtomicOrdering) *InstAtomicRMW {
+	defer b.recoverCheck("atomicrmw")
+	inst := NewAtomicRMW(op, dst, x, ordering)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ getelementptr ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewGetElementPtr inserts a new getelementptr instruction at the insertion
+// point based on the given element type, source address and element indices.
+func (b *Builder) NewGetElementPtr(elemType types.Type, src value.Value, indices ...value.Value) *InstGetElementPtr {
+	defer b.recoverCheck("getelementptr")
+	inst := NewGetElementPtr(elemType, src, indices...)
+	b.insert(inst)
+	return inst
+}
+
+// --- [ Conversion instructions ] ---------------------------------------------
+
+// ~~~ [ trunc ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewTrunc inserts a new trunc instruction at the insertion point based on the
+// given source value and target type.
+func (b *Builder) NewTrunc(from value.Value, to types.Type) *InstTrunc {
+	defer b.recoverCheck("trunc")
+	inst := NewTrunc(from, to)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ zext ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewZExt inserts a new zext instruction at the insertion point based on the
+// given source value and target type.
+func (b *Builder) NewZExt(from value.Value, to types.Type) *InstZExt {
+	defer b.recoverCheck("zext")
+	inst := NewZExt(from, to)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ sext ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewSExt inserts a new sext instruction at the insertion point based on the
+// given source value and target type.
+func (b *Builder) NewSExt(from value.Value, to types.Type) *InstSExt {
+	defer b.recoverCheck("sext")
+	inst := NewSExt(from, to)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ fptrunc ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewFPTrunc inserts a new fptrunc instruction at the insertion point based on
+// the given source value and target type.
+func (b *Builder) NewFPTrunc(from value.Value, to types.Type) *InstFPTrunc {
+	defer b.recoverCheck("fptrunc")
+	inst := NewFPTrunc(from, to)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ fpext ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewFPExt inserts a new fpext instruction at the insertion point based on the
+// given source value and target type.
+func (b *Builder) NewFPExt(from value.Value, to types.Type) *InstFPExt {
+	defer b.recoverCheck("fpext")
+	inst := NewFPExt(from, to)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ fptoui ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewFPToUI inserts a new fptoui instruction at the insertion point based on
+// the given source value and target type.
+func (b *Builder) NewFPToUI(from value.Value, to types.Type) *InstFPToUI {
+	defer b.recoverCheck("fptoui")
+	inst := NewFPToUI(from, to)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ fptosi ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewFPToSI inserts a new fptosi instruction at the insertion point based on
+// the given source value and target type.
+func (b *Builder) NewFPToSI(from value.Value, to types.Type) *InstFPToSI {
+	defer b.recoverCheck("fptosi")
+	inst := NewFPToSI(from, to)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ uitofp ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewUIToFP inserts a new uitofp instruction at the insertion point based on
+// the given source value and target type.
+func (b *Builder) NewUIToFP(from value.Value, to types.Type) *InstUIToFP {
+	defer b.recoverCheck("uitofp")
+	inst := NewUIToFP(from, to)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ sitofp ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewSIToFP inserts a new sitofp instruction at the insertion point based on
+// the given source value and target type.
+func (b *Builder) NewSIToFP(from value.Value, to types.Type) *InstSIToFP {
+	defer b.recoverCheck("sitofp")
+	inst := NewSIToFP(from, to)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ ptrtoint ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewPtrToInt inserts a new ptrtoint instruction at the insertion point based
+// on the given source value and target type.
+func (b *Builder) NewPtrToInt(from value.Value, to types.Type) *InstPtrToInt {
+	defer b.recoverCheck("ptrtoint")
+	inst := NewPtrToInt(from, to)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ inttoptr ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewIntToPtr inserts a new inttoptr instruction at the insertion point based
+// on the given source value and target type.
+func (b *Builder) NewIntToPtr(from value.Value, to types.Type) *InstIntToPtr {
+	defer b.recoverCheck("inttoptr")
+	inst := NewIntToPtr(from, to)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ bitcast ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewBitCast inserts a new bitcast instruction at the insertion point based on
+// the given source value and target type.
+func (b *Builder) NewBitCast(from value.Value, to types.Type) *InstBitCast {
+	defer b.recoverCheck("bitcast")
+	inst := NewBitCast(from, to)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ addrspacecast ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewAddrSpaceCast inserts a new addrspacecast instruction at the insertion
+// point based on the given source value and target type.
+func (b *Builder) NewAddrSpaceCast(from value.Value, to types.Type) *InstAddrSpaceCast {
+	defer b.recoverCheck("addrspacecast")
+	inst := NewAddrSpaceCast(from, to)
+	b.insert(inst)
+	return inst
+}
+
+// --- [ Other instructions ] --------------------------------------------------
+
+// ~~~ [ icmp ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewICmp inserts a new icmp instruction at the insertion point based on the
+// given integer comparison predicate and integer scalar or vector operands.
+func (b *Builder) NewICmp(pred enum.IPred, x, y value.Value) *InstICmp {
+	defer b.recoverCheck("icmp")
+	inst := NewICmp(pred, x, y)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ fcmp ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewFCmp inserts a new fcmp instruction at the insertion point based on the
+// given floating-point comparison predicate and floating-point scalar or vector
+// operands.
+func (b *Builder) NewFCmp(pred enum.FPred, x, y value.Value) *InstFCmp {
+	defer b.recoverCheck("fcmp")
+	inst := NewFCmp(pred, x, y)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ phi ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewPhi inserts a new phi instruction at the insertion point based on the
+// given incoming values.
+func (b *Builder) NewPhi(incs ...*Incoming) *InstPhi {
+	defer b.recoverCheck("phi")
+	inst := NewPhi(incs...)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ select ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewSelect inserts a new select instruction at the insertion point based on
+// the given selection condition and true and false condition values.
+func (b *Builder) NewSelect(cond, valueTrue, valueFalse value.Value) *InstSelect {
+	defer b.recoverCheck("select")
+	inst := NewSelect(cond, valueTrue, valueFalse)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ freeze ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewFreeze inserts a new freeze instruction at the insertion point based on
+// the given operand.
+func (b *Builder) NewFreeze(x value.Value) *InstFreeze {
+	defer b.recoverCheck("freeze")
+	inst := NewInstFreeze(x)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ call ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewCall inserts a new call instruction at the insertion point based on the
+// given callee and function arguments.
+func (b *Builder) NewCall(callee value.Value, args ...value.Value) *InstCall {
+	defer b.recoverCheck("call")
+	inst := NewCall(callee, args...)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ va_arg ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewVAArg inserts a new va_arg instruction at the insertion point based on the
+// given variable argument list and argument type.
+func (b *Builder) NewVAArg(vaList value.Value, argType types.Type) *InstVAArg {
+	defer b.recoverCheck("va_arg")
+	inst := NewVAArg(vaList, argType)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ landingpad ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewLandingPad inserts a new landingpad instruction at the insertion point
+// based on the given result type and filter/catch clauses.
+func (b *Builder) NewLandingPad(resultType types.Type, clauses ...*Clause) *InstLandingPad {
+	defer b.recoverCheck("landingpad")
+	inst := NewLandingPad(resultType, clauses...)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ catchpad ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewCatchPad inserts a new catchpad instruction at the insertion point based
+// on the given parent catchswitch terminator and exception arguments.
+func (b *Builder) NewCatchPad(catchSwitch *TermCatchSwitch, args ...value.Value) *InstCatchPad {
+	defer b.recoverCheck("catchpad")
+	inst := NewCatchPad(catchSwitch, args...)
+	b.insert(inst)
+	return inst
+}
+
+// ~~~ [ cleanuppad ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewCleanupPad inserts a new cleanuppad instruction at the insertion point
+// based on the given parent exception pad and exception arguments.
+func (b *Builder) NewCleanupPad(parentPad ExceptionPad, args ...value.Value) *InstCleanupPad {
+	defer b.recoverCheck("cleanuppad")
+	inst := NewCleanupPad(parentPad, args...)
+	b.insert(inst)
+	return inst
+}
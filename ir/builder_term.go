@@ -0,0 +1,151 @@
+package ir
+
+import (
+	"github.com/llir/llvm/ir/value"
+)
+
+// --- [ Terminators ] ---------------------------------------------------------
+
+// ~~~ [ ret ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewRet sets the terminator of the insertion block to a new ret terminator
+// based on the given return value. A nil return value indicates a void return.
+func (b *Builder) NewRet(x value.Value) *TermRet {
+	defer b.recoverCheck("ret")
+	term := NewRet(x)
+	b.setTerm(term)
+	return term
+}
+
+// ~~~ [ br ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewBr sets the terminator of the insertion block to a new unconditional br
+// terminator based on the given target basic block.
+func (b *Builder) NewBr(target *Block) *TermBr {
+	defer b.recoverCheck("br")
+	term := NewBr(target)
+	b.setTerm(term)
+	return term
+}
+
+// ~~~ [ conditional br ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewCondBr sets the terminator of the insertion block to a new conditional br
+// terminator based on the given branching condition and conditional target
+// basic blocks.
+func (b *Builder) NewCondBr(cond value.Value, targetTrue, targetFalse *Block) *TermCondBr {
+	defer b.recoverCheck("br")
+	term := NewCondBr(cond, targetTrue, targetFalse)
+	b.setTerm(term)
+	return term
+}
+
+// ~~~ [ switch ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewSwitch sets the terminator of the insertion block to a new switch
+// terminator based on the given control variable, default target basic block
+// and switch cases.
+func (b *Builder) NewSwitch(x value.Value, targetDefault *Block, cases ...*Case) *TermSwitch {
+	defer b.recoverCheck("switch")
+	term := NewSwitch(x, targetDefault, cases...)
+	b.setTerm(term)
+	return term
+}
+
+// ~~~ [ indirectbr ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewIndirectBr sets the terminator of the insertion block to a new indirectbr
+// terminator based on the given target address (derived from a blockaddress
+// constant of type i8*) and set of valid target basic blocks.
+func (b *Builder) NewIndirectBr(addr value.Value, validTargets ...*Block) *TermIndirectBr {
+	defer b.recoverCheck("indirectbr")
+	term := NewIndirectBr(addr, validTargets...)
+	b.setTerm(term)
+	return term
+}
+
+// ~~~ [ invoke ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// TODO: specify the set of underlying types of invokee in Block.NewInvoke.
+
+// NewInvoke sets the terminator of the insertion block to a new invoke
+// terminator based on the given invokee, function arguments and control flow
+// return points for normal and exceptional execution.
+func (b *Builder) NewInvoke(invokee value.Value, args []value.Value, normalRetTarget, exceptionRetTarget *Block) *TermInvoke {
+	defer b.recoverCheck("invoke")
+	term := NewInvoke(invokee, args, normalRetTarget, exceptionRetTarget)
+	b.setTerm(term)
+	return term
+}
+
+// ~~~ [ callbr ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// TODO: specify the set of underlying types of callee in Builder.NewCallBr.
+
+// NewCallBr sets the terminator of the insertion block to a new callbr
+// terminator based on the given callee, function arguments and control flow
+// return points for normal and exceptional execution.
+func (b *Builder) NewCallBr(callee value.Value, args []value.Value, normalRetTarget *Block, otherRetTargets ...*Block) *TermCallBr {
+	defer b.recoverCheck("callbr")
+	term := NewCallBr(callee, args, normalRetTarget, otherRetTargets...)
+	b.setTerm(term)
+	return term
+}
+
+// ~~~ [ resume ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewResume sets the terminator of the insertion block to a new resume
+// terminator based on the given exception argument to propagate.
+func (b *Builder) NewResume(x value.Value) *TermResume {
+	defer b.recoverCheck("resume")
+	term := NewResume(x)
+	b.setTerm(term)
+	return term
+}
+
+// ~~~ [ catchswitch ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewCatchSwitch sets the terminator of the insertion block to a new
+// catchswitch terminator based on the given parent exception pad, exception
+// handlers and optional default unwind target. If defaultUnwindTarget is nil,
+// catchswitch unwinds to caller function.
+func (b *Builder) NewCatchSwitch(parentPad ExceptionPad, handlers []*Block, defaultUnwindTarget *Block) *TermCatchSwitch {
+	defer b.recoverCheck("catchswitch")
+	term := NewCatchSwitch(parentPad, handlers, defaultUnwindTarget)
+	b.setTerm(term)
+	return term
+}
+
+// ~~~ [ catchret ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewCatchRet sets the terminator of the insertion block to a new catchret
+// terminator based on the given exit catchpad and target basic block.
+func (b *Builder) NewCatchRet(catchPad *InstCatchPad, target *Block) *TermCatchRet {
+	defer b.recoverCheck("catchret")
+	term := NewCatchRet(catchPad, target)
+	b.setTerm(term)
+	return term
+}
+
+// ~~~ [ cleanupret ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewCleanupRet sets the terminator of the insertion block to a new cleanupret
+// terminator based on the given exit cleanuppad and optional unwind target. If
+// unwindTarget is nil, cleanupret unwinds to caller function.
+func (b *Builder) NewCleanupRet(cleanupPad *InstCleanupPad, unwindTarget *Block) *TermCleanupRet {
+	defer b.recoverCheck("cleanupret")
+	term := NewCleanupRet(cleanupPad, unwindTarget)
+	b.setTerm(term)
+	return term
+}
+
+// ~~~ [ unreachable ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewUnreachable sets the terminator of the insertion block to a new
+// unreachable terminator.
+func (b *Builder) NewUnreachable() *TermUnreachable {
+	defer b.recoverCheck("unreachable")
+	term := NewUnreachable()
+	b.setTerm(term)
+	return term
+}
@@ -0,0 +1,225 @@
+package ir
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/metadata"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+func TestBuilder(t *testing.T) {
+	m := NewModule()
+	x := NewParam("x", types.I32)
+	y := NewParam("y", types.Double)
+	f := m.NewFunc("f", types.Double, x, y)
+	b := NewBuilder(f)
+	entry := b.NewBlock("entry")
+	b.SetInsertPointAtEnd(entry)
+	a := b.NewAdd(x, constant.NewInt(types.I32, 1))
+	a.SetName("a")
+	c := b.NewMul(a, a)
+	c.SetName("c")
+	// Insert before and after existing instructions.
+	b.SetInsertPointBefore(c)
+	s := b.NewSub(a, constant.NewInt(types.I32, 2))
+	s.SetName("s")
+	b.SetInsertPointAfter(a)
+	n := b.NewShl(a, constant.NewInt(types.I32, 3))
+	n.SetName("n")
+	// Scoped defaults.
+	b.SetInsertPointAtEnd(entry)
+	loc := &metadata.DILocation{Line: 7, Column: 3}
+	var fp *InstFAdd
+	b.WithDebugLoc(loc, func() {
+		b.WithFastMathFlags([]enum.FastMathFlag{enum.FastMathFlagFast}, func() {
+			fp = b.NewFAdd(y, y)
+			fp.SetName("fp")
+		})
+	})
+	d := b.NewFMul(fp, y)
+	d.SetName("d")
+	b.NewRet(d)
+	// Removal and movement.
+	b.MoveBefore(c, n)
+	b.Remove(s)
+	if err := f.AssignIDs(); err != nil {
+		t.Fatalf("unable to assign IDs; %+v", err)
+	}
+	const want = `define double @f(i32 %x, double %y) {
+entry:
+	%a = add i32 %x, 1
+	%c = mul i32 %a, %a
+	%n = shl i32 %a, 3
+	%fp = fadd fast double %y, %y, !dbg !0
+	%d = fmul double %fp, %y
+	ret double %d
+}`
+	if got := f.LLString(); got != want {
+		t.Errorf("function mismatch; expected\n%s\ngot\n%s", want, got)
+	}
+	if mds := fp.MDAttachments(); len(mds) != 1 || mds[0].Node != loc {
+		t.Errorf("debug location mismatch of %q; expected %v, got %v", fp.LLString(), loc, mds)
+	}
+	if got := b.DebugLoc(); got != nil {
+		t.Errorf("debug location mismatch after scope; expected nil, got %v", got)
+	}
+}
+
+func TestBuilderControl(t *testing.T) {
+	m := NewModule()
+	n := NewParam("n", types.I32)
+	a := NewParam("a", types.NewPointer(types.I32))
+	f := m.NewFunc("f", types.I32, n, a)
+	b := NewBuilder(f)
+	b.SetInsertPointAtEnd(b.NewBlock("entry"))
+	zero := constant.NewInt(types.I32, 0)
+	one := constant.NewInt(types.I32, 1)
+	i := b.NewAlloca(types.I32)
+	i.SetName("i")
+	sum := b.NewAlloca(types.I32)
+	sum.SetName("sum")
+	b.NewStore(zero, sum)
+	// for (i = 0; i < n; i++) {
+	//    if (a[i] == 0) break;
+	//    if (a[i] < 0) continue;
+	//    sum += a[i];
+	// }
+	var elem *InstLoad
+	b.For(
+		func() { b.NewStore(zero, i) },
+		func() value.Value { return b.NewICmp(enum.IPredSLT, b.NewLoad(types.I32, i), n) },
+		func() { b.NewStore(b.NewAdd(b.NewLoad(types.I32, i), one), i) },
+		func() {
+			elem = b.NewLoad(types.I32, b.NewGetElementPtr(types.I32, a, b.NewLoad(types.I32, i)))
+			b.If(b.NewICmp(enum.IPredEQ, elem, zero), b.Break, nil)
+			b.If(b.NewICmp(enum.IPredSLT, elem, zero), b.Continue, nil)
+			b.NewStore(b.NewAdd(b.NewLoad(types.I32, sum), elem), sum)
+		},
+	)
+	// while (n > 0 && sum > n) {
+	//    switch (n) {
+	//    case 1: break;
+	//    default: sum--;
+	//    }
+	// }
+	b.While(
+		func() value.Value {
+			return b.And(b.NewICmp(enum.IPredSGT, n, zero), func() value.Value {
+				return b.NewICmp(enum.IPredSGT, b.NewLoad(types.I32, sum), n)
+			})
+		},
+		func() {
+			b.Switch(n, []SwitchCase{{X: one, Body: b.Break}}, func() {
+				b.NewStore(b.NewSub(b.NewLoad(types.I32, sum), one), sum)
+			})
+		},
+	)
+	// return n > 0 ? sum : -1;
+	v := b.IfValue(b.NewICmp(enum.IPredSGT, n, zero),
+		func() value.Value { return b.NewLoad(types.I32, sum) },
+		func() value.Value { return constant.NewInt(types.I32, -1) },
+	)
+	b.NewRet(v)
+	if err := f.AssignIDs(); err != nil {
+		t.Fatalf("unable to assign IDs; %+v", err)
+	}
+	const want = `define i32 @f(i32 %n, i32* %a) {
+entry:
+	%i = alloca i32
+	%sum = alloca i32
+	store i32 0, i32* %sum
+	store i32 0, i32* %i
+	br label %for.cond
+
+for.cond:
+	%0 = load i32, i32* %i
+	%1 = icmp slt i32 %0, %n
+	br i1 %1, label %for.body, label %for.end
+
+for.body:
+	%2 = load i32, i32* %i
+	%3 = getelementptr i32, i32* %a, i32 %2
+	%4 = load i32, i32* %3
+	%5 = icmp eq i32 %4, 0
+	br i1 %5, label %if.then, label %if.end
+
+if.then:
+	br label %for.end
+
+if.end:
+	%6 = icmp slt i32 %4, 0
+	br i1 %6, label %if.then1, label %if.end1
+
+if.then1:
+	br label %for.inc
+
+if.end1:
+	%7 = load i32, i32* %sum
+	%8 = add i32 %7, %4
+	store i32 %8, i32* %sum
+	br label %for.inc
+
+for.inc:
+	%9 = load i32, i32* %i
+	%10 = add i32 %9, 1
+	store i32 %10, i32* %i
+	br label %for.cond
+
+for.end:
+	br label %while.cond
+
+while.cond:
+	%11 = icmp sgt i32 %n, 0
+	br i1 %11, label %and.rhs, label %and.end
+
+and.rhs:
+	%12 = load i32, i32* %sum
+	%13 = icmp sgt i32 %12, %n
+	br label %and.end
+
+and.end:
+	%14 = phi i1 [ false, %while.cond ], [ %13, %and.rhs ]
+	br i1 %14, label %while.body, label %while.end
+
+while.body:
+	switch i32 %n, label %switch.default [
+		i32 1, label %switch.case
+	]
+
+switch.case:
+	br label %switch.end
+
+switch.default:
+	%15 = load i32, i32* %sum
+	%16 = sub i32 %15, 1
+	store i32 %16, i32* %sum
+	br label %switch.end
+
+switch.end:
+	br label %while.cond
+
+while.end:
+	%17 = icmp sgt i32 %n, 0
+	br i1 %17, label %if.then2, label %if.else
+
+if.then2:
+	%18 = load i32, i32* %sum
+	br label %if.end2
+
+if.else:
+	br label %if.end2
+
+if.end2:
+	%19 = phi i32 [ %18, %if.then2 ], [ -1, %if.else ]
+	ret i32 %19
+}`
+	if got := f.LLString(); got != want {
+		t.Errorf("function mismatch; expected\n%s\ngot\n%s", want, got)
+	}
+	if err := CheckFunc(f); err != nil {
+		t.Errorf("unexpected type error; %v", err)
+	}
+}
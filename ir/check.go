@@ -0,0 +1,620 @@
+package ir
+
+import (
+	"fmt"
+
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// === [ Type checking ] =======================================================
+
+// TypeError is a type error of an instruction or terminator.
+type TypeError struct {
+	// Opcode of the instruction or terminator; e.g. "store".
+	Op string
+	// Name of the invalid operand (e.g. "Dst" or "Args[1]"), as named by the
+	// fields of the instruction; or empty if the type error is not specific to
+	// an operand.
+	Operand string
+	// Description of the type error.
+	Msg string
+}
+
+// Error returns the string representation of the type error.
+func (e *TypeError) Error() string {
+	if len(e.Operand) == 0 {
+		return fmt.Sprintf("invalid %s instruction; %s", e.Op, e.Msg)
+	}
+	return fmt.Sprintf("invalid operand %s of %s instruction; %s", e.Operand, e.Op, e.Msg)
+}
+
+// CheckInst type-checks the operands of the given instruction against the
+// rules of the LLVM IR language reference. The returned error, if any, is of
+// type *TypeError.
+//
+// CheckInst only inspects the types of operands, and may thus be used on
+// invalid instructions which would otherwise panic when computing their type.
+//
+// ref: https://llvm.org/docs/LangRef.html#instruction-reference
+func CheckInst(inst Instruction) error {
+	switch inst := inst.(type) {
+	// Unary instructions.
+	case *InstFNeg:
+		return checkFloat("fneg", "X", inst.X)
+	// Binary instructions.
+	case *InstAdd:
+		return checkIntBinary("add", inst.X, inst.Y)
+	case *InstFAdd:
+		return checkFloatBinary("fadd", inst.X, inst.Y)
+	case *InstSub:
+		return checkIntBinary("sub", inst.X, inst.Y)
+	case *InstFSub:
+		return checkFloatBinary("fsub", inst.X, inst.Y)
+	case *InstMul:
+		return checkIntBinary("mul", inst.X, inst.Y)
+	case *InstFMul:
+		return checkFloatBinary("fmul", inst.X, inst.Y)
+	case *InstUDiv:
+		return checkIntBinary("udiv", inst.X, inst.Y)
+	case *InstSDiv:
+		return checkIntBinary("sdiv", inst.X, inst.Y)
+	case *InstFDiv:
+		return checkFloatBinary("fdiv", inst.X, inst.Y)
+	case *InstURem:
+		return checkIntBinary("urem", inst.X, inst.Y)
+	case *InstSRem:
+		return checkIntBinary("srem", inst.X, inst.Y)
+	case *InstFRem:
+		return checkFloatBinary("frem", inst.X, inst.Y)
+	// Bitwise instructions.
+	case *InstShl:
+		return checkIntBinary("shl", inst.X, inst.Y)
+	case *InstLShr:
+		return checkIntBinary("lshr", inst.X, inst.Y)
+	case *InstAShr:
+		return checkIntBinary("ashr", inst.X, inst.Y)
+	case *InstAnd:
+		return checkIntBinary("and", inst.X, inst.Y)
+	case *InstOr:
+		return checkIntBinary("or", inst.X, inst.Y)
+	case *InstXor:
+		return checkIntBinary("xor", inst.X, inst.Y)
+	// Vector instructions.
+	case *InstExtractElement:
+		if _, ok := inst.X.Type().(*types.VectorType); !ok {
+			return typeErrorf("extractelement", "X", "expected vector type, got %v", inst.X.Type())
+		}
+		return checkInt("extractelement", "Index", inst.Index)
+	case *InstInsertElement:
+		vt, ok := inst.X.Type().(*types.VectorType)
+		if !ok {
+			return typeErrorf("insertelement", "X", "expected vector type, got %v", inst.X.Type())
+		}
+		if err := checkType("insertelement", "Elem", inst.Elem, vt.ElemType); err != nil {
+			return err
+		}
+		return checkInt("insertelement", "Index", inst.Index)
+	case *InstShuffleVector:
+		if _, ok := inst.X.Type().(*types.VectorType); !ok {
+			return typeErrorf("shufflevector", "X", "expected vector type, got %v", inst.X.Type())
+		}
+		if err := checkType("shufflevector", "Y", inst.Y, inst.X.Type()); err != nil {
+			return err
+		}
+		if mt, ok := inst.Mask.Type().(*types.VectorType); !ok || !types.IsInt(mt.ElemType) {
+			return typeErrorf("shufflevector", "Mask", "expected vector of integers, got %v", inst.Mask.Type())
+		}
+		return nil
+	// Aggregate instructions.
+	case *InstExtractValue:
+		_, err := checkIndices("extractvalue", inst.X, inst.Indices)
+		return err
+	case *InstInsertValue:
+		elemType, err := checkIndices("insertvalue", inst.X, inst.Indices)
+		if err != nil {
+			return err
+		}
+		return checkType("insertvalue", "Elem", inst.Elem, elemType)
+	// Memory instructions.
+	case *InstAlloca:
+		if inst.NElems != nil {
+			return checkInt("alloca", "NElems", inst.NElems)
+		}
+		return nil
+	case *InstLoad:
+		elemType, err := checkPointer("load", "Src", inst.Src)
+		if err != nil {
+			return err
+		}
+		if inst.ElemType != nil && !inst.ElemType.Equal(elemType) {
+			return typeErrorf("load", "Src", "expected pointer to %v, got %v", inst.ElemType, inst.Src.Type())
+		}
+		return nil
+	case *InstStore:
+		elemType, err := checkPointer("store", "Dst", inst.Dst)
+		if err != nil {
+			return err
+		}
+		return checkType("store", "Src", inst.Src, elemType)
+	case *InstFence:
+		return nil
+	case *InstCmpXchg:
+		elemType, err := checkPointer("cmpxchg", "Ptr", inst.Ptr)
+		if err != nil {
+			return err
+		}
+		if err := checkType("cmpxchg", "Cmp", inst.Cmp, elemType); err != nil {
+			return err
+		}
+		return checkType("cmpxchg", "New", inst.New, elemType)
+	case *InstAtomicRMW:
+		elemType, err := checkPointer("atomicrmw", "Dst", inst.Dst)
+		if err != nil {
+			return err
+		}
+		return checkType("atomicrmw", "X", inst.X, elemType)
+	case *InstGetElementPtr:
+		return checkGEP(inst)
+	// Conversion instructions.
+	case *InstTrunc:
+		return checkConv("trunc", inst.From, inst.To, intKind, intKind, sizeSmaller)
+	case *InstZExt:
+		return checkConv("zext", inst.From, inst.To, intKind, intKind, sizeLarger)
+	case *InstSExt:
+		return checkConv("sext", inst.From, inst.To, intKind, intKind, sizeLarger)
+	case *InstFPTrunc:
+		return checkConv("fptrunc", inst.From, inst.To, floatKind, floatKind, sizeSmaller)
+	case *InstFPExt:
+		return checkConv("fpext", inst.From, inst.To, floatKind, floatKind, sizeLarger)
+	case *InstFPToUI:
+		return checkConv("fptoui", inst.From, inst.To, floatKind, intKind, sizeAny)
+	case *InstFPToSI:
+		return checkConv("fptosi", inst.From, inst.To, floatKind, intKind, sizeAny)
+	case *InstUIToFP:
+		return checkConv("uitofp", inst.From, inst.To, intKind, floatKind, sizeAny)
+	case *InstSIToFP:
+		return checkConv("sitofp", inst.From, inst.To, intKind, floatKind, sizeAny)
+	case *InstPtrToInt:
+		return checkConv("ptrtoint", inst.From, inst.To, pointerKind, intKind, sizeAny)
+	case *InstIntToPtr:
+		return checkConv("inttoptr", inst.From, inst.To, intKind, pointerKind, sizeAny)
+	case *InstBitCast:
+		return checkBitCast(inst.From, inst.To)
+	case *InstAddrSpaceCast:
+		if err := checkConv("addrspacecast", inst.From, inst.To, pointerKind, pointerKind, sizeAny); err != nil {
+			return err
+		}
+		from, _ := scalarType(inst.From.Type())
+		to, _ := scalarType(inst.To)
+		if from.(*types.PointerType).AddrSpace == to.(*types.PointerType).AddrSpace {
+			return typeErrorf("addrspacecast", "To", "expected address space other than that of %v, got %v", inst.From.Type(), inst.To)
+		}
+		return nil
+	// Other instructions.
+	case *InstICmp:
+		if t, _ := scalarType(inst.X.Type()); !types.IsInt(t) && !types.IsPointer(t) {
+			return typeErrorf("icmp", "X", "expected integer, pointer or vector thereof, got %v", inst.X.Type())
+		}
+		return checkType("icmp", "Y", inst.Y, inst.X.Type())
+	case *InstFCmp:
+		return checkFloatBinary("fcmp", inst.X, inst.Y)
+	case *InstPhi:
+		if len(inst.Incs) == 0 {
+			return typeErrorf("phi", "", "expected at least one incoming value")
+		}
+		want := inst.Typ
+		if want == nil {
+			want = inst.Incs[0].X.Type()
+		}
+		for i, inc := range inst.Incs {
+			if err := checkType("phi", fmt.Sprintf("Incs[%d].X", i), inc.X, want); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *InstSelect:
+		return checkSelect(inst)
+	case *InstFreeze:
+		return nil
+	case *InstCall:
+		return checkCall("call", "Callee", inst.Callee, inst.Args)
+	case *InstVAArg:
+		_, err := checkPointer("va_arg", "ArgList", inst.ArgList)
+		return err
+	case *InstLandingPad, *InstCatchPad, *InstCleanupPad:
+		return nil
+	default:
+		panic(fmt.Errorf("support for instruction type %T not yet implemented", inst))
+	}
+}
+
+// CheckTerm type-checks the operands of the given terminator against the rules
+// of the LLVM IR language reference. The returned error, if any, is of type
+// *TypeError.
+//
+// The return value of ret terminators is checked by CheckFunc, as terminators do
+// not track their parent function.
+//
+// ref: https://llvm.org/docs/LangRef.html#terminator-instructions
+func CheckTerm(term Terminator) error {
+	switch term := term.(type) {
+	case *TermRet, *TermBr:
+		return nil
+	case *TermCondBr:
+		return checkType("br", "Cond", term.Cond, types.I1)
+	case *TermSwitch:
+		if err := checkInt("switch", "X", term.X); err != nil {
+			return err
+		}
+		for i, c := range term.Cases {
+			if err := checkType("switch", fmt.Sprintf("Cases[%d].X", i), c.X, term.X.Type()); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *TermIndirectBr:
+		_, err := checkPointer("indirectbr", "Addr", term.Addr)
+		return err
+	case *TermInvoke:
+		return checkCall("invoke", "Invokee", term.Invokee, term.Args)
+	case *TermCallBr:
+		return checkCall("callbr", "Callee", term.Callee, term.Args)
+	case *TermResume, *TermCatchSwitch, *TermCatchRet, *TermCleanupRet, *TermUnreachable:
+		return nil
+	default:
+		panic(fmt.Errorf("support for terminator type %T not yet implemented", term))
+	}
+}
+
+// CheckFunc type-checks the instructions and terminators of the given function,
+// and the return values of its ret terminators against the function signature.
+// The first type error encountered is returned.
+func CheckFunc(f *Func) error {
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			if err := CheckInst(inst); err != nil {
+				return err
+			}
+		}
+		if block.Term == nil {
+			continue
+		}
+		if err := CheckTerm(block.Term); err != nil {
+			return err
+		}
+		if ret, ok := block.Term.(*TermRet); ok {
+			if err := checkRet(ret, f.Sig.RetType); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ### [ Helper functions ] ####################################################
+
+// typeErrorf returns a new type error of the given operand of the instruction
+// with the specified opcode, based on the given format specifier and arguments.
+func typeErrorf(op, operand, format string, args ...interface{}) *TypeError {
+	return &TypeError{Op: op, Operand: operand, Msg: fmt.Sprintf(format, args...)}
+}
+
+// checkType checks that the given operand is of type want.
+func checkType(op, operand string, v value.Value, want types.Type) error {
+	if got := v.Type(); !got.Equal(want) {
+		return typeErrorf(op, operand, "expected %v, got %v", want, got)
+	}
+	return nil
+}
+
+// checkInt checks that the given operand is of integer type or vector of
+// integers type.
+func checkInt(op, operand string, v value.Value) error {
+	if t, _ := scalarType(v.Type()); !types.IsInt(t) {
+		return typeErrorf(op, operand, "expected integer or vector of integers, got %v", v.Type())
+	}
+	return nil
+}
+
+// checkFloat checks that the given operand is of floating-point type or vector
+// of floating-point type.
+func checkFloat(op, operand string, v value.Value) error {
+	if t, _ := scalarType(v.Type()); !types.IsFloat(t) {
+		return typeErrorf(op, operand, "expected floating-point or vector of floating-points, got %v", v.Type())
+	}
+	return nil
+}
+
+// checkIntBinary checks the operands of an integer binary instruction; both
+// operands are integers (or vectors of integers) of identical type.
+func checkIntBinary(op string, x, y value.Value) error {
+	if err := checkInt(op, "X", x); err != nil {
+		return err
+	}
+	return checkType(op, "Y", y, x.Type())
+}
+
+// checkFloatBinary checks the operands of a floating-point binary instruction;
+// both operands are floating-points (or vectors of floating-points) of
+// identical type.
+func checkFloatBinary(op string, x, y value.Value) error {
+	if err := checkFloat(op, "X", x); err != nil {
+		return err
+	}
+	return checkType(op, "Y", y, x.Type())
+}
+
+// checkPointer checks that the given operand is of pointer type, and returns
+// its element type.
+func checkPointer(op, operand string, v value.Value) (types.Type, error) {
+	pt, ok := v.Type().(*types.PointerType)
+	if !ok {
+		return nil, typeErrorf(op, operand, "expected pointer type, got %v", v.Type())
+	}
+	return pt.ElemType, nil
+}
+
+// checkIndices checks that the given indices are valid indices into the
+// aggregate operand x, and returns the type of the indexed element.
+func checkIndices(op string, x value.Value, indices []uint64) (types.Type, error) {
+	t := x.Type()
+	if !types.IsStruct(t) && !types.IsArray(t) {
+		return nil, typeErrorf(op, "X", "expected struct or array type, got %v", t)
+	}
+	if len(indices) == 0 {
+		return nil, typeErrorf(op, "Indices", "expected at least one index")
+	}
+	for i, index := range indices {
+		switch tt := t.(type) {
+		case *types.StructType:
+			if index >= uint64(len(tt.Fields)) {
+				return nil, typeErrorf(op, fmt.Sprintf("Indices[%d]", i), "index %d out of bounds of %v", index, tt)
+			}
+			t = tt.Fields[index]
+		case *types.ArrayType:
+			if index >= tt.Len {
+				return nil, typeErrorf(op, fmt.Sprintf("Indices[%d]", i), "index %d out of bounds of %v", index, tt)
+			}
+			t = tt.ElemType
+		default:
+			return nil, typeErrorf(op, fmt.Sprintf("Indices[%d]", i), "unable to index into non-aggregate type %v", tt)
+		}
+	}
+	return t, nil
+}
+
+// checkGEP checks the operands of the given getelementptr instruction.
+func checkGEP(inst *InstGetElementPtr) error {
+	src, srcLen := scalarType(inst.Src.Type())
+	pt, ok := src.(*types.PointerType)
+	if !ok {
+		return typeErrorf("getelementptr", "Src", "expected pointer or vector of pointers, got %v", inst.Src.Type())
+	}
+	if inst.ElemType != nil && !inst.ElemType.Equal(pt.ElemType) {
+		return typeErrorf("getelementptr", "Src", "expected pointer to %v, got %v", inst.ElemType, inst.Src.Type())
+	}
+	t := pt.ElemType
+	for i, index := range inst.Indices {
+		operand := fmt.Sprintf("Indices[%d]", i)
+		it, n := scalarType(index.Type())
+		if !types.IsInt(it) {
+			return typeErrorf("getelementptr", operand, "expected integer or vector of integers, got %v", index.Type())
+		}
+		if srcLen != 0 && n != 0 && srcLen != n {
+			return typeErrorf("getelementptr", operand, "vector length mismatch; expected %d, got %d", srcLen, n)
+		}
+		// The first index steps through the source pointer.
+		if i == 0 {
+			continue
+		}
+		switch tt := t.(type) {
+		case *types.StructType:
+			c, ok := index.(*constant.Int)
+			if !ok {
+				return typeErrorf("getelementptr", operand, "expected constant struct index, got %v", index)
+			}
+			if !c.X.IsInt64() || c.X.Int64() < 0 || c.X.Int64() >= int64(len(tt.Fields)) {
+				return typeErrorf("getelementptr", operand, "index %v out of bounds of %v", c.X, tt)
+			}
+			t = tt.Fields[c.X.Int64()]
+		case *types.ArrayType:
+			t = tt.ElemType
+		case *types.VectorType:
+			t = tt.ElemType
+		default:
+			return typeErrorf("getelementptr", operand, "unable to index into non-aggregate type %v", tt)
+		}
+	}
+	return nil
+}
+
+// scalarKind is the kind of the scalar operands of a conversion instruction.
+type scalarKind struct {
+	// Description of the kind; e.g. "integer".
+	desc string
+	// Reports whether the given scalar type is of the kind.
+	is func(t types.Type) bool
+}
+
+// Kinds of scalar operands of conversion instructions.
+var (
+	intKind     = scalarKind{desc: "integer", is: types.IsInt}
+	floatKind   = scalarKind{desc: "floating-point", is: types.IsFloat}
+	pointerKind = scalarKind{desc: "pointer", is: types.IsPointer}
+)
+
+// sizeRel is the bit size relation between the source and target type of a
+// conversion instruction.
+type sizeRel uint8
+
+// Bit size relations.
+const (
+	// Arbitrary bit sizes.
+	sizeAny sizeRel = iota
+	// Target type is smaller than source type.
+	sizeSmaller
+	// Target type is larger than source type.
+	sizeLarger
+)
+
+// checkConv checks the operands of a conversion instruction from a scalar of
+// kind fromKind to a scalar of kind toKind (or between vectors of equal length
+// of such scalars), with the given relation between their bit sizes.
+func checkConv(op string, from value.Value, to types.Type, fromKind, toKind scalarKind, size sizeRel) error {
+	fromType, fromLen := scalarType(from.Type())
+	if !fromKind.is(fromType) {
+		return typeErrorf(op, "From", "expected %s or vector of %ss, got %v", fromKind.desc, fromKind.desc, from.Type())
+	}
+	toType, toLen := scalarType(to)
+	if !toKind.is(toType) {
+		return typeErrorf(op, "To", "expected %s or vector of %ss, got %v", toKind.desc, toKind.desc, to)
+	}
+	if fromLen != toLen {
+		return typeErrorf(op, "To", "vector length mismatch between %v and %v", from.Type(), to)
+	}
+	switch size {
+	case sizeSmaller:
+		if bitSize(toType) >= bitSize(fromType) {
+			return typeErrorf(op, "To", "expected type smaller than %v, got %v", from.Type(), to)
+		}
+	case sizeLarger:
+		if bitSize(toType) <= bitSize(fromType) {
+			return typeErrorf(op, "To", "expected type larger than %v, got %v", from.Type(), to)
+		}
+	}
+	return nil
+}
+
+// checkBitCast checks the operands of a bitcast instruction. Pointers may only
+// be cast to pointers of the same address space, and non-pointer types may only
+// be cast to non-aggregate first-class types of the same bit size.
+func checkBitCast(from value.Value, to types.Type) error {
+	fromType, fromLen := scalarType(from.Type())
+	toType, toLen := scalarType(to)
+	fromPtr, fromOk := fromType.(*types.PointerType)
+	toPtr, toOk := toType.(*types.PointerType)
+	switch {
+	case fromOk && toOk:
+		if fromLen != toLen {
+			return typeErrorf("bitcast", "To", "vector length mismatch between %v and %v", from.Type(), to)
+		}
+		if fromPtr.AddrSpace != toPtr.AddrSpace {
+			return typeErrorf("bitcast", "To", "address space mismatch between %v and %v; use addrspacecast", from.Type(), to)
+		}
+		return nil
+	case fromOk:
+		return typeErrorf("bitcast", "To", "unable to cast pointer %v to non-pointer type %v", from.Type(), to)
+	case toOk:
+		return typeErrorf("bitcast", "To", "unable to cast non-pointer %v to pointer type %v", from.Type(), to)
+	}
+	fromSize := bitSize(fromType) * max1(fromLen)
+	if fromSize == 0 {
+		return typeErrorf("bitcast", "From", "expected non-aggregate first-class type, got %v", from.Type())
+	}
+	toSize := bitSize(toType) * max1(toLen)
+	if toSize == 0 {
+		return typeErrorf("bitcast", "To", "expected non-aggregate first-class type, got %v", to)
+	}
+	if fromSize != toSize {
+		return typeErrorf("bitcast", "To", "bit size mismatch between %v (%d bits) and %v (%d bits)", from.Type(), fromSize, to, toSize)
+	}
+	return nil
+}
+
+// checkSelect checks the operands of the given select instruction.
+func checkSelect(inst *InstSelect) error {
+	cond, condLen := scalarType(inst.Cond.Type())
+	if !cond.Equal(types.I1) {
+		return typeErrorf("select", "Cond", "expected i1 or vector of i1, got %v", inst.Cond.Type())
+	}
+	if err := checkType("select", "ValueFalse", inst.ValueFalse, inst.ValueTrue.Type()); err != nil {
+		return err
+	}
+	if condLen != 0 {
+		if _, n := scalarType(inst.ValueTrue.Type()); n != condLen {
+			return typeErrorf("select", "ValueTrue", "expected vector of length %d, got %v", condLen, inst.ValueTrue.Type())
+		}
+	}
+	return nil
+}
+
+// checkCall checks the callee and arguments of a call, invoke or callbr
+// instruction against the function signature of the callee.
+func checkCall(op, operand string, callee value.Value, args []value.Value) error {
+	pt, ok := callee.Type().(*types.PointerType)
+	if !ok {
+		return typeErrorf(op, operand, "expected pointer to function type, got %v", callee.Type())
+	}
+	sig, ok := pt.ElemType.(*types.FuncType)
+	if !ok {
+		return typeErrorf(op, operand, "expected pointer to function type, got %v", callee.Type())
+	}
+	switch {
+	case len(args) < len(sig.Params):
+		return typeErrorf(op, "Args", "too few arguments to %v; expected %d, got %d", sig, len(sig.Params), len(args))
+	case len(args) > len(sig.Params) && !sig.Variadic:
+		return typeErrorf(op, "Args", "too many arguments to %v; expected %d, got %d", sig, len(sig.Params), len(args))
+	}
+	for i, param := range sig.Params {
+		if err := checkType(op, fmt.Sprintf("Args[%d]", i), args[i], param); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkRet checks the return value of the given ret terminator against the
+// return type of its function.
+func checkRet(ret *TermRet, retType types.Type) error {
+	if ret.X == nil {
+		if !types.IsVoid(retType) {
+			return typeErrorf("ret", "X", "expected return value of type %v", retType)
+		}
+		return nil
+	}
+	return checkType("ret", "X", ret.X, retType)
+}
+
+// scalarType returns the element type and length of the given vector type, or
+// the given type and zero if not a vector type.
+func scalarType(t types.Type) (types.Type, uint64) {
+	if vt, ok := t.(*types.VectorType); ok {
+		return vt.ElemType, vt.Len
+	}
+	return t, 0
+}
+
+// bitSize returns the size in bits of the given integer, floating-point or MMX
+// type; or zero for other types.
+func bitSize(t types.Type) uint64 {
+	switch t := t.(type) {
+	case *types.IntType:
+		return t.BitSize
+	case *types.FloatType:
+		switch t.Kind {
+		case types.FloatKindHalf:
+			return 16
+		case types.FloatKindFloat:
+			return 32
+		case types.FloatKindDouble:
+			return 64
+		case types.FloatKindX86_FP80:
+			return 80
+		case types.FloatKindFP128, types.FloatKindPPC_FP128:
+			return 128
+		}
+	case *types.MMXType:
+		return 64
+	}
+	return 0
+}
+
+// max1 returns n, or 1 if n is zero.
+func max1(n uint64) uint64 {
+	if n == 0 {
+		return 1
+	}
+	return n
+}
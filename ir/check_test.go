@@ -0,0 +1,137 @@
+package ir
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+func TestCheckInst(t *testing.T) {
+	i32 := NewParam("i32", types.I32)
+	i64 := NewParam("i64", types.I64)
+	f := NewParam("f", types.Float)
+	v4 := NewParam("v4", types.NewVector(4, types.I32))
+	v8 := NewParam("v8", types.NewVector(8, types.I32))
+	p := NewParam("p", types.NewPointer(types.I32))
+	pp := NewParam("pp", types.NewPointer(types.NewPointer(types.I8)))
+	callee := NewFunc("g", types.Void, NewParam("x", types.I32))
+	printf := NewFunc("printf", types.I32, NewParam("format", types.I8Ptr))
+	printf.Sig.Variadic = true
+	golden := []struct {
+		inst Instruction
+		want string // empty if valid
+	}{
+		// Integer width equality.
+		{inst: &InstAdd{X: i32, Y: i32}},
+		{inst: &InstAdd{X: i32, Y: i64}, want: "invalid operand Y of add instruction; expected i32, got i64"},
+		{inst: &InstAdd{X: f, Y: f}, want: "invalid operand X of add instruction; expected integer or vector of integers, got float"},
+		{inst: &InstFAdd{X: f, Y: i32}, want: "invalid operand Y of fadd instruction; expected float, got i32"},
+		// Vector lengths.
+		{inst: &InstXor{X: v4, Y: v8}, want: "invalid operand Y of xor instruction; expected <4 x i32>, got <8 x i32>"},
+		{inst: &InstSExt{From: v4, To: types.NewVector(8, types.I64)}, want: "invalid operand To of sext instruction; vector length mismatch between <4 x i32> and <8 x i64>"},
+		// Pointer destination for a store.
+		{inst: &InstStore{Src: i32, Dst: p}},
+		{inst: &InstStore{Src: i32, Dst: i64}, want: "invalid operand Dst of store instruction; expected pointer type, got i64"},
+		{inst: &InstStore{Src: i64, Dst: p}, want: "invalid operand Src of store instruction; expected i32, got i64"},
+		{inst: &InstLoad{ElemType: types.I64, Src: p}, want: "invalid operand Src of load instruction; expected pointer to i64, got i32*"},
+		// Function arguments.
+		{inst: &InstCall{Callee: callee, Args: []value.Value{i32}}},
+		{inst: &InstCall{Callee: callee}, want: "invalid operand Args of call instruction; too few arguments to void (i32); expected 1, got 0"},
+		{inst: &InstCall{Callee: callee, Args: []value.Value{i32, i32}}, want: "invalid operand Args of call instruction; too many arguments to void (i32); expected 1, got 2"},
+		{inst: &InstCall{Callee: callee, Args: []value.Value{i64}}, want: "invalid operand Args[0] of call instruction; expected i32, got i64"},
+		{inst: &InstCall{Callee: printf, Args: []value.Value{constant.NewNull(types.I8Ptr), i32, f}}},
+		{inst: &InstCall{Callee: i32}, want: "invalid operand Callee of call instruction; expected pointer to function type, got i32"},
+		// Casts.
+		{inst: &InstZExt{From: i32, To: types.I64}},
+		{inst: &InstZExt{From: i64, To: types.I32}, want: "invalid operand To of zext instruction; expected type larger than i64, got i32"},
+		{inst: &InstZExt{From: f, To: types.I64}, want: "invalid operand From of zext instruction; expected integer or vector of integers, got float"},
+		{inst: &InstTrunc{From: i32, To: types.I32}, want: "invalid operand To of trunc instruction; expected type smaller than i32, got i32"},
+		{inst: &InstBitCast{From: i32, To: types.Float}},
+		{inst: &InstBitCast{From: v4, To: types.NewVector(2, types.I64)}},
+		{inst: &InstBitCast{From: pp, To: types.I8Ptr}},
+		{inst: &InstBitCast{From: i64, To: types.Float}, want: "invalid operand To of bitcast instruction; bit size mismatch between i64 (64 bits) and float (32 bits)"},
+		{inst: &InstBitCast{From: i64, To: types.I8Ptr}, want: "invalid operand To of bitcast instruction; unable to cast non-pointer i64 to pointer type i8*"},
+		// Other instructions.
+		{inst: &InstSelect{Cond: i32, ValueTrue: i32, ValueFalse: i32}, want: "invalid operand Cond of select instruction; expected i1 or vector of i1, got i32"},
+		{inst: &InstICmp{X: p, Y: p}},
+		{inst: &InstExtractValue{X: i32, Indices: []uint64{0}}, want: "invalid operand X of extractvalue instruction; expected struct or array type, got i32"},
+		{inst: &InstGetElementPtr{ElemType: types.I32, Src: p, Indices: []value.Value{i64, i64}}, want: "invalid operand Indices[1] of getelementptr instruction; unable to index into non-aggregate type i32"},
+	}
+	for _, g := range golden {
+		err := CheckInst(g.inst)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != g.want {
+			t.Errorf("type error mismatch of %T; expected %q, got %q", g.inst, g.want, got)
+		}
+	}
+}
+
+func TestBuilderChecking(t *testing.T) {
+	m := NewModule()
+	x := NewParam("x", types.I32)
+	p := NewParam("p", types.I8Ptr)
+	f := m.NewFunc("f", types.I32, x, p)
+	b := NewBuilder(f)
+	b.SetInsertPointAtEnd(b.NewBlock("entry"))
+	b.SetChecking(true)
+	if inst := b.NewAdd(x, x); inst == nil || b.Err() != nil {
+		t.Fatalf("unexpected type error; %v", b.Err())
+	}
+	// Type errors detected by CheckInst.
+	if inst := b.NewZExt(x, types.I16); inst != nil {
+		t.Errorf("expected nil instruction, got %q", inst.LLString())
+	}
+	const want = "invalid operand To of zext instruction; expected type larger than i32, got i16"
+	if err := b.Err(); err == nil || err.Error() != want {
+		t.Errorf("type error mismatch; expected %q, got %v", want, err)
+	}
+	// Type errors detected by the constructor; the first error is kept.
+	if inst := b.NewStore(x, x); inst != nil {
+		t.Errorf("expected nil instruction, got %q", inst.LLString())
+	}
+	if err := b.Err(); err == nil || err.Error() != want {
+		t.Errorf("type error mismatch; expected %q, got %v", want, err)
+	}
+	// Return values are checked against the function signature.
+	b.err = nil
+	if term := b.NewRet(p); term != nil {
+		t.Errorf("expected nil terminator, got %q", term.LLString())
+	}
+	const wantRet = "invalid operand X of ret instruction; expected i32, got i8*"
+	if err := b.Err(); err == nil || err.Error() != wantRet {
+		t.Errorf("type error mismatch; expected %q, got %v", wantRet, err)
+	}
+	if got := len(b.Block().Insts); got != 1 {
+		t.Errorf("number of instructions mismatch; expected 1, got %d", got)
+	}
+	if b.Block().Term != nil {
+		t.Errorf("expected no terminator, got %q", b.Block().Term.LLString())
+	}
+	// Misuse of the builder is not recovered in checking mode.
+	func() {
+		nb := NewBuilder(nil)
+		nb.SetChecking(true)
+		defer func() {
+			if e := recover(); e == nil {
+				t.Errorf("expected panic of builder without insertion point")
+			}
+			if err := nb.Err(); err != nil {
+				t.Errorf("unexpected type error; %v", err)
+			}
+		}()
+		nb.NewAdd(x, x)
+	}()
+	// Outside of checking mode, constructors panic as before.
+	b.SetChecking(false)
+	defer func() {
+		if e := recover(); e == nil {
+			t.Errorf("expected panic of store with non-pointer destination")
+		}
+	}()
+	b.NewStore(x, x)
+}
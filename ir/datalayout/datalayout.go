@@ -0,0 +1,309 @@
+// Package datalayout implements the data layout of LLVM IR modules, which
+// specifies how values of each type are laid out in memory; i.e. the size and
+// alignment of types and the offsets of the fields of aggregate types.
+//
+// The data layout is specified by the "target datalayout" string of a module,
+// as described in the LLVM language reference.
+//
+// ref: https://llvm.org/docs/LangRef.html#data-layout
+package datalayout
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/llir/llvm/ir/types"
+	"github.com/pkg/errors"
+)
+
+// Layout is the data layout of an LLVM IR module. Sizes and alignments of
+// specifications are given in bits, as in the data layout string.
+type Layout struct {
+	// Big-endian byte order; otherwise little-endian.
+	BigEndian bool
+	// Natural stack alignment in bits; or zero if unspecified.
+	StackAlign uint64
+	// Pointer specifications, keyed by address space.
+	Pointers map[types.AddrSpace]PointerSpec
+	// Alignment of integer types, sorted by bit size.
+	Ints []AlignSpec
+	// Alignment of floating-point types, sorted by bit size.
+	Floats []AlignSpec
+	// Alignment of vector types, sorted by bit size.
+	Vectors []AlignSpec
+	// Alignment of aggregate types (the bit size is unused).
+	Aggregate AlignSpec
+	// Native integer bit sizes of the target CPU.
+	NativeInts []uint64
+	// Mangling style of symbol names; or zero if unspecified.
+	Mangling byte
+}
+
+// AlignSpec specifies the alignment of types of a given bit size.
+type AlignSpec struct {
+	// Bit size of the type.
+	BitSize uint64
+	// ABI alignment in bits.
+	ABI uint64
+	// Preferred alignment in bits.
+	Pref uint64
+}
+
+// PointerSpec specifies the size and alignment of pointer types of an address
+// space.
+type PointerSpec struct {
+	// Bit size of pointers.
+	BitSize uint64
+	// ABI alignment in bits.
+	ABI uint64
+	// Preferred alignment in bits.
+	Pref uint64
+	// Bit size of indices used in address computations.
+	IndexSize uint64
+}
+
+// Default returns the default data layout of LLVM, which is used for
+// specifications not present in the data layout string.
+func Default() *Layout {
+	return &Layout{
+		Pointers: map[types.AddrSpace]PointerSpec{
+			0: {BitSize: 64, ABI: 64, Pref: 64, IndexSize: 64},
+		},
+		Ints: []AlignSpec{
+			{BitSize: 1, ABI: 8, Pref: 8},
+			{BitSize: 8, ABI: 8, Pref: 8},
+			{BitSize: 16, ABI: 16, Pref: 16},
+			{BitSize: 32, ABI: 32, Pref: 32},
+			{BitSize: 64, ABI: 32, Pref: 64},
+		},
+		Floats: []AlignSpec{
+			{BitSize: 16, ABI: 16, Pref: 16},
+			{BitSize: 32, ABI: 32, Pref: 32},
+			{BitSize: 64, ABI: 64, Pref: 64},
+			{BitSize: 128, ABI: 128, Pref: 128},
+		},
+		Vectors: []AlignSpec{
+			{BitSize: 64, ABI: 64, Pref: 64},
+			{BitSize: 128, ABI: 128, Pref: 128},
+		},
+		Aggregate: AlignSpec{ABI: 0, Pref: 64},
+	}
+}
+
+// Parse parses the given data layout string. Specifications not present in the
+// data layout string default to those of Default. An empty data layout string
+// denotes the default data layout.
+func Parse(s string) (*Layout, error) {
+	dl := Default()
+	if len(s) == 0 {
+		return dl, nil
+	}
+	for _, spec := range strings.Split(s, "-") {
+		if err := dl.parseSpec(spec); err != nil {
+			return nil, errors.Wrapf(err, "invalid data layout specification %q", spec)
+		}
+	}
+	return dl, nil
+}
+
+// parseSpec parses the given specification of a data layout string.
+func (dl *Layout) parseSpec(spec string) error {
+	if len(spec) == 0 {
+		return errors.New("empty specification")
+	}
+	switch {
+	case spec == "e":
+		dl.BigEndian = false
+	case spec == "E":
+		dl.BigEndian = true
+	case strings.HasPrefix(spec, "ni:"):
+		// Non-integral pointer address spaces.
+		_, err := parseInts(spec[len("ni:"):])
+		return err
+	case spec[0] == 'm':
+		// m:<mangling>
+		if len(spec) != 3 || spec[1] != ':' {
+			return errors.New("invalid mangling specification")
+		}
+		dl.Mangling = spec[2]
+	case spec[0] == 'S':
+		align, err := parseInt(spec[1:])
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		dl.StackAlign = align
+	case spec[0] == 'P', spec[0] == 'A', spec[0] == 'G':
+		// Program, alloca and global address spaces.
+		_, err := parseInt(spec[1:])
+		return err
+	case spec[0] == 'F':
+		// Function pointer alignment; F<type><abi>
+		if len(spec) < 3 || (spec[1] != 'i' && spec[1] != 'n') {
+			return errors.New("invalid function pointer alignment specification")
+		}
+		_, err := parseInt(spec[2:])
+		return err
+	case spec[0] == 'n':
+		ns, err := parseInts(spec[1:])
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		dl.NativeInts = ns
+	case spec[0] == 'p':
+		// p[n]:<size>:<abi>[:<pref>[:<idx>]]
+		parts := strings.Split(spec[1:], ":")
+		var addrSpace uint64
+		if len(parts[0]) > 0 {
+			var err error
+			if addrSpace, err = parseInt(parts[0]); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+		ns, err := parseInts(strings.Join(parts[1:], ":"))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if len(ns) < 2 || len(ns) > 4 {
+			return errors.New("invalid number of pointer specification fields")
+		}
+		p := PointerSpec{BitSize: ns[0], ABI: ns[1], Pref: ns[1], IndexSize: ns[0]}
+		if len(ns) >= 3 {
+			p.Pref = ns[2]
+		}
+		if len(ns) == 4 {
+			p.IndexSize = ns[3]
+		}
+		dl.Pointers[types.AddrSpace(addrSpace)] = p
+	case spec[0] == 'i', spec[0] == 'f', spec[0] == 'v', spec[0] == 'a':
+		// <kind><size>:<abi>[:<pref>]
+		parts := strings.SplitN(spec[1:], ":", 2)
+		var size uint64
+		if len(parts[0]) > 0 {
+			var err error
+			if size, err = parseInt(parts[0]); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+		if len(parts) != 2 {
+			return errors.New("missing ABI alignment")
+		}
+		ns, err := parseInts(parts[1])
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if len(ns) < 1 || len(ns) > 2 {
+			return errors.New("invalid number of alignment specification fields")
+		}
+		a := AlignSpec{BitSize: size, ABI: ns[0], Pref: ns[0]}
+		if len(ns) == 2 {
+			a.Pref = ns[1]
+		}
+		switch spec[0] {
+		case 'i':
+			if size == 0 {
+				return errors.New("invalid integer bit size 0")
+			}
+			dl.Ints = setAlign(dl.Ints, a)
+		case 'f':
+			dl.Floats = setAlign(dl.Floats, a)
+		case 'v':
+			dl.Vectors = setAlign(dl.Vectors, a)
+		case 'a':
+			dl.Aggregate = a
+		}
+	default:
+		return errors.New("unknown specification")
+	}
+	return nil
+}
+
+// String returns the string representation of the data layout.
+func (dl *Layout) String() string {
+	var specs []string
+	if dl.BigEndian {
+		specs = append(specs, "E")
+	} else {
+		specs = append(specs, "e")
+	}
+	if dl.Mangling != 0 {
+		specs = append(specs, fmt.Sprintf("m:%c", dl.Mangling))
+	}
+	var addrSpaces []types.AddrSpace
+	for addrSpace := range dl.Pointers {
+		addrSpaces = append(addrSpaces, addrSpace)
+	}
+	sort.Slice(addrSpaces, func(i, j int) bool { return addrSpaces[i] < addrSpaces[j] })
+	for _, addrSpace := range addrSpaces {
+		p := dl.Pointers[addrSpace]
+		as := ""
+		if addrSpace != 0 {
+			as = strconv.FormatUint(uint64(addrSpace), 10)
+		}
+		spec := fmt.Sprintf("p%s:%d:%d:%d", as, p.BitSize, p.ABI, p.Pref)
+		if p.IndexSize != p.BitSize {
+			spec += fmt.Sprintf(":%d", p.IndexSize)
+		}
+		specs = append(specs, spec)
+	}
+	for _, a := range dl.Ints {
+		specs = append(specs, fmt.Sprintf("i%d:%d:%d", a.BitSize, a.ABI, a.Pref))
+	}
+	for _, a := range dl.Floats {
+		specs = append(specs, fmt.Sprintf("f%d:%d:%d", a.BitSize, a.ABI, a.Pref))
+	}
+	for _, a := range dl.Vectors {
+		specs = append(specs, fmt.Sprintf("v%d:%d:%d", a.BitSize, a.ABI, a.Pref))
+	}
+	specs = append(specs, fmt.Sprintf("a:%d:%d", dl.Aggregate.ABI, dl.Aggregate.Pref))
+	if len(dl.NativeInts) > 0 {
+		var ns []string
+		for _, n := range dl.NativeInts {
+			ns = append(ns, strconv.FormatUint(n, 10))
+		}
+		specs = append(specs, "n"+strings.Join(ns, ":"))
+	}
+	if dl.StackAlign != 0 {
+		specs = append(specs, fmt.Sprintf("S%d", dl.StackAlign))
+	}
+	return strings.Join(specs, "-")
+}
+
+// ### [ Helper functions ] ####################################################
+
+// parseInt parses the given unsigned decimal integer.
+func parseInt(s string) (uint64, error) {
+	x, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return x, nil
+}
+
+// parseInts parses the given colon-separated list of unsigned decimal integers.
+func parseInts(s string) ([]uint64, error) {
+	var xs []uint64
+	for _, part := range strings.Split(s, ":") {
+		x, err := parseInt(part)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		xs = append(xs, x)
+	}
+	return xs, nil
+}
+
+// setAlign sets the alignment specification of the given bit size, keeping the
+// specifications sorted by bit size.
+func setAlign(specs []AlignSpec, a AlignSpec) []AlignSpec {
+	for i, spec := range specs {
+		if spec.BitSize == a.BitSize {
+			specs[i] = a
+			return specs
+		}
+	}
+	specs = append(specs, a)
+	sort.Slice(specs, func(i, j int) bool { return specs[i].BitSize < specs[j].BitSize })
+	return specs
+}
@@ -0,0 +1,64 @@
+package datalayout
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestLayout(t *testing.T) {
+	const x86_64 = "e-m:e-p270:32:32-p271:32:32-p272:64:64-i64:64-f80:128-n8:16:32:64-S128"
+	dl, err := Parse(x86_64)
+	if err != nil {
+		t.Fatalf("unable to parse data layout; %+v", err)
+	}
+	mixed := types.NewStruct(types.I8, types.I32, types.I64)
+	packed := types.NewStruct(types.I8, types.I32)
+	packed.Packed = true
+	golden := []struct {
+		typ   types.Type
+		size  uint64
+		align uint64
+	}{
+		{typ: types.I1, size: 1, align: 1},
+		{typ: types.I64, size: 8, align: 8},
+		{typ: types.NewInt(24), size: 4, align: 4},
+		{typ: types.X86_FP80, size: 16, align: 16},
+		{typ: types.NewPointer(types.I8), size: 8, align: 8},
+		{typ: mixed, size: 16, align: 8},
+		{typ: packed, size: 5, align: 1},
+		{typ: types.NewArray(3, types.NewStruct(types.I8, types.I16)), size: 12, align: 2},
+		{typ: types.NewVector(4, types.Float), size: 16, align: 16},
+	}
+	for _, g := range golden {
+		if got := dl.AllocSize(g.typ); got != g.size {
+			t.Errorf("allocation size mismatch of %v; expected %d, got %d", g.typ, g.size, got)
+		}
+		if got := dl.ABIAlign(g.typ); got != g.align {
+			t.Errorf("alignment mismatch of %v; expected %d, got %d", g.typ, g.align, got)
+		}
+	}
+	want := []uint64{0, 4, 8}
+	for i, got := range dl.StructLayout(mixed).Offsets {
+		if got != want[i] {
+			t.Errorf("offset mismatch of field %d; expected %d, got %d", i, want[i], got)
+		}
+	}
+	offset, err := dl.IndexedOffset(types.NewArray(2, mixed), []int64{1, 1, 2})
+	if err != nil {
+		t.Fatalf("unable to compute indexed offset; %+v", err)
+	}
+	if offset != 32+16+8 {
+		t.Errorf("indexed offset mismatch; expected %d, got %d", 32+16+8, offset)
+	}
+	if p := dl.Pointers[270]; p.BitSize != 32 {
+		t.Errorf("pointer size mismatch of address space 270; expected 32, got %d", p.BitSize)
+	}
+	// Default i64 alignment.
+	if got := Default().ABIAlign(types.I64); got != 4 {
+		t.Errorf("default alignment mismatch of i64; expected 4, got %d", got)
+	}
+	if _, err := Parse("e-x42"); err == nil {
+		t.Errorf("expected error for invalid data layout specification")
+	}
+}
@@ -0,0 +1,233 @@
+package datalayout
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/llir/llvm/ir/types"
+)
+
+// --- [ Size and alignment ] --------------------------------------------------
+
+// BitSize returns the size in bits of values of the given type.
+func (dl *Layout) BitSize(t types.Type) uint64 {
+	switch t := t.(type) {
+	case *types.IntType:
+		return t.BitSize
+	case *types.FloatType:
+		switch t.Kind {
+		case types.FloatKindHalf:
+			return 16
+		case types.FloatKindFloat:
+			return 32
+		case types.FloatKindDouble:
+			return 64
+		case types.FloatKindX86_FP80:
+			return 80
+		case types.FloatKindFP128, types.FloatKindPPC_FP128:
+			return 128
+		}
+	case *types.MMXType:
+		return 64
+	case *types.PointerType:
+		return dl.pointer(t.AddrSpace).BitSize
+	case *types.VectorType:
+		return t.Len * dl.BitSize(t.ElemType)
+	case *types.ArrayType:
+		return 8 * t.Len * dl.AllocSize(t.ElemType)
+	case *types.StructType:
+		return 8 * dl.StructLayout(t).Size
+	case *types.VoidType, *types.LabelType, *types.TokenType, *types.MetadataType, *types.FuncType:
+		return 0
+	}
+	panic(fmt.Errorf("support for type %T not yet implemented", t))
+}
+
+// StoreSize returns the maximum number of bytes which may be overwritten by
+// storing a value of the given type.
+func (dl *Layout) StoreSize(t types.Type) uint64 {
+	return (dl.BitSize(t) + 7) / 8
+}
+
+// AllocSize returns the offset in bytes between successive values of the given
+// type in memory (e.g. array elements), including alignment padding.
+func (dl *Layout) AllocSize(t types.Type) uint64 {
+	return alignTo(dl.StoreSize(t), dl.ABIAlign(t))
+}
+
+// ABIAlign returns the minimum alignment in bytes required by the ABI for
+// values of the given type.
+func (dl *Layout) ABIAlign(t types.Type) uint64 {
+	return dl.align(t, true)
+}
+
+// PrefAlign returns the preferred alignment in bytes of values of the given
+// type.
+func (dl *Layout) PrefAlign(t types.Type) uint64 {
+	return dl.align(t, false)
+}
+
+// align returns the ABI or preferred alignment in bytes of the given type.
+func (dl *Layout) align(t types.Type, abi bool) uint64 {
+	pick := func(a AlignSpec) uint64 {
+		bits := a.Pref
+		if abi {
+			bits = a.ABI
+		}
+		if bits < 8 {
+			return 1
+		}
+		return bits / 8
+	}
+	switch t := t.(type) {
+	case *types.IntType:
+		// Use the alignment of the smallest integer type at least as large as
+		// t, or the largest integer type if none is large enough.
+		for _, a := range dl.Ints {
+			if a.BitSize >= t.BitSize {
+				return pick(a)
+			}
+		}
+		if len(dl.Ints) > 0 {
+			return pick(dl.Ints[len(dl.Ints)-1])
+		}
+	case *types.FloatType:
+		if a, ok := findAlign(dl.Floats, dl.BitSize(t)); ok {
+			return pick(a)
+		}
+	case *types.PointerType:
+		p := dl.pointer(t.AddrSpace)
+		return pick(AlignSpec{ABI: p.ABI, Pref: p.Pref})
+	case *types.VectorType:
+		if a, ok := findAlign(dl.Vectors, dl.BitSize(t)); ok {
+			return pick(a)
+		}
+	case *types.ArrayType:
+		return dl.align(t.ElemType, abi)
+	case *types.StructType:
+		if t.Packed && abi {
+			return 1
+		}
+		align := pick(dl.Aggregate)
+		if a := dl.StructLayout(t).Align; a > align {
+			align = a
+		}
+		return align
+	}
+	// Natural alignment; the store size rounded up to a power of two.
+	return nextPowerOf2(dl.StoreSize(t))
+}
+
+// pointer returns the pointer specification of the given address space,
+// defaulting to that of the default address space.
+func (dl *Layout) pointer(addrSpace types.AddrSpace) PointerSpec {
+	if p, ok := dl.Pointers[addrSpace]; ok {
+		return p
+	}
+	return dl.Pointers[0]
+}
+
+// IntPtrType returns the integer type with the same bit size as pointers of
+// the given address space.
+func (dl *Layout) IntPtrType(addrSpace types.AddrSpace) *types.IntType {
+	return types.NewInt(dl.pointer(addrSpace).BitSize)
+}
+
+// --- [ Aggregate layout ] ----------------------------------------------------
+
+// StructLayout is the memory layout of a struct type.
+type StructLayout struct {
+	// Size in bytes of the struct, including tail padding.
+	Size uint64
+	// ABI alignment in bytes of the struct.
+	Align uint64
+	// Offset in bytes of each field.
+	Offsets []uint64
+}
+
+// StructLayout returns the memory layout of the given struct type.
+func (dl *Layout) StructLayout(t *types.StructType) *StructLayout {
+	l := &StructLayout{Align: 1}
+	for _, field := range t.Fields {
+		align := uint64(1)
+		if !t.Packed {
+			align = dl.ABIAlign(field)
+		}
+		l.Size = alignTo(l.Size, align)
+		l.Offsets = append(l.Offsets, l.Size)
+		l.Size += dl.AllocSize(field)
+		if align > l.Align {
+			l.Align = align
+		}
+	}
+	l.Size = alignTo(l.Size, l.Align)
+	return l
+}
+
+// FieldAt returns the index of the field of the struct containing the given
+// byte offset, or -1 if the offset is past the end of the struct.
+func (l *StructLayout) FieldAt(offset uint64) int {
+	if offset >= l.Size {
+		return -1
+	}
+	i := sort.Search(len(l.Offsets), func(i int) bool { return l.Offsets[i] > offset })
+	return i - 1
+}
+
+// IndexedOffset returns the byte offset computed by a getelementptr
+// instruction with the given source element type and constant indices.
+func (dl *Layout) IndexedOffset(elemType types.Type, indices []int64) (int64, error) {
+	if len(indices) == 0 {
+		return 0, nil
+	}
+	offset := indices[0] * int64(dl.AllocSize(elemType))
+	t := elemType
+	for _, index := range indices[1:] {
+		switch tt := t.(type) {
+		case *types.StructType:
+			if index < 0 || index >= int64(len(tt.Fields)) {
+				return 0, fmt.Errorf("invalid field index %d of struct type %v", index, tt)
+			}
+			offset += int64(dl.StructLayout(tt).Offsets[index])
+			t = tt.Fields[index]
+		case *types.ArrayType:
+			offset += index * int64(dl.AllocSize(tt.ElemType))
+			t = tt.ElemType
+		case *types.VectorType:
+			offset += index * int64(dl.AllocSize(tt.ElemType))
+			t = tt.ElemType
+		default:
+			return 0, fmt.Errorf("unable to index into non-aggregate type %v", t)
+		}
+	}
+	return offset, nil
+}
+
+// ### [ Helper functions ] ####################################################
+
+// findAlign returns the alignment specification of the given bit size.
+func findAlign(specs []AlignSpec, bitSize uint64) (AlignSpec, bool) {
+	for _, a := range specs {
+		if a.BitSize == bitSize {
+			return a, true
+		}
+	}
+	return AlignSpec{}, false
+}
+
+// alignTo returns x rounded up to a multiple of align.
+func alignTo(x, align uint64) uint64 {
+	if align == 0 {
+		return x
+	}
+	return (x + align - 1) / align * align
+}
+
+// nextPowerOf2 returns the smallest power of two greater than or equal to x.
+func nextPowerOf2(x uint64) uint64 {
+	p := uint64(1)
+	for p < x {
+		p <<= 1
+	}
+	return p
+}
@@ -381,6 +381,21 @@ func (mds Metadata) MDAttachments() []*metadata.Attachment {
 	return mds
 }
 
+// SetAttachment sets the metadata attachment of the given name (without '!'
+// prefix; e.g. dbg) to node, replacing any existing attachment of the same name.
+func (mds *Metadata) SetAttachment(name string, node metadata.MDNode) {
+	md := &metadata.Attachment{Name: name, Node: node}
+	for i, old := range *mds {
+		if old.Name == name {
+			// Attachments may be shared with other values; replace rather than
+			// modify.
+			(*mds)[i] = md
+			return
+		}
+	}
+	*mds = append(*mds, md)
+}
+
 // OperandBundle is a tagged set of SSA values associated with a call-site.
 type OperandBundle struct {
 	Tag    string
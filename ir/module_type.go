@@ -5,16 +5,16 @@ import "github.com/llir/llvm/ir/types"
 // --- [ Type definitions ] ----------------------------------------------------
 
 // NewTypeDef appends a new type definition to the module based on the given
-// type name and underlying type.
+// type name and underlying type, and returns the named type.
 //
-// Note, the name of the given type is set by invoking typ.SetName. As such,
-// users are advised to refrain from creating type definitions from the
-// convenience global variables of the types package (e.g. types.I64), since
-// doing so would change the LLVM IR name of all uses of the types.I64 global
-// variable. Instead, create a new type (e.g. types.NewInt(64)) for this
-// purpose.
+// Struct types are named in place, so that recursive struct types may refer to
+// themselves (e.g. by a field of type pointer to the struct type). Other types,
+// and literal struct types interned by a type context, are copied before being
+// named; as such, creating a type definition from a shared type (e.g. the
+// convenience global variable types.I64) leaves the shared type unchanged, and
+// the returned type should be used to refer to the type definition.
 func (m *Module) NewTypeDef(name string, typ types.Type) types.Type {
-	typ.SetName(name)
-	m.TypeDefs = append(m.TypeDefs, typ)
-	return typ
+	named := types.Named(name, typ)
+	m.TypeDefs = append(m.TypeDefs, named)
+	return named
 }
@@ -0,0 +1,37 @@
+package ir_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestNewTypeDef(t *testing.T) {
+	const want = `%size_t = type i64
+%list = type { %size_t, %list* }
+
+define i64 @len(%list* %l) {
+0:
+	ret i64 0
+}
+`
+	m := ir.NewModule()
+	// Shared types are not renamed.
+	sizeT := m.NewTypeDef("size_t", types.I64)
+	if got := types.I64.String(); got != "i64" {
+		t.Errorf("shared type mismatch; expected %q, got %q", "i64", got)
+	}
+	// Struct types are named in place, for recursive struct types.
+	list := types.NewStruct()
+	list.Fields = []types.Type{sizeT, types.NewPointer(list)}
+	m.NewTypeDef("list", list)
+	l := ir.NewParam("l", types.NewPointer(list))
+	m.NewFunc("len", types.I64, l).NewBlock("").NewRet(constant.NewInt(types.I64, 0))
+	got := m.String()
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("module mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,323 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// --- [ Type context ] --------------------------------------------------------
+
+// Context is a type uniquing context, which interns literal types (integer,
+// floating-point, pointer, vector, array, literal struct and function types,
+// etc) so that equal literal types of the context are identical; i.e. may be
+// compared by pointer identity.
+//
+// Identified (named) struct types are not interned; each identified struct type
+// of the context is distinct, and its type name is unique within the context.
+// Type names of identified struct types are changed by Rename, which keeps the
+// type names of the context unique.
+//
+// The convenience types of the types package (e.g. types.I64, types.I8Ptr) are
+// interned by every context. As such, interned types are shared and must not be
+// modified (e.g. by SetName); use Named to create named types from shared
+// types.
+type Context struct {
+	// Interned literal types, indexed by structural hash.
+	literals map[uint64][]Type
+	// Identified struct types, indexed by type name.
+	named map[string]*StructType
+}
+
+// NewContext returns a new type uniquing context.
+func NewContext() *Context {
+	c := &Context{
+		literals: make(map[uint64][]Type),
+		named:    make(map[string]*StructType),
+	}
+	convenienceTypes := []Type{
+		// Basic types.
+		Void, MMX, Label, Token, Metadata,
+		// Integer types.
+		I1, I2, I3, I4, I5, I6, I7, I8, I16, I32, I64, I128, I256, I512, I1024,
+		// Floating-point types.
+		Half, Float, Double, X86_FP80, FP128, PPC_FP128,
+		// Integer pointer types.
+		I1Ptr, I8Ptr, I16Ptr, I32Ptr, I64Ptr, I128Ptr,
+	}
+	for _, t := range convenienceTypes {
+		// Skip convenience types renamed by users.
+		if len(t.Name()) > 0 {
+			continue
+		}
+		h := Hash(t)
+		c.literals[h] = append(c.literals[h], t)
+	}
+	return c
+}
+
+// Intern returns the interned type of the context equal to t, interning t if
+// not yet present. The element, field, parameter and return types of t are
+// interned recursively.
+//
+// Identified struct types are returned as is. Type names of other types are
+// dropped, as such types are equal to their underlying type.
+func (c *Context) Intern(t Type) Type {
+	return c.intern(t, make(map[Type]bool))
+}
+
+// Int returns the interned integer type of the given bit size.
+func (c *Context) Int(bitSize uint64) *IntType {
+	return c.Intern(&IntType{BitSize: bitSize}).(*IntType)
+}
+
+// Float returns the interned floating-point type of the given kind.
+func (c *Context) Float(kind FloatKind) *FloatType {
+	return c.Intern(&FloatType{Kind: kind}).(*FloatType)
+}
+
+// Pointer returns the interned pointer type of the given element type, in the
+// default address space.
+func (c *Context) Pointer(elemType Type) *PointerType {
+	return c.PointerAddrSpace(elemType, 0)
+}
+
+// PointerAddrSpace returns the interned pointer type of the given element type
+// and address space.
+func (c *Context) PointerAddrSpace(elemType Type, addrSpace AddrSpace) *PointerType {
+	return c.Intern(&PointerType{ElemType: elemType, AddrSpace: addrSpace}).(*PointerType)
+}
+
+// Vector returns the interned vector type of the given vector length and element
+// type.
+func (c *Context) Vector(len uint64, elemType Type) *VectorType {
+	return c.Intern(&VectorType{Len: len, ElemType: elemType}).(*VectorType)
+}
+
+// ScalableVector returns the interned scalable vector type of the given minimum
+// vector length and element type.
+func (c *Context) ScalableVector(len uint64, elemType Type) *VectorType {
+	return c.Intern(&VectorType{Scalable: true, Len: len, ElemType: elemType}).(*VectorType)
+}
+
+// Array returns the interned array type of the given array length and element
+// type.
+func (c *Context) Array(len uint64, elemType Type) *ArrayType {
+	return c.Intern(&ArrayType{Len: len, ElemType: elemType}).(*ArrayType)
+}
+
+// Struct returns the interned literal struct type of the given field types.
+func (c *Context) Struct(fields ...Type) *StructType {
+	return c.Intern(&StructType{Fields: fields}).(*StructType)
+}
+
+// PackedStruct returns the interned packed literal struct type of the given
+// field types.
+func (c *Context) PackedStruct(fields ...Type) *StructType {
+	return c.Intern(&StructType{Packed: true, Fields: fields}).(*StructType)
+}
+
+// Func returns the interned function type of the given return type and
+// function parameter types.
+func (c *Context) Func(retType Type, params ...Type) *FuncType {
+	return c.Intern(&FuncType{RetType: retType, Params: params}).(*FuncType)
+}
+
+// VariadicFunc returns the interned variadic function type of the given return
+// type and function parameter types.
+func (c *Context) VariadicFunc(retType Type, params ...Type) *FuncType {
+	return c.Intern(&FuncType{RetType: retType, Params: params, Variadic: true}).(*FuncType)
+}
+
+// NamedStruct returns a new identified struct type of the context based on the
+// given type name and field types. The type name is made unique within the
+// context by appending a numeric suffix if already in use (e.g. "foo.0").
+//
+// Field types may be set after creation, to create recursive struct types.
+func (c *Context) NamedStruct(name string, fields ...Type) *StructType {
+	t := &StructType{Fields: fields}
+	c.Rename(t, name)
+	return t
+}
+
+// OpaqueStruct returns a new opaque identified struct type of the context based
+// on the given type name, which is made unique within the context.
+func (c *Context) OpaqueStruct(name string) *StructType {
+	t := &StructType{Opaque: true}
+	c.Rename(t, name)
+	return t
+}
+
+// NamedType returns the identified struct type of the context with the given
+// type name; or nil if not present.
+func (c *Context) NamedType(name string) *StructType {
+	if t, ok := c.named[name]; ok && t.TypeName == name {
+		return t
+	}
+	return nil
+}
+
+// Rename sets the type name of the given identified struct type of the context,
+// and returns the type name; which is made unique within the context by
+// appending a numeric suffix if the given type name is already in use.
+func (c *Context) Rename(t *StructType, name string) string {
+	if t.interned {
+		panic(fmt.Errorf("unable to rename interned literal struct type %v", t))
+	}
+	if len(name) == 0 {
+		panic(fmt.Errorf("unable to rename struct type %v; empty type name", t))
+	}
+	if c.NamedType(t.TypeName) == t {
+		delete(c.named, t.TypeName)
+	}
+	unique := name
+	for i := 0; c.NamedType(unique) != nil; i++ {
+		unique = name + "." + strconv.Itoa(i)
+	}
+	t.TypeName = unique
+	c.named[unique] = t
+	return unique
+}
+
+// --- [ Named types ] ---------------------------------------------------------
+
+// Named returns a type of the given type name with the underlying type t.
+//
+// Struct types which are not interned are named in place, so that references to
+// the struct type (e.g. fields of recursive struct types) refer to the named
+// type. Other types are copied, leaving shared types (e.g. types.I64 and
+// interned types of a type context) unchanged.
+func Named(name string, t Type) Type {
+	var named Type
+	switch t := t.(type) {
+	case *StructType:
+		if !t.interned {
+			t.SetName(name)
+			return t
+		}
+		u := *t
+		u.Fields = append([]Type(nil), t.Fields...)
+		u.interned = false
+		named = &u
+	case *VoidType:
+		u := *t
+		named = &u
+	case *FuncType:
+		u := *t
+		u.Params = append([]Type(nil), t.Params...)
+		named = &u
+	case *IntType:
+		u := *t
+		named = &u
+	case *FloatType:
+		u := *t
+		named = &u
+	case *MMXType:
+		u := *t
+		named = &u
+	case *PointerType:
+		u := *t
+		named = &u
+	case *VectorType:
+		u := *t
+		named = &u
+	case *LabelType:
+		u := *t
+		named = &u
+	case *TokenType:
+		u := *t
+		named = &u
+	case *MetadataType:
+		u := *t
+		named = &u
+	case *ArrayType:
+		u := *t
+		named = &u
+	default:
+		panic(fmt.Errorf("support for type %T not yet implemented", t))
+	}
+	named.SetName(name)
+	return named
+}
+
+// ### [ Helper functions ] ####################################################
+
+// intern returns the interned type of the context equal to t, interning t if
+// not yet present. The visiting set records the literal types currently being
+// interned, to detect recursive literal types.
+func (c *Context) intern(t Type, visiting map[Type]bool) Type {
+	if s, ok := t.(*StructType); ok && len(s.TypeName) > 0 {
+		// Identified struct types are not interned.
+		return t
+	}
+	if c.isInterned(t) {
+		return t
+	}
+	if visiting[t] {
+		panic(fmt.Errorf("unable to intern recursive literal type %v; recursive types must contain an identified struct type", t.LLString()))
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+	// Create a candidate type with interned element, field, parameter and return
+	// types.
+	var candidate Type
+	switch t := t.(type) {
+	case *VoidType:
+		candidate = &VoidType{}
+	case *FuncType:
+		params := make([]Type, len(t.Params))
+		for i, param := range t.Params {
+			params[i] = c.intern(param, visiting)
+		}
+		candidate = &FuncType{RetType: c.intern(t.RetType, visiting), Params: params, Variadic: t.Variadic}
+	case *IntType:
+		candidate = &IntType{BitSize: t.BitSize}
+	case *FloatType:
+		candidate = &FloatType{Kind: t.Kind}
+	case *MMXType:
+		candidate = &MMXType{}
+	case *PointerType:
+		candidate = &PointerType{ElemType: c.intern(t.ElemType, visiting), AddrSpace: t.AddrSpace}
+	case *VectorType:
+		candidate = &VectorType{Scalable: t.Scalable, Len: t.Len, ElemType: c.intern(t.ElemType, visiting)}
+	case *LabelType:
+		candidate = &LabelType{}
+	case *TokenType:
+		candidate = &TokenType{}
+	case *MetadataType:
+		candidate = &MetadataType{}
+	case *ArrayType:
+		candidate = &ArrayType{Len: t.Len, ElemType: c.intern(t.ElemType, visiting)}
+	case *StructType:
+		fields := make([]Type, len(t.Fields))
+		for i, field := range t.Fields {
+			fields[i] = c.intern(field, visiting)
+		}
+		candidate = &StructType{Packed: t.Packed, Fields: fields}
+	default:
+		panic(fmt.Errorf("support for type %T not yet implemented", t))
+	}
+	h := Hash(candidate)
+	for _, u := range c.literals[h] {
+		if Equal(candidate, u) {
+			return u
+		}
+	}
+	if s, ok := candidate.(*StructType); ok {
+		s.interned = true
+	}
+	c.literals[h] = append(c.literals[h], candidate)
+	return candidate
+}
+
+// isInterned reports whether t is an interned type of the context.
+func (c *Context) isInterned(t Type) bool {
+	if len(t.Name()) > 0 {
+		return false
+	}
+	for _, u := range c.literals[Hash(t)] {
+		if t == u {
+			return true
+		}
+	}
+	return false
+}
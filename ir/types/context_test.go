@@ -0,0 +1,108 @@
+package types
+
+import "testing"
+
+func TestContextIntern(t *testing.T) {
+	c := NewContext()
+	// Convenience types are interned.
+	if got := c.Int(64); got != I64 {
+		t.Errorf("interned type mismatch; expected types.I64, got %p", got)
+	}
+	if got := c.Pointer(c.Int(8)); got != I8Ptr {
+		t.Errorf("interned type mismatch; expected types.I8Ptr, got %p", got)
+	}
+	// Equal literal types are identical.
+	foo := c.NamedStruct("foo", I32)
+	golden := []struct {
+		t, u Type
+	}{
+		{t: c.Int(17), u: c.Int(17)},
+		{t: c.Float(FloatKindDouble), u: Double},
+		{t: c.Pointer(NewPointer(NewInt(32))), u: c.Pointer(c.Pointer(I32))},
+		{t: c.PointerAddrSpace(I8, 1), u: c.Intern(&PointerType{ElemType: NewInt(8), AddrSpace: 1})},
+		{t: c.Vector(4, I32), u: c.Intern(NewVector(4, NewInt(32)))},
+		{t: c.Array(3, c.Struct(I8, I64Ptr)), u: c.Intern(NewArray(3, NewStruct(I8, NewPointer(I64))))},
+		{t: c.PackedStruct(foo), u: c.Intern(&StructType{Packed: true, Fields: []Type{foo}})},
+		{t: c.Func(Void, I8Ptr), u: c.Intern(NewFunc(&VoidType{}, NewPointer(I8)))},
+		// Type names of non-struct types are dropped.
+		{t: c.Int(32), u: c.Intern(&IntType{TypeName: "i", BitSize: 32})},
+	}
+	for _, g := range golden {
+		if g.t != g.u {
+			t.Errorf("interned type mismatch; expected %p (%v), got %p (%v)", g.t, g.t, g.u, g.u)
+		}
+	}
+	// Distinct literal types.
+	distinct := []struct {
+		t, u Type
+	}{
+		{t: c.Pointer(I8), u: c.PointerAddrSpace(I8, 1)},
+		{t: c.Vector(4, I32), u: c.ScalableVector(4, I32)},
+		{t: c.Struct(I32), u: c.PackedStruct(I32)},
+		{t: c.Func(Void, I8Ptr), u: c.VariadicFunc(Void, I8Ptr)},
+		{t: c.Struct(foo), u: c.Struct(c.NamedStruct("foo", I32))},
+	}
+	for _, g := range distinct {
+		if g.t == g.u {
+			t.Errorf("expected distinct interned types for %v and %v", g.t, g.u)
+		}
+	}
+}
+
+func TestContextNamedStruct(t *testing.T) {
+	c := NewContext()
+	// Recursive identified struct type.
+	list := c.NamedStruct("list")
+	list.Fields = []Type{I32, c.Pointer(list)}
+	if got, want := list.LLString(), "{ i32, %list* }"; got != want {
+		t.Errorf("struct type mismatch; expected %q, got %q", want, got)
+	}
+	// Type names are unique within the context.
+	list2 := c.OpaqueStruct("list")
+	if got, want := list2.Name(), "list.0"; got != want {
+		t.Errorf("type name mismatch; expected %q, got %q", want, got)
+	}
+	if got, want := c.Rename(list, "node"), "node"; got != want {
+		t.Errorf("type name mismatch; expected %q, got %q", want, got)
+	}
+	if got, want := c.Rename(list2, "node"), "node.0"; got != want {
+		t.Errorf("type name mismatch; expected %q, got %q", want, got)
+	}
+	if got := c.NamedType("node"); got != list {
+		t.Errorf("named type mismatch of %q; expected %p, got %p", "node", list, got)
+	}
+	if got := c.NamedType("list"); got != nil {
+		t.Errorf("named type mismatch of %q; expected nil, got %v", "list", got)
+	}
+	// Pointer types refer to the renamed struct type.
+	if got, want := c.Pointer(list).String(), "%node*"; got != want {
+		t.Errorf("pointer type mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestNamed(t *testing.T) {
+	c := NewContext()
+	// Shared types are copied.
+	shared := []Type{I64, I8Ptr, c.Struct(I32, I32), c.Func(Void)}
+	for _, typ := range shared {
+		want := typ.String()
+		named := Named("foo", typ)
+		if named == typ {
+			t.Errorf("expected copy of shared type %v", typ)
+		}
+		if got := typ.String(); got != want {
+			t.Errorf("shared type mismatch; expected %q, got %q", want, got)
+		}
+		if got := named.String(); got != "%foo" {
+			t.Errorf("named type mismatch; expected %q, got %q", "%foo", got)
+		}
+		if got := named.LLString(); got != want {
+			t.Errorf("underlying type mismatch; expected %q, got %q", want, got)
+		}
+	}
+	// Struct types which are not interned are named in place.
+	s := NewStruct(I32)
+	if named := Named("bar", s); named != s || s.Name() != "bar" {
+		t.Errorf("expected struct type named in place; got %v", named)
+	}
+}
@@ -0,0 +1,263 @@
+package types
+
+import "fmt"
+
+// --- [ Structural equality ] -------------------------------------------------
+
+// typePair is a pair of types assumed to be equal while comparing their
+// structure.
+type typePair struct {
+	t, u Type
+}
+
+// equal reports whether t and u are of equal type, based on structural
+// identity for literal types and on type names for identified struct types.
+//
+// Recursive types are handled by assuming that the pairs of composite types
+// currently being compared are equal; i.e. t and u are equal if no difference
+// is found when unfolding their structure.
+func equal(t, u Type, assumed map[typePair]bool) bool {
+	if t == u {
+		return true
+	}
+	switch t := t.(type) {
+	case *FuncType:
+		u, ok := u.(*FuncType)
+		if !ok || len(t.Params) != len(u.Params) || t.Variadic != u.Variadic {
+			return false
+		}
+		if !assume(&assumed, t, u) {
+			return true
+		}
+		if !equal(t.RetType, u.RetType, assumed) {
+			return false
+		}
+		for i := range t.Params {
+			if !equal(t.Params[i], u.Params[i], assumed) {
+				return false
+			}
+		}
+		return true
+	case *PointerType:
+		u, ok := u.(*PointerType)
+		if !ok || t.AddrSpace != u.AddrSpace {
+			return false
+		}
+		if !assume(&assumed, t, u) {
+			return true
+		}
+		return equal(t.ElemType, u.ElemType, assumed)
+	case *VectorType:
+		u, ok := u.(*VectorType)
+		if !ok || t.Scalable != u.Scalable || t.Len != u.Len {
+			return false
+		}
+		if !assume(&assumed, t, u) {
+			return true
+		}
+		return equal(t.ElemType, u.ElemType, assumed)
+	case *ArrayType:
+		u, ok := u.(*ArrayType)
+		if !ok || t.Len != u.Len {
+			return false
+		}
+		if !assume(&assumed, t, u) {
+			return true
+		}
+		return equal(t.ElemType, u.ElemType, assumed)
+	case *StructType:
+		u, ok := u.(*StructType)
+		if !ok {
+			return false
+		}
+		if len(t.TypeName) > 0 || len(u.TypeName) > 0 {
+			// Identified struct types are uniqued by type names, not by structural
+			// identity.
+			return t.TypeName == u.TypeName
+		}
+		if t.Packed != u.Packed || len(t.Fields) != len(u.Fields) {
+			return false
+		}
+		if !assume(&assumed, t, u) {
+			return true
+		}
+		for i := range t.Fields {
+			if !equal(t.Fields[i], u.Fields[i], assumed) {
+				return false
+			}
+		}
+		return true
+	default:
+		// Non-composite types.
+		return t.Equal(u)
+	}
+}
+
+// assume records the assumption that the composite types t and u are equal,
+// and reports whether the assumption is new; i.e. whether the structure of t
+// and u should be compared.
+func assume(assumed *map[typePair]bool, t, u Type) bool {
+	if *assumed == nil {
+		*assumed = make(map[typePair]bool)
+	}
+	p := typePair{t: t, u: u}
+	if (*assumed)[p] {
+		return false
+	}
+	(*assumed)[p] = true
+	return true
+}
+
+// --- [ Structural hashing ] --------------------------------------------------
+
+// maxHashDepth specifies the maximum depth of composite types visited when
+// hashing a type.
+//
+// Hashing the structure of a type down to a fixed depth ensures termination for
+// recursive types, and is consistent with structural equality since equal types
+// have equal structure at every depth.
+const maxHashDepth = 4
+
+// Hash returns the structural hash of the given type; such that equal types (as
+// reported by Equal) have equal hashes.
+//
+// Literal types are hashed by structure and identified struct types are hashed
+// by type name. Type names of other types are not part of the hash, as such
+// types are equal to their underlying type.
+func Hash(t Type) uint64 {
+	h := newHasher()
+	h.hashType(t, 0)
+	return uint64(h)
+}
+
+// Type kinds of the structural hash.
+const (
+	hashVoid uint64 = iota
+	hashFunc
+	hashInt
+	hashFloat
+	hashMMX
+	hashPointer
+	hashVector
+	hashLabel
+	hashToken
+	hashMetadata
+	hashArray
+	hashStruct
+	hashNamedStruct
+	// Composite type below maximum hash depth.
+	hashDeep
+)
+
+// hasher is a 64-bit FNV-1a hash of the structure of a type.
+type hasher uint64
+
+// newHasher returns a new hasher.
+func newHasher() hasher {
+	const offset64 = 14695981039346656037
+	return hasher(offset64)
+}
+
+// hashType adds the structure of t at the given depth to the hash.
+func (h *hasher) hashType(t Type, depth int) {
+	switch t := t.(type) {
+	case *VoidType:
+		h.add(hashVoid)
+	case *IntType:
+		h.add(hashInt)
+		h.add(t.BitSize)
+	case *FloatType:
+		h.add(hashFloat)
+		h.add(uint64(t.Kind))
+	case *MMXType:
+		h.add(hashMMX)
+	case *LabelType:
+		h.add(hashLabel)
+	case *TokenType:
+		h.add(hashToken)
+	case *MetadataType:
+		h.add(hashMetadata)
+	case *StructType:
+		if len(t.TypeName) > 0 {
+			h.add(hashNamedStruct)
+			h.addString(t.TypeName)
+			return
+		}
+		if h.deep(depth) {
+			return
+		}
+		h.add(hashStruct)
+		h.addBool(t.Packed)
+		h.add(uint64(len(t.Fields)))
+		for _, field := range t.Fields {
+			h.hashType(field, depth+1)
+		}
+	default:
+		// Composite literal types.
+		if h.deep(depth) {
+			return
+		}
+		switch t := t.(type) {
+		case *FuncType:
+			h.add(hashFunc)
+			h.addBool(t.Variadic)
+			h.add(uint64(len(t.Params)))
+			h.hashType(t.RetType, depth+1)
+			for _, param := range t.Params {
+				h.hashType(param, depth+1)
+			}
+		case *PointerType:
+			h.add(hashPointer)
+			h.add(uint64(t.AddrSpace))
+			h.hashType(t.ElemType, depth+1)
+		case *VectorType:
+			h.add(hashVector)
+			h.addBool(t.Scalable)
+			h.add(t.Len)
+			h.hashType(t.ElemType, depth+1)
+		case *ArrayType:
+			h.add(hashArray)
+			h.add(t.Len)
+			h.hashType(t.ElemType, depth+1)
+		default:
+			panic(fmt.Errorf("support for type %T not yet implemented", t))
+		}
+	}
+}
+
+// deep adds a marker to the hash and reports true if the given depth is at or
+// below the maximum hash depth.
+func (h *hasher) deep(depth int) bool {
+	if depth < maxHashDepth {
+		return false
+	}
+	h.add(hashDeep)
+	return true
+}
+
+// add adds the given integer to the hash.
+func (h *hasher) add(x uint64) {
+	const prime64 = 1099511628211
+	for i := 0; i < 8; i++ {
+		*h ^= hasher(x & 0xFF)
+		*h *= prime64
+		x >>= 8
+	}
+}
+
+// addBool adds the given boolean to the hash.
+func (h *hasher) addBool(x bool) {
+	if x {
+		h.add(1)
+	} else {
+		h.add(0)
+	}
+}
+
+// addString adds the given string to the hash.
+func (h *hasher) addString(s string) {
+	h.add(uint64(len(s)))
+	for i := 0; i < len(s); i++ {
+		h.add(uint64(s[i]))
+	}
+}
@@ -0,0 +1,51 @@
+package types
+
+import "testing"
+
+func TestEqualRecursive(t *testing.T) {
+	// Recursive literal types; only possible to create programmatically.
+	//
+	//    t = { i32, t* }
+	//    u = { i32, u* }
+	//    v = { i32, { i32, v* }* }
+	//    w = { i64, w* }
+	t1 := NewStruct(I32, nil)
+	t1.Fields[1] = NewPointer(t1)
+	u := NewStruct(I32, nil)
+	u.Fields[1] = NewPointer(u)
+	v := NewStruct(I32, nil)
+	v.Fields[1] = NewPointer(NewStruct(I32, NewPointer(v)))
+	w := NewStruct(I64, nil)
+	w.Fields[1] = NewPointer(w)
+	// Recursive identified struct types.
+	//
+	//    %list = type { i32, %list* }
+	list := &StructType{TypeName: "list"}
+	list.Fields = []Type{I32, NewPointer(list)}
+	golden := []struct {
+		t, u Type
+		want bool
+	}{
+		{t: t1, u: t1, want: true},
+		{t: t1, u: u, want: true},
+		{t: t1, u: v, want: true},
+		{t: NewPointer(t1), u: NewPointer(v), want: true},
+		{t: t1, u: w, want: false},
+		{t: NewPointer(list), u: NewPointer(list), want: true},
+		{t: NewPointer(list), u: NewPointer(&StructType{TypeName: "list"}), want: true},
+		{t: NewPointer(list), u: NewPointer(NewStruct(I32, NewPointer(list))), want: false},
+		// Pointer types are compared by structure, not by type names.
+		{t: &PointerType{TypeName: "p", ElemType: I8}, u: I8Ptr, want: true},
+		{t: NewPointer(I8), u: &PointerType{ElemType: I8, AddrSpace: 1}, want: false},
+	}
+	for i, g := range golden {
+		got := Equal(g.t, g.u)
+		if g.want != got {
+			// Note, recursive literal types cannot be printed.
+			t.Errorf("equality mismatch of types %d; expected %t, got %t", i, g.want, got)
+		}
+		if got && Hash(g.t) != Hash(g.u) {
+			t.Errorf("hash mismatch of equal types %d", i)
+		}
+	}
+}
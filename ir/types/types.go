@@ -224,21 +224,7 @@ func NewFunc(retType Type, params ...Type) *FuncType {
 
 // Equal reports whether t and u are of equal type.
 func (t *FuncType) Equal(u Type) bool {
-	if u, ok := u.(*FuncType); ok {
-		if !t.RetType.Equal(u.RetType) {
-			return false
-		}
-		if len(t.Params) != len(u.Params) {
-			return false
-		}
-		for i := range t.Params {
-			if !t.Params[i].Equal(u.Params[i]) {
-				return false
-			}
-		}
-		return t.Variadic == u.Variadic
-	}
-	return false
+	return equal(t, u, nil)
 }
 
 // String returns the string representation of the function type.
@@ -457,9 +443,7 @@ func NewPointer(elemType Type) *PointerType {
 
 // Equal reports whether t and u are of equal type.
 func (t *PointerType) Equal(u Type) bool {
-	// HACK: to prevent infinite loops (e.g. struct foo containing field of type
-	// pointer to foo).
-	return t.String() == u.String()
+	return equal(t, u, nil)
 }
 
 // String returns the string representation of the pointer type.
@@ -527,16 +511,7 @@ func NewVector(len uint64, elemType Type) *VectorType {
 
 // Equal reports whether t and u are of equal type.
 func (t *VectorType) Equal(u Type) bool {
-	if u, ok := u.(*VectorType); ok {
-		if t.Scalable != u.Scalable {
-			return false
-		}
-		if t.Len != u.Len {
-			return false
-		}
-		return t.ElemType.Equal(u.ElemType)
-	}
-	return false
+	return equal(t, u, nil)
 }
 
 // String returns the string representation of the vector type.
@@ -716,13 +691,7 @@ func NewArray(len uint64, elemType Type) *ArrayType {
 
 // Equal reports whether t and u are of equal type.
 func (t *ArrayType) Equal(u Type) bool {
-	if u, ok := u.(*ArrayType); ok {
-		if t.Len != u.Len {
-			return false
-		}
-		return t.ElemType.Equal(u.ElemType)
-	}
-	return false
+	return equal(t, u, nil)
 }
 
 // String returns the string representation of the array type.
@@ -763,6 +732,9 @@ type StructType struct {
 	Fields []Type
 	// Opaque struct type.
 	Opaque bool
+
+	// Literal struct type interned by a type context.
+	interned bool
 }
 
 // NewStruct returns a new struct type based on the given field types.
@@ -774,29 +746,7 @@ func NewStruct(fields ...Type) *StructType {
 
 // Equal reports whether t and u are of equal type.
 func (t *StructType) Equal(u Type) bool {
-	if u, ok := u.(*StructType); ok {
-		if len(t.TypeName) > 0 || len(u.TypeName) > 0 {
-			// Identified struct types are uniqued by type names, not by structural
-			// identity.
-			//
-			// t or u is an identified struct type.
-			return t.TypeName == u.TypeName
-		}
-		// Literal struct types are uniqued by structural identity.
-		if t.Packed != u.Packed {
-			return false
-		}
-		if len(t.Fields) != len(u.Fields) {
-			return false
-		}
-		for i := range t.Fields {
-			if !t.Fields[i].Equal(u.Fields[i]) {
-				return false
-			}
-		}
-		return true
-	}
-	return false
+	return equal(t, u, nil)
 }
 
 // String returns the string representation of the structure type.
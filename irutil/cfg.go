@@ -0,0 +1,297 @@
+package irutil
+
+import (
+	"fmt"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/value"
+)
+
+// --- [ Control flow graph ] --------------------------------------------------
+
+// ReplaceSucc replaces the successor basic block old with new in the given
+// terminator, and reports whether any successor was replaced.
+func ReplaceSucc(term ir.Terminator, old, new *ir.Block) bool {
+	replaced := false
+	for _, op := range term.Operands() {
+		if block, ok := (*op).(*ir.Block); ok && block == old {
+			*op = new
+			replaced = true
+		}
+	}
+	if replaced {
+		ResetSuccs(term)
+	}
+	return replaced
+}
+
+// ResetSuccs clears the cached successor basic blocks of the given terminator,
+// so that they are recomputed from the operands of the terminator. ResetSuccs
+// should be invoked after the target operands of a terminator have been
+// modified.
+func ResetSuccs(term ir.Terminator) {
+	switch term := term.(type) {
+	case *ir.TermBr:
+		term.Successors = nil
+	case *ir.TermCondBr:
+		term.Successors = nil
+	case *ir.TermSwitch:
+		term.Successors = nil
+	case *ir.TermIndirectBr:
+		term.Successors = nil
+	case *ir.TermInvoke:
+		term.Successors = nil
+	case *ir.TermCallBr:
+		term.Successors = nil
+	case *ir.TermCatchSwitch:
+		term.Successors = nil
+	case *ir.TermCatchRet:
+		term.Successors = nil
+	case *ir.TermCleanupRet:
+		term.Successors = nil
+	}
+}
+
+// Phis returns the phi instructions at the start of the given basic block.
+func Phis(block *ir.Block) []*ir.InstPhi {
+	var phis []*ir.InstPhi
+	for _, inst := range block.Insts {
+		phi, ok := inst.(*ir.InstPhi)
+		if !ok {
+			break
+		}
+		phis = append(phis, phi)
+	}
+	return phis
+}
+
+// FirstInsertionIndex returns the index of the first instruction of the given
+// basic block before which new non-phi instructions may be inserted; i.e. the
+// index after any leading phi and landing pad instructions.
+func FirstInsertionIndex(block *ir.Block) int {
+	for i, inst := range block.Insts {
+		switch inst.(type) {
+		case *ir.InstPhi, *ir.InstLandingPad, *ir.InstCatchPad, *ir.InstCleanupPad:
+			continue
+		}
+		return i
+	}
+	return len(block.Insts)
+}
+
+// ReplacePhiPred replaces the predecessor basic block old with new in the
+// incoming values of the phi instructions of the given basic block.
+func ReplacePhiPred(block *ir.Block, old, new *ir.Block) {
+	for _, phi := range Phis(block) {
+		for _, inc := range phi.Incs {
+			if inc.Pred == old {
+				inc.Pred = new
+			}
+		}
+	}
+}
+
+// RemovePhiPred removes the incoming values from the predecessor basic block
+// pred of the phi instructions of the given basic block.
+func RemovePhiPred(block *ir.Block, pred *ir.Block) {
+	for _, phi := range Phis(block) {
+		incs := phi.Incs[:0]
+		for _, inc := range phi.Incs {
+			if inc.Pred != pred {
+				incs = append(incs, inc)
+			}
+		}
+		phi.Incs = incs
+	}
+}
+
+// IncomingValue returns the incoming value from the predecessor basic block
+// pred of the given phi instruction, or nil if not present.
+func IncomingValue(phi *ir.InstPhi, pred *ir.Block) value.Value {
+	for _, inc := range phi.Incs {
+		if inc.Pred == pred {
+			return inc.X
+		}
+	}
+	return nil
+}
+
+// InsertInst inserts the given instruction at index i of the instructions of
+// the basic block.
+func InsertInst(block *ir.Block, i int, inst ir.Instruction) {
+	block.Insts = append(block.Insts, nil)
+	copy(block.Insts[i+1:], block.Insts[i:])
+	block.Insts[i] = inst
+}
+
+// NewBlockBefore inserts a new basic block before the basic block before in the
+// list of basic blocks of f. The name of the new basic block is made unique
+// within f; an empty name indicates an unnamed basic block.
+//
+// The Parent field of the new basic block is set to f.
+func NewBlockBefore(f *ir.Func, before *ir.Block, name string) *ir.Block {
+	block := ir.NewBlock(UniqueLocalName(f, name))
+	block.Parent = f
+	i := blockIndex(f, before)
+	f.Blocks = append(f.Blocks, nil)
+	copy(f.Blocks[i+1:], f.Blocks[i:])
+	f.Blocks[i] = block
+	return block
+}
+
+// NewBlockAfter inserts a new basic block after the basic block after in the
+// list of basic blocks of f. The name of the new basic block is made unique
+// within f; an empty name indicates an unnamed basic block.
+//
+// The Parent field of the new basic block is set to f.
+func NewBlockAfter(f *ir.Func, after *ir.Block, name string) *ir.Block {
+	block := ir.NewBlock(UniqueLocalName(f, name))
+	block.Parent = f
+	i := blockIndex(f, after) + 1
+	f.Blocks = append(f.Blocks, nil)
+	copy(f.Blocks[i+1:], f.Blocks[i:])
+	f.Blocks[i] = block
+	return block
+}
+
+// SplitPreds inserts a new basic block before the basic block block, through
+// which control flows from the given predecessor basic blocks to block. The
+// incoming values of the phi instructions of block from the given predecessors
+// are moved to phi instructions of the new basic block, unless they are all
+// identical. The name of the new basic block is made unique within f.
+//
+// The terminators of the predecessors must permit their targets to be replaced
+// (i.e. not indirectbr or callbr terminators), and block must not be an
+// exception handling pad.
+func SplitPreds(f *ir.Func, block *ir.Block, preds []*ir.Block, name string) *ir.Block {
+	split := NewBlockBefore(f, block, name)
+	isPred := make(map[*ir.Block]bool)
+	for _, pred := range preds {
+		isPred[pred] = true
+		ReplaceSucc(pred.Term, block, split)
+	}
+	for _, phi := range Phis(block) {
+		var incs, moved []*ir.Incoming
+		for _, inc := range phi.Incs {
+			if isPred[inc.Pred.(*ir.Block)] {
+				moved = append(moved, inc)
+			} else {
+				incs = append(incs, inc)
+			}
+		}
+		if len(moved) == 0 {
+			continue
+		}
+		x := moved[0].X
+		for _, inc := range moved[1:] {
+			if inc.X != x {
+				x = nil
+				break
+			}
+		}
+		if x == nil {
+			p := ir.NewPhi(moved...)
+			p.SetName(UniqueLocalName(f, LocalName(phi)))
+			split.Insts = append(split.Insts, p)
+			x = p
+		}
+		phi.Incs = append(incs, ir.NewIncoming(x, split))
+	}
+	split.Term = ir.NewBr(block)
+	return split
+}
+
+// RemoveBlocks removes the basic blocks of f which are present in the set dead.
+func RemoveBlocks(f *ir.Func, dead map[*ir.Block]bool) {
+	if len(dead) == 0 {
+		return
+	}
+	blocks := f.Blocks[:0]
+	for _, block := range f.Blocks {
+		if !dead[block] {
+			blocks = append(blocks, block)
+		}
+	}
+	for i := len(blocks); i < len(f.Blocks); i++ {
+		f.Blocks[i] = nil
+	}
+	f.Blocks = blocks
+}
+
+// UniqueLocalName returns a local name based on the given name which is not in
+// use by any parameter, basic block or instruction of f. An empty name is
+// returned unchanged, as it denotes an unnamed local variable.
+func UniqueLocalName(f *ir.Func, name string) string {
+	if len(name) == 0 {
+		return name
+	}
+	used := make(map[string]bool)
+	add := func(v interface{}) {
+		if name := LocalName(v); len(name) > 0 {
+			used[name] = true
+		}
+	}
+	for _, param := range f.Params {
+		add(param)
+	}
+	for _, block := range f.Blocks {
+		add(block)
+		for _, inst := range block.Insts {
+			add(inst)
+		}
+		add(block.Term)
+	}
+	if !used[name] {
+		return name
+	}
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s.%d", name, i)
+		if !used[candidate] {
+			return candidate
+		}
+	}
+}
+
+// LocalName returns the name of the given local variable, basic block or
+// parameter, or an empty string if unnamed.
+func LocalName(v interface{}) string {
+	if n, ok := v.(localNamer); ok && !n.IsUnnamed() {
+		return n.Name()
+	}
+	return ""
+}
+
+// localNamer is a local variable, basic block or parameter with an optional
+// name.
+type localNamer interface {
+	IsUnnamed() bool
+	Name() string
+}
+
+// DefBlocks returns a map from each instruction and value-producing terminator
+// of f to its parent basic block.
+func DefBlocks(f *ir.Func) map[value.Value]*ir.Block {
+	defs := make(map[value.Value]*ir.Block)
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			if v, ok := inst.(value.Value); ok {
+				defs[v] = block
+			}
+		}
+		if v, ok := block.Term.(value.Value); ok {
+			defs[v] = block
+		}
+	}
+	return defs
+}
+
+// blockIndex returns the index of the given basic block in the list of basic
+// blocks of f.
+func blockIndex(f *ir.Func, block *ir.Block) int {
+	for i, b := range f.Blocks {
+		if b == block {
+			return i
+		}
+	}
+	panic(fmt.Errorf("unable to locate basic block %q in function %q", block.Ident(), f.Ident()))
+}
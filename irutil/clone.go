@@ -0,0 +1,300 @@
+package irutil
+
+import (
+	"fmt"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/value"
+)
+
+// CloneInst returns a copy of the given instruction, with the same name,
+// operands, flags and metadata. The operand lists of the copy are not shared
+// with the original instruction, so that the operands of either instruction
+// may be replaced independently (e.g. using ReplaceOperands).
+func CloneInst(inst ir.Instruction) ir.Instruction {
+	switch inst := inst.(type) {
+	case *ir.InstFNeg:
+		c := *inst
+		c.FastMathFlags = inst.FastMathFlags[:len(inst.FastMathFlags):len(inst.FastMathFlags)]
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstAdd:
+		c := *inst
+		c.OverflowFlags = inst.OverflowFlags[:len(inst.OverflowFlags):len(inst.OverflowFlags)]
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstFAdd:
+		c := *inst
+		c.FastMathFlags = inst.FastMathFlags[:len(inst.FastMathFlags):len(inst.FastMathFlags)]
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstSub:
+		c := *inst
+		c.OverflowFlags = inst.OverflowFlags[:len(inst.OverflowFlags):len(inst.OverflowFlags)]
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstFSub:
+		c := *inst
+		c.FastMathFlags = inst.FastMathFlags[:len(inst.FastMathFlags):len(inst.FastMathFlags)]
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstMul:
+		c := *inst
+		c.OverflowFlags = inst.OverflowFlags[:len(inst.OverflowFlags):len(inst.OverflowFlags)]
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstFMul:
+		c := *inst
+		c.FastMathFlags = inst.FastMathFlags[:len(inst.FastMathFlags):len(inst.FastMathFlags)]
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstUDiv:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstSDiv:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstFDiv:
+		c := *inst
+		c.FastMathFlags = inst.FastMathFlags[:len(inst.FastMathFlags):len(inst.FastMathFlags)]
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstURem:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstSRem:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstFRem:
+		c := *inst
+		c.FastMathFlags = inst.FastMathFlags[:len(inst.FastMathFlags):len(inst.FastMathFlags)]
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstShl:
+		c := *inst
+		c.OverflowFlags = inst.OverflowFlags[:len(inst.OverflowFlags):len(inst.OverflowFlags)]
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstLShr:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstAShr:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstAnd:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstOr:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstXor:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstExtractElement:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstInsertElement:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstShuffleVector:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstExtractValue:
+		c := *inst
+		c.Indices = inst.Indices[:len(inst.Indices):len(inst.Indices)]
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstInsertValue:
+		c := *inst
+		c.Indices = inst.Indices[:len(inst.Indices):len(inst.Indices)]
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstAlloca:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstLoad:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstStore:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstFence:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstCmpXchg:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstAtomicRMW:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstGetElementPtr:
+		c := *inst
+		c.Indices = cloneValues(inst.Indices)
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstTrunc:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstZExt:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstSExt:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstFPTrunc:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstFPExt:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstFPToUI:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstFPToSI:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstUIToFP:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstSIToFP:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstPtrToInt:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstIntToPtr:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstBitCast:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstAddrSpaceCast:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstICmp:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstFCmp:
+		c := *inst
+		c.FastMathFlags = inst.FastMathFlags[:len(inst.FastMathFlags):len(inst.FastMathFlags)]
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstPhi:
+		c := *inst
+		c.Incs = cloneIncs(inst.Incs)
+		c.FastMathFlags = inst.FastMathFlags[:len(inst.FastMathFlags):len(inst.FastMathFlags)]
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstSelect:
+		c := *inst
+		c.FastMathFlags = inst.FastMathFlags[:len(inst.FastMathFlags):len(inst.FastMathFlags)]
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstFreeze:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstCall:
+		c := *inst
+		c.Args = cloneValues(inst.Args)
+		c.FastMathFlags = inst.FastMathFlags[:len(inst.FastMathFlags):len(inst.FastMathFlags)]
+		c.ReturnAttrs = inst.ReturnAttrs[:len(inst.ReturnAttrs):len(inst.ReturnAttrs)]
+		c.FuncAttrs = inst.FuncAttrs[:len(inst.FuncAttrs):len(inst.FuncAttrs)]
+		c.OperandBundles = inst.OperandBundles[:len(inst.OperandBundles):len(inst.OperandBundles)]
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstVAArg:
+		c := *inst
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstLandingPad:
+		c := *inst
+		c.Clauses = cloneClauses(inst.Clauses)
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstCatchPad:
+		c := *inst
+		c.Args = cloneValues(inst.Args)
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	case *ir.InstCleanupPad:
+		c := *inst
+		c.Args = cloneValues(inst.Args)
+		c.Metadata = inst.Metadata[:len(inst.Metadata):len(inst.Metadata)]
+		return &c
+	default:
+		panic(fmt.Errorf("support for instruction %T not yet implemented", inst))
+	}
+}
+
+// ### [ Helper functions ] ####################################################
+
+// cloneValues returns a copy of the given operand list. Function arguments with
+// parameter attributes (i.e. *ir.Arg) are copied, as their underlying values
+// are replaced in place.
+func cloneValues(vs []value.Value) []value.Value {
+	if vs == nil {
+		return nil
+	}
+	cs := make([]value.Value, len(vs))
+	for i, v := range vs {
+		if arg, ok := v.(*ir.Arg); ok {
+			c := *arg
+			v = &c
+		}
+		cs[i] = v
+	}
+	return cs
+}
+
+// cloneIncs returns a copy of the given incoming values of a phi instruction.
+func cloneIncs(incs []*ir.Incoming) []*ir.Incoming {
+	cs := make([]*ir.Incoming, len(incs))
+	for i, inc := range incs {
+		c := *inc
+		cs[i] = &c
+	}
+	return cs
+}
+
+// cloneClauses returns a copy of the given clauses of a landingpad instruction.
+func cloneClauses(clauses []*ir.Clause) []*ir.Clause {
+	cs := make([]*ir.Clause, len(clauses))
+	for i, clause := range clauses {
+		c := *clause
+		cs[i] = &c
+	}
+	return cs
+}
@@ -0,0 +1,117 @@
+package irutil
+
+import (
+	"github.com/llir/llvm/ir/constant"
+)
+
+// ConstOperands returns the constant operands of the given constant; i.e. the
+// elements of aggregate constants, the operands of constant expressions and the
+// functions referred to by blockaddress, dso_local_equivalent and no_cfi
+// constants.
+func ConstOperands(c constant.Constant) []constant.Constant {
+	switch c := c.(type) {
+	// Aggregate constants.
+	case *constant.Array:
+		return c.Elems
+	case *constant.Struct:
+		return c.Fields
+	case *constant.Vector:
+		return c.Elems
+	// Function references.
+	case *constant.BlockAddress:
+		return []constant.Constant{c.Func}
+	case *constant.DSOLocalEquivalent:
+		return []constant.Constant{c.Func}
+	case *constant.NoCFI:
+		return []constant.Constant{c.Func}
+	// Binary expressions.
+	case *constant.ExprAdd:
+		return []constant.Constant{c.X, c.Y}
+	case *constant.ExprSub:
+		return []constant.Constant{c.X, c.Y}
+	case *constant.ExprMul:
+		return []constant.Constant{c.X, c.Y}
+	// Bitwise expressions.
+	case *constant.ExprShl:
+		return []constant.Constant{c.X, c.Y}
+	case *constant.ExprLShr:
+		return []constant.Constant{c.X, c.Y}
+	case *constant.ExprAShr:
+		return []constant.Constant{c.X, c.Y}
+	case *constant.ExprAnd:
+		return []constant.Constant{c.X, c.Y}
+	case *constant.ExprOr:
+		return []constant.Constant{c.X, c.Y}
+	case *constant.ExprXor:
+		return []constant.Constant{c.X, c.Y}
+	// Unary expressions.
+	case *constant.ExprFNeg:
+		return []constant.Constant{c.X}
+	// Vector expressions.
+	case *constant.ExprExtractElement:
+		return []constant.Constant{c.X, c.Index}
+	case *constant.ExprInsertElement:
+		return []constant.Constant{c.X, c.Elem, c.Index}
+	case *constant.ExprShuffleVector:
+		return []constant.Constant{c.X, c.Y, c.Mask}
+	// Memory expressions.
+	case *constant.ExprGetElementPtr:
+		ops := []constant.Constant{c.Src}
+		for _, index := range c.Indices {
+			if i, ok := index.(*constant.Index); ok {
+				index = i.Constant
+			}
+			ops = append(ops, index)
+		}
+		return ops
+	// Conversion expressions.
+	case *constant.ExprTrunc:
+		return []constant.Constant{c.From}
+	case *constant.ExprZExt:
+		return []constant.Constant{c.From}
+	case *constant.ExprSExt:
+		return []constant.Constant{c.From}
+	case *constant.ExprFPTrunc:
+		return []constant.Constant{c.From}
+	case *constant.ExprFPExt:
+		return []constant.Constant{c.From}
+	case *constant.ExprFPToUI:
+		return []constant.Constant{c.From}
+	case *constant.ExprFPToSI:
+		return []constant.Constant{c.From}
+	case *constant.ExprUIToFP:
+		return []constant.Constant{c.From}
+	case *constant.ExprSIToFP:
+		return []constant.Constant{c.From}
+	case *constant.ExprPtrToInt:
+		return []constant.Constant{c.From}
+	case *constant.ExprIntToPtr:
+		return []constant.Constant{c.From}
+	case *constant.ExprBitCast:
+		return []constant.Constant{c.From}
+	case *constant.ExprAddrSpaceCast:
+		return []constant.Constant{c.From}
+	// Other expressions.
+	case *constant.ExprICmp:
+		return []constant.Constant{c.X, c.Y}
+	case *constant.ExprFCmp:
+		return []constant.Constant{c.X, c.Y}
+	case *constant.ExprSelect:
+		return []constant.Constant{c.Cond, c.X, c.Y}
+	case *constant.Index:
+		return []constant.Constant{c.Constant}
+	}
+	return nil
+}
+
+// WalkConst invokes visit on the given constant and, recursively, on its
+// constant operands, in pre-order. Operands are not visited if visit returns
+// false.
+func WalkConst(c constant.Constant, visit func(c constant.Constant) bool) {
+	if c == nil || !visit(c) {
+		return
+	}
+	for _, op := range ConstOperands(c) {
+		WalkConst(op, visit)
+	}
+}
@@ -0,0 +1,105 @@
+package irutil
+
+import (
+	"github.com/llir/llvm/ir"
+)
+
+// --- [ Critical edges ] ------------------------------------------------------
+
+// IsCriticalEdge reports whether the control flow edge from -> to is critical;
+// i.e. whether from has several successors and to has several predecessors.
+// The predecessors of each basic block are given by preds (see cfg.Preds).
+func IsCriticalEdge(from, to *ir.Block, preds map[*ir.Block][]*ir.Block) bool {
+	return len(uniqueSuccs(from)) > 1 && len(preds[to]) > 1
+}
+
+// CanSplitEdge reports whether the control flow edge from -> to may be split by
+// SplitEdge. Edges of indirectbr and callbr terminators and edges to exception
+// handling pads may not be split, as their targets may not be replaced.
+func CanSplitEdge(from, to *ir.Block) bool {
+	switch term := from.Term.(type) {
+	case *ir.TermBr, *ir.TermCondBr, *ir.TermSwitch:
+	case *ir.TermInvoke:
+		if term.ExceptionRetTarget == to {
+			return false
+		}
+	default:
+		return false
+	}
+	if _, ok := to.Term.(*ir.TermCatchSwitch); ok {
+		return false
+	}
+	i := len(Phis(to))
+	return i == len(to.Insts) || !isPad(to.Insts[i])
+}
+
+// SplitEdge inserts a new basic block on the control flow edge from -> to, and
+// returns the new basic block; or nil if the edge may not be split (see
+// CanSplitEdge). All edges from -> to (e.g. switch cases with the same target)
+// are redirected to the new basic block, and the incoming values of the phi
+// instructions of to are updated accordingly.
+func SplitEdge(f *ir.Func, from, to *ir.Block) *ir.Block {
+	if !CanSplitEdge(from, to) {
+		return nil
+	}
+	name := ""
+	if len(LocalName(from)) > 0 && len(LocalName(to)) > 0 {
+		name = LocalName(from) + "." + LocalName(to) + "_crit_edge"
+	}
+	split := NewBlockAfter(f, from, name)
+	split.Term = ir.NewBr(to)
+	ReplaceSucc(from.Term, to, split)
+	ReplacePhiPred(to, from, split)
+	return split
+}
+
+// SplitCriticalEdges splits the critical edges of f which may be split (see
+// CanSplitEdge), and returns the new basic blocks.
+func SplitCriticalEdges(f *ir.Func) []*ir.Block {
+	preds := make(map[*ir.Block][]*ir.Block)
+	for _, block := range f.Blocks {
+		for _, succ := range uniqueSuccs(block) {
+			preds[succ] = append(preds[succ], block)
+		}
+	}
+	var splits []*ir.Block
+	// Copy the basic blocks, as new basic blocks are inserted into f.
+	blocks := append([]*ir.Block(nil), f.Blocks...)
+	for _, from := range blocks {
+		for _, to := range uniqueSuccs(from) {
+			if !IsCriticalEdge(from, to, preds) {
+				continue
+			}
+			if split := SplitEdge(f, from, to); split != nil {
+				splits = append(splits, split)
+			}
+		}
+	}
+	return splits
+}
+
+// uniqueSuccs returns the successor basic blocks of the given basic block,
+// including duplicate successors only once.
+func uniqueSuccs(block *ir.Block) []*ir.Block {
+	if block.Term == nil {
+		return nil
+	}
+	var succs []*ir.Block
+	seen := make(map[*ir.Block]bool)
+	for _, succ := range block.Term.Succs() {
+		if !seen[succ] {
+			seen[succ] = true
+			succs = append(succs, succ)
+		}
+	}
+	return succs
+}
+
+// isPad reports whether the given instruction is an exception handling pad.
+func isPad(inst ir.Instruction) bool {
+	switch inst.(type) {
+	case *ir.InstLandingPad, *ir.InstCatchPad, *ir.InstCleanupPad:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package irutil
+
+import (
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/value"
+)
+
+// --- [ Memory effects ] ------------------------------------------------------
+
+// MayReadMemory reports whether the given instruction may read memory.
+func MayReadMemory(inst ir.Instruction) bool {
+	switch inst := inst.(type) {
+	case *ir.InstLoad, *ir.InstCmpXchg, *ir.InstAtomicRMW, *ir.InstVAArg, *ir.InstFence:
+		return true
+	case *ir.InstStore:
+		// Volatile and atomic stores are ordered with respect to other memory
+		// accesses.
+		return inst.Volatile || isStrongerThanMonotonic(inst.Ordering)
+	case *ir.InstCall:
+		return !HasFuncAttr(CallFuncAttrs(inst), enum.FuncAttrReadNone)
+	case *ir.InstLandingPad, *ir.InstCatchPad, *ir.InstCleanupPad:
+		return true
+	}
+	return false
+}
+
+// MayWriteMemory reports whether the given instruction may write memory.
+//
+// Volatile loads and atomic loads with an ordering stronger than monotonic are
+// considered to write memory, as they may not be reordered with respect to
+// other memory accesses.
+func MayWriteMemory(inst ir.Instruction) bool {
+	switch inst := inst.(type) {
+	case *ir.InstStore, *ir.InstCmpXchg, *ir.InstAtomicRMW, *ir.InstVAArg, *ir.InstFence:
+		return true
+	case *ir.InstLoad:
+		return inst.Volatile || isStrongerThanMonotonic(inst.Ordering)
+	case *ir.InstCall:
+		attrs := CallFuncAttrs(inst)
+		return !HasFuncAttr(attrs, enum.FuncAttrReadNone) && !HasFuncAttr(attrs, enum.FuncAttrReadOnly)
+	case *ir.InstLandingPad, *ir.InstCatchPad, *ir.InstCleanupPad:
+		return true
+	}
+	return false
+}
+
+// HasSideEffects reports whether the given instruction may have side effects
+// besides computing its result; i.e. whether it may write memory, unwind, not
+// return or otherwise not be removed even if its result is unused.
+func HasSideEffects(inst ir.Instruction) bool {
+	switch inst := inst.(type) {
+	case *ir.InstLoad:
+		return inst.Volatile || inst.Atomic
+	case *ir.InstCall:
+		if IsDebugIntrinsic(inst) {
+			return false
+		}
+		attrs := CallFuncAttrs(inst)
+		if !HasFuncAttr(attrs, enum.FuncAttrNoUnwind) || !HasFuncAttr(attrs, enum.FuncAttrWillReturn) {
+			return true
+		}
+		return MayWriteMemory(inst)
+	}
+	return MayWriteMemory(inst)
+}
+
+// IsTriviallyDead reports whether the given instruction has no side effects,
+// and may thus be removed if its result is unused.
+func IsTriviallyDead(inst ir.Instruction) bool {
+	switch inst.(type) {
+	case *ir.InstAlloca:
+		// Unused allocas may be removed.
+		return true
+	}
+	return !HasSideEffects(inst)
+}
+
+// IsDebugIntrinsic reports whether the given call instruction invokes a debug
+// info intrinsic (e.g. @llvm.dbg.value).
+func IsDebugIntrinsic(inst *ir.InstCall) bool {
+	if f := Callee(inst.Callee); f != nil {
+		return strings.HasPrefix(f.Name(), "llvm.dbg.")
+	}
+	return false
+}
+
+// isStrongerThanMonotonic reports whether the given atomic ordering is stronger
+// than monotonic.
+func isStrongerThanMonotonic(ordering enum.AtomicOrdering) bool {
+	switch ordering {
+	case enum.AtomicOrderingNone, enum.AtomicOrderingUnordered, enum.AtomicOrderingMonotonic:
+		return false
+	}
+	return true
+}
+
+// --- [ Function attributes ] -------------------------------------------------
+
+// Callee returns the function invoked by the given callee value, looking
+// through pointer casts and aliases. A nil function is returned if the callee
+// is not statically known (e.g. indirect calls and inline assembly).
+func Callee(callee value.Value) *ir.Func {
+	for i := 0; ; i++ {
+		switch c := callee.(type) {
+		case *ir.Func:
+			return c
+		case *ir.Alias:
+			callee = c.Aliasee
+		case *constant.ExprBitCast:
+			callee = c.From
+		case *constant.ExprAddrSpaceCast:
+			callee = c.From
+		default:
+			return nil
+		}
+		// Guard against (invalid) cyclic aliases.
+		if i > 64 {
+			return nil
+		}
+	}
+}
+
+// CallFuncAttrs returns the function attributes of the given call instruction,
+// including the function attributes of the callee (if statically known).
+func CallFuncAttrs(inst *ir.InstCall) []ir.FuncAttribute {
+	attrs := inst.FuncAttrs
+	if f := Callee(inst.Callee); f != nil && len(f.FuncAttrs) > 0 {
+		attrs = append(attrs[:len(attrs):len(attrs)], f.FuncAttrs...)
+	}
+	return attrs
+}
+
+// HasFuncAttr reports whether the given list of function attributes contains
+// attr, either directly or through an attribute group.
+func HasFuncAttr(attrs []ir.FuncAttribute, attr enum.FuncAttr) bool {
+	for _, a := range attrs {
+		switch a := a.(type) {
+		case enum.FuncAttr:
+			if a == attr {
+				return true
+			}
+		case *ir.AttrGroupDef:
+			if HasFuncAttr(a.FuncAttrs, attr) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasParamAttr reports whether the given list of parameter attributes contains
+// attr.
+func HasParamAttr(attrs []ir.ParamAttribute, attr enum.ParamAttr) bool {
+	for _, a := range attrs {
+		if a == attr {
+			return true
+		}
+	}
+	return false
+}
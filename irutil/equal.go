@@ -0,0 +1,286 @@
+package irutil
+
+import (
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/value"
+)
+
+// --- [ Structural equality ] -------------------------------------------------
+
+// Equal reports whether the instructions x and y are structurally equal; i.e.
+// whether they have the same opcode, result type, flags and operands. Operands
+// are compared by identity, except for constants which are compared
+// structurally.
+//
+// Equal is commutativity aware; e.g. `add i32 %a, %b` is equal to
+// `add i32 %b, %a`, and `icmp sgt i32 %a, %b` is equal to `icmp slt i32 %b, %a`.
+//
+// Note, structural equality does not imply that the instructions compute the
+// same value, as they may read memory or have side effects.
+func Equal(x, y ir.Instruction) bool {
+	if x == y {
+		return true
+	}
+	kx, ky := keyOf(x), keyOf(y)
+	if kx.op != ky.op || len(kx.operands) != len(ky.operands) {
+		return false
+	}
+	if operandsEqual(kx.operands, ky.operands) {
+		return true
+	}
+	if kx.commutative {
+		a, b := kx.operands, ky.operands
+		return valueEqual(a[0], b[1]) && valueEqual(a[1], b[0])
+	}
+	return false
+}
+
+// Hash returns a structural hash of the given instruction. Structurally equal
+// instructions (as reported by Equal) have the same hash.
+func Hash(inst ir.Instruction) uint64 {
+	k := keyOf(inst)
+	h := fnv.New64a()
+	h.Write([]byte(k.op))
+	sum := h.Sum64()
+	if k.commutative {
+		// Combine operand hashes in an order-independent manner.
+		a, b := hashValue(k.operands[0]), hashValue(k.operands[1])
+		return mix(mix(sum, a+b), a^b)
+	}
+	for _, op := range k.operands {
+		sum = mix(sum, hashValue(op))
+	}
+	return sum
+}
+
+// ValueEqual reports whether the values x and y are equal. Constants are
+// compared structurally, and other values by identity.
+func ValueEqual(x, y value.Value) bool {
+	return valueEqual(Unwrap(x), Unwrap(y))
+}
+
+// ### [ Helper functions ] ####################################################
+
+// instKey is the canonical structural representation of an instruction.
+type instKey struct {
+	// Opcode and non-operand properties of the instruction (e.g. flags,
+	// predicates, types and indices).
+	op string
+	// Operands of the instruction.
+	operands []value.Value
+	// Commutative binary operation.
+	commutative bool
+}
+
+// keyOf returns the canonical structural representation of the given
+// instruction.
+func keyOf(inst ir.Instruction) instKey {
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "%T", inst)
+	if v, ok := inst.(value.Value); ok {
+		fmt.Fprintf(buf, " %s", v.Type())
+	}
+	var operands []value.Value
+	for _, op := range inst.Operands() {
+		if arg, ok := (*op).(*ir.Arg); ok {
+			// Include parameter attributes of function arguments.
+			fmt.Fprintf(buf, " %v", arg.Attrs)
+		}
+		operands = append(operands, Unwrap(*op))
+	}
+	commutative := false
+	switch inst := inst.(type) {
+	// Unary instructions.
+	case *ir.InstFNeg:
+		fmt.Fprintf(buf, " %v", inst.FastMathFlags)
+	// Binary instructions.
+	case *ir.InstAdd:
+		fmt.Fprintf(buf, " %v", sortedOverflowFlags(inst.OverflowFlags))
+		commutative = true
+	case *ir.InstFAdd:
+		fmt.Fprintf(buf, " %v", inst.FastMathFlags)
+		commutative = true
+	case *ir.InstSub:
+		fmt.Fprintf(buf, " %v", sortedOverflowFlags(inst.OverflowFlags))
+	case *ir.InstFSub:
+		fmt.Fprintf(buf, " %v", inst.FastMathFlags)
+	case *ir.InstMul:
+		fmt.Fprintf(buf, " %v", sortedOverflowFlags(inst.OverflowFlags))
+		commutative = true
+	case *ir.InstFMul:
+		fmt.Fprintf(buf, " %v", inst.FastMathFlags)
+		commutative = true
+	case *ir.InstUDiv:
+		fmt.Fprintf(buf, " %t", inst.Exact)
+	case *ir.InstSDiv:
+		fmt.Fprintf(buf, " %t", inst.Exact)
+	case *ir.InstFDiv:
+		fmt.Fprintf(buf, " %v", inst.FastMathFlags)
+	case *ir.InstFRem:
+		fmt.Fprintf(buf, " %v", inst.FastMathFlags)
+	// Bitwise instructions.
+	case *ir.InstShl:
+		fmt.Fprintf(buf, " %v", sortedOverflowFlags(inst.OverflowFlags))
+	case *ir.InstLShr:
+		fmt.Fprintf(buf, " %t", inst.Exact)
+	case *ir.InstAShr:
+		fmt.Fprintf(buf, " %t", inst.Exact)
+	case *ir.InstAnd, *ir.InstOr, *ir.InstXor:
+		commutative = true
+	// Aggregate instructions.
+	case *ir.InstExtractValue:
+		fmt.Fprintf(buf, " %v", inst.Indices)
+	case *ir.InstInsertValue:
+		fmt.Fprintf(buf, " %v", inst.Indices)
+	// Memory instructions.
+	case *ir.InstAlloca:
+		fmt.Fprintf(buf, " %s %t %t %d", inst.ElemType, inst.InAlloca, inst.SwiftError, inst.Align)
+	case *ir.InstLoad:
+		fmt.Fprintf(buf, " %t %t %q %v %d", inst.Atomic, inst.Volatile, inst.SyncScope, inst.Ordering, inst.Align)
+	case *ir.InstStore:
+		fmt.Fprintf(buf, " %t %t %q %v %d", inst.Atomic, inst.Volatile, inst.SyncScope, inst.Ordering, inst.Align)
+	case *ir.InstFence:
+		fmt.Fprintf(buf, " %q %v", inst.SyncScope, inst.Ordering)
+	case *ir.InstCmpXchg:
+		fmt.Fprintf(buf, " %t %t %q %v %v", inst.Weak, inst.Volatile, inst.SyncScope, inst.SuccessOrdering, inst.FailureOrdering)
+	case *ir.InstAtomicRMW:
+		fmt.Fprintf(buf, " %v %t %q %v", inst.Op, inst.Volatile, inst.SyncScope, inst.Ordering)
+	case *ir.InstGetElementPtr:
+		fmt.Fprintf(buf, " %s %t", inst.ElemType, inst.InBounds)
+	// Conversion instructions are fully described by their result type and
+	// operand.
+	// Other instructions.
+	case *ir.InstICmp:
+		pred := inst.Pred
+		if swapped, ok := swapIPredToCanonical(pred); ok {
+			// Canonicalize `icmp sgt x, y` to `icmp slt y, x`.
+			pred = swapped
+			operands[0], operands[1] = operands[1], operands[0]
+		}
+		fmt.Fprintf(buf, " %v", pred)
+		commutative = pred == enum.IPredEQ || pred == enum.IPredNE
+	case *ir.InstFCmp:
+		fmt.Fprintf(buf, " %v %v", inst.Pred, inst.FastMathFlags)
+		switch inst.Pred {
+		case enum.FPredFalse, enum.FPredTrue, enum.FPredOEQ, enum.FPredONE, enum.FPredUEQ, enum.FPredUNE, enum.FPredORD, enum.FPredUNO:
+			commutative = true
+		}
+	case *ir.InstPhi:
+		fmt.Fprintf(buf, " %v", inst.FastMathFlags)
+	case *ir.InstSelect:
+		fmt.Fprintf(buf, " %v", inst.FastMathFlags)
+	case *ir.InstCall:
+		fmt.Fprintf(buf, " %v %v %v %v %v %v", inst.Tail, inst.FastMathFlags, inst.CallingConv, inst.ReturnAttrs, inst.FuncAttrs, inst.OperandBundles)
+	case *ir.InstVAArg:
+		fmt.Fprintf(buf, " %s", inst.ArgType)
+	case *ir.InstLandingPad:
+		fmt.Fprintf(buf, " %t", inst.Cleanup)
+		for _, clause := range inst.Clauses {
+			fmt.Fprintf(buf, " %v", clause.Type)
+		}
+	}
+	return instKey{op: buf.String(), operands: operands, commutative: commutative && len(operands) == 2}
+}
+
+// swapIPredToCanonical returns the swapped predicate of pred if pred is a
+// "greater than" predicate, so that comparisons may be canonicalized to use
+// "less than" predicates.
+func swapIPredToCanonical(pred enum.IPred) (enum.IPred, bool) {
+	switch pred {
+	case enum.IPredSGT:
+		return enum.IPredSLT, true
+	case enum.IPredSGE:
+		return enum.IPredSLE, true
+	case enum.IPredUGT:
+		return enum.IPredULT, true
+	case enum.IPredUGE:
+		return enum.IPredULE, true
+	}
+	return pred, false
+}
+
+// sortedOverflowFlags returns the given overflow flags in canonical order.
+func sortedOverflowFlags(flags []enum.OverflowFlag) []enum.OverflowFlag {
+	var nsw, nuw bool
+	for _, flag := range flags {
+		switch flag {
+		case enum.OverflowFlagNSW:
+			nsw = true
+		case enum.OverflowFlagNUW:
+			nuw = true
+		}
+	}
+	var sorted []enum.OverflowFlag
+	if nsw {
+		sorted = append(sorted, enum.OverflowFlagNSW)
+	}
+	if nuw {
+		sorted = append(sorted, enum.OverflowFlagNUW)
+	}
+	return sorted
+}
+
+// operandsEqual reports whether the operand lists xs and ys are pairwise equal.
+func operandsEqual(xs, ys []value.Value) bool {
+	for i := range xs {
+		if !valueEqual(xs[i], ys[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// valueEqual reports whether the (unwrapped) values x and y are equal.
+func valueEqual(x, y value.Value) bool {
+	if x == y {
+		return true
+	}
+	if !isStructural(x) || !isStructural(y) {
+		return false
+	}
+	return x.String() == y.String()
+}
+
+// hashValue returns the hash of the given (unwrapped) value, consistent with
+// valueEqual.
+func hashValue(v value.Value) uint64 {
+	if isStructural(v) {
+		h := fnv.New64a()
+		h.Write([]byte(v.String()))
+		return h.Sum64()
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr {
+		return uint64(rv.Pointer())
+	}
+	// Fall back to hashing the identifier of non-pointer values.
+	h := fnv.New64a()
+	h.Write([]byte(v.Ident()))
+	return h.Sum64()
+}
+
+// isStructural reports whether the given value is compared structurally (i.e.
+// constants other than global values).
+func isStructural(v value.Value) bool {
+	switch v.(type) {
+	case *ir.Global, *ir.Func, *ir.Alias, *ir.IFunc:
+		return false
+	case constant.Constant:
+		return true
+	}
+	return false
+}
+
+// mix combines the hash h with the value x.
+func mix(h, x uint64) uint64 {
+	const prime = 1099511628211
+	h ^= x
+	h *= prime
+	return h ^ (h >> 29)
+}
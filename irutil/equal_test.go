@@ -0,0 +1,58 @@
+package irutil
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestEqual(t *testing.T) {
+	a := ir.NewParam("a", types.I32)
+	b := ir.NewParam("b", types.I32)
+	p := ir.NewParam("p", types.NewPointer(types.NewArray(4, types.I32)))
+	one := constant.NewInt(types.I32, 1)
+	nswAdd := ir.NewAdd(a, b)
+	nswAdd.OverflowFlags = []enum.OverflowFlag{enum.OverflowFlagNSW}
+	golden := []struct {
+		x, y ir.Instruction
+		want bool
+	}{
+		// Commutative operations.
+		{x: ir.NewAdd(a, b), y: ir.NewAdd(b, a), want: true},
+		{x: ir.NewMul(a, one), y: ir.NewMul(constant.NewInt(types.I32, 1), a), want: true},
+		{x: ir.NewSub(a, b), y: ir.NewSub(b, a), want: false},
+		// Flags.
+		{x: ir.NewAdd(a, b), y: nswAdd, want: false},
+		// Swapped comparison predicates.
+		{x: ir.NewICmp(enum.IPredSGT, a, b), y: ir.NewICmp(enum.IPredSLT, b, a), want: true},
+		{x: ir.NewICmp(enum.IPredSGT, a, b), y: ir.NewICmp(enum.IPredSLT, a, b), want: false},
+		{x: ir.NewICmp(enum.IPredEQ, a, b), y: ir.NewICmp(enum.IPredEQ, b, a), want: true},
+		// Result types.
+		{x: ir.NewZExt(a, types.I64), y: ir.NewSExt(a, types.I64), want: false},
+		{x: ir.NewZExt(a, types.I64), y: ir.NewZExt(a, types.I64), want: true},
+		// Address computations.
+		{
+			x:    ir.NewGetElementPtr(p.Typ.(*types.PointerType).ElemType, p, constant.NewInt(types.I64, 0), one),
+			y:    ir.NewGetElementPtr(p.Typ.(*types.PointerType).ElemType, p, constant.NewInt(types.I64, 0), one),
+			want: true,
+		},
+		{
+			x:    ir.NewGetElementPtr(p.Typ.(*types.PointerType).ElemType, p, constant.NewInt(types.I64, 0), one),
+			y:    ir.NewGetElementPtr(p.Typ.(*types.PointerType).ElemType, p, constant.NewInt(types.I64, 0), a),
+			want: false,
+		},
+	}
+	for i, g := range golden {
+		got := Equal(g.x, g.y)
+		if got != g.want {
+			t.Errorf("%d: equality mismatch of %q and %q; expected %v, got %v", i, g.x.LLString(), g.y.LLString(), g.want, got)
+			continue
+		}
+		if g.want && Hash(g.x) != Hash(g.y) {
+			t.Errorf("%d: hash mismatch of equal instructions %q and %q", i, g.x.LLString(), g.y.LLString())
+		}
+	}
+}
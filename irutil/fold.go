@@ -0,0 +1,486 @@
+package irutil
+
+import (
+	"math"
+	"math/big"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// --- [ Constant folding ] ----------------------------------------------------
+
+// FoldInst returns the constant computed by the given instruction if its
+// operands are constants, or nil if the instruction cannot be folded.
+func FoldInst(inst ir.Instruction) constant.Constant {
+	return FoldInstWith(inst, nil)
+}
+
+// FoldInstWith returns the constant computed by the given instruction, or nil
+// if the instruction cannot be folded. The constant value of each operand is
+// given by lookup, which returns nil for non-constant operands; a nil lookup
+// function treats constant operands as their own value.
+//
+// Instructions whose result would be poison or undefined behaviour (e.g.
+// division by zero, or signed overflow of an add nsw instruction) are not
+// folded.
+func FoldInstWith(inst ir.Instruction, lookup func(v value.Value) constant.Constant) constant.Constant {
+	c := func(v value.Value) constant.Constant {
+		if lookup != nil {
+			return lookup(v)
+		}
+		if c, ok := v.(constant.Constant); ok {
+			return c
+		}
+		return nil
+	}
+	switch inst := inst.(type) {
+	// Binary instructions.
+	case *ir.InstAdd:
+		return foldIntBinary(opAdd, c(inst.X), c(inst.Y), overflowFlags(inst.OverflowFlags), false)
+	case *ir.InstSub:
+		return foldIntBinary(opSub, c(inst.X), c(inst.Y), overflowFlags(inst.OverflowFlags), false)
+	case *ir.InstMul:
+		return foldIntBinary(opMul, c(inst.X), c(inst.Y), overflowFlags(inst.OverflowFlags), false)
+	case *ir.InstUDiv:
+		return foldIntBinary(opUDiv, c(inst.X), c(inst.Y), 0, inst.Exact)
+	case *ir.InstSDiv:
+		return foldIntBinary(opSDiv, c(inst.X), c(inst.Y), 0, inst.Exact)
+	case *ir.InstURem:
+		return foldIntBinary(opURem, c(inst.X), c(inst.Y), 0, false)
+	case *ir.InstSRem:
+		return foldIntBinary(opSRem, c(inst.X), c(inst.Y), 0, false)
+	case *ir.InstFAdd:
+		return foldFloatBinary(opFAdd, c(inst.X), c(inst.Y))
+	case *ir.InstFSub:
+		return foldFloatBinary(opFSub, c(inst.X), c(inst.Y))
+	case *ir.InstFMul:
+		return foldFloatBinary(opFMul, c(inst.X), c(inst.Y))
+	case *ir.InstFDiv:
+		return foldFloatBinary(opFDiv, c(inst.X), c(inst.Y))
+	case *ir.InstFNeg:
+		if x, ok := c(inst.X).(*constant.Float); ok && isFoldableFloat(x) {
+			return newFloat(x.Typ, -floatValue(x))
+		}
+	// Bitwise instructions.
+	case *ir.InstShl:
+		return foldIntBinary(opShl, c(inst.X), c(inst.Y), overflowFlags(inst.OverflowFlags), false)
+	case *ir.InstLShr:
+		return foldIntBinary(opLShr, c(inst.X), c(inst.Y), 0, inst.Exact)
+	case *ir.InstAShr:
+		return foldIntBinary(opAShr, c(inst.X), c(inst.Y), 0, inst.Exact)
+	case *ir.InstAnd:
+		return foldIntBinary(opAnd, c(inst.X), c(inst.Y), 0, false)
+	case *ir.InstOr:
+		return foldIntBinary(opOr, c(inst.X), c(inst.Y), 0, false)
+	case *ir.InstXor:
+		return foldIntBinary(opXor, c(inst.X), c(inst.Y), 0, false)
+	// Conversion instructions.
+	case *ir.InstTrunc:
+		if x, ok := c(inst.From).(*constant.Int); ok {
+			if to, ok := inst.To.(*types.IntType); ok {
+				return NewIntValue(to, Unsigned(x))
+			}
+		}
+	case *ir.InstZExt:
+		if x, ok := c(inst.From).(*constant.Int); ok {
+			if to, ok := inst.To.(*types.IntType); ok {
+				return NewIntValue(to, Unsigned(x))
+			}
+		}
+	case *ir.InstSExt:
+		if x, ok := c(inst.From).(*constant.Int); ok {
+			if to, ok := inst.To.(*types.IntType); ok {
+				return NewIntValue(to, Signed(x))
+			}
+		}
+	// Other instructions.
+	case *ir.InstICmp:
+		return FoldICmp(inst.Pred, c(inst.X), c(inst.Y))
+	case *ir.InstFCmp:
+		return FoldFCmp(inst.Pred, c(inst.X), c(inst.Y))
+	case *ir.InstSelect:
+		cond, ok := c(inst.Cond).(*constant.Int)
+		if !ok {
+			return nil
+		}
+		if cond.X.Sign() != 0 {
+			return c(inst.ValueTrue)
+		}
+		return c(inst.ValueFalse)
+	case *ir.InstPhi:
+		// Phi instructions with identical constant incoming values.
+		var x constant.Constant
+		for _, inc := range inst.Incs {
+			y := c(inc.X)
+			if y == nil || (x != nil && !ConstEqual(x, y)) {
+				return nil
+			}
+			x = y
+		}
+		return x
+	}
+	return nil
+}
+
+// Integer binary operations.
+const (
+	opAdd = iota
+	opSub
+	opMul
+	opUDiv
+	opSDiv
+	opURem
+	opSRem
+	opShl
+	opLShr
+	opAShr
+	opAnd
+	opOr
+	opXor
+)
+
+// Integer overflow flags, as a bit set.
+const (
+	flagNUW = 1 << iota
+	flagNSW
+)
+
+// overflowFlags returns the bit set of the given overflow flags.
+func overflowFlags(flags []enum.OverflowFlag) int {
+	bits := 0
+	for _, flag := range flags {
+		switch flag {
+		case enum.OverflowFlagNUW:
+			bits |= flagNUW
+		case enum.OverflowFlagNSW:
+			bits |= flagNSW
+		}
+	}
+	return bits
+}
+
+// foldIntBinary returns the result of the given integer binary operation (one
+// of the op* constants) on the constants x and y, or nil if either operand is
+// not an integer constant or the result is poison. The overflow flags of the
+// operation are given by the bit set flags, and exact specifies whether the
+// operation is exact.
+func foldIntBinary(op int, x, y constant.Constant, flags int, exact bool) constant.Constant {
+	cx, ok := x.(*constant.Int)
+	if !ok {
+		return nil
+	}
+	cy, ok := y.(*constant.Int)
+	if !ok {
+		return nil
+	}
+	n := cx.Typ.BitSize
+	ux, uy := Unsigned(cx), Unsigned(cy)
+	sx, sy := Signed(cx), Signed(cy)
+	r := new(big.Int)
+	switch op {
+	case opAdd:
+		r.Add(ux, uy)
+		if flags&flagNUW != 0 && !fitsUnsigned(r, n) || flags&flagNSW != 0 && !fitsSigned(new(big.Int).Add(sx, sy), n) {
+			return nil
+		}
+	case opSub:
+		r.Sub(ux, uy)
+		if flags&flagNUW != 0 && r.Sign() < 0 || flags&flagNSW != 0 && !fitsSigned(new(big.Int).Sub(sx, sy), n) {
+			return nil
+		}
+	case opMul:
+		r.Mul(ux, uy)
+		if flags&flagNUW != 0 && !fitsUnsigned(r, n) || flags&flagNSW != 0 && !fitsSigned(new(big.Int).Mul(sx, sy), n) {
+			return nil
+		}
+	case opUDiv, opURem:
+		if uy.Sign() == 0 {
+			return nil
+		}
+		q, m := new(big.Int).QuoRem(ux, uy, new(big.Int))
+		if exact && m.Sign() != 0 {
+			return nil
+		}
+		r = q
+		if op == opURem {
+			r = m
+		}
+	case opSDiv, opSRem:
+		if sy.Sign() == 0 || !fitsSigned(new(big.Int).Neg(sx), n) && sy.Cmp(big.NewInt(-1)) == 0 {
+			// Division by zero, or overflow of minimum signed integer divided
+			// by -1.
+			return nil
+		}
+		q, m := new(big.Int).QuoRem(sx, sy, new(big.Int))
+		if exact && m.Sign() != 0 {
+			return nil
+		}
+		r = q
+		if op == opSRem {
+			r = m
+		}
+	case opShl, opLShr, opAShr:
+		if uy.Cmp(new(big.Int).SetUint64(n)) >= 0 {
+			return nil
+		}
+		k := uint(uy.Uint64())
+		switch op {
+		case opShl:
+			r.Lsh(ux, k)
+			res := NewIntValue(cx.Typ, r)
+			if flags&flagNUW != 0 && !fitsUnsigned(r, n) {
+				return nil
+			}
+			if flags&flagNSW != 0 && new(big.Int).Rsh(Signed(res), k).Cmp(sx) != 0 {
+				return nil
+			}
+			return res
+		case opLShr:
+			r.Rsh(ux, k)
+		case opAShr:
+			r.Rsh(sx, k)
+		}
+		// Exact shifts are poison if any non-zero bits are shifted out.
+		if exact && ux.TrailingZeroBits() < k && ux.Sign() != 0 {
+			return nil
+		}
+	case opAnd:
+		r.And(ux, uy)
+	case opOr:
+		r.Or(ux, uy)
+	case opXor:
+		r.Xor(ux, uy)
+	default:
+		return nil
+	}
+	return NewIntValue(cx.Typ, r)
+}
+
+// FoldICmp returns the result of the integer comparison of the constants x and
+// y with the given predicate, or nil if the comparison cannot be folded.
+func FoldICmp(pred enum.IPred, x, y constant.Constant) constant.Constant {
+	if x == nil || y == nil {
+		return nil
+	}
+	if _, ok := x.(*constant.Null); ok {
+		if _, ok := y.(*constant.Null); ok {
+			switch pred {
+			case enum.IPredEQ, enum.IPredUGE, enum.IPredULE, enum.IPredSGE, enum.IPredSLE:
+				return constant.True
+			default:
+				return constant.False
+			}
+		}
+	}
+	cx, ok := x.(*constant.Int)
+	if !ok {
+		return nil
+	}
+	cy, ok := y.(*constant.Int)
+	if !ok {
+		return nil
+	}
+	ucmp := Unsigned(cx).Cmp(Unsigned(cy))
+	scmp := Signed(cx).Cmp(Signed(cy))
+	var r bool
+	switch pred {
+	case enum.IPredEQ:
+		r = ucmp == 0
+	case enum.IPredNE:
+		r = ucmp != 0
+	case enum.IPredUGT:
+		r = ucmp > 0
+	case enum.IPredUGE:
+		r = ucmp >= 0
+	case enum.IPredULT:
+		r = ucmp < 0
+	case enum.IPredULE:
+		r = ucmp <= 0
+	case enum.IPredSGT:
+		r = scmp > 0
+	case enum.IPredSGE:
+		r = scmp >= 0
+	case enum.IPredSLT:
+		r = scmp < 0
+	case enum.IPredSLE:
+		r = scmp <= 0
+	default:
+		return nil
+	}
+	return constant.NewBool(r)
+}
+
+// FoldFCmp returns the result of the floating-point comparison of the constants
+// x and y with the given predicate, or nil if the comparison cannot be folded.
+func FoldFCmp(pred enum.FPred, x, y constant.Constant) constant.Constant {
+	switch pred {
+	case enum.FPredFalse:
+		return constant.False
+	case enum.FPredTrue:
+		return constant.True
+	}
+	cx, ok := x.(*constant.Float)
+	if !ok {
+		return nil
+	}
+	cy, ok := y.(*constant.Float)
+	if !ok {
+		return nil
+	}
+	if cx.NaN || cy.NaN {
+		// Unordered.
+		switch pred {
+		case enum.FPredUEQ, enum.FPredUGE, enum.FPredUGT, enum.FPredULE, enum.FPredULT, enum.FPredUNE, enum.FPredUNO:
+			return constant.True
+		}
+		return constant.False
+	}
+	cmp := cx.X.Cmp(cy.X)
+	var r bool
+	switch pred {
+	case enum.FPredOEQ, enum.FPredUEQ:
+		r = cmp == 0
+	case enum.FPredONE, enum.FPredUNE:
+		r = cmp != 0
+	case enum.FPredOGT, enum.FPredUGT:
+		r = cmp > 0
+	case enum.FPredOGE, enum.FPredUGE:
+		r = cmp >= 0
+	case enum.FPredOLT, enum.FPredULT:
+		r = cmp < 0
+	case enum.FPredOLE, enum.FPredULE:
+		r = cmp <= 0
+	case enum.FPredORD:
+		r = true
+	case enum.FPredUNO:
+		r = false
+	default:
+		return nil
+	}
+	return constant.NewBool(r)
+}
+
+// Floating-point binary operations.
+const (
+	opFAdd = iota
+	opFSub
+	opFMul
+	opFDiv
+)
+
+// foldFloatBinary returns the result of the given floating-point binary
+// operation on the constants x and y, or nil if the operation cannot be folded.
+// Only single and double precision operands which are not NaN are folded.
+func foldFloatBinary(op int, x, y constant.Constant) constant.Constant {
+	cx, ok := x.(*constant.Float)
+	if !ok || !isFoldableFloat(cx) {
+		return nil
+	}
+	cy, ok := y.(*constant.Float)
+	if !ok || !isFoldableFloat(cy) {
+		return nil
+	}
+	a, b := floatValue(cx), floatValue(cy)
+	var r float64
+	switch op {
+	case opFAdd:
+		r = a + b
+	case opFSub:
+		r = a - b
+	case opFMul:
+		r = a * b
+	case opFDiv:
+		r = a / b
+	}
+	if math.IsNaN(r) || math.IsInf(r, 0) {
+		return nil
+	}
+	return newFloat(cx.Typ, r)
+}
+
+// isFoldableFloat reports whether the given floating-point constant is a
+// single or double precision value which is not NaN.
+func isFoldableFloat(x *constant.Float) bool {
+	switch x.Typ.Kind {
+	case types.FloatKindFloat, types.FloatKindDouble:
+		return !x.NaN
+	}
+	return false
+}
+
+// floatValue returns the value of the given floating-point constant.
+func floatValue(x *constant.Float) float64 {
+	f, _ := x.X.Float64()
+	return f
+}
+
+// newFloat returns a floating-point constant of the given type, rounding the
+// value to single precision if needed.
+func newFloat(typ *types.FloatType, x float64) *constant.Float {
+	if typ.Kind == types.FloatKindFloat {
+		x = float64(float32(x))
+	}
+	return constant.NewFloat(typ, x)
+}
+
+// --- [ Integer values ] ------------------------------------------------------
+
+// Unsigned returns the value of the given integer constant interpreted as an
+// unsigned integer.
+func Unsigned(x *constant.Int) *big.Int {
+	m := new(big.Int).Lsh(big.NewInt(1), uint(x.Typ.BitSize))
+	return new(big.Int).Mod(x.X, m)
+}
+
+// Signed returns the value of the given integer constant interpreted as a
+// signed integer in two's complement.
+func Signed(x *constant.Int) *big.Int {
+	u := Unsigned(x)
+	n := uint(x.Typ.BitSize)
+	if u.Bit(int(n)-1) == 1 {
+		u.Sub(u, new(big.Int).Lsh(big.NewInt(1), n))
+	}
+	return u
+}
+
+// NewIntValue returns an integer constant of the given type with the value x
+// truncated to the bit size of the type. Values are represented as signed
+// integers, except for values of type i1, which are represented as 0 or 1.
+func NewIntValue(typ *types.IntType, x *big.Int) *constant.Int {
+	c := &constant.Int{Typ: typ, X: x}
+	if typ.BitSize == 1 {
+		return &constant.Int{Typ: typ, X: Unsigned(c)}
+	}
+	return &constant.Int{Typ: typ, X: Signed(c)}
+}
+
+// ConstEqual reports whether the constants x and y are identical.
+func ConstEqual(x, y constant.Constant) bool {
+	if x == y {
+		return true
+	}
+	if cx, ok := x.(*constant.Int); ok {
+		if cy, ok := y.(*constant.Int); ok {
+			return cx.Typ.Equal(cy.Typ) && Unsigned(cx).Cmp(Unsigned(cy)) == 0
+		}
+		return false
+	}
+	return ValueEqual(x, y)
+}
+
+// fitsUnsigned reports whether x is representable as an unsigned integer of n
+// bits.
+func fitsUnsigned(x *big.Int, n uint64) bool {
+	return x.Sign() >= 0 && uint64(x.BitLen()) <= n
+}
+
+// fitsSigned reports whether x is representable as a signed integer of n bits.
+func fitsSigned(x *big.Int, n uint64) bool {
+	min := new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), uint(n-1)))
+	max := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(n-1)), big.NewInt(1))
+	return x.Cmp(min) >= 0 && x.Cmp(max) <= 0
+}
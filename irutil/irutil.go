@@ -0,0 +1,78 @@
+// Package irutil implements utility functions for inspecting and rewriting LLVM
+// IR functions, such as replacing uses of values, comparing instructions
+// structurally and querying the memory effects of instructions.
+//
+// The ir package does not maintain use-def chains. As such, the rewrite
+// functions of this package operate by scanning the operands of every
+// instruction and terminator of a function.
+package irutil
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/value"
+)
+
+// ResetLocalIDs resets the IDs of the unnamed local variables of the given
+// function (parameters, basic blocks, instructions and terminators), so that
+// they may be reassigned by ir.Func.AssignIDs.
+//
+// ResetLocalIDs should be invoked after instructions or basic blocks have been
+// inserted or removed, as the local IDs of unnamed local variables must be
+// consecutive.
+func ResetLocalIDs(f *ir.Func) {
+	type unnamed interface {
+		IsUnnamed() bool
+		SetID(id int64)
+	}
+	reset := func(v interface{}) {
+		if n, ok := v.(unnamed); ok && n.IsUnnamed() {
+			n.SetID(0)
+		}
+	}
+	for _, param := range f.Params {
+		reset(param)
+	}
+	for _, block := range f.Blocks {
+		reset(block)
+		for _, inst := range block.Insts {
+			reset(inst)
+		}
+		reset(block.Term)
+	}
+}
+
+// Users returns the instructions and terminators of f which use v as an
+// operand, in program order.
+func Users(f *ir.Func, v value.Value) []value.User {
+	var users []value.User
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			if uses(inst, v) {
+				users = append(users, inst)
+			}
+		}
+		if block.Term != nil && uses(block.Term, v) {
+			users = append(users, block.Term)
+		}
+	}
+	return users
+}
+
+// uses reports whether the given user has v as an operand.
+func uses(user value.User, v value.Value) bool {
+	for _, op := range user.Operands() {
+		if Unwrap(*op) == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Unwrap returns the underlying value of a function argument with parameter
+// attributes (i.e. *ir.Arg). Other values are returned unchanged.
+func Unwrap(v value.Value) value.Value {
+	if arg, ok := v.(*ir.Arg); ok {
+		return arg.Value
+	}
+	return v
+}
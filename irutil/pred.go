@@ -0,0 +1,72 @@
+package irutil
+
+import (
+	"fmt"
+
+	"github.com/llir/llvm/ir/enum"
+)
+
+// --- [ Comparison predicates ] -----------------------------------------------
+
+// SwapIPred returns the predicate of the integer comparison with swapped
+// operands; e.g. x < y is equivalent to y > x.
+func SwapIPred(pred enum.IPred) enum.IPred {
+	switch pred {
+	case enum.IPredSGT:
+		return enum.IPredSLT
+	case enum.IPredSGE:
+		return enum.IPredSLE
+	case enum.IPredSLT:
+		return enum.IPredSGT
+	case enum.IPredSLE:
+		return enum.IPredSGE
+	case enum.IPredUGT:
+		return enum.IPredULT
+	case enum.IPredUGE:
+		return enum.IPredULE
+	case enum.IPredULT:
+		return enum.IPredUGT
+	case enum.IPredULE:
+		return enum.IPredUGE
+	}
+	// eq and ne are symmetric.
+	return pred
+}
+
+// InverseIPred returns the predicate of the negated integer comparison; e.g.
+// !(x < y) is equivalent to x >= y.
+func InverseIPred(pred enum.IPred) enum.IPred {
+	switch pred {
+	case enum.IPredEQ:
+		return enum.IPredNE
+	case enum.IPredNE:
+		return enum.IPredEQ
+	case enum.IPredSGT:
+		return enum.IPredSLE
+	case enum.IPredSGE:
+		return enum.IPredSLT
+	case enum.IPredSLT:
+		return enum.IPredSGE
+	case enum.IPredSLE:
+		return enum.IPredSGT
+	case enum.IPredUGT:
+		return enum.IPredULE
+	case enum.IPredUGE:
+		return enum.IPredULT
+	case enum.IPredULT:
+		return enum.IPredUGE
+	case enum.IPredULE:
+		return enum.IPredUGT
+	}
+	panic(fmt.Errorf("support for integer comparison predicate %v not yet implemented", pred))
+}
+
+// IsSignedIPred reports whether the given integer comparison predicate is a
+// signed comparison.
+func IsSignedIPred(pred enum.IPred) bool {
+	switch pred {
+	case enum.IPredSGT, enum.IPredSGE, enum.IPredSLT, enum.IPredSLE:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package irutil
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/value"
+)
+
+// ReplaceUses replaces all uses of old with new in the instructions and
+// terminators of f.
+func ReplaceUses(f *ir.Func, old, new value.Value) {
+	ReplaceAll(f, map[value.Value]value.Value{old: new})
+}
+
+// ReplaceAll replaces the uses of values in the instructions and terminators of
+// f, as specified by the replacement map repl. Chains of replacements (e.g.
+// a -> b and b -> c) are followed, so that uses of a are replaced with c.
+func ReplaceAll(f *ir.Func, repl map[value.Value]value.Value) {
+	if len(repl) == 0 {
+		return
+	}
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			ReplaceOperands(inst, repl)
+		}
+		if block.Term != nil {
+			ReplaceOperands(block.Term, repl)
+		}
+	}
+}
+
+// ReplaceOperands replaces the operands of the given instruction or terminator,
+// as specified by the replacement map repl. Chains of replacements are
+// followed. The underlying values of function arguments with parameter
+// attributes (i.e. *ir.Arg) are replaced in place, to retain their attributes.
+func ReplaceOperands(user value.User, repl map[value.Value]value.Value) {
+	replaced := false
+	for _, op := range user.Operands() {
+		if arg, ok := (*op).(*ir.Arg); ok {
+			arg.Value = Lookup(repl, arg.Value)
+			continue
+		}
+		if v := Lookup(repl, *op); v != *op {
+			*op = v
+			replaced = true
+		}
+	}
+	if term, ok := user.(ir.Terminator); ok && replaced {
+		// Successor basic blocks may have been replaced.
+		ResetSuccs(term)
+	}
+}
+
+// Lookup returns the replacement of v as specified by the replacement map repl,
+// following chains of replacements. The value v is returned if no replacement
+// is present.
+func Lookup(repl map[value.Value]value.Value, v value.Value) value.Value {
+	for i := 0; i <= len(repl); i++ {
+		w, ok := repl[v]
+		if !ok || w == v {
+			return v
+		}
+		v = w
+	}
+	// Cycle of replacements; leave as is.
+	return v
+}
+
+// RemoveInsts removes the instructions of f which are present in the set dead.
+// The uses of removed instructions are left unchanged, and should be replaced
+// by the caller beforehand.
+func RemoveInsts(f *ir.Func, dead map[ir.Instruction]bool) {
+	if len(dead) == 0 {
+		return
+	}
+	for _, block := range f.Blocks {
+		insts := block.Insts[:0]
+		for _, inst := range block.Insts {
+			if !dead[inst] {
+				insts = append(insts, inst)
+			}
+		}
+		// Clear dangling references in the tail of the backing array.
+		for i := len(insts); i < len(block.Insts); i++ {
+			block.Insts[i] = nil
+		}
+		block.Insts = insts
+	}
+}
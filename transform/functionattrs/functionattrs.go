@@ -0,0 +1,535 @@
+// Package functionattrs implements function attribute inference, which deduces
+// the memory effects and other properties of the function definitions of an
+// LLVM IR module, and records them as function and parameter attributes.
+//
+// Functions are visited bottom-up over the strongly connected components of the
+// call graph (see package callgraph), so that the attributes inferred for
+// callees are known when their callers are visited. Within a component, calls
+// between members of the component are optimistically assumed to have the
+// attributes being inferred, which are only added if they hold for every member
+// of the component. The following function attributes are inferred:
+//
+//	readnone     no memory is read or written, except for memory local to the
+//	             function (allocas)
+//	readonly     no memory is written, except for local memory
+//	argmemonly   only memory pointed to by arguments (or local memory) is
+//	             accessed
+//	nounwind     no call may unwind, and no exception is resumed
+//	norecurse    the function is not recursive, and calls only norecurse
+//	             functions
+//	willreturn   the function has no cycles in its control flow graph, is not
+//	             recursive, and calls only willreturn functions
+//	nofree       only nofree functions (or functions not writing memory) are
+//	             called
+//
+// The following parameter attributes are inferred for pointer parameters:
+//
+//	nocapture    the pointer does not escape the function (see package escape)
+//	readonly     no memory is written through the pointer, which is not captured
+//
+// Attributes are only inferred for exact definitions; i.e. not for functions
+// which may be replaced at link time (weak and linkonce linkage), nor for
+// optnone functions. Calls to unresolved indirect callees and inline assembly
+// are assumed to have arbitrary effects.
+package functionattrs
+
+import (
+	"strings"
+
+	"github.com/llir/llvm/analysis/alias"
+	"github.com/llir/llvm/analysis/callgraph"
+	"github.com/llir/llvm/analysis/escape"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/llir/llvm/irutil"
+)
+
+// Run infers the function and parameter attributes of the function definitions
+// of the given module, and reports whether any attribute was added.
+func Run(m *ir.Module) bool {
+	g := callgraph.New(m)
+	aa := alias.NewBasic(nil)
+	changed := false
+	for _, nodes := range g.SCCs() {
+		c := &component{g: g, aa: aa, funcs: make(map[*ir.Func]bool)}
+		for _, n := range nodes {
+			if !isExactDefinition(n.Func) {
+				c = nil
+				break
+			}
+			c.nodes = append(c.nodes, n)
+			c.funcs[n.Func] = true
+		}
+		if c == nil {
+			continue
+		}
+		if c.inferFuncAttrs() {
+			changed = true
+		}
+		if c.inferParamAttrs() {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// component is a strongly connected component of the call graph.
+type component struct {
+	// Call graph of the module.
+	g *callgraph.Graph
+	// Alias analysis used to compute the underlying objects of pointers.
+	aa *alias.Basic
+	// Nodes of the component.
+	nodes []*callgraph.Node
+	// Functions of the component.
+	funcs map[*ir.Func]bool
+}
+
+// === [ Function attributes ] =================================================
+
+// effects are the inferred properties of the functions of a component.
+type effects struct {
+	// Memory other than local memory may be read or written.
+	read, write bool
+	// Memory not pointed to by arguments (or local) may be accessed.
+	otherMem bool
+	// An exception may be thrown or resumed.
+	unwind bool
+	// A function not known to be norecurse may be called.
+	recurse bool
+	// The function may not return; e.g. due to cycles or calls to functions not
+	// known to be willreturn.
+	noReturn bool
+	// Memory may be freed.
+	free bool
+}
+
+// inferFuncAttrs infers the function attributes of the functions of the
+// component, and reports whether any attribute was added.
+func (c *component) inferFuncAttrs() bool {
+	e := &effects{}
+	for _, n := range c.nodes {
+		c.scan(n, e)
+	}
+	if c.isRecursive() {
+		e.recurse = true
+		e.noReturn = true
+	}
+	var attrs []enum.FuncAttr
+	switch {
+	case !e.read && !e.write:
+		attrs = append(attrs, enum.FuncAttrReadNone)
+	case !e.write:
+		attrs = append(attrs, enum.FuncAttrReadOnly)
+	}
+	if (e.read || e.write) && !e.otherMem {
+		attrs = append(attrs, enum.FuncAttrArgMemOnly)
+	}
+	if !e.unwind {
+		attrs = append(attrs, enum.FuncAttrNoUnwind)
+	}
+	if !e.recurse {
+		attrs = append(attrs, enum.FuncAttrNoRecurse)
+	}
+	if !e.noReturn {
+		attrs = append(attrs, enum.FuncAttrWillReturn)
+	}
+	if !e.free {
+		attrs = append(attrs, enum.FuncAttrNoFree)
+	}
+	changed := false
+	for _, n := range c.nodes {
+		for _, attr := range attrs {
+			if addFuncAttr(n.Func, attr) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// scan records the effects of the instructions of the function of the given
+// node.
+func (c *component) scan(n *callgraph.Node, e *effects) {
+	f := n.Func
+	// Callees of each call site; unresolved call sites have no callees.
+	callees := make(map[value.Value][]*ir.Func)
+	for _, edge := range n.Out {
+		callees[edge.Site.Inst] = append(callees[edge.Site.Inst], edge.Callee.Func)
+	}
+	if hasCycle(f) {
+		e.noReturn = true
+	}
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			switch inst := inst.(type) {
+			case *ir.InstLoad:
+				c.access(e, inst.Src, true, false)
+				if inst.Volatile {
+					e.read, e.write, e.otherMem = true, true, true
+				}
+			case *ir.InstStore:
+				c.access(e, inst.Dst, false, true)
+				if inst.Volatile {
+					e.read, e.write, e.otherMem = true, true, true
+				}
+			case *ir.InstCmpXchg:
+				c.access(e, inst.Ptr, true, true)
+			case *ir.InstAtomicRMW:
+				c.access(e, inst.Dst, true, true)
+			case *ir.InstVAArg, *ir.InstFence:
+				e.read, e.write, e.otherMem = true, true, true
+			case *ir.InstCall:
+				if irutil.IsDebugIntrinsic(inst) {
+					continue
+				}
+				c.call(e, inst, inst.Callee, inst.Args, inst.FuncAttrs, callees[inst], true)
+			}
+		}
+		switch term := block.Term.(type) {
+		case *ir.TermInvoke:
+			// Exceptions of the callee are caught by the unwind target.
+			c.call(e, term, term.Invokee, term.Args, term.FuncAttrs, callees[term], false)
+		case *ir.TermCallBr:
+			c.call(e, term, term.Callee, term.Args, term.FuncAttrs, callees[term], true)
+		case *ir.TermResume:
+			e.unwind = true
+		case *ir.TermCleanupRet:
+			if term.UnwindTarget == nil {
+				e.unwind = true
+			}
+		case *ir.TermCatchSwitch:
+			if term.DefaultUnwindTarget == nil {
+				e.unwind = true
+			}
+		}
+	}
+}
+
+// access records a memory access through the given pointer.
+func (c *component) access(e *effects, ptr value.Value, read, write bool) {
+	switch c.aa.UnderlyingObject(ptr).(type) {
+	case *ir.InstAlloca:
+		// Local memory.
+		return
+	case *ir.Param:
+	default:
+		e.otherMem = true
+	}
+	e.read = e.read || read
+	e.write = e.write || write
+}
+
+// call records the effects of a call site with the given callee, arguments,
+// call site function attributes and resolved callees. The call site may unwind
+// to the caller if mayUnwind is true.
+func (c *component) call(e *effects, site value.Value, callee value.Value, args []value.Value, siteAttrs []ir.FuncAttribute, callees []*ir.Func, mayUnwind bool) {
+	if len(callees) == 0 {
+		if asm, ok := irutil.Unwrap(callee).(*ir.InlineAsm); ok && !asm.SideEffect {
+			// Inline assembly without side effects.
+			e.recurse = true
+			e.noReturn = true
+			return
+		}
+		// Unknown callee.
+		e.read, e.write, e.otherMem = true, true, true
+		e.unwind = e.unwind || mayUnwind
+		e.recurse, e.noReturn, e.free = true, true, true
+		return
+	}
+	for _, f := range callees {
+		if c.funcs[f] {
+			// Optimistically assume that members of the component have the
+			// attributes being inferred.
+			continue
+		}
+		attrs := append(siteAttrs[:len(siteAttrs):len(siteAttrs)], f.FuncAttrs...)
+		has := func(attr enum.FuncAttr) bool {
+			return irutil.HasFuncAttr(attrs, attr)
+		}
+		intrinsic := strings.HasPrefix(f.Name(), "llvm.")
+		readNone, readOnly := has(enum.FuncAttrReadNone), has(enum.FuncAttrReadOnly)
+		switch {
+		case readNone:
+		case has(enum.FuncAttrArgMemOnly):
+			for _, arg := range args {
+				if types.IsPointer(arg.Type()) {
+					c.access(e, arg, true, !readOnly)
+				}
+			}
+		default:
+			e.read, e.otherMem = true, true
+			e.write = e.write || !readOnly
+		}
+		if mayUnwind && !has(enum.FuncAttrNoUnwind) {
+			e.unwind = true
+		}
+		if !intrinsic && !has(enum.FuncAttrNoRecurse) {
+			e.recurse = true
+		}
+		if !has(enum.FuncAttrWillReturn) {
+			e.noReturn = true
+		}
+		if !has(enum.FuncAttrNoFree) && !readNone && !readOnly {
+			e.free = true
+		}
+	}
+}
+
+// isRecursive reports whether the functions of the component may call
+// themselves.
+func (c *component) isRecursive() bool {
+	for _, n := range c.nodes {
+		if c.g.IsRecursive(n.Func) {
+			return true
+		}
+	}
+	return false
+}
+
+// === [ Parameter attributes ] ================================================
+
+// inferParamAttrs infers the nocapture and readonly parameter attributes of the
+// pointer parameters of the functions of the component, and reports whether
+// any attribute was added.
+//
+// The attributes are tentatively added to every pointer parameter, and removed
+// from the parameters for which they do not hold until a fixed point is
+// reached, so that parameters passed to recursive calls may be inferred.
+func (c *component) inferParamAttrs() bool {
+	var candidates []*candidate
+	for _, n := range c.nodes {
+		for _, param := range n.Func.Params {
+			if !types.IsPointer(param.Type()) {
+				continue
+			}
+			cand := &candidate{f: n.Func, param: param}
+			if !irutil.HasParamAttr(param.Attrs, enum.ParamAttrNoCapture) {
+				cand.noCapture = true
+				param.Attrs = append(param.Attrs, enum.ParamAttrNoCapture)
+			}
+			if !irutil.HasParamAttr(param.Attrs, enum.ParamAttrReadOnly) && !irutil.HasParamAttr(param.Attrs, enum.ParamAttrReadNone) {
+				cand.readOnly = true
+				param.Attrs = append(param.Attrs, enum.ParamAttrReadOnly)
+			}
+			if cand.noCapture || cand.readOnly {
+				candidates = append(candidates, cand)
+			}
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		infos := make(map[*ir.Func]*escape.Info)
+		for _, cand := range candidates {
+			if cand.noCapture {
+				info, ok := infos[cand.f]
+				if !ok {
+					info = escape.Analyze(cand.f, nil)
+					infos[cand.f] = info
+				}
+				if info.Escapes(cand.param) {
+					cand.noCapture = false
+					removeParamAttr(cand.param, enum.ParamAttrNoCapture)
+					changed = true
+				}
+			}
+			if cand.readOnly {
+				if !irutil.HasParamAttr(cand.param.Attrs, enum.ParamAttrNoCapture) || writesThrough(cand.f, cand.param) {
+					cand.readOnly = false
+					removeParamAttr(cand.param, enum.ParamAttrReadOnly)
+					changed = true
+				}
+			}
+		}
+	}
+	for _, cand := range candidates {
+		if cand.noCapture || cand.readOnly {
+			return true
+		}
+	}
+	return false
+}
+
+// candidate is a pointer parameter with tentatively added attributes.
+type candidate struct {
+	// Function of the parameter.
+	f *ir.Func
+	// Pointer parameter.
+	param *ir.Param
+	// The nocapture and readonly attributes have been tentatively added.
+	noCapture, readOnly bool
+}
+
+// writesThrough reports whether memory may be written through the given
+// pointer parameter of f, or through pointers derived from it.
+func writesThrough(f *ir.Func, param *ir.Param) bool {
+	derived := map[value.Value]bool{param: true}
+	for changed := true; changed; {
+		changed = false
+		for _, block := range f.Blocks {
+			for _, inst := range block.Insts {
+				switch inst := inst.(type) {
+				case *ir.InstBitCast, *ir.InstAddrSpaceCast, *ir.InstGetElementPtr, *ir.InstPhi, *ir.InstSelect, *ir.InstFreeze:
+					v := inst.(value.Value)
+					if !derived[v] && usesAny(inst, derived) {
+						derived[v] = true
+						changed = true
+					}
+				}
+			}
+		}
+	}
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			switch inst := inst.(type) {
+			case *ir.InstStore:
+				if derived[inst.Dst] {
+					return true
+				}
+			case *ir.InstCmpXchg:
+				if derived[inst.Ptr] {
+					return true
+				}
+			case *ir.InstAtomicRMW:
+				if derived[inst.Dst] {
+					return true
+				}
+			case *ir.InstCall:
+				if callWritesThrough(inst.Callee, inst.Args, irutil.CallFuncAttrs(inst), derived) {
+					return true
+				}
+			}
+		}
+		switch term := block.Term.(type) {
+		case *ir.TermInvoke:
+			if callWritesThrough(term.Invokee, term.Args, term.FuncAttrs, derived) {
+				return true
+			}
+		case *ir.TermCallBr:
+			if callWritesThrough(term.Callee, term.Args, term.FuncAttrs, derived) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// callWritesThrough reports whether a call with the given callee, arguments and
+// function attributes may write memory through the derived pointers passed as
+// arguments.
+func callWritesThrough(callee value.Value, args []value.Value, attrs []ir.FuncAttribute, derived map[value.Value]bool) bool {
+	f := irutil.Callee(callee)
+	if f != nil {
+		attrs = append(attrs[:len(attrs):len(attrs)], f.FuncAttrs...)
+	}
+	if irutil.HasFuncAttr(attrs, enum.FuncAttrReadNone) || irutil.HasFuncAttr(attrs, enum.FuncAttrReadOnly) {
+		return false
+	}
+	for i, arg := range args {
+		if !derived[irutil.Unwrap(arg)] {
+			continue
+		}
+		var paramAttrs []ir.ParamAttribute
+		if arg, ok := arg.(*ir.Arg); ok {
+			paramAttrs = arg.Attrs
+		}
+		if f != nil && i < len(f.Params) {
+			paramAttrs = append(paramAttrs[:len(paramAttrs):len(paramAttrs)], f.Params[i].Attrs...)
+		}
+		if !irutil.HasParamAttr(paramAttrs, enum.ParamAttrReadOnly) && !irutil.HasParamAttr(paramAttrs, enum.ParamAttrReadNone) {
+			return true
+		}
+	}
+	return false
+}
+
+// ### [ Helper functions ] ####################################################
+
+// isExactDefinition reports whether the given function is a definition which
+// may not be replaced at link time, and may thus be optimized based on its
+// body.
+func isExactDefinition(f *ir.Func) bool {
+	if len(f.Blocks) == 0 {
+		return false
+	}
+	switch f.Linkage {
+	case enum.LinkageWeak, enum.LinkageWeakODR, enum.LinkageLinkOnce, enum.LinkageLinkOnceODR, enum.LinkageExternWeak, enum.LinkageCommon:
+		return false
+	}
+	return !irutil.HasFuncAttr(f.FuncAttrs, enum.FuncAttrOptNone)
+}
+
+// hasCycle reports whether the control flow graph of the given function has a
+// cycle reachable from its entry basic block.
+func hasCycle(f *ir.Func) bool {
+	const (
+		unvisited = iota
+		active
+		done
+	)
+	state := make(map[*ir.Block]int)
+	var visit func(block *ir.Block) bool
+	visit = func(block *ir.Block) bool {
+		state[block] = active
+		for _, succ := range block.Term.Succs() {
+			switch state[succ] {
+			case active:
+				return true
+			case unvisited:
+				if visit(succ) {
+					return true
+				}
+			}
+		}
+		state[block] = done
+		return false
+	}
+	return visit(f.Blocks[0])
+}
+
+// addFuncAttr adds the given function attribute to f, unless already present
+// or implied by a stronger attribute, and reports whether it was added. The
+// readnone attribute replaces readonly.
+func addFuncAttr(f *ir.Func, attr enum.FuncAttr) bool {
+	if irutil.HasFuncAttr(f.FuncAttrs, attr) {
+		return false
+	}
+	if attr == enum.FuncAttrReadOnly && irutil.HasFuncAttr(f.FuncAttrs, enum.FuncAttrReadNone) {
+		return false
+	}
+	if attr == enum.FuncAttrReadNone {
+		attrs := f.FuncAttrs[:0]
+		for _, a := range f.FuncAttrs {
+			if a != enum.FuncAttrReadOnly {
+				attrs = append(attrs, a)
+			}
+		}
+		f.FuncAttrs = attrs
+	}
+	f.FuncAttrs = append(f.FuncAttrs, attr)
+	return true
+}
+
+// removeParamAttr removes the given parameter attribute from param.
+func removeParamAttr(param *ir.Param, attr enum.ParamAttr) {
+	attrs := param.Attrs[:0]
+	for _, a := range param.Attrs {
+		if a != attr {
+			attrs = append(attrs, a)
+		}
+	}
+	param.Attrs = attrs
+}
+
+// usesAny reports whether the given user has any of the given values as an
+// operand.
+func usesAny(user value.User, vs map[value.Value]bool) bool {
+	for _, op := range user.Operands() {
+		if vs[*op] {
+			return true
+		}
+	}
+	return false
+}
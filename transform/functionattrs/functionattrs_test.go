@@ -0,0 +1,153 @@
+package functionattrs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+)
+
+func TestRun(t *testing.T) {
+	const src = `
+@g = global i32 0
+
+@gp = global i8* null
+
+declare void @ext()
+
+define i32 @get(i32* %p) {
+entry:
+	%x = load i32, i32* %p
+	ret i32 %x
+}
+
+define void @set(i32* %p, i32 %x) {
+entry:
+	%q = getelementptr i32, i32* %p, i64 1
+	store i32 %x, i32* %q
+	ret void
+}
+
+define i32 @pure(i32 %x) {
+entry:
+	%local = alloca i32
+	store i32 %x, i32* %local
+	%y = load i32, i32* %local
+	%z = add i32 %y, 1
+	ret i32 %z
+}
+
+define void @setg(i32 %x) {
+entry:
+	store i32 %x, i32* @g
+	ret void
+}
+
+define i32 @caller(i32* %p) {
+entry:
+	%x = call i32 @get(i32* %p)
+	ret i32 %x
+}
+
+define void @leak(i8* %p) {
+entry:
+	store i8* %p, i8** @gp
+	ret void
+}
+
+define void @callext() {
+entry:
+	call void @ext()
+	ret void
+}
+
+define void @loop(i1 %c) {
+entry:
+	br label %loop
+
+loop:
+	br i1 %c, label %loop, label %exit
+
+exit:
+	ret void
+}
+
+define i32 @even(i32* %p, i32 %n) {
+entry:
+	%c = icmp eq i32 %n, 0
+	br i1 %c, label %done, label %rec
+
+rec:
+	%m = sub i32 %n, 1
+	%r = call i32 @odd(i32* %p, i32 %m)
+	ret i32 %r
+
+done:
+	%x = load i32, i32* %p
+	ret i32 %x
+}
+
+define i32 @odd(i32* %p, i32 %n) {
+entry:
+	%m = sub i32 %n, 1
+	%r = call i32 @even(i32* %p, i32 %m)
+	ret i32 %r
+}
+
+define weak i32 @weak() {
+entry:
+	ret i32 0
+}
+`
+	m, err := asm.ParseString("functionattrs.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	if !Run(m) {
+		t.Errorf("expected module to be changed")
+	}
+	golden := map[string]struct {
+		attrs  string
+		params string
+	}{
+		"get":     {attrs: "readonly argmemonly nounwind norecurse willreturn nofree", params: "nocapture readonly"},
+		"set":     {attrs: "argmemonly nounwind norecurse willreturn nofree", params: "nocapture; "},
+		"pure":    {attrs: "readnone nounwind norecurse willreturn nofree", params: ""},
+		"setg":    {attrs: "nounwind norecurse willreturn nofree", params: ""},
+		"caller":  {attrs: "readonly argmemonly nounwind norecurse willreturn nofree", params: "nocapture readonly"},
+		"leak":    {attrs: "nounwind norecurse willreturn nofree", params: ""},
+		"callext": {attrs: "", params: ""},
+		"loop":    {attrs: "readnone nounwind norecurse nofree", params: ""},
+		"even":    {attrs: "readonly argmemonly nounwind nofree", params: "nocapture readonly; "},
+		"odd":     {attrs: "readonly argmemonly nounwind nofree", params: "nocapture readonly; "},
+		"weak":    {attrs: "", params: ""},
+	}
+	for _, f := range m.Funcs {
+		g, ok := golden[f.Name()]
+		if !ok {
+			continue
+		}
+		var attrs []string
+		for _, attr := range f.FuncAttrs {
+			attrs = append(attrs, attr.String())
+		}
+		if got := strings.Join(attrs, " "); got != g.attrs {
+			t.Errorf("function attributes mismatch of %s; expected %q, got %q", f.Ident(), g.attrs, got)
+		}
+		var params []string
+		for _, param := range f.Params {
+			var attrs []string
+			for _, attr := range param.Attrs {
+				attrs = append(attrs, attr.String())
+			}
+			params = append(params, strings.Join(attrs, " "))
+		}
+		if got := strings.Join(params, "; "); got != g.params {
+			t.Errorf("parameter attributes mismatch of %s; expected %q, got %q", f.Ident(), g.params, got)
+		}
+	}
+	// Attributes are only added once.
+	if Run(m) {
+		t.Errorf("expected module to be unchanged on second run")
+	}
+}
@@ -0,0 +1,484 @@
+// Package globaldce implements dead global elimination, which removes global
+// variables, functions, aliases and indirect functions that are unreachable
+// from the roots of an LLVM IR module, and internalization, which gives
+// internal linkage to the definitions of a module not explicitly preserved.
+//
+// The roots of a module are its definitions which may not be discarded if
+// unused (i.e. not of internal, private, linkonce or available_externally
+// linkage), the @llvm.used, @llvm.compiler.used, @llvm.global_ctors and
+// @llvm.global_dtors global variables, and the symbols of a user-provided
+// preserve list. Reachability is propagated through the initializers of global
+// variables, the instructions of function definitions (including their
+// personality, prefix and prologue data), the aliasees of aliases and the
+// resolvers of indirect functions. Members of a comdat group are kept or
+// removed together. Metadata does not keep global symbols alive; references of
+// metadata to removed global symbols are replaced by null.
+package globaldce
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/metadata"
+	"github.com/llir/llvm/ir/value"
+	"github.com/llir/llvm/irutil"
+)
+
+// Run removes the global variables, functions, aliases and indirect functions
+// of the given module which are unreachable from its roots, and reports
+// whether the module was changed. The symbols of the preserve list (global
+// names without '@' prefix) are roots, and thus retained.
+func Run(m *ir.Module, preserve ...string) bool {
+	r := &reach{
+		live:    make(map[constant.Constant]bool),
+		comdats: comdatMembers(m),
+		done:    make(map[*ir.ComdatDef]bool),
+	}
+	preserved := makeSet(preserve)
+	for _, v := range symbols(m) {
+		if isRoot(v, preserved) {
+			r.mark(v)
+		}
+	}
+	r.propagate()
+	// Metadata referring to removed global symbols is replaced by null, as
+	// metadata does not keep global symbols alive.
+	dropMetadataRefs(m, r.live)
+	changed := false
+	globals := m.Globals[:0]
+	for _, g := range m.Globals {
+		if r.live[g] {
+			globals = append(globals, g)
+		} else {
+			changed = true
+		}
+	}
+	m.Globals = globals
+	funcs := m.Funcs[:0]
+	for _, f := range m.Funcs {
+		if r.live[f] {
+			funcs = append(funcs, f)
+		} else {
+			changed = true
+		}
+	}
+	m.Funcs = funcs
+	aliases := m.Aliases[:0]
+	for _, alias := range m.Aliases {
+		if r.live[alias] {
+			aliases = append(aliases, alias)
+		} else {
+			changed = true
+		}
+	}
+	m.Aliases = aliases
+	ifuncs := m.IFuncs[:0]
+	for _, ifunc := range m.IFuncs {
+		if r.live[ifunc] {
+			ifuncs = append(ifuncs, ifunc)
+		} else {
+			changed = true
+		}
+	}
+	m.IFuncs = ifuncs
+	if removeComdats(m) {
+		changed = true
+	}
+	return changed
+}
+
+// Internalize gives internal linkage to the global variable, function, alias
+// and indirect function definitions of the given module which are not in the
+// preserve list (global names without '@' prefix), and reports whether the
+// module was changed. Comdat groups with a preserved member are left
+// unchanged, as are special symbols of LLVM (e.g. @llvm.used) and
+// available_externally definitions.
+//
+// Internalize is typically followed by Run, to remove definitions which are no
+// longer reachable.
+func Internalize(m *ir.Module, preserve ...string) bool {
+	preserved := makeSet(preserve)
+	// Comdat groups with a preserved member.
+	keep := make(map[*ir.ComdatDef]bool)
+	for c, members := range comdatMembers(m) {
+		for _, v := range members {
+			if preserved[name(v)] {
+				keep[c] = true
+			}
+		}
+	}
+	changed := false
+	internalize := func(v constant.Constant, linkage *enum.Linkage, visibility *enum.Visibility, dll *enum.DLLStorageClass) {
+		switch *linkage {
+		case enum.LinkageInternal, enum.LinkagePrivate, enum.LinkageAvailableExternally, enum.LinkageAppending:
+			return
+		}
+		if !isDefinition(v) || preserved[name(v)] || strings.HasPrefix(name(v), "llvm.") || keep[comdatOf(v)] {
+			return
+		}
+		*linkage = enum.LinkageInternal
+		*visibility = enum.VisibilityNone
+		*dll = enum.DLLStorageClassNone
+		changed = true
+	}
+	for _, g := range m.Globals {
+		internalize(g, &g.Linkage, &g.Visibility, &g.DLLStorageClass)
+	}
+	for _, f := range m.Funcs {
+		internalize(f, &f.Linkage, &f.Visibility, &f.DLLStorageClass)
+	}
+	for _, alias := range m.Aliases {
+		internalize(alias, &alias.Linkage, &alias.Visibility, &alias.DLLStorageClass)
+	}
+	for _, ifunc := range m.IFuncs {
+		internalize(ifunc, &ifunc.Linkage, &ifunc.Visibility, &ifunc.DLLStorageClass)
+	}
+	return changed
+}
+
+// --- [ Reachability ] --------------------------------------------------------
+
+// reach tracks the reachability of the global symbols of a module.
+type reach struct {
+	// Live global symbols.
+	live map[constant.Constant]bool
+	// Worklist of live global symbols not yet visited.
+	work []constant.Constant
+	// Members of each comdat group.
+	comdats map[*ir.ComdatDef][]constant.Constant
+	// Comdat groups whose members have been marked live.
+	done map[*ir.ComdatDef]bool
+}
+
+// mark marks the given global symbol live.
+func (r *reach) mark(v constant.Constant) {
+	if r.live[v] {
+		return
+	}
+	r.live[v] = true
+	r.work = append(r.work, v)
+	// Members of a comdat group are kept together.
+	if c := comdatOf(v); c != nil && !r.done[c] {
+		r.done[c] = true
+		for _, member := range r.comdats[c] {
+			r.mark(member)
+		}
+	}
+}
+
+// markConst marks the global symbols referred to by the given constant live.
+func (r *reach) markConst(c constant.Constant) {
+	irutil.WalkConst(c, func(c constant.Constant) bool {
+		switch c.(type) {
+		case *ir.Global, *ir.Func, *ir.Alias, *ir.IFunc:
+			r.mark(c)
+			// Global symbols are visited through the worklist.
+			return false
+		}
+		return true
+	})
+}
+
+// propagate marks the global symbols reachable from the live global symbols
+// live.
+func (r *reach) propagate() {
+	for len(r.work) > 0 {
+		v := r.work[len(r.work)-1]
+		r.work = r.work[:len(r.work)-1]
+		switch v := v.(type) {
+		case *ir.Global:
+			r.markConst(v.Init)
+		case *ir.Func:
+			r.markConst(v.Prefix)
+			r.markConst(v.Prologue)
+			r.markConst(v.Personality)
+			for _, block := range v.Blocks {
+				for _, inst := range block.Insts {
+					if user, ok := inst.(value.User); ok {
+						r.markOperands(user)
+					}
+				}
+				if block.Term != nil {
+					r.markOperands(block.Term)
+				}
+			}
+		case *ir.Alias:
+			r.markConst(v.Aliasee)
+		case *ir.IFunc:
+			r.markConst(v.Resolver)
+		}
+	}
+}
+
+// markOperands marks the global symbols referred to by the operands of the
+// given instruction or terminator live.
+func (r *reach) markOperands(user value.User) {
+	for _, op := range user.Operands() {
+		v := irutil.Unwrap(*op)
+		if md, ok := v.(*metadata.Value); ok {
+			// Constants of metadata arguments (e.g. of @llvm.dbg.value).
+			if c, ok := md.Value.(constant.Constant); ok {
+				r.markConst(c)
+			}
+			continue
+		}
+		if c, ok := v.(constant.Constant); ok {
+			r.markConst(c)
+		}
+	}
+}
+
+// --- [ Metadata references ] -------------------------------------------------
+
+// dropMetadataRefs replaces the references of the metadata of the given module
+// to global symbols which are not live (e.g. !{void ()* @dead}) by null.
+func dropMetadataRefs(m *ir.Module, live map[constant.Constant]bool) {
+	d := &mdDropper{
+		dead: func(c constant.Constant) bool {
+			dead := false
+			irutil.WalkConst(c, func(c constant.Constant) bool {
+				switch c.(type) {
+				case *ir.Global, *ir.Func, *ir.Alias, *ir.IFunc:
+					if !live[c] {
+						dead = true
+					}
+					return false
+				}
+				return true
+			})
+			return dead
+		},
+		visited: make(map[interface{}]bool),
+	}
+	for _, md := range m.MetadataDefs {
+		d.node(md)
+	}
+	for _, def := range m.NamedMetadataDefs {
+		for _, node := range def.Nodes {
+			d.node(node)
+		}
+	}
+	attachments := func(mds []*metadata.Attachment) {
+		for _, md := range mds {
+			d.node(md.Node)
+		}
+	}
+	for _, g := range m.Globals {
+		if live[g] {
+			attachments(g.MDAttachments())
+		}
+	}
+	for _, f := range m.Funcs {
+		if !live[f] {
+			continue
+		}
+		attachments(f.MDAttachments())
+		for _, block := range f.Blocks {
+			for _, inst := range block.Insts {
+				if inst, ok := inst.(interface{ MDAttachments() []*metadata.Attachment }); ok {
+					attachments(inst.MDAttachments())
+				}
+			}
+			if term, ok := block.Term.(interface{ MDAttachments() []*metadata.Attachment }); ok {
+				attachments(term.MDAttachments())
+			}
+		}
+	}
+}
+
+// mdPkgPath is the import path of the metadata package.
+var mdPkgPath = reflect.TypeOf(metadata.Tuple{}).PkgPath()
+
+// mdDropper replaces references of metadata nodes to dead global symbols by
+// null.
+type mdDropper struct {
+	// dead reports whether the given constant refers to a dead global symbol.
+	dead func(c constant.Constant) bool
+	// Visited metadata nodes.
+	visited map[interface{}]bool
+}
+
+// node replaces the references of the given metadata node, and of the metadata
+// nodes it refers to, to dead global symbols by null.
+func (d *mdDropper) node(node interface{}) {
+	rv := reflect.ValueOf(node)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return
+	}
+	// Only visit metadata nodes; not values of function-local metadata (e.g.
+	// instructions).
+	if rv.Elem().Type().PkgPath() != mdPkgPath {
+		return
+	}
+	if d.visited[node] {
+		return
+	}
+	d.visited[node] = true
+	// Fields of metadata nodes (e.g. tuple fields and specialized node fields
+	// such as DITemplateValueParameter.Value) are of interface type.
+	st := rv.Elem()
+	for i := 0; i < st.NumField(); i++ {
+		field := st.Field(i)
+		if !field.CanSet() {
+			continue
+		}
+		switch {
+		case field.Kind() == reflect.Interface:
+			d.field(field)
+		case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Interface:
+			for j := 0; j < field.Len(); j++ {
+				d.field(field.Index(j))
+			}
+		case field.Kind() == reflect.Ptr:
+			// Nodes of concrete type (e.g. *metadata.Tuple or *metadata.DIFile).
+			d.node(field.Interface())
+		}
+	}
+}
+
+// field replaces the given metadata field by null if it refers to a dead global
+// symbol, or visits the metadata node of the field otherwise.
+func (d *mdDropper) field(field reflect.Value) {
+	if field.IsNil() {
+		return
+	}
+	v := field.Interface()
+	if md, ok := v.(*metadata.Value); ok {
+		v = md.Value
+	}
+	if c, ok := v.(constant.Constant); ok {
+		if d.dead(c) && reflect.TypeOf(metadata.Null).AssignableTo(field.Type()) {
+			field.Set(reflect.ValueOf(metadata.Null))
+		}
+		return
+	}
+	d.node(v)
+}
+
+// ### [ Helper functions ] ####################################################
+
+// symbols returns the global symbols of the given module.
+func symbols(m *ir.Module) []constant.Constant {
+	var vs []constant.Constant
+	for _, g := range m.Globals {
+		vs = append(vs, g)
+	}
+	for _, f := range m.Funcs {
+		vs = append(vs, f)
+	}
+	for _, alias := range m.Aliases {
+		vs = append(vs, alias)
+	}
+	for _, ifunc := range m.IFuncs {
+		vs = append(vs, ifunc)
+	}
+	return vs
+}
+
+// isRoot reports whether the given global symbol is a root of reachability.
+func isRoot(v constant.Constant, preserved map[string]bool) bool {
+	if preserved[name(v)] {
+		return true
+	}
+	switch name(v) {
+	case "llvm.used", "llvm.compiler.used", "llvm.global_ctors", "llvm.global_dtors":
+		return true
+	}
+	if !isDefinition(v) {
+		// Unused declarations are removed.
+		return false
+	}
+	switch linkage(v) {
+	case enum.LinkageInternal, enum.LinkagePrivate, enum.LinkageLinkOnce, enum.LinkageLinkOnceODR, enum.LinkageAvailableExternally:
+		// Discardable if unused.
+		return false
+	}
+	return true
+}
+
+// isDefinition reports whether the given global symbol is a definition.
+func isDefinition(v constant.Constant) bool {
+	switch v := v.(type) {
+	case *ir.Global:
+		return v.Init != nil
+	case *ir.Func:
+		return len(v.Blocks) > 0
+	}
+	// Aliases and indirect functions.
+	return true
+}
+
+// name returns the name of the given global symbol.
+func name(v constant.Constant) string {
+	if v, ok := v.(value.Named); ok {
+		return v.Name()
+	}
+	return ""
+}
+
+// linkage returns the linkage of the given global symbol.
+func linkage(v constant.Constant) enum.Linkage {
+	switch v := v.(type) {
+	case *ir.Global:
+		return v.Linkage
+	case *ir.Func:
+		return v.Linkage
+	case *ir.Alias:
+		return v.Linkage
+	case *ir.IFunc:
+		return v.Linkage
+	}
+	return enum.LinkageNone
+}
+
+// comdatOf returns the comdat group of the given global symbol, or nil if not
+// present.
+func comdatOf(v constant.Constant) *ir.ComdatDef {
+	switch v := v.(type) {
+	case *ir.Global:
+		return v.Comdat
+	case *ir.Func:
+		return v.Comdat
+	}
+	return nil
+}
+
+// comdatMembers returns the members of each comdat group of the given module.
+func comdatMembers(m *ir.Module) map[*ir.ComdatDef][]constant.Constant {
+	members := make(map[*ir.ComdatDef][]constant.Constant)
+	for _, v := range symbols(m) {
+		if c := comdatOf(v); c != nil {
+			members[c] = append(members[c], v)
+		}
+	}
+	return members
+}
+
+// removeComdats removes the comdat definitions of the given module which have
+// no members, and reports whether any comdat definition was removed.
+func removeComdats(m *ir.Module) bool {
+	members := comdatMembers(m)
+	defs := m.ComdatDefs[:0]
+	removed := false
+	for _, def := range m.ComdatDefs {
+		if len(members[def]) > 0 {
+			defs = append(defs, def)
+		} else {
+			removed = true
+		}
+	}
+	m.ComdatDefs = defs
+	return removed
+}
+
+// makeSet returns the set of the given names.
+func makeSet(names []string) map[string]bool {
+	set := make(map[string]bool)
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
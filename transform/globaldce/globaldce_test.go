@@ -0,0 +1,200 @@
+package globaldce
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+)
+
+func TestRun(t *testing.T) {
+	golden := []struct {
+		name     string
+		preserve []string
+		in       string
+		want     string
+	}{
+		// Roots of @llvm.used and exported definitions, comdat groups and alias
+		// chains.
+		{
+			name: "roots",
+			in: `
+$dead = comdat any
+$live = comdat any
+
+@used = internal global i32 1
+@llvm.used = appending global [1 x i8*] [i8* bitcast (i32* @used to i8*)], section "llvm.metadata"
+@unused = internal global i32 2
+@table = private constant [1 x void ()*] [void ()* @helper]
+@comdat.a = linkonce_odr global i32 3, comdat($live)
+@comdat.b = linkonce_odr global i32 4, comdat($live)
+@comdat.c = linkonce_odr global i32 5, comdat($dead)
+
+@alias.a = internal alias void (), void ()* @alias.b
+@alias.b = internal alias void (), void ()* @target
+
+declare void @external()
+declare void @unused.decl()
+
+define internal void @helper() {
+	call void @external()
+	ret void
+}
+
+define internal void @target() {
+	ret void
+}
+
+define internal void @dead() {
+	call void @unused.decl()
+	ret void
+}
+
+define void @main() {
+	%p = getelementptr [1 x void ()*], [1 x void ()*]* @table, i64 0, i64 0
+	%1 = load i32, i32* @comdat.a
+	call void @alias.a()
+	ret void
+}`,
+			want: `
+$live = comdat any
+
+@used = internal global i32 1
+@llvm.used = appending global [1 x i8*] [i8* bitcast (i32* @used to i8*)], section "llvm.metadata"
+@table = private constant [1 x void ()*] [void ()* @helper]
+@comdat.a = linkonce_odr global i32 3, comdat($live)
+@comdat.b = linkonce_odr global i32 4, comdat($live)
+
+@alias.a = internal alias void (), void ()* @alias.b
+@alias.b = internal alias void (), void ()* @target
+
+declare void @external()
+
+define internal void @helper() {
+0:
+	call void @external()
+	ret void
+}
+
+define internal void @target() {
+0:
+	ret void
+}
+
+define void @main() {
+0:
+	%p = getelementptr [1 x void ()*], [1 x void ()*]* @table, i64 0, i64 0
+	%1 = load i32, i32* @comdat.a
+	call void @alias.a()
+	ret void
+}`,
+		},
+		// Preserved symbols.
+		{
+			name:     "preserve",
+			preserve: []string{"unused"},
+			in: `
+@unused = internal global i32 0
+@dead = internal global i32 1`,
+			want: `
+@unused = internal global i32 0`,
+		},
+		// Metadata does not keep global symbols alive; references to removed
+		// global symbols are replaced by null.
+		{
+			name: "metadata",
+			in: `
+@dead.global = internal global i32 1, !dbg !3
+
+define internal void @dead() {
+	ret void
+}
+
+define void @main() !dbg !3 {
+	ret void, !annotation !1
+}
+
+!named = !{!0, !1}
+
+!0 = !{void ()* @dead, void ()* @main, i32 1}
+!1 = !{!2, i8* bitcast (i32* @dead.global to i8*)}
+!2 = !{i32* @dead.global}
+!3 = distinct !{}`,
+			want: `
+define void @main() !dbg !3 {
+0:
+	ret void, !annotation !1
+}
+
+!named = !{!0, !1}
+
+!0 = !{null, void ()* @main, i32 1}
+!1 = !{!2, null}
+!2 = !{null}
+!3 = distinct !{}`,
+		},
+	}
+	for _, g := range golden {
+		m, err := asm.ParseString(g.name+".ll", g.in)
+		if err != nil {
+			t.Errorf("%q: unable to parse module; %+v", g.name, err)
+			continue
+		}
+		Run(m, g.preserve...)
+		got := strings.TrimSpace(m.String())
+		want := strings.TrimSpace(g.want)
+		if got != want {
+			t.Errorf("%q: module mismatch; expected:\n%s\n\ngot:\n%s", g.name, want, got)
+			continue
+		}
+		// The output is valid LLVM IR.
+		if _, err := asm.ParseString(g.name+".ll", got); err != nil {
+			t.Errorf("%q: unable to parse output module; %+v", g.name, err)
+		}
+	}
+}
+
+func TestInternalize(t *testing.T) {
+	const in = `
+@counter = global i32 0
+@config = global i32 1
+
+define void @api() {
+	%1 = load i32, i32* @config
+	ret void
+}
+
+define void @main() {
+	call void @helper()
+	ret void
+}
+
+define void @helper() {
+	store i32 1, i32* @counter
+	ret void
+}`
+	const want = `
+@counter = internal global i32 0
+
+define void @main() {
+0:
+	call void @helper()
+	ret void
+}
+
+define internal void @helper() {
+0:
+	store i32 1, i32* @counter
+	ret void
+}`
+	m, err := asm.ParseString("internalize.ll", in)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	Internalize(m, "main")
+	Run(m)
+	got := strings.TrimSpace(m.String())
+	if got != strings.TrimSpace(want) {
+		t.Errorf("module mismatch; expected:\n%s\n\ngot:\n%s", strings.TrimSpace(want), got)
+	}
+}
@@ -0,0 +1,279 @@
+// Package gvn implements a dominator-based global value numbering pass, which
+// eliminates redundant computations and redundant loads of LLVM IR functions.
+//
+// The pass walks the dominator tree of a function in pre-order, maintaining a
+// scoped table of available expressions keyed by their structural hash (see
+// irutil.Hash). An instruction which is structurally equal to an available
+// instruction of a dominating basic block is redundant, and its uses are
+// replaced by the available instruction.
+//
+// Loads are eliminated if an earlier load from (or store to) the same address
+// is available, and no intervening instruction may clobber the loaded memory
+// location, as determined by an alias analysis. Memory state is only propagated
+// from a basic block to the basic blocks it immediately dominates if the
+// dominated basic block has no other predecessor, as stores along other paths
+// could otherwise clobber memory.
+package gvn
+
+import (
+	"github.com/llir/llvm/analysis/alias"
+	"github.com/llir/llvm/analysis/cfg"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/llir/llvm/irutil"
+)
+
+// Run eliminates redundant computations and loads of the given function, based
+// on the alias analysis aa, and reports whether the function was changed. A
+// basic alias analysis (see alias.NewBasic) is used if aa is nil.
+func Run(f *ir.Func, aa alias.AliasAnalysis) bool {
+	if len(f.Blocks) == 0 {
+		return false
+	}
+	if aa == nil {
+		aa = alias.NewBasic(nil)
+	}
+	p := &pass{
+		aa:    aa,
+		dt:    cfg.NewDomTree(f),
+		preds: cfg.Preds(f),
+		repl:  make(map[value.Value]value.Value),
+		dead:  make(map[ir.Instruction]bool),
+	}
+	p.visit(p.dt.Root(), nil, nil)
+	if len(p.dead) == 0 {
+		return false
+	}
+	irutil.ReplaceAll(f, p.repl)
+	irutil.RemoveInsts(f, p.dead)
+	irutil.ResetLocalIDs(f)
+	return true
+}
+
+// RunModule eliminates redundant computations and loads of the function
+// definitions of the given module, based on a basic alias analysis, and reports
+// whether the module was changed.
+func RunModule(m *ir.Module) bool {
+	aa := alias.NewBasic(nil)
+	changed := false
+	for _, f := range m.Funcs {
+		if Run(f, aa) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// pass tracks the state of global value numbering of a function.
+type pass struct {
+	// Alias analysis used to determine whether instructions may clobber
+	// available values.
+	aa alias.AliasAnalysis
+	// Dominator tree of the function.
+	dt *cfg.DomTree
+	// Predecessors of each basic block.
+	preds map[*ir.Block][]*ir.Block
+	// Replacement of each redundant value.
+	repl map[value.Value]value.Value
+	// Redundant instructions to be removed.
+	dead map[ir.Instruction]bool
+}
+
+// visit eliminates redundant instructions of the given basic block and the
+// basic blocks it dominates. The expressions available at the start of block
+// are given by parent, and mem is the memory state at the end of the immediate
+// dominator of block.
+func (p *pass) visit(block *ir.Block, parent *scope, mem *memState) {
+	s := &scope{parent: parent, exprs: make(map[uint64][]ir.Instruction)}
+	if preds := p.preds[block]; len(preds) == 1 && preds[0] == p.dt.IDom(block) {
+		mem = mem.clone()
+	} else {
+		mem = newMemState()
+	}
+	for _, inst := range block.Insts {
+		// Operands defined by dominating instructions have already been
+		// numbered.
+		irutil.ReplaceOperands(inst, p.repl)
+		switch inst := inst.(type) {
+		case *ir.InstLoad:
+			if inst.Volatile || inst.Atomic {
+				if irutil.MayWriteMemory(inst) {
+					mem.clear()
+				}
+				continue
+			}
+			if v := mem.lookup(inst.Src, inst.ElemType); v != nil {
+				p.replace(inst, v)
+				continue
+			}
+			mem.add(inst.Src, inst.ElemType, inst)
+		case *ir.InstStore:
+			if inst.Volatile || inst.Atomic {
+				mem.clear()
+				continue
+			}
+			mem.clobber(p.aa, inst)
+			// Forward stored value to subsequent loads of the same address.
+			mem.add(inst.Dst, inst.Src.Type(), inst.Src)
+		default:
+			if irutil.MayWriteMemory(inst) {
+				mem.clobber(p.aa, inst)
+			}
+			if !isCandidate(inst) {
+				continue
+			}
+			h := irutil.Hash(inst)
+			if leader := s.lookup(h, inst); leader != nil {
+				p.replace(inst, leader.(value.Value))
+				continue
+			}
+			s.exprs[h] = append(s.exprs[h], inst)
+		}
+	}
+	// Invoke and callbr terminators may write memory before control is
+	// transferred to the dominated basic blocks.
+	if termMayWriteMemory(block.Term) {
+		mem.clear()
+	}
+	for _, child := range p.dt.Children(block) {
+		p.visit(child, s, mem)
+	}
+}
+
+// replace marks the given redundant instruction for removal, and records v as
+// its replacement.
+func (p *pass) replace(inst ir.Instruction, v value.Value) {
+	p.repl[inst.(value.Value)] = v
+	p.dead[inst] = true
+}
+
+// isCandidate reports whether the given instruction is a candidate for
+// elimination by structural equality; i.e. whether it computes a value solely
+// based on its operands.
+func isCandidate(inst ir.Instruction) bool {
+	switch inst := inst.(type) {
+	case *ir.InstAlloca, *ir.InstPhi, *ir.InstVAArg, *ir.InstLandingPad, *ir.InstCatchPad, *ir.InstCleanupPad:
+		return false
+	case *ir.InstCall:
+		if types.Equal(inst.Type(), types.Void) {
+			return false
+		}
+		return !irutil.MayReadMemory(inst) && !irutil.HasSideEffects(inst)
+	}
+	if _, ok := inst.(value.Value); !ok {
+		return false
+	}
+	return !irutil.MayReadMemory(inst) && !irutil.HasSideEffects(inst)
+}
+
+// termMayWriteMemory reports whether the given terminator may write memory.
+func termMayWriteMemory(term ir.Terminator) bool {
+	var callee value.Value
+	var attrs []ir.FuncAttribute
+	switch term := term.(type) {
+	case *ir.TermInvoke:
+		callee, attrs = term.Invokee, term.FuncAttrs
+	case *ir.TermCallBr:
+		callee, attrs = term.Callee, term.FuncAttrs
+	default:
+		return false
+	}
+	if f := irutil.Callee(callee); f != nil {
+		attrs = append(attrs[:len(attrs):len(attrs)], f.FuncAttrs...)
+	}
+	return !irutil.HasFuncAttr(attrs, enum.FuncAttrReadNone) && !irutil.HasFuncAttr(attrs, enum.FuncAttrReadOnly)
+}
+
+// --- [ Scoped expression table ] ---------------------------------------------
+
+// scope is a scope of available expressions, corresponding to a basic block in
+// the dominator tree.
+type scope struct {
+	// Parent scope; nil for the entry basic block.
+	parent *scope
+	// Available expressions of the scope, keyed by structural hash.
+	exprs map[uint64][]ir.Instruction
+}
+
+// lookup returns an available instruction of the scope (or its parents) which
+// is structurally equal to inst, or nil if not present.
+func (s *scope) lookup(h uint64, inst ir.Instruction) ir.Instruction {
+	for ; s != nil; s = s.parent {
+		for _, cand := range s.exprs[h] {
+			if irutil.Equal(cand, inst) {
+				return cand
+			}
+		}
+	}
+	return nil
+}
+
+// --- [ Memory state ] --------------------------------------------------------
+
+// memState tracks the values available in memory at a given program point.
+type memState struct {
+	// Available values, keyed by address.
+	avail map[value.Value][]availValue
+}
+
+// availValue is a value of a given type available in memory at an address.
+type availValue struct {
+	// Type of the memory access.
+	typ types.Type
+	// Value stored at the address.
+	v value.Value
+}
+
+// newMemState returns a new memory state without available values.
+func newMemState() *memState {
+	return &memState{avail: make(map[value.Value][]availValue)}
+}
+
+// clone returns a copy of the memory state. A nil memory state is cloned into
+// an empty memory state.
+func (mem *memState) clone() *memState {
+	m := newMemState()
+	if mem == nil {
+		return m
+	}
+	for addr, vs := range mem.avail {
+		m.avail[addr] = vs
+	}
+	return m
+}
+
+// lookup returns the value of the given type available at addr, or nil if not
+// present.
+func (mem *memState) lookup(addr value.Value, typ types.Type) value.Value {
+	for _, av := range mem.avail[addr] {
+		if av.typ.Equal(typ) {
+			return av.v
+		}
+	}
+	return nil
+}
+
+// add records v of the given type as available at addr.
+func (mem *memState) add(addr value.Value, typ types.Type, v value.Value) {
+	vs := mem.avail[addr]
+	// Copy on write, as the slice may be shared with cloned memory states.
+	mem.avail[addr] = append(vs[:len(vs):len(vs)], availValue{typ: typ, v: v})
+}
+
+// clobber removes the available values of the memory locations which may be
+// modified by inst, as determined by the alias analysis aa.
+func (mem *memState) clobber(aa alias.AliasAnalysis, inst ir.Instruction) {
+	for addr := range mem.avail {
+		loc := alias.Location(addr, alias.UnknownSize)
+		if aa.ModRef(inst, loc).IsMod() {
+			delete(mem.avail, addr)
+		}
+	}
+}
+
+// clear removes all available values.
+func (mem *memState) clear() {
+	mem.avail = make(map[value.Value][]availValue)
+}
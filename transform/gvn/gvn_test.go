@@ -0,0 +1,209 @@
+package gvn
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+)
+
+func TestRun(t *testing.T) {
+	golden := []struct {
+		name string
+		in   string
+		want string
+	}{
+		// Redundant address computation and commutative arithmetic.
+		{
+			name: "gep",
+			in: `
+define i32 @f([4 x i32]* %p, i32 %a, i32 %b) {
+entry:
+	%x = add i32 %a, %b
+	%y = add i32 %b, %a
+	%g1 = getelementptr inbounds [4 x i32], [4 x i32]* %p, i64 0, i64 1
+	%g2 = getelementptr inbounds [4 x i32], [4 x i32]* %p, i64 0, i64 1
+	store i32 %x, i32* %g1
+	store i32 %y, i32* %g2
+	ret i32 %y
+}`,
+			want: `
+define i32 @f([4 x i32]* %p, i32 %a, i32 %b) {
+entry:
+	%x = add i32 %a, %b
+	%g1 = getelementptr inbounds [4 x i32], [4 x i32]* %p, i64 0, i64 1
+	store i32 %x, i32* %g1
+	store i32 %x, i32* %g1
+	ret i32 %x
+}`,
+		},
+		// Redundant loads; loads are forwarded across stores to distinct allocas,
+		// but not across calls which may write memory.
+		{
+			name: "load",
+			in: `
+declare void @g()
+
+define i32 @f(i32* %p) {
+entry:
+	%a = alloca i32
+	%x = load i32, i32* %p
+	store i32 1, i32* %a
+	%y = load i32, i32* %p
+	%z = load i32, i32* %a
+	call void @g()
+	%w = load i32, i32* %p
+	%s1 = add i32 %x, %y
+	%s2 = add i32 %z, %w
+	%s3 = add i32 %s1, %s2
+	ret i32 %s3
+}`,
+			want: `
+define i32 @f(i32* %p) {
+entry:
+	%a = alloca i32
+	%x = load i32, i32* %p
+	store i32 1, i32* %a
+	call void @g()
+	%w = load i32, i32* %p
+	%s1 = add i32 %x, %x
+	%s2 = add i32 1, %w
+	%s3 = add i32 %s1, %s2
+	ret i32 %s3
+}`,
+		},
+		// Loads are forwarded across stores to distinct global variables addressed
+		// by constant expressions, and across calls which only write memory of
+		// their (non-aliasing) arguments.
+		{
+			name: "alias",
+			in: `
+@a = global [2 x i32] zeroinitializer
+@b = global [2 x i32] zeroinitializer
+
+declare void @g(i32*) argmemonly nounwind
+
+define i32 @f() {
+entry:
+	%l = alloca i32
+	%p = getelementptr [2 x i32], [2 x i32]* @a, i64 0, i64 1
+	%x = load i32, i32* %p
+	store i32 1, i32* getelementptr ([2 x i32], [2 x i32]* @b, i64 0, i64 1)
+	call void @g(i32* %l)
+	%y = load i32, i32* %p
+	%s = add i32 %x, %y
+	ret i32 %s
+}`,
+			want: `
+define i32 @f() {
+entry:
+	%l = alloca i32
+	%p = getelementptr [2 x i32], [2 x i32]* @a, i64 0, i64 1
+	%x = load i32, i32* %p
+	store i32 1, i32* getelementptr ([2 x i32], [2 x i32]* @b, i64 0, i64 1)
+	call void @g(i32* %l)
+	%s = add i32 %x, %x
+	ret i32 %s
+}`,
+		},
+		// Expressions are only reused in dominated basic blocks, and loads are not
+		// reused in join blocks.
+		{
+			name: "scope",
+			in: `
+define i32 @f(i1 %c, i32 %a, i32* %p) {
+entry:
+	%x = mul i32 %a, 3
+	%l = load i32, i32* %p
+	br i1 %c, label %then, label %join
+
+then:
+	%y = mul i32 %a, 3
+	%z = sub i32 %y, 1
+	store i32 %z, i32* %p
+	br label %join
+
+join:
+	%w = sub i32 %x, 1
+	%m = load i32, i32* %p
+	%r = add i32 %w, %m
+	ret i32 %r
+}`,
+			want: `
+define i32 @f(i1 %c, i32 %a, i32* %p) {
+entry:
+	%x = mul i32 %a, 3
+	%l = load i32, i32* %p
+	br i1 %c, label %then, label %join
+
+then:
+	%z = sub i32 %x, 1
+	store i32 %z, i32* %p
+	br label %join
+
+join:
+	%w = sub i32 %x, 1
+	%m = load i32, i32* %p
+	%r = add i32 %w, %m
+	ret i32 %r
+}`,
+		},
+		// Loads are not forwarded across invoke terminators which may write
+		// memory.
+		{
+			name: "invoke",
+			in: `
+declare void @clobber(i32*)
+
+declare i32 @personality(...)
+
+define i32 @f(i32* %p) personality i32 (...)* @personality {
+entry:
+	%x = load i32, i32* %p
+	invoke void @clobber(i32* %p)
+		to label %cont unwind label %lp
+
+cont:
+	%y = load i32, i32* %p
+	%s = add i32 %x, %y
+	ret i32 %s
+
+lp:
+	%e = landingpad { i8*, i32 }
+		cleanup
+	ret i32 %x
+}`,
+			want: `
+define i32 @f(i32* %p) personality i32 (...)* @personality {
+entry:
+	%x = load i32, i32* %p
+	invoke void @clobber(i32* %p)
+		to label %cont unwind label %lp
+
+cont:
+	%y = load i32, i32* %p
+	%s = add i32 %x, %y
+	ret i32 %s
+
+lp:
+	%e = landingpad { i8*, i32 }
+		cleanup
+	ret i32 %x
+}`,
+		},
+	}
+	for _, g := range golden {
+		m, err := asm.ParseString(g.name+".ll", g.in)
+		if err != nil {
+			t.Errorf("%q: unable to parse module; %+v", g.name, err)
+			continue
+		}
+		f := m.Funcs[len(m.Funcs)-1]
+		Run(f, nil)
+		got := strings.TrimSpace(f.LLString())
+		want := strings.TrimSpace(g.want)
+		if got != want {
+			t.Errorf("%q: function mismatch; expected:\n%s\n\ngot:\n%s", g.name, want, got)
+		}
+	}
+}
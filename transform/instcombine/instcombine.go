@@ -0,0 +1,359 @@
+// Package instcombine implements a peephole combiner of LLVM IR instructions,
+// which simplifies instructions using algebraic identities, strength reduction,
+// constant folding and canonicalization.
+//
+// The combiner is driven by a worklist of instructions, initially containing
+// every instruction of the function in program order. Each instruction taken
+// from the worklist is matched against the rules of the combiner, in order. The
+// first rule which applies either returns a value replacing the instruction, or
+// modifies the instruction in place. In both cases the users of the instruction
+// are added to the worklist, as they may now be simplified further.
+// Instructions without uses and side effects are removed, and their operands
+// are added to the worklist.
+//
+// The rules of the combiner are extensible; custom rules may be added to a
+// combiner alongside (or instead of) the default rules of the package.
+package instcombine
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/value"
+	"github.com/llir/llvm/irutil"
+)
+
+// Run simplifies the instructions of the given function using the default
+// rules, and reports whether the function was changed.
+func Run(f *ir.Func) bool {
+	return New(DefaultRules()...).Run(f)
+}
+
+// RunModule simplifies the instructions of the function definitions of the
+// given module using the default rules, and reports whether the module was
+// changed.
+func RunModule(m *ir.Module) bool {
+	c := New(DefaultRules()...)
+	changed := false
+	for _, f := range m.Funcs {
+		if c.Run(f) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// --- [ Rules ] ---------------------------------------------------------------
+
+// Rule is a rewrite rule of the combiner.
+type Rule interface {
+	// Combine simplifies the given instruction. It returns a value equivalent to
+	// inst which replaces all uses of inst, or inst itself if the instruction was
+	// modified in place. A nil value is returned if the rule does not apply.
+	//
+	// Combine must only return inst if the instruction was changed, as the
+	// instruction is otherwise revisited indefinitely.
+	Combine(c *Context, inst ir.Instruction) value.Value
+}
+
+// RuleFunc is a rewrite rule implemented by a function.
+type RuleFunc func(c *Context, inst ir.Instruction) value.Value
+
+// Combine simplifies the given instruction; see Rule.Combine.
+func (f RuleFunc) Combine(c *Context, inst ir.Instruction) value.Value {
+	return f(c, inst)
+}
+
+// --- [ Combiner ] ------------------------------------------------------------
+
+// Combiner is a worklist-driven peephole combiner of instructions.
+type Combiner struct {
+	// Rewrite rules, in order of application.
+	rules []Rule
+}
+
+// New returns a new combiner with the given rewrite rules, which are applied
+// in order.
+func New(rules ...Rule) *Combiner {
+	return &Combiner{rules: rules}
+}
+
+// AddRule appends the given rewrite rule to the rules of the combiner.
+func (cb *Combiner) AddRule(rule Rule) {
+	cb.rules = append(cb.rules, rule)
+}
+
+// Run simplifies the instructions of the given function until no rule applies,
+// and reports whether the function was changed.
+func (cb *Combiner) Run(f *ir.Func) bool {
+	if len(f.Blocks) == 0 {
+		return false
+	}
+	c := &Context{
+		f:      f,
+		users:  make(map[value.Value][]value.User),
+		before: make(map[ir.Instruction][]ir.Instruction),
+		added:  make(map[ir.Instruction]bool),
+		dead:   make(map[ir.Instruction]bool),
+		queued: make(map[ir.Instruction]bool),
+	}
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			c.addUser(inst)
+		}
+		if block.Term != nil {
+			c.addUser(block.Term)
+		}
+	}
+	// Push instructions in reverse, so that they are visited in program order.
+	for i := len(f.Blocks) - 1; i >= 0; i-- {
+		insts := f.Blocks[i].Insts
+		for j := len(insts) - 1; j >= 0; j-- {
+			c.push(insts[j])
+		}
+	}
+	changed := false
+	for len(c.worklist) > 0 {
+		inst := c.pop()
+		if c.dead[inst] {
+			continue
+		}
+		if !c.isUsed(inst) && irutil.IsTriviallyDead(inst) {
+			c.remove(inst)
+			changed = true
+			continue
+		}
+		if cb.combine(c, inst) {
+			changed = true
+		}
+	}
+	if !changed {
+		return false
+	}
+	c.rebuild()
+	irutil.ResetLocalIDs(f)
+	return true
+}
+
+// combine applies the first matching rule of the combiner to the given
+// instruction, and reports whether a rule applied.
+func (cb *Combiner) combine(c *Context, inst ir.Instruction) bool {
+	// Record operands before applying rules, as they may be replaced in place.
+	var ops []value.Value
+	if user, ok := inst.(value.User); ok {
+		for _, op := range user.Operands() {
+			ops = append(ops, irutil.Unwrap(*op))
+		}
+	}
+	c.cur = inst
+	defer func() { c.cur = nil }()
+	for _, rule := range cb.rules {
+		v := rule.Combine(c, inst)
+		if v == nil {
+			continue
+		}
+		if x, ok := v.(ir.Instruction); ok && x == inst {
+			// Modified in place; revisit the instruction and its users, and
+			// former operands which may no longer be used.
+			c.addUser(inst)
+			c.push(inst)
+			if x, ok := inst.(value.Value); ok {
+				c.pushUsers(x)
+			}
+			c.pushValues(ops)
+		} else if x, ok := inst.(value.Value); ok {
+			c.replace(inst, x, v)
+		}
+		return true
+	}
+	return false
+}
+
+// --- [ Context ] -------------------------------------------------------------
+
+// Context is the state of the combiner while combining the instructions of a
+// function.
+type Context struct {
+	// Function being combined.
+	f *ir.Func
+	// Instruction currently being combined.
+	cur ir.Instruction
+	// Users of each instruction; may contain stale or duplicate entries.
+	users map[value.Value][]value.User
+	// Instructions inserted before each instruction.
+	before map[ir.Instruction][]ir.Instruction
+	// Instructions inserted by rules.
+	added map[ir.Instruction]bool
+	// Removed instructions.
+	dead map[ir.Instruction]bool
+	// Worklist of instructions to combine.
+	worklist []ir.Instruction
+	// Instructions present in the worklist.
+	queued map[ir.Instruction]bool
+}
+
+// Func returns the function being combined.
+func (c *Context) Func() *ir.Func {
+	return c.f
+}
+
+// Insert inserts the given new instruction before the instruction currently
+// being combined, and adds it to the worklist. Rules combining phi
+// instructions must not insert instructions.
+func (c *Context) Insert(inst ir.Instruction) {
+	c.before[c.cur] = append(c.before[c.cur], inst)
+	c.added[inst] = true
+	c.addUser(inst)
+	c.push(inst)
+}
+
+// NumUses returns the number of uses of the given instruction.
+func (c *Context) NumUses(v value.Value) int {
+	n := 0
+	for _, user := range c.users[v] {
+		if inst, ok := user.(ir.Instruction); ok && c.dead[inst] {
+			continue
+		}
+		for _, op := range user.Operands() {
+			if irutil.Unwrap(*op) == v {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// isUsed reports whether the given instruction has uses.
+func (c *Context) isUsed(inst ir.Instruction) bool {
+	if v, ok := inst.(value.Value); ok {
+		return c.NumUses(v) > 0
+	}
+	return false
+}
+
+// addUser records user as a user of its instruction operands.
+func (c *Context) addUser(user interface{}) {
+	u, ok := user.(value.User)
+	if !ok {
+		return
+	}
+	seen := make(map[value.Value]bool)
+	for _, op := range u.Operands() {
+		v := irutil.Unwrap(*op)
+		if _, ok := v.(ir.Instruction); !ok || seen[v] {
+			continue
+		}
+		seen[v] = true
+		if !containsUser(c.users[v], u) {
+			c.users[v] = append(c.users[v], u)
+		}
+	}
+}
+
+// replace replaces all uses of inst (with value old) with v, and removes inst if
+// it has no side effects.
+func (c *Context) replace(inst ir.Instruction, old, v value.Value) {
+	repl := map[value.Value]value.Value{old: v}
+	for _, user := range c.users[old] {
+		if u, ok := user.(ir.Instruction); ok && c.dead[u] {
+			continue
+		}
+		irutil.ReplaceOperands(user, repl)
+		c.addUser(user)
+		if u, ok := user.(ir.Instruction); ok {
+			c.push(u)
+		}
+	}
+	delete(c.users, old)
+	if !irutil.IsTriviallyDead(inst) {
+		return
+	}
+	// Retain the name of the replaced instruction for new instructions.
+	if newInst, ok := v.(ir.Instruction); ok && c.added[newInst] {
+		if name := irutil.LocalName(inst); len(name) > 0 && len(irutil.LocalName(newInst)) == 0 {
+			if named, ok := newInst.(value.Named); ok {
+				named.SetName(name)
+			}
+		}
+	}
+	c.remove(inst)
+}
+
+// remove removes the given instruction, and adds its operands to the worklist.
+func (c *Context) remove(inst ir.Instruction) {
+	c.dead[inst] = true
+	if user, ok := inst.(value.User); ok {
+		for _, op := range user.Operands() {
+			if v, ok := irutil.Unwrap(*op).(ir.Instruction); ok {
+				c.push(v)
+			}
+		}
+	}
+}
+
+// push adds the given instruction to the worklist.
+func (c *Context) push(inst ir.Instruction) {
+	if c.dead[inst] || c.queued[inst] {
+		return
+	}
+	c.queued[inst] = true
+	c.worklist = append(c.worklist, inst)
+}
+
+// pushUsers adds the users of the given value to the worklist.
+func (c *Context) pushUsers(v value.Value) {
+	for _, user := range c.users[v] {
+		if u, ok := user.(ir.Instruction); ok {
+			c.push(u)
+		}
+	}
+}
+
+// pushValues adds the instructions among the given values to the worklist.
+func (c *Context) pushValues(vs []value.Value) {
+	for _, v := range vs {
+		if inst, ok := v.(ir.Instruction); ok {
+			c.push(inst)
+		}
+	}
+}
+
+// pop removes and returns the last instruction of the worklist.
+func (c *Context) pop() ir.Instruction {
+	n := len(c.worklist) - 1
+	inst := c.worklist[n]
+	c.worklist = c.worklist[:n]
+	delete(c.queued, inst)
+	return inst
+}
+
+// rebuild updates the instructions of each basic block of the function,
+// inserting new instructions and removing dead instructions.
+func (c *Context) rebuild() {
+	for _, block := range c.f.Blocks {
+		var insts []ir.Instruction
+		var emit func(inst ir.Instruction)
+		emit = func(inst ir.Instruction) {
+			for _, b := range c.before[inst] {
+				emit(b)
+			}
+			if !c.dead[inst] {
+				insts = append(insts, inst)
+			}
+		}
+		for _, inst := range block.Insts {
+			emit(inst)
+		}
+		block.Insts = insts
+	}
+}
+
+// ### [ Helper functions ] ####################################################
+
+// containsUser reports whether users contains the given user.
+func containsUser(users []value.User, user value.User) bool {
+	for _, u := range users {
+		if u == user {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,236 @@
+package instcombine
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/value"
+	"github.com/llir/llvm/irutil"
+)
+
+func TestRun(t *testing.T) {
+	golden := []struct {
+		name string
+		in   string
+		want string
+	}{
+		// Algebraic identities.
+		{
+			name: "identities",
+			in: `
+define i32 @identities(i32 %x, i32 %y) {
+	%1 = add i32 %x, 0
+	%2 = mul i32 1, %1
+	%3 = xor i32 %y, %y
+	%4 = or i32 %2, %3
+	%5 = sub i32 %4, %4
+	%6 = add i32 %4, %5
+	ret i32 %6
+}`,
+			want: `
+define i32 @identities(i32 %x, i32 %y) {
+0:
+	ret i32 %x
+}`,
+		},
+		// Strength reduction of multiplications and divisions by powers of two.
+		{
+			name: "strength",
+			in: `
+define i32 @strength(i32 %x, i32 %y) {
+	%a = mul nuw nsw i32 %x, 8
+	%b = udiv exact i32 %a, 4
+	%c = sdiv exact i32 %y, 16
+	%d = urem i32 %c, 32
+	%e = mul nsw i32 %d, -2147483648
+	%f = add i32 %b, %e
+	ret i32 %f
+}`,
+			want: `
+define i32 @strength(i32 %x, i32 %y) {
+0:
+	%a = shl nuw nsw i32 %x, 3
+	%b = lshr exact i32 %a, 2
+	%c = ashr exact i32 %y, 4
+	%d = and i32 %c, 31
+	%e = shl i32 %d, 31
+	%f = add i32 %b, %e
+	ret i32 %f
+}`,
+		},
+		// Casts of casts.
+		{
+			name: "casts",
+			in: `
+define i64 @casts(i64 %x, i8 %y) {
+	%t = trunc i64 %x to i32
+	%z = zext i32 %t to i64
+	%a = zext i8 %y to i16
+	%b = zext i16 %a to i64
+	%s = sext i8 %y to i32
+	%u = trunc i32 %s to i16
+	%v = sext i16 %u to i64
+	%r1 = add i64 %z, %b
+	%r2 = add i64 %r1, %v
+	ret i64 %r2
+}`,
+			want: `
+define i64 @casts(i64 %x, i8 %y) {
+0:
+	%z = and i64 %x, u0xFFFFFFFF
+	%b = zext i8 %y to i64
+	%v = sext i8 %y to i64
+	%r1 = add i64 %z, %b
+	%r2 = add i64 %r1, %v
+	ret i64 %r2
+}`,
+		},
+		// Canonicalization and simplification of integer comparisons.
+		{
+			name: "icmps",
+			in: `
+define i1 @icmps(i32 %x, i32 %y) {
+	%a = icmp sgt i32 10, %x
+	%b = icmp sge i32 %y, 5
+	%c = icmp ule i32 %x, 7
+	%d = icmp eq i32 %x, %x
+	%e = and i1 %a, %b
+	%f = and i1 %e, %c
+	%g = and i1 %f, %d
+	%h = icmp eq i1 %g, true
+	ret i1 %h
+}`,
+			want: `
+define i1 @icmps(i32 %x, i32 %y) {
+0:
+	%a = icmp slt i32 %x, 10
+	%b = icmp sgt i32 %y, 4
+	%c = icmp ult i32 %x, 8
+	%e = and i1 %a, %b
+	%f = and i1 %e, %c
+	ret i1 %f
+}`,
+		},
+		// Selects of constants.
+		{
+			name: "selects",
+			in: `
+define i32 @selects(i1 %c, i1 %d) {
+	%a = select i1 %c, i32 1, i32 0
+	%b = select i1 %d, i32 0, i32 -1
+	%n = select i1 %c, i1 false, i1 true
+	%k = select i1 true, i32 %a, i32 %b
+	%m = select i1 %n, i32 %k, i32 %b
+	ret i32 %m
+}`,
+			want: `
+define i32 @selects(i1 %c, i1 %d) {
+0:
+	%a = zext i1 %c to i32
+	%1 = xor i1 %d, true
+	%b = sext i1 %1 to i32
+	%n = xor i1 %c, true
+	%m = select i1 %n, i32 %a, i32 %b
+	ret i32 %m
+}`,
+		},
+		// Fast-math flags; fadd x, 0.0 is only simplified with nsz.
+		{
+			name: "fastmath",
+			in: `
+define double @fast(double %x) {
+	%a = fadd double %x, -0.0
+	%b = fadd double %a, 0.0
+	%c = fadd nsz double %b, 0.0
+	%d = fmul double 1.0, %c
+	%e = fsub double %d, 0.0
+	ret double %e
+}`,
+			want: `
+define double @fast(double %x) {
+0:
+	%b = fadd double %x, 0.0
+	ret double %b
+}`,
+		},
+		// Reassociation of constants, dropping overflow flags.
+		{
+			name: "reassoc",
+			in: `
+define i32 @reassoc(i32 %x) {
+	%a = add nuw nsw i32 %x, 3
+	%b = add nuw i32 %a, 4
+	%c = sub i32 %b, 2
+	%d = and i32 %c, 255
+	%e = and i32 %d, 15
+	%f = add i32 2, 3
+	%g = mul i32 %e, %f
+	ret i32 %g
+}`,
+			want: `
+define i32 @reassoc(i32 %x) {
+0:
+	%c = add i32 %x, 5
+	%e = and i32 %c, 15
+	%g = mul i32 %e, 5
+	ret i32 %g
+}`,
+		},
+	}
+	for _, g := range golden {
+		m, err := asm.ParseString(g.name+".ll", g.in)
+		if err != nil {
+			t.Errorf("%q: unable to parse module; %+v", g.name, err)
+			continue
+		}
+		f := m.Funcs[len(m.Funcs)-1]
+		Run(f)
+		got := strings.TrimSpace(f.LLString())
+		want := strings.TrimSpace(g.want)
+		if got != want {
+			t.Errorf("%q: function mismatch; expected:\n%s\n\ngot:\n%s", g.name, want, got)
+		}
+	}
+}
+
+func TestAddRule(t *testing.T) {
+	const in = `
+declare i32 @id(i32) nounwind readnone willreturn
+
+define i32 @f(i32 %x) {
+	%a = call i32 @id(i32 %x)
+	%b = mul i32 %a, 4
+	ret i32 %b
+}`
+	const want = `
+define i32 @f(i32 %x) {
+0:
+	%b = shl i32 %x, 2
+	ret i32 %b
+}`
+	m, err := asm.ParseString("custom.ll", in)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	// Replace calls to the identity function by their argument.
+	id := RuleFunc(func(c *Context, inst ir.Instruction) value.Value {
+		if call, ok := inst.(*ir.InstCall); ok {
+			if callee := irutil.Callee(call.Callee); callee != nil && callee.Name() == "id" {
+				return call.Args[0]
+			}
+		}
+		return nil
+	})
+	c := New(DefaultRules()...)
+	c.AddRule(id)
+	f := m.Funcs[len(m.Funcs)-1]
+	if !c.Run(f) {
+		t.Fatalf("expected function to be changed")
+	}
+	got := strings.TrimSpace(f.LLString())
+	if got != strings.TrimSpace(want) {
+		t.Errorf("function mismatch; expected:\n%s\n\ngot:\n%s", strings.TrimSpace(want), got)
+	}
+}
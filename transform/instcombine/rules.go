@@ -0,0 +1,679 @@
+package instcombine
+
+import (
+	"math/big"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/llir/llvm/irutil"
+)
+
+// DefaultRules returns the default rewrite rules of the combiner, in order of
+// application.
+func DefaultRules() []Rule {
+	return []Rule{
+		RuleFunc(FoldConstants),
+		RuleFunc(Canonicalize),
+		RuleFunc(Simplify),
+		RuleFunc(StrengthReduce),
+		RuleFunc(CombineCasts),
+		RuleFunc(CombineSelects),
+		RuleFunc(Reassociate),
+	}
+}
+
+// --- [ Constant folding ] ----------------------------------------------------
+
+// FoldConstants replaces instructions with constant operands by their constant
+// result.
+func FoldConstants(c *Context, inst ir.Instruction) value.Value {
+	if v := irutil.FoldInst(inst); v != nil {
+		return v
+	}
+	return nil
+}
+
+// --- [ Canonicalization ] ----------------------------------------------------
+
+// Canonicalize rewrites instructions in place to a canonical form, which
+// simplifies the matching of other rules:
+//
+//	add C, x       ->  add x, C          (likewise for other commutative ops)
+//	icmp sgt C, x  ->  icmp slt x, C     (constant operand on the right)
+//	icmp sge x, C  ->  icmp sgt x, C-1   (strict predicates)
+//	icmp ule x, C  ->  icmp ult x, C+1
+func Canonicalize(c *Context, inst ir.Instruction) value.Value {
+	switch inst := inst.(type) {
+	case *ir.InstAdd:
+		if swapOperands(&inst.X, &inst.Y) {
+			return inst
+		}
+	case *ir.InstMul:
+		if swapOperands(&inst.X, &inst.Y) {
+			return inst
+		}
+	case *ir.InstAnd:
+		if swapOperands(&inst.X, &inst.Y) {
+			return inst
+		}
+	case *ir.InstOr:
+		if swapOperands(&inst.X, &inst.Y) {
+			return inst
+		}
+	case *ir.InstXor:
+		if swapOperands(&inst.X, &inst.Y) {
+			return inst
+		}
+	case *ir.InstFAdd:
+		if swapOperands(&inst.X, &inst.Y) {
+			return inst
+		}
+	case *ir.InstFMul:
+		if swapOperands(&inst.X, &inst.Y) {
+			return inst
+		}
+	case *ir.InstICmp:
+		if swapOperands(&inst.X, &inst.Y) {
+			inst.Pred = irutil.SwapIPred(inst.Pred)
+			return inst
+		}
+		y, ok := inst.Y.(*constant.Int)
+		if !ok {
+			return nil
+		}
+		one := big.NewInt(1)
+		switch inst.Pred {
+		case enum.IPredSGE:
+			if !isMinSigned(y) {
+				inst.Pred, inst.Y = enum.IPredSGT, irutil.NewIntValue(y.Typ, new(big.Int).Sub(irutil.Signed(y), one))
+				return inst
+			}
+		case enum.IPredSLE:
+			if !isMaxSigned(y) {
+				inst.Pred, inst.Y = enum.IPredSLT, irutil.NewIntValue(y.Typ, new(big.Int).Add(irutil.Signed(y), one))
+				return inst
+			}
+		case enum.IPredUGE:
+			if irutil.Unsigned(y).Sign() != 0 {
+				inst.Pred, inst.Y = enum.IPredUGT, irutil.NewIntValue(y.Typ, new(big.Int).Sub(irutil.Unsigned(y), one))
+				return inst
+			}
+		case enum.IPredULE:
+			if !isInt(y, -1) {
+				inst.Pred, inst.Y = enum.IPredULT, irutil.NewIntValue(y.Typ, new(big.Int).Add(irutil.Unsigned(y), one))
+				return inst
+			}
+		}
+	}
+	return nil
+}
+
+// --- [ Algebraic identities ] ------------------------------------------------
+
+// Simplify replaces instructions by an existing value using algebraic
+// identities, without creating new instructions:
+//
+//	x + 0, x - 0, x * 1, x / 1, x << 0, x | 0, x ^ 0, x & -1  ->  x
+//	x - x, x ^ x, x * 0, x & 0, x % 1                         ->  0
+//	x & x, x | x                                              ->  x
+//	fadd x, -0.0, fsub x, 0.0, fmul x, 1.0, fdiv x, 1.0       ->  x
+//	fadd nsz x, 0.0, fsub nsz x, -0.0                         ->  x
+//	select c, x, x, select true, x, y                         ->  x
+//	icmp eq x, x, icmp uge x, 0                               ->  true
+//	trunc (zext x), trunc (sext x) of the type of x           ->  x
+//	phi [x, %a], [x, %b]                                      ->  x
+func Simplify(c *Context, inst ir.Instruction) value.Value {
+	switch inst := inst.(type) {
+	// Binary instructions.
+	case *ir.InstAdd:
+		if isInt(inst.Y, 0) {
+			return inst.X
+		}
+	case *ir.InstSub:
+		if isInt(inst.Y, 0) {
+			return inst.X
+		}
+		if inst.X == inst.Y {
+			return zero(inst.Type())
+		}
+	case *ir.InstMul:
+		if isInt(inst.Y, 1) {
+			return inst.X
+		}
+		if isInt(inst.Y, 0) {
+			return inst.Y
+		}
+	case *ir.InstUDiv:
+		if isInt(inst.Y, 1) {
+			return inst.X
+		}
+	case *ir.InstSDiv:
+		if isInt(inst.Y, 1) {
+			return inst.X
+		}
+	case *ir.InstURem:
+		if isInt(inst.Y, 1) {
+			return zero(inst.Type())
+		}
+	case *ir.InstSRem:
+		if isInt(inst.Y, 1) || isInt(inst.Y, -1) {
+			return zero(inst.Type())
+		}
+	case *ir.InstFAdd:
+		if isFloat(inst.Y, 0, true) || isFloat(inst.Y, 0, false) && hasFastMath(inst.FastMathFlags, enum.FastMathFlagNSZ) {
+			return inst.X
+		}
+	case *ir.InstFSub:
+		if isFloat(inst.Y, 0, false) || isFloat(inst.Y, 0, true) && hasFastMath(inst.FastMathFlags, enum.FastMathFlagNSZ) {
+			return inst.X
+		}
+	case *ir.InstFMul:
+		if isFloat(inst.Y, 1, false) {
+			return inst.X
+		}
+	case *ir.InstFDiv:
+		if isFloat(inst.Y, 1, false) {
+			return inst.X
+		}
+	// Bitwise instructions.
+	case *ir.InstShl:
+		if isInt(inst.Y, 0) || isInt(inst.X, 0) {
+			return inst.X
+		}
+	case *ir.InstLShr:
+		if isInt(inst.Y, 0) || isInt(inst.X, 0) {
+			return inst.X
+		}
+	case *ir.InstAShr:
+		if isInt(inst.Y, 0) || isInt(inst.X, 0) {
+			return inst.X
+		}
+	case *ir.InstAnd:
+		if isInt(inst.Y, 0) {
+			return inst.Y
+		}
+		if isInt(inst.Y, -1) || inst.X == inst.Y {
+			return inst.X
+		}
+	case *ir.InstOr:
+		if isInt(inst.Y, -1) {
+			return inst.Y
+		}
+		if isInt(inst.Y, 0) || inst.X == inst.Y {
+			return inst.X
+		}
+	case *ir.InstXor:
+		if isInt(inst.Y, 0) {
+			return inst.X
+		}
+		if inst.X == inst.Y {
+			return zero(inst.Type())
+		}
+	// Conversion instructions.
+	case *ir.InstTrunc:
+		switch from := inst.From.(type) {
+		case *ir.InstZExt:
+			if from.From.Type().Equal(inst.To) {
+				return from.From
+			}
+		case *ir.InstSExt:
+			if from.From.Type().Equal(inst.To) {
+				return from.From
+			}
+		}
+	case *ir.InstBitCast:
+		if inst.From.Type().Equal(inst.To) {
+			return inst.From
+		}
+		if from, ok := inst.From.(*ir.InstBitCast); ok && from.From.Type().Equal(inst.To) {
+			return from.From
+		}
+	// Other instructions.
+	case *ir.InstICmp:
+		return simplifyICmp(inst)
+	case *ir.InstSelect:
+		if inst.ValueTrue == inst.ValueFalse {
+			return inst.ValueTrue
+		}
+		if cond, ok := inst.Cond.(*constant.Int); ok {
+			if cond.X.Sign() != 0 {
+				return inst.ValueTrue
+			}
+			return inst.ValueFalse
+		}
+		if isBool(inst.Type()) && isInt(inst.ValueTrue, 1) && isInt(inst.ValueFalse, 0) {
+			return inst.Cond
+		}
+	case *ir.InstPhi:
+		// Phi instructions with identical incoming values, ignoring references
+		// to the phi instruction itself.
+		var x value.Value
+		for _, inc := range inst.Incs {
+			if inc.X == inst || inc.X == x {
+				continue
+			}
+			if x != nil {
+				return nil
+			}
+			x = inc.X
+		}
+		return x
+	}
+	return nil
+}
+
+// simplifyICmp simplifies the given integer comparison instruction.
+func simplifyICmp(inst *ir.InstICmp) value.Value {
+	if _, ok := inst.Type().(*types.IntType); !ok {
+		// Vector comparisons.
+		return nil
+	}
+	if inst.X == inst.Y {
+		switch inst.Pred {
+		case enum.IPredEQ, enum.IPredUGE, enum.IPredULE, enum.IPredSGE, enum.IPredSLE:
+			return constant.True
+		default:
+			return constant.False
+		}
+	}
+	y, ok := inst.Y.(*constant.Int)
+	if !ok {
+		return nil
+	}
+	switch {
+	case inst.Pred == enum.IPredULT && isInt(y, 0), inst.Pred == enum.IPredUGT && isInt(y, -1):
+		return constant.False
+	case inst.Pred == enum.IPredUGE && isInt(y, 0), inst.Pred == enum.IPredULE && isInt(y, -1):
+		return constant.True
+	case isBool(y.Typ) && (inst.Pred == enum.IPredEQ && isInt(y, 1) || inst.Pred == enum.IPredNE && isInt(y, 0)):
+		// icmp eq i1 x, true
+		return inst.X
+	}
+	return nil
+}
+
+// --- [ Strength reduction ] --------------------------------------------------
+
+// StrengthReduce replaces multiplications and divisions by powers of two with
+// shifts, retaining the flags of the instructions where valid:
+//
+//	mul x, 2^k        ->  shl x, k         (nuw retained; nsw if k < n-1)
+//	udiv x, 2^k       ->  lshr x, k        (exact retained)
+//	sdiv exact x, 2^k ->  ashr exact x, k
+//	urem x, 2^k       ->  and x, 2^k-1
+//	sub x, C          ->  add x, -C        (nsw retained if C != INT_MIN)
+func StrengthReduce(c *Context, inst ir.Instruction) value.Value {
+	switch inst := inst.(type) {
+	case *ir.InstMul:
+		y, k, ok := powerOf2(inst.Y)
+		if !ok {
+			return nil
+		}
+		shl := ir.NewShl(inst.X, constant.NewInt(y.Typ, int64(k)))
+		if hasOverflowFlag(inst.OverflowFlags, enum.OverflowFlagNUW) {
+			shl.OverflowFlags = append(shl.OverflowFlags, enum.OverflowFlagNUW)
+		}
+		if hasOverflowFlag(inst.OverflowFlags, enum.OverflowFlagNSW) && k < y.Typ.BitSize-1 {
+			shl.OverflowFlags = append(shl.OverflowFlags, enum.OverflowFlagNSW)
+		}
+		c.Insert(shl)
+		return shl
+	case *ir.InstUDiv:
+		y, k, ok := powerOf2(inst.Y)
+		if !ok {
+			return nil
+		}
+		lshr := ir.NewLShr(inst.X, constant.NewInt(y.Typ, int64(k)))
+		lshr.Exact = inst.Exact
+		c.Insert(lshr)
+		return lshr
+	case *ir.InstSDiv:
+		y, k, ok := powerOf2(inst.Y)
+		if !ok || !inst.Exact || k == y.Typ.BitSize-1 {
+			// Division by INT_MIN is not a shift.
+			return nil
+		}
+		ashr := ir.NewAShr(inst.X, constant.NewInt(y.Typ, int64(k)))
+		ashr.Exact = true
+		c.Insert(ashr)
+		return ashr
+	case *ir.InstURem:
+		y, _, ok := powerOf2(inst.Y)
+		if !ok {
+			return nil
+		}
+		mask := irutil.NewIntValue(y.Typ, new(big.Int).Sub(irutil.Unsigned(y), big.NewInt(1)))
+		and := ir.NewAnd(inst.X, mask)
+		c.Insert(and)
+		return and
+	case *ir.InstSub:
+		y, ok := inst.Y.(*constant.Int)
+		if !ok {
+			return nil
+		}
+		add := ir.NewAdd(inst.X, irutil.NewIntValue(y.Typ, new(big.Int).Neg(irutil.Signed(y))))
+		if hasOverflowFlag(inst.OverflowFlags, enum.OverflowFlagNSW) && !isMinSigned(y) {
+			add.OverflowFlags = []enum.OverflowFlag{enum.OverflowFlagNSW}
+		}
+		c.Insert(add)
+		return add
+	}
+	return nil
+}
+
+// --- [ Casts ] ---------------------------------------------------------------
+
+// CombineCasts combines casts of casts:
+//
+//	zext (trunc x to T) to U  ->  and x, 2^n-1         (x of type U, T of n bits)
+//	zext (zext x)             ->  zext x
+//	sext (sext x)             ->  sext x
+//	sext (zext x)             ->  zext x
+//	trunc (trunc x)           ->  trunc x
+//	trunc (zext x)            ->  zext x or trunc x    (likewise for sext)
+//	bitcast (bitcast x)       ->  bitcast x
+func CombineCasts(c *Context, inst ir.Instruction) value.Value {
+	switch inst := inst.(type) {
+	case *ir.InstZExt:
+		switch from := inst.From.(type) {
+		case *ir.InstTrunc:
+			t, ok := from.To.(*types.IntType)
+			if !ok || !from.From.Type().Equal(inst.To) {
+				return nil
+			}
+			to := inst.To.(*types.IntType)
+			mask := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(t.BitSize)), big.NewInt(1))
+			and := ir.NewAnd(from.From, irutil.NewIntValue(to, mask))
+			c.Insert(and)
+			return and
+		case *ir.InstZExt:
+			zext := ir.NewZExt(from.From, inst.To)
+			c.Insert(zext)
+			return zext
+		}
+	case *ir.InstSExt:
+		switch from := inst.From.(type) {
+		case *ir.InstSExt:
+			sext := ir.NewSExt(from.From, inst.To)
+			c.Insert(sext)
+			return sext
+		case *ir.InstZExt:
+			zext := ir.NewZExt(from.From, inst.To)
+			c.Insert(zext)
+			return zext
+		}
+	case *ir.InstTrunc:
+		var x value.Value
+		var signed bool
+		switch from := inst.From.(type) {
+		case *ir.InstTrunc:
+			x = from.From
+		case *ir.InstZExt:
+			x = from.From
+		case *ir.InstSExt:
+			x, signed = from.From, true
+		default:
+			return nil
+		}
+		n, m := bitSize(x.Type()), bitSize(inst.To)
+		var v instValue
+		switch {
+		case n == 0 || m == 0 || n == m:
+			// Vector casts, or handled by Simplify.
+			return nil
+		case n > m:
+			v = ir.NewTrunc(x, inst.To)
+		case signed:
+			v = ir.NewSExt(x, inst.To)
+		default:
+			v = ir.NewZExt(x, inst.To)
+		}
+		c.Insert(v)
+		return v
+	case *ir.InstBitCast:
+		if from, ok := inst.From.(*ir.InstBitCast); ok {
+			bitcast := ir.NewBitCast(from.From, inst.To)
+			c.Insert(bitcast)
+			return bitcast
+		}
+	}
+	return nil
+}
+
+// --- [ Selects ] -------------------------------------------------------------
+
+// CombineSelects replaces selects of constants by casts of the condition:
+//
+//	select i1 c, i1 false, i1 true  ->  xor c, true
+//	select i1 c, iN 1, iN 0         ->  zext c
+//	select i1 c, iN -1, iN 0        ->  sext c
+//	select i1 c, iN 0, iN 1         ->  zext (xor c, true)
+//	select i1 c, iN 0, iN -1        ->  sext (xor c, true)
+func CombineSelects(c *Context, inst ir.Instruction) value.Value {
+	sel, ok := inst.(*ir.InstSelect)
+	if !ok || !isBool(sel.Cond.Type()) {
+		return nil
+	}
+	t, ok := sel.Type().(*types.IntType)
+	if !ok {
+		return nil
+	}
+	cond := sel.Cond
+	x, y := sel.ValueTrue, sel.ValueFalse
+	if isInt(x, 0) && !isInt(y, 0) {
+		// Invert the condition.
+		not := ir.NewXor(cond, constant.True)
+		if isBool(t) {
+			if isInt(y, 1) {
+				c.Insert(not)
+				return not
+			}
+			return nil
+		}
+		if !isInt(y, 1) && !isInt(y, -1) {
+			return nil
+		}
+		c.Insert(not)
+		cond, x, y = not, y, x
+	}
+	if isBool(t) || !isInt(y, 0) {
+		return nil
+	}
+	var v instValue
+	switch {
+	case isInt(x, 1):
+		v = ir.NewZExt(cond, t)
+	case isInt(x, -1):
+		v = ir.NewSExt(cond, t)
+	default:
+		return nil
+	}
+	c.Insert(v)
+	return v
+}
+
+// --- [ Reassociation ] -------------------------------------------------------
+
+// Reassociate folds the constants of nested associative operations:
+//
+//	(x + C1) + C2  ->  x + (C1 + C2)   (likewise for mul, and, or and xor)
+//
+// The nsw flag is dropped, and the nuw flag retained only if present on both
+// instructions and the constants do not overflow.
+func Reassociate(c *Context, inst ir.Instruction) value.Value {
+	switch inst := inst.(type) {
+	case *ir.InstAdd:
+		if inner, ok := inst.X.(*ir.InstAdd); ok {
+			sum := ir.NewAdd(inner.Y, inst.Y)
+			flags := reassociateFlags(sum, inner.OverflowFlags, inst.OverflowFlags)
+			if y := irutil.FoldInst(sum); y != nil {
+				inst.X, inst.Y, inst.OverflowFlags = inner.X, y, flags
+				return inst
+			}
+		}
+	case *ir.InstMul:
+		if inner, ok := inst.X.(*ir.InstMul); ok {
+			prod := ir.NewMul(inner.Y, inst.Y)
+			flags := reassociateFlags(prod, inner.OverflowFlags, inst.OverflowFlags)
+			if y := irutil.FoldInst(prod); y != nil {
+				inst.X, inst.Y, inst.OverflowFlags = inner.X, y, flags
+				return inst
+			}
+		}
+	case *ir.InstAnd:
+		if inner, ok := inst.X.(*ir.InstAnd); ok {
+			if y := irutil.FoldInst(ir.NewAnd(inner.Y, inst.Y)); y != nil {
+				inst.X, inst.Y = inner.X, y
+				return inst
+			}
+		}
+	case *ir.InstOr:
+		if inner, ok := inst.X.(*ir.InstOr); ok {
+			if y := irutil.FoldInst(ir.NewOr(inner.Y, inst.Y)); y != nil {
+				inst.X, inst.Y = inner.X, y
+				return inst
+			}
+		}
+	case *ir.InstXor:
+		if inner, ok := inst.X.(*ir.InstXor); ok {
+			if y := irutil.FoldInst(ir.NewXor(inner.Y, inst.Y)); y != nil {
+				inst.X, inst.Y = inner.X, y
+				return inst
+			}
+		}
+	}
+	return nil
+}
+
+// reassociateFlags returns the overflow flags of a reassociated add or mul
+// instruction, given the flags of the inner and outer instruction. The nuw flag
+// is set on the folding instruction inst of the constants if retained, so that
+// folding fails on unsigned overflow.
+func reassociateFlags(inst ir.Instruction, inner, outer []enum.OverflowFlag) []enum.OverflowFlag {
+	if !hasOverflowFlag(inner, enum.OverflowFlagNUW) || !hasOverflowFlag(outer, enum.OverflowFlagNUW) {
+		return nil
+	}
+	nuw := []enum.OverflowFlag{enum.OverflowFlagNUW}
+	switch inst := inst.(type) {
+	case *ir.InstAdd:
+		inst.OverflowFlags = nuw
+	case *ir.InstMul:
+		inst.OverflowFlags = nuw
+	}
+	return nuw
+}
+
+// ### [ Helper functions ] ####################################################
+
+// instValue is an instruction which produces a value.
+type instValue interface {
+	ir.Instruction
+	value.Value
+}
+
+// swapOperands swaps the operands of a commutative instruction if x is a
+// constant and y is not, and reports whether the operands were swapped.
+func swapOperands(x, y *value.Value) bool {
+	_, xconst := (*x).(constant.Constant)
+	_, yconst := (*y).(constant.Constant)
+	if xconst && !yconst {
+		*x, *y = *y, *x
+		return true
+	}
+	return false
+}
+
+// isInt reports whether v is the integer constant x (truncated to the bit size
+// of v; e.g. -1 matches the all-ones integer of any type).
+func isInt(v value.Value, x int64) bool {
+	c, ok := v.(*constant.Int)
+	if !ok {
+		return false
+	}
+	return irutil.ConstEqual(c, irutil.NewIntValue(c.Typ, big.NewInt(x)))
+}
+
+// isFloat reports whether v is the floating-point constant x, with the sign
+// bit given by neg.
+func isFloat(v value.Value, x float64, neg bool) bool {
+	c, ok := v.(*constant.Float)
+	if !ok || c.NaN {
+		return false
+	}
+	return c.X.Signbit() == neg && c.X.Cmp(big.NewFloat(x)) == 0
+}
+
+// isMinSigned reports whether x is the minimum signed integer of its type.
+func isMinSigned(x *constant.Int) bool {
+	s := irutil.Signed(x)
+	return s.Sign() < 0 && s.Cmp(new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), uint(x.Typ.BitSize-1)))) == 0
+}
+
+// isMaxSigned reports whether x is the maximum signed integer of its type.
+func isMaxSigned(x *constant.Int) bool {
+	max := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(x.Typ.BitSize-1)), big.NewInt(1))
+	return irutil.Signed(x).Cmp(max) == 0
+}
+
+// powerOf2 returns the integer constant v and its base 2 logarithm k, if v is a
+// power of two greater than one.
+func powerOf2(v value.Value) (*constant.Int, uint64, bool) {
+	c, ok := v.(*constant.Int)
+	if !ok {
+		return nil, 0, false
+	}
+	u := irutil.Unsigned(c)
+	if u.Cmp(big.NewInt(1)) <= 0 || new(big.Int).And(u, new(big.Int).Sub(u, big.NewInt(1))).Sign() != 0 {
+		return nil, 0, false
+	}
+	return c, uint64(u.BitLen() - 1), true
+}
+
+// zero returns the zero value of the given type.
+func zero(t types.Type) constant.Constant {
+	if t, ok := t.(*types.IntType); ok {
+		return constant.NewInt(t, 0)
+	}
+	return constant.NewZeroInitializer(t)
+}
+
+// isBool reports whether t is the i1 type.
+func isBool(t types.Type) bool {
+	if t, ok := t.(*types.IntType); ok {
+		return t.BitSize == 1
+	}
+	return false
+}
+
+// bitSize returns the bit size of the given integer type, or zero if t is not
+// an integer type.
+func bitSize(t types.Type) uint64 {
+	if t, ok := t.(*types.IntType); ok {
+		return t.BitSize
+	}
+	return 0
+}
+
+// hasOverflowFlag reports whether flags contains the given overflow flag.
+func hasOverflowFlag(flags []enum.OverflowFlag, flag enum.OverflowFlag) bool {
+	for _, f := range flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// hasFastMath reports whether flags contains the given fast-math flag, or the
+// fast flag which implies all others.
+func hasFastMath(flags []enum.FastMathFlag, flag enum.FastMathFlag) bool {
+	for _, f := range flags {
+		if f == flag || f == enum.FastMathFlagFast {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,340 @@
+// Package licm implements a loop-invariant code motion pass for LLVM IR
+// functions.
+//
+// Instructions of a loop which compute the same value on every iteration are
+// hoisted to the preheader of the loop, provided that executing them before the
+// loop is safe; i.e. they have no side effects, may not trap unless guaranteed
+// to execute, and read no memory which may be written within the loop.
+//
+// Instructions whose results are only used outside of a loop are sunk into the
+// exit block of the loop dominating their uses, so that they are computed once
+// rather than on every iteration.
+//
+// Loops are first put into canonical form (see the loopsimplify package), and
+// processed from the innermost loop outwards, so that invariant instructions
+// may be hoisted out of an entire loop nest.
+package licm
+
+import (
+	"github.com/llir/llvm/analysis/cfg"
+	"github.com/llir/llvm/analysis/loop"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/value"
+	"github.com/llir/llvm/irutil"
+	"github.com/llir/llvm/transform/loopsimplify"
+)
+
+// Run hoists loop-invariant instructions out of the loops of the given
+// function, sinks instructions only used outside of loops into loop exits, and
+// reports whether the function was changed.
+func Run(f *ir.Func) bool {
+	if len(f.Blocks) == 0 {
+		return false
+	}
+	changed := loopsimplify.Run(f)
+	dt := cfg.NewDomTree(f)
+	p := &pass{
+		dt:      dt,
+		info:    loop.NewInfo(f, dt),
+		preds:   cfg.Preds(f),
+		blockOf: make(map[value.User]*ir.Block),
+		users:   make(map[value.Value][]value.User),
+	}
+	p.init(f)
+	moved := false
+	for _, l := range p.info.PostOrder() {
+		if p.hoist(l) {
+			moved = true
+		}
+		if p.sink(l) {
+			moved = true
+		}
+	}
+	if moved {
+		irutil.ResetLocalIDs(f)
+	}
+	return changed || moved
+}
+
+// RunModule applies loop-invariant code motion to the function definitions of
+// the given module, and reports whether the module was changed.
+func RunModule(m *ir.Module) bool {
+	changed := false
+	for _, f := range m.Funcs {
+		if Run(f) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// pass tracks the state of loop-invariant code motion of a function.
+type pass struct {
+	// Dominator tree of the function.
+	dt *cfg.DomTree
+	// Loop nesting forest of the function.
+	info *loop.Info
+	// Predecessors of each basic block.
+	preds map[*ir.Block][]*ir.Block
+	// Parent basic block of each instruction and terminator; updated as
+	// instructions are moved.
+	blockOf map[value.User]*ir.Block
+	// Users of each instruction and value-producing terminator.
+	users map[value.Value][]value.User
+}
+
+// init records the parent basic block and users of each instruction and
+// terminator of f.
+func (p *pass) init(f *ir.Func) {
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			p.blockOf[inst] = block
+		}
+		p.blockOf[block.Term] = block
+	}
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			p.addUser(inst)
+		}
+		p.addUser(block.Term)
+	}
+}
+
+// addUser records user as a user of its instruction operands.
+func (p *pass) addUser(user value.User) {
+	for _, op := range user.Operands() {
+		v := irutil.Unwrap(*op)
+		if p.defBlock(v) != nil {
+			p.users[v] = append(p.users[v], user)
+		}
+	}
+}
+
+// defBlock returns the parent basic block of the given value, or nil if v is
+// not defined by an instruction or terminator of the function.
+func (p *pass) defBlock(v value.Value) *ir.Block {
+	if u, ok := v.(value.User); ok {
+		return p.blockOf[u]
+	}
+	return nil
+}
+
+// --- [ Hoisting ] ------------------------------------------------------------
+
+// hoist moves the loop-invariant instructions of the given loop to its
+// preheader, and reports whether any instruction was moved.
+func (p *pass) hoist(l *loop.Loop) bool {
+	preheader := l.Preheader()
+	if preheader == nil {
+		return false
+	}
+	mayWrite, mayThrow := false, false
+	for _, block := range l.Blocks {
+		for _, inst := range block.Insts {
+			if irutil.MayWriteMemory(inst) {
+				mayWrite = true
+			}
+			if call, ok := inst.(*ir.InstCall); ok && !irutil.IsDebugIntrinsic(call) {
+				attrs := irutil.CallFuncAttrs(call)
+				if !irutil.HasFuncAttr(attrs, enum.FuncAttrNoUnwind) || !irutil.HasFuncAttr(attrs, enum.FuncAttrWillReturn) {
+					mayThrow = true
+				}
+			}
+		}
+		switch block.Term.(type) {
+		case *ir.TermInvoke, *ir.TermCallBr:
+			mayWrite, mayThrow = true, true
+		}
+	}
+	exiting := l.ExitingBlocks()
+	// guaranteed reports whether the instructions of block are executed on
+	// every iteration of the loop which reaches block before exiting.
+	guaranteed := func(block *ir.Block) bool {
+		if mayThrow {
+			return false
+		}
+		for _, e := range exiting {
+			if !p.dt.Dominates(block, e) {
+				return false
+			}
+		}
+		return true
+	}
+	changed := false
+	for _, block := range l.Blocks {
+		// Instructions of nested loops have already been considered.
+		if p.info.LoopOf(block) != l {
+			continue
+		}
+		insts := block.Insts[:0]
+		for _, inst := range block.Insts {
+			if !p.isInvariant(l, inst) || !canHoist(inst, mayWrite, guaranteed(block)) {
+				insts = append(insts, inst)
+				continue
+			}
+			preheader.Insts = append(preheader.Insts, inst)
+			p.blockOf[inst] = preheader
+			changed = true
+		}
+		for i := len(insts); i < len(block.Insts); i++ {
+			block.Insts[i] = nil
+		}
+		block.Insts = insts
+	}
+	return changed
+}
+
+// isInvariant reports whether the operands of the given instruction are
+// defined outside of the loop.
+func (p *pass) isInvariant(l *loop.Loop, inst ir.Instruction) bool {
+	for _, op := range inst.Operands() {
+		if block := p.defBlock(irutil.Unwrap(*op)); block != nil && l.Contains(block) {
+			return false
+		}
+	}
+	return true
+}
+
+// canHoist reports whether the given loop-invariant instruction may be moved to
+// the preheader of its loop. The loop may write memory if mayWrite is set, and
+// the instruction is executed on every iteration if guaranteed is set.
+func canHoist(inst ir.Instruction, mayWrite, guaranteed bool) bool {
+	if !isMovable(inst) || irutil.HasSideEffects(inst) {
+		return false
+	}
+	if irutil.MayReadMemory(inst) {
+		// Loads may trap, and their result depends on stores within the loop.
+		return !mayWrite && guaranteed
+	}
+	return guaranteed || isSafeToSpeculate(inst)
+}
+
+// isSafeToSpeculate reports whether the given instruction without side effects
+// may be executed even if not executed by the original program; i.e. whether it
+// cannot trap.
+func isSafeToSpeculate(inst ir.Instruction) bool {
+	switch inst := inst.(type) {
+	case *ir.InstUDiv:
+		return isNonZero(inst.Y, false)
+	case *ir.InstURem:
+		return isNonZero(inst.Y, false)
+	case *ir.InstSDiv:
+		return isNonZero(inst.Y, true)
+	case *ir.InstSRem:
+		return isNonZero(inst.Y, true)
+	case *ir.InstCall:
+		return irutil.HasFuncAttr(irutil.CallFuncAttrs(inst), enum.FuncAttrSpeculatable)
+	}
+	return true
+}
+
+// isNonZero reports whether the given divisor is a non-zero integer constant.
+// Signed divisors must furthermore not be -1, as the division of the minimum
+// signed integer by -1 overflows.
+func isNonZero(y value.Value, signed bool) bool {
+	c, ok := y.(*constant.Int)
+	if !ok || c.X.Sign() == 0 {
+		return false
+	}
+	return !signed || !c.X.IsInt64() || c.X.Int64() != -1
+}
+
+// --- [ Sinking ] -------------------------------------------------------------
+
+// sink moves the instructions of the given loop which are only used outside of
+// the loop to the exit block dominating their uses, and reports whether any
+// instruction was moved.
+func (p *pass) sink(l *loop.Loop) bool {
+	exits := l.ExitBlocks()
+	changed := false
+	// Visit instructions bottom-up, so that the operands of sunk instructions
+	// may subsequently be sunk.
+	for i := len(l.Blocks) - 1; i >= 0; i-- {
+		block := l.Blocks[i]
+		for j := len(block.Insts) - 1; j >= 0; j-- {
+			inst := block.Insts[j]
+			exit := p.sinkTarget(l, exits, block, inst)
+			if exit == nil {
+				continue
+			}
+			block.Insts = append(block.Insts[:j], block.Insts[j+1:]...)
+			irutil.InsertInst(exit, irutil.FirstInsertionIndex(exit), inst)
+			p.blockOf[inst] = exit
+			changed = true
+		}
+	}
+	return changed
+}
+
+// sinkTarget returns the exit block of the given loop to which the instruction
+// inst of block may be sunk, or nil if the instruction cannot be sunk.
+func (p *pass) sinkTarget(l *loop.Loop, exits []*ir.Block, block *ir.Block, inst ir.Instruction) *ir.Block {
+	if !isMovable(inst) || irutil.HasSideEffects(inst) || irutil.MayReadMemory(inst) {
+		return nil
+	}
+	v := inst.(value.Value)
+	users := p.users[v]
+	if len(users) == 0 {
+		return nil
+	}
+	var target *ir.Block
+	for _, user := range users {
+		for _, useBlock := range p.useBlocks(user, v) {
+			if l.Contains(useBlock) {
+				return nil
+			}
+			if target == nil {
+				for _, exit := range exits {
+					if p.dt.Dominates(exit, useBlock) {
+						target = exit
+						break
+					}
+				}
+				if target == nil {
+					return nil
+				}
+			} else if !p.dt.Dominates(target, useBlock) {
+				return nil
+			}
+		}
+	}
+	// The instruction must dominate the exit block.
+	preds := p.preds[target]
+	if len(preds) != 1 || !p.dt.Dominates(block, preds[0]) {
+		return nil
+	}
+	return target
+}
+
+// useBlocks returns the basic blocks in which user uses v. Uses by phi
+// instructions occur in the corresponding predecessor basic blocks.
+func (p *pass) useBlocks(user value.User, v value.Value) []*ir.Block {
+	phi, ok := user.(*ir.InstPhi)
+	if !ok {
+		return []*ir.Block{p.blockOf[user]}
+	}
+	var blocks []*ir.Block
+	for _, inc := range phi.Incs {
+		if inc.X == v {
+			blocks = append(blocks, inc.Pred.(*ir.Block))
+		}
+	}
+	return blocks
+}
+
+// ### [ Helper functions ] ####################################################
+
+// isMovable reports whether the given instruction produces a value and may be
+// moved to another basic block.
+func isMovable(inst ir.Instruction) bool {
+	switch inst := inst.(type) {
+	case *ir.InstPhi, *ir.InstAlloca, *ir.InstVAArg, *ir.InstLandingPad, *ir.InstCatchPad, *ir.InstCleanupPad:
+		return false
+	case *ir.InstCall:
+		return !irutil.IsDebugIntrinsic(inst)
+	}
+	_, ok := inst.(value.Value)
+	return ok
+}
@@ -0,0 +1,131 @@
+package licm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+)
+
+func TestRun(t *testing.T) {
+	golden := []struct {
+		name string
+		in   string
+		want string
+	}{
+		// Invariant arithmetic, divisions and loads are hoisted to the preheader,
+		// and values only used after the loop are sunk into the exit block.
+		{
+			name: "hoist",
+			in: `
+define i32 @f(i32 %n, i32 %a, i32* %p) {
+entry:
+	br label %loop
+
+loop:
+	%i = phi i32 [ 0, %entry ], [ %i.next, %loop ]
+	%x = mul i32 %a, 3
+	%d = sdiv i32 %a, %n
+	%v = load i32, i32* %p
+	%s = add i32 %x, %v
+	%t = add i32 %s, %d
+	%u = add i32 %t, %i
+	%i.next = add i32 %i, 1
+	%c = icmp slt i32 %i.next, %n
+	br i1 %c, label %loop, label %exit
+
+exit:
+	ret i32 %u
+}`,
+			want: `
+define i32 @f(i32 %n, i32 %a, i32* %p) {
+entry:
+	%x = mul i32 %a, 3
+	%d = sdiv i32 %a, %n
+	%v = load i32, i32* %p
+	%s = add i32 %x, %v
+	%t = add i32 %s, %d
+	br label %loop
+
+loop:
+	%i = phi i32 [ 0, %entry ], [ %i.next, %loop ]
+	%i.next = add i32 %i, 1
+	%c = icmp slt i32 %i.next, %n
+	br i1 %c, label %loop, label %exit
+
+exit:
+	%u = add i32 %t, %i
+	ret i32 %u
+}`,
+		},
+		// Loads are not hoisted from loops which write memory, and instructions
+		// which may trap are not hoisted unless guaranteed to execute.
+		{
+			name: "unsafe",
+			in: `
+define void @f(i32 %n, i32 %a, i1 %b, i32* %p) {
+entry:
+	br label %loop
+
+loop:
+	%i = phi i32 [ 0, %entry ], [ %i.next, %latch ]
+	%v = load i32, i32* %p
+	br i1 %b, label %then, label %latch
+
+then:
+	%d = udiv i32 %a, %n
+	%e = udiv i32 %a, 7
+	%s = add i32 %d, %e
+	store i32 %s, i32* %p
+	br label %latch
+
+latch:
+	%i.next = add i32 %i, %v
+	%c = icmp slt i32 %i.next, %n
+	br i1 %c, label %loop, label %exit
+
+exit:
+	ret void
+}`,
+			want: `
+define void @f(i32 %n, i32 %a, i1 %b, i32* %p) {
+entry:
+	%e = udiv i32 %a, 7
+	br label %loop
+
+loop:
+	%i = phi i32 [ 0, %entry ], [ %i.next, %latch ]
+	%v = load i32, i32* %p
+	br i1 %b, label %then, label %latch
+
+then:
+	%d = udiv i32 %a, %n
+	%s = add i32 %d, %e
+	store i32 %s, i32* %p
+	br label %latch
+
+latch:
+	%i.next = add i32 %i, %v
+	%c = icmp slt i32 %i.next, %n
+	br i1 %c, label %loop, label %exit
+
+exit:
+	ret void
+}`,
+		},
+	}
+	for _, g := range golden {
+		m, err := asm.ParseString(g.name+".ll", g.in)
+		if err != nil {
+			t.Errorf("%q: unable to parse module; %+v", g.name, err)
+			continue
+		}
+		f := m.Funcs[len(m.Funcs)-1]
+		Run(f)
+		got := strings.TrimSpace(f.LLString())
+		want := strings.TrimSpace(g.want)
+		if got != want {
+			t.Errorf("%q: function mismatch; expected:\n%s\n\ngot:\n%s", g.name, want, got)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+// Package looprotate implements a loop rotation pass for LLVM IR functions,
+// which converts while loops into guarded do-while loops.
+//
+// The header of a while loop evaluates the exit condition at the start of each
+// iteration. Rotation duplicates the header into the preheader, to guard the
+// first iteration, and moves the exit condition to the end of the loop, so that
+// the loop has a single exiting latch:
+//
+//	preheader:              preheader:
+//	  br label %header        <header'>
+//	header:                   br i1 %c', label %body, label %exit
+//	  <header>              body:
+//	  br i1 %c, label %body,  ...
+//	     label %exit          <header>
+//	body:                     br i1 %c, label %body, label %exit
+//	  ...
+//	  br label %header
+//
+// Values of the header used within the loop body or after the loop are merged
+// with their duplicates by phi instructions.
+package looprotate
+
+import (
+	"github.com/llir/llvm/analysis/cfg"
+	"github.com/llir/llvm/analysis/loop"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/value"
+	"github.com/llir/llvm/irutil"
+	"github.com/llir/llvm/transform/loopsimplify"
+)
+
+// MaxHeaderSize is the maximum number of non-phi instructions of loop headers
+// duplicated by loop rotation.
+const MaxHeaderSize = 16
+
+// Run rotates the loops of the given function into guarded do-while loops, and
+// reports whether the function was changed.
+func Run(f *ir.Func) bool {
+	if len(f.Blocks) == 0 {
+		return false
+	}
+	changed := loopsimplify.Run(f)
+	rotated := false
+	// Each rotation invalidates the dominator tree and loop nesting forest,
+	// which are therefore recomputed until no loop may be rotated.
+	for rotateOne(f) {
+		rotated = true
+	}
+	if rotated {
+		irutil.ResetLocalIDs(f)
+	}
+	return changed || rotated
+}
+
+// RunModule rotates the loops of the function definitions of the given module,
+// and reports whether the module was changed.
+func RunModule(m *ir.Module) bool {
+	changed := false
+	for _, f := range m.Funcs {
+		if Run(f) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// rotateOne rotates the first loop of f which may be rotated, and reports
+// whether a loop was rotated.
+func rotateOne(f *ir.Func) bool {
+	dt := cfg.NewDomTree(f)
+	info := loop.NewInfo(f, dt)
+	preds := cfg.Preds(f)
+	for _, l := range info.PostOrder() {
+		r := &rotation{f: f, l: l, dt: dt, preds: preds}
+		if r.init() {
+			r.rotate()
+			return true
+		}
+	}
+	return false
+}
+
+// rotation tracks the state of the rotation of a loop.
+type rotation struct {
+	f     *ir.Func
+	l     *loop.Loop
+	dt    *cfg.DomTree
+	preds map[*ir.Block][]*ir.Block
+
+	// Preheader, header and latch of the loop.
+	preheader, header, latch *ir.Block
+	// Successors of the header within the loop (body) and outside of the loop
+	// (exit).
+	body, exit *ir.Block
+}
+
+// init reports whether the loop may be rotated, and records its structure.
+func (r *rotation) init() bool {
+	l := r.l
+	r.header = l.Header
+	r.preheader = l.Preheader()
+	latches := l.Latches()
+	if r.preheader == nil || len(latches) != 1 || latches[0] == r.header {
+		return false
+	}
+	r.latch = latches[0]
+	// Loops with an exiting latch are already rotated.
+	if br, ok := r.latch.Term.(*ir.TermBr); !ok || br.Target != r.header {
+		return false
+	}
+	br, ok := r.header.Term.(*ir.TermCondBr)
+	if !ok {
+		return false
+	}
+	r.body, r.exit = br.TargetTrue.(*ir.Block), br.TargetFalse.(*ir.Block)
+	if !l.Contains(r.body) {
+		r.body, r.exit = r.exit, r.body
+	}
+	if !l.Contains(r.body) || l.Contains(r.exit) {
+		return false
+	}
+	if len(r.preds[r.body]) != 1 || len(r.preds[r.exit]) != 1 {
+		return false
+	}
+	if _, ok := r.preheader.Term.(*ir.TermBr); !ok {
+		return false
+	}
+	// Check that the header may be duplicated, and that its phi instructions
+	// may be replaced by their incoming values from the latch once the header
+	// is merged into the latch.
+	n := 0
+	for _, inst := range r.header.Insts {
+		switch inst := inst.(type) {
+		case *ir.InstPhi:
+			if r.isHeaderValue(irutil.IncomingValue(inst, r.latch)) {
+				return false
+			}
+			continue
+		case *ir.InstAlloca, *ir.InstLandingPad, *ir.InstCatchPad, *ir.InstCleanupPad:
+			return false
+		case *ir.InstCall:
+			attrs := irutil.CallFuncAttrs(inst)
+			if irutil.HasFuncAttr(attrs, enum.FuncAttrNoDuplicate) || irutil.HasFuncAttr(attrs, enum.FuncAttrConvergent) {
+				return false
+			}
+		}
+		n++
+	}
+	if n > MaxHeaderSize {
+		return false
+	}
+	// Check that each use of a header value outside of the header is dominated
+	// by the body or the exit, where it may be merged with its duplicate.
+	for _, block := range r.f.Blocks {
+		if block == r.header {
+			continue
+		}
+		for _, inst := range block.Insts {
+			if !r.usesDominated(inst, block) {
+				return false
+			}
+		}
+		if !r.usesDominated(block.Term, block) {
+			return false
+		}
+	}
+	return true
+}
+
+// usesDominated reports whether the uses of header values by the given user of
+// block are dominated by the body or exit of the loop.
+func (r *rotation) usesDominated(user value.User, block *ir.Block) bool {
+	for _, useBlock := range r.headerUses(user, block) {
+		if !r.dt.Dominates(r.body, useBlock) && !r.dt.Dominates(r.exit, useBlock) {
+			return false
+		}
+	}
+	return true
+}
+
+// headerUses returns the basic blocks in which the given user of block uses
+// values defined by the header. Uses by phi instructions occur in the
+// corresponding predecessor basic blocks, and uses by phi instructions of the
+// body and exit through the edges from the header are omitted.
+func (r *rotation) headerUses(user value.User, block *ir.Block) []*ir.Block {
+	var blocks []*ir.Block
+	if phi, ok := user.(*ir.InstPhi); ok {
+		for _, inc := range phi.Incs {
+			pred := inc.Pred.(*ir.Block)
+			if r.isHeaderValue(inc.X) && pred != r.header {
+				blocks = append(blocks, pred)
+			}
+		}
+		return blocks
+	}
+	for _, op := range user.Operands() {
+		if r.isHeaderValue(irutil.Unwrap(*op)) {
+			return []*ir.Block{block}
+		}
+	}
+	return nil
+}
+
+// isHeaderValue reports whether v is defined by an instruction of the header.
+func (r *rotation) isHeaderValue(v value.Value) bool {
+	for _, inst := range r.header.Insts {
+		if w, ok := inst.(value.Value); ok && w == v {
+			return true
+		}
+	}
+	return false
+}
+
+// rotate rotates the loop.
+func (r *rotation) rotate() {
+	// Duplicate the header into the preheader, with the incoming values of its
+	// phi instructions from the preheader.
+	vmap := make(map[value.Value]value.Value)
+	var defs []value.Value
+	for _, inst := range r.header.Insts {
+		if phi, ok := inst.(*ir.InstPhi); ok {
+			defs = append(defs, phi)
+			vmap[phi] = irutil.IncomingValue(phi, r.preheader)
+			continue
+		}
+		clone := irutil.CloneInst(inst)
+		irutil.ReplaceOperands(clone, vmap)
+		r.preheader.Insts = append(r.preheader.Insts, clone)
+		if v, ok := inst.(value.Named); ok {
+			defs = append(defs, v)
+			clone := clone.(value.Named)
+			clone.SetName(irutil.UniqueLocalName(r.f, irutil.LocalName(v)))
+			vmap[v] = clone
+		}
+	}
+	br := r.header.Term.(*ir.TermCondBr)
+	guard := ir.NewCondBr(irutil.Lookup(vmap, br.Cond), br.TargetTrue.(*ir.Block), br.TargetFalse.(*ir.Block))
+	guard.Metadata = guardMetadata(br.Metadata)
+	r.preheader.Term = guard
+	irutil.RemovePhiPred(r.header, r.preheader)
+	// Add incoming values from the preheader to the phi instructions of the
+	// body and exit.
+	for _, succ := range []*ir.Block{r.body, r.exit} {
+		for _, phi := range irutil.Phis(succ) {
+			if x := irutil.IncomingValue(phi, r.header); x != nil {
+				phi.Incs = append(phi.Incs, ir.NewIncoming(irutil.Lookup(vmap, x), r.preheader))
+			}
+		}
+	}
+	// Merge header values used outside of the header with their duplicates.
+	for _, v := range defs {
+		r.merge(v, vmap[v])
+	}
+	r.mergeLatch()
+}
+
+// merge inserts phi instructions into the body and exit of the loop which merge
+// the header value v with its duplicate dup in the preheader, and replaces the
+// uses of v dominated by the body or exit accordingly.
+func (r *rotation) merge(v, dup value.Value) {
+	var phis [2]*ir.InstPhi
+	for i, succ := range []*ir.Block{r.body, r.exit} {
+		for _, block := range r.f.Blocks {
+			if block == r.header || !r.dt.Dominates(succ, block) {
+				continue
+			}
+			for _, inst := range block.Insts {
+				r.replaceUses(inst, block, succ, v, dup, &phis[i])
+			}
+			r.replaceUses(block.Term, block, succ, v, dup, &phis[i])
+		}
+	}
+}
+
+// replaceUses replaces the uses of v by the given user of block with a phi
+// instruction in succ merging v and its duplicate dup; the phi instruction is
+// created on first use.
+func (r *rotation) replaceUses(user value.User, block, succ *ir.Block, v, dup value.Value, phi **ir.InstPhi) {
+	get := func() value.Value {
+		if *phi == nil {
+			p := ir.NewPhi(ir.NewIncoming(dup, r.preheader), ir.NewIncoming(v, r.header))
+			p.SetName(irutil.UniqueLocalName(r.f, irutil.LocalName(v)))
+			irutil.InsertInst(succ, len(irutil.Phis(succ)), p)
+			*phi = p
+		}
+		return *phi
+	}
+	if user == *phi {
+		return
+	}
+	if p, ok := user.(*ir.InstPhi); ok {
+		for _, inc := range p.Incs {
+			if inc.X == v && inc.Pred != r.header && r.dt.Dominates(succ, inc.Pred.(*ir.Block)) {
+				inc.X = get()
+			}
+		}
+		return
+	}
+	for _, op := range user.Operands() {
+		if arg, ok := (*op).(*ir.Arg); ok && arg.Value == v {
+			arg.Value = get()
+		} else if *op == v {
+			*op = get()
+		}
+	}
+}
+
+// mergeLatch merges the header into the latch of the loop, which is the only
+// predecessor of the header after rotation.
+func (r *rotation) mergeLatch() {
+	repl := make(map[value.Value]value.Value)
+	for _, phi := range irutil.Phis(r.header) {
+		repl[phi] = irutil.IncomingValue(phi, r.latch)
+	}
+	for _, inst := range r.header.Insts[len(repl):] {
+		r.latch.Insts = append(r.latch.Insts, inst)
+	}
+	// Loop metadata (!llvm.loop) of the back edge is kept on the branch of the
+	// rotated latch.
+	br := r.header.Term.(*ir.TermCondBr)
+	if old, ok := r.latch.Term.(*ir.TermBr); ok {
+		for _, md := range old.Metadata {
+			if md.Name == "llvm.loop" {
+				br.SetAttachment(md.Name, md.Node)
+			}
+		}
+	}
+	r.latch.Term = br
+	irutil.ReplacePhiPred(r.body, r.header, r.latch)
+	irutil.ReplacePhiPred(r.exit, r.header, r.latch)
+	irutil.RemoveBlocks(r.f, map[*ir.Block]bool{r.header: true})
+	irutil.ReplaceAll(r.f, repl)
+}
+
+// guardMetadata returns the metadata attachments of the header branch to copy
+// to the guard branch of the preheader. Loop metadata (!llvm.loop) only applies
+// to the latch, and the branch weights (!prof) of the header do not describe
+// the guard; both are omitted.
+func guardMetadata(mds ir.Metadata) ir.Metadata {
+	var guard ir.Metadata
+	for _, md := range mds {
+		switch md.Name {
+		case "llvm.loop", "prof":
+			continue
+		}
+		guard = append(guard, md)
+	}
+	return guard
+}
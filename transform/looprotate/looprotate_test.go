@@ -0,0 +1,145 @@
+package looprotate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+)
+
+func TestRun(t *testing.T) {
+	golden := []struct {
+		name string
+		in   string
+		want string
+	}{
+		// While loop rotated into a guarded do-while loop; header values used in
+		// the body and after the loop are merged with their duplicates.
+		{
+			name: "while",
+			in: `
+define i32 @f(i32 %n) {
+entry:
+	br label %header
+
+header:
+	%i = phi i32 [ 0, %entry ], [ %i.next, %body ]
+	%s = phi i32 [ 0, %entry ], [ %s.next, %body ]
+	%cmp = icmp slt i32 %i, %n
+	br i1 %cmp, label %body, label %exit
+
+body:
+	%s.next = add i32 %s, %i
+	%i.next = add i32 %i, 1
+	br label %header
+
+exit:
+	ret i32 %s
+}`,
+			want: `
+define i32 @f(i32 %n) {
+entry:
+	%cmp.1 = icmp slt i32 0, %n
+	br i1 %cmp.1, label %body, label %exit
+
+body:
+	%i.1 = phi i32 [ 0, %entry ], [ %i.next, %body ]
+	%s.1 = phi i32 [ 0, %entry ], [ %s.next, %body ]
+	%s.next = add i32 %s.1, %i.1
+	%i.next = add i32 %i.1, 1
+	%cmp = icmp slt i32 %i.next, %n
+	br i1 %cmp, label %body, label %exit
+
+exit:
+	%s.2 = phi i32 [ 0, %entry ], [ %s.next, %body ]
+	ret i32 %s.2
+}`,
+		},
+		// Loops with an exiting latch are already rotated.
+		{
+			name: "rotated",
+			in: `
+define void @f(i32 %n) {
+entry:
+	br label %loop
+
+loop:
+	%i = phi i32 [ 0, %entry ], [ %i.next, %loop ]
+	%i.next = add i32 %i, 1
+	%cmp = icmp slt i32 %i.next, %n
+	br i1 %cmp, label %loop, label %exit
+
+exit:
+	ret void
+}`,
+			want: `
+define void @f(i32 %n) {
+entry:
+	br label %loop
+
+loop:
+	%i = phi i32 [ 0, %entry ], [ %i.next, %loop ]
+	%i.next = add i32 %i, 1
+	%cmp = icmp slt i32 %i.next, %n
+	br i1 %cmp, label %loop, label %exit
+
+exit:
+	ret void
+}`,
+		},
+		// Loop metadata is kept on the branch of the rotated latch, and neither
+		// loop metadata nor branch weights are copied to the guard.
+		{
+			name: "metadata",
+			in: `
+define void @f(i32 %n) {
+entry:
+	br label %header
+
+header:
+	%i = phi i32 [ 0, %entry ], [ %i.next, %body ]
+	%cmp = icmp slt i32 %i, %n
+	br i1 %cmp, label %body, label %exit, !prof !0
+
+body:
+	%i.next = add i32 %i, 1
+	br label %header, !llvm.loop !1
+
+exit:
+	ret void
+}
+
+!0 = !{!"branch_weights", i32 64, i32 4}
+!1 = distinct !{!1}`,
+			want: `
+define void @f(i32 %n) {
+entry:
+	%cmp.1 = icmp slt i32 0, %n
+	br i1 %cmp.1, label %body, label %exit
+
+body:
+	%i.1 = phi i32 [ 0, %entry ], [ %i.next, %body ]
+	%i.next = add i32 %i.1, 1
+	%cmp = icmp slt i32 %i.next, %n
+	br i1 %cmp, label %body, label %exit, !prof !0, !llvm.loop !1
+
+exit:
+	ret void
+}`,
+		},
+	}
+	for _, g := range golden {
+		m, err := asm.ParseString(g.name+".ll", g.in)
+		if err != nil {
+			t.Errorf("%q: unable to parse module; %+v", g.name, err)
+			continue
+		}
+		f := m.Funcs[len(m.Funcs)-1]
+		Run(f)
+		got := strings.TrimSpace(f.LLString())
+		want := strings.TrimSpace(g.want)
+		if got != want {
+			t.Errorf("%q: function mismatch; expected:\n%s\n\ngot:\n%s", g.name, want, got)
+		}
+	}
+}
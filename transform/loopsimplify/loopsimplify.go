@@ -0,0 +1,124 @@
+// Package loopsimplify implements a pass which canonicalizes the loops of LLVM
+// IR functions, to simplify subsequent loop transformations.
+//
+// A loop in canonical form has a preheader (a single basic block outside of the
+// loop which branches unconditionally to the header), and dedicated exit blocks
+// (exit blocks of the loop with predecessors only inside of the loop).
+package loopsimplify
+
+import (
+	"github.com/llir/llvm/analysis/cfg"
+	"github.com/llir/llvm/analysis/loop"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/irutil"
+)
+
+// Run inserts preheaders and dedicated exit blocks for the loops of the given
+// function, and reports whether the function was changed. Loops with entering
+// or exiting edges which cannot be redirected (e.g. from indirectbr
+// terminators) are left as is.
+func Run(f *ir.Func) bool {
+	if len(f.Blocks) == 0 {
+		return false
+	}
+	changed := false
+	// Each inserted basic block invalidates the loop nesting forest, which is
+	// therefore recomputed until all loops are in canonical form.
+	for simplifyOne(f) {
+		changed = true
+	}
+	if changed {
+		irutil.ResetLocalIDs(f)
+	}
+	return changed
+}
+
+// RunModule inserts preheaders and dedicated exit blocks for the loops of the
+// function definitions of the given module, and reports whether the module was
+// changed.
+func RunModule(m *ir.Module) bool {
+	changed := false
+	for _, f := range m.Funcs {
+		if Run(f) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// simplifyOne inserts a preheader or dedicated exit block for the first loop of
+// f not in canonical form, and reports whether a basic block was inserted.
+func simplifyOne(f *ir.Func) bool {
+	info := loop.NewInfo(f, cfg.NewDomTree(f))
+	preds := cfg.Preds(f)
+	for _, l := range info.PostOrder() {
+		if l.Preheader() == nil && InsertPreheader(f, l, preds) != nil {
+			return true
+		}
+		for _, exit := range l.ExitBlocks() {
+			var inside []*ir.Block
+			for _, pred := range preds[exit] {
+				if l.Contains(pred) {
+					inside = append(inside, pred)
+				}
+			}
+			if len(inside) == len(preds[exit]) || !canSplitPreds(exit, inside) {
+				continue
+			}
+			irutil.SplitPreds(f, exit, inside, blockName(exit, ".loopexit"))
+			return true
+		}
+	}
+	return false
+}
+
+// InsertPreheader inserts a preheader for the given loop of f if not already
+// present, and returns the preheader of the loop. The predecessors of each basic
+// block of f are given by preds. A nil basic block is returned if a preheader
+// cannot be inserted.
+//
+// The loop nesting forest of f is invalidated if a preheader is inserted.
+func InsertPreheader(f *ir.Func, l *loop.Loop, preds map[*ir.Block][]*ir.Block) *ir.Block {
+	if preheader := l.Preheader(); preheader != nil {
+		return preheader
+	}
+	var entering []*ir.Block
+	for _, pred := range preds[l.Header] {
+		if !l.Contains(pred) {
+			entering = append(entering, pred)
+		}
+	}
+	if len(entering) == 0 || !canSplitPreds(l.Header, entering) {
+		return nil
+	}
+	return irutil.SplitPreds(f, l.Header, entering, blockName(l.Header, ".preheader"))
+}
+
+// ### [ Helper functions ] ####################################################
+
+// canSplitPreds reports whether the edges from the given predecessors to block
+// may be redirected through a new basic block.
+func canSplitPreds(block *ir.Block, preds []*ir.Block) bool {
+	if irutil.FirstInsertionIndex(block) != len(irutil.Phis(block)) {
+		// Exception handling pads must remain at the start of their basic
+		// blocks.
+		return false
+	}
+	for _, pred := range preds {
+		switch pred.Term.(type) {
+		case *ir.TermIndirectBr, *ir.TermCallBr, *ir.TermCatchSwitch, *ir.TermCatchRet, *ir.TermCleanupRet:
+			return false
+		}
+	}
+	return true
+}
+
+// blockName returns the name of a new basic block derived from the given basic
+// block and suffix, or an empty name if block is unnamed.
+func blockName(block *ir.Block, suffix string) string {
+	name := irutil.LocalName(block)
+	if len(name) == 0 {
+		return ""
+	}
+	return name + suffix
+}
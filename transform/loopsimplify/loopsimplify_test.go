@@ -0,0 +1,83 @@
+package loopsimplify
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+)
+
+func TestRun(t *testing.T) {
+	golden := []struct {
+		name string
+		in   string
+		want string
+	}{
+		// Loop with multiple entering basic blocks, and an exit block shared
+		// with a path bypassing the loop.
+		{
+			name: "simplify",
+			in: `
+define i32 @f(i1 %c, i32 %a, i32 %b) {
+entry:
+	br i1 %c, label %left, label %right
+
+left:
+	br i1 %c, label %loop, label %exit
+
+right:
+	br label %loop
+
+loop:
+	%x = phi i32 [ %a, %left ], [ %b, %right ], [ %y, %loop ]
+	%y = add i32 %x, 1
+	br i1 %c, label %loop, label %exit
+
+exit:
+	%r = phi i32 [ 0, %left ], [ %y, %loop ]
+	ret i32 %r
+}`,
+			want: `
+define i32 @f(i1 %c, i32 %a, i32 %b) {
+entry:
+	br i1 %c, label %left, label %right
+
+left:
+	br i1 %c, label %loop.preheader, label %exit
+
+right:
+	br label %loop.preheader
+
+loop.preheader:
+	%x.1 = phi i32 [ %a, %left ], [ %b, %right ]
+	br label %loop
+
+loop:
+	%x = phi i32 [ %y, %loop ], [ %x.1, %loop.preheader ]
+	%y = add i32 %x, 1
+	br i1 %c, label %loop, label %exit.loopexit
+
+exit.loopexit:
+	br label %exit
+
+exit:
+	%r = phi i32 [ 0, %left ], [ %y, %exit.loopexit ]
+	ret i32 %r
+}`,
+		},
+	}
+	for _, g := range golden {
+		m, err := asm.ParseString(g.name+".ll", g.in)
+		if err != nil {
+			t.Errorf("%q: unable to parse module; %+v", g.name, err)
+			continue
+		}
+		f := m.Funcs[len(m.Funcs)-1]
+		Run(f)
+		got := strings.TrimSpace(f.LLString())
+		want := strings.TrimSpace(g.want)
+		if got != want {
+			t.Errorf("%q: function mismatch; expected:\n%s\n\ngot:\n%s", g.name, want, got)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Package outofssa implements the translation of LLVM IR functions out of SSA
+// form, replacing phi instructions with copies in predecessor basic blocks.
+//
+// Each phi instruction is assigned a stack slot (an alloca instruction of the
+// entry basic block), which acts as the variable of the phi instruction. The
+// incoming values of the phi instruction are copied to the variable (stored to
+// the slot) at the end of each predecessor basic block, and the phi instruction
+// is replaced by a copy from the variable (a load from the slot) at the start
+// of its basic block.
+//
+// Critical edges are split beforehand, so that copies placed in a predecessor
+// basic block are only executed on the edge to the basic block of the phi
+// instruction. Edges from invoke terminators to their normal destination are
+// split as well, as the result of an invoke is only defined on that edge, as
+// are edges whose incoming value is the result of the terminator of the
+// predecessor (e.g. callbr). Functions with such edges which may not be split
+// (e.g. edges from callbr terminators) are left in SSA form, as the copies of
+// the edge cannot be placed in the predecessor.
+//
+// Isolating the result of each phi instruction by a copy at the start of its
+// basic block solves the lost-copy problem: uses of the phi instruction refer
+// to the copied value, which is not clobbered by copies to the variable in
+// later iterations of a loop. The copies of a predecessor basic block form a
+// parallel copy; as their sources are SSA values rather than phi variables, no
+// copy overwrites the source of another, which solves the swap problem (e.g.
+// phi instructions whose incoming values are each other's results).
+package outofssa
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/value"
+	"github.com/llir/llvm/irutil"
+)
+
+// Run translates the given function out of SSA form, and reports whether the
+// function was changed. Functions with edges to phi instructions which must be
+// split but may not be split are left unchanged.
+func Run(f *ir.Func) bool {
+	if !hasPhis(f) {
+		return false
+	}
+	edges, ok := splitEdges(f)
+	if !ok {
+		return false
+	}
+	for _, e := range edges {
+		irutil.SplitEdge(f, e.from, e.to)
+	}
+	entry := f.Blocks[0]
+	var slots []ir.Instruction
+	repl := make(map[value.Value]value.Value)
+	for _, block := range f.Blocks {
+		phis := irutil.Phis(block)
+		if len(phis) == 0 {
+			continue
+		}
+		block.Insts = block.Insts[len(phis):]
+		var copies []ir.Instruction
+		for _, phi := range phis {
+			slot := ir.NewAlloca(phi.Type())
+			if name := irutil.LocalName(phi); len(name) > 0 {
+				slot.SetName(irutil.UniqueLocalName(f, name+".slot"))
+			}
+			slots = append(slots, slot)
+			// Copy incoming values at the end of predecessors, once per
+			// predecessor.
+			seen := make(map[*ir.Block]bool)
+			for _, inc := range phi.Incs {
+				pred := inc.Pred.(*ir.Block)
+				if seen[pred] {
+					continue
+				}
+				seen[pred] = true
+				pred.Insts = append(pred.Insts, ir.NewStore(inc.X, slot))
+			}
+			load := ir.NewLoad(phi.Type(), slot)
+			load.SetName(irutil.LocalName(phi))
+			copies = append(copies, load)
+			repl[phi] = load
+		}
+		i := irutil.FirstInsertionIndex(block)
+		block.Insts = append(block.Insts[:i], append(copies, block.Insts[i:]...)...)
+	}
+	entry.Insts = append(slots, entry.Insts...)
+	irutil.ReplaceAll(f, repl)
+	irutil.ResetLocalIDs(f)
+	return true
+}
+
+// RunModule translates the function definitions of the given module out of SSA
+// form, and reports whether the module was changed.
+func RunModule(m *ir.Module) bool {
+	changed := false
+	for _, f := range m.Funcs {
+		if Run(f) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// edge is a control flow edge.
+type edge struct {
+	from, to *ir.Block
+}
+
+// splitEdges returns the edges to basic blocks with phi instructions which must
+// be split; i.e. critical edges, edges from invoke terminators and edges whose
+// incoming value is the result of the terminator of the predecessor, and
+// reports whether all such edges may be split.
+func splitEdges(f *ir.Func) ([]edge, bool) {
+	preds := make(map[*ir.Block][]*ir.Block)
+	for _, block := range f.Blocks {
+		seen := make(map[*ir.Block]bool)
+		for _, succ := range block.Term.Succs() {
+			if !seen[succ] {
+				seen[succ] = true
+				preds[succ] = append(preds[succ], block)
+			}
+		}
+	}
+	var edges []edge
+	for _, to := range f.Blocks {
+		if len(irutil.Phis(to)) == 0 {
+			continue
+		}
+		for _, from := range preds[to] {
+			_, invoke := from.Term.(*ir.TermInvoke)
+			if !invoke && !irutil.IsCriticalEdge(from, to, preds) && !incomingTerm(to, from) {
+				continue
+			}
+			if !irutil.CanSplitEdge(from, to) {
+				return nil, false
+			}
+			edges = append(edges, edge{from: from, to: to})
+		}
+	}
+	return edges, true
+}
+
+// incomingTerm reports whether the incoming value of a phi instruction of to
+// from the predecessor from is the result of the terminator of from.
+func incomingTerm(to, from *ir.Block) bool {
+	term, ok := from.Term.(value.Value)
+	if !ok {
+		return false
+	}
+	for _, phi := range irutil.Phis(to) {
+		for _, inc := range phi.Incs {
+			if inc.Pred == from && inc.X == term {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasPhis reports whether the given function contains phi instructions.
+func hasPhis(f *ir.Func) bool {
+	for _, block := range f.Blocks {
+		if len(irutil.Phis(block)) > 0 {
+			return true
+		}
+	}
+	return false
+}
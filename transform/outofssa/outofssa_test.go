@@ -0,0 +1,196 @@
+package outofssa
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+)
+
+func TestRun(t *testing.T) {
+	golden := []struct {
+		name string
+		in   string
+		want string
+	}{
+		// Lost-copy problem; the phi result is used after the loop, while the phi
+		// variable is overwritten on the back edge.
+		{
+			name: "lostcopy",
+			in: `
+define i32 @lostcopy(i32 %n) {
+entry:
+	br label %loop
+
+loop:
+	%x = phi i32 [ 1, %entry ], [ %y, %loop ]
+	%y = add i32 %x, 1
+	%c = icmp slt i32 %y, %n
+	br i1 %c, label %loop, label %exit
+
+exit:
+	ret i32 %x
+}`,
+			want: `
+define i32 @lostcopy(i32 %n) {
+entry:
+	%x.slot = alloca i32
+	store i32 1, i32* %x.slot
+	br label %loop
+
+loop:
+	%x = load i32, i32* %x.slot
+	%y = add i32 %x, 1
+	%c = icmp slt i32 %y, %n
+	br i1 %c, label %loop.loop_crit_edge, label %exit
+
+loop.loop_crit_edge:
+	store i32 %y, i32* %x.slot
+	br label %loop
+
+exit:
+	ret i32 %x
+}`,
+		},
+		// Swap problem; the incoming values of the phi instructions are each
+		// other's results.
+		{
+			name: "swap",
+			in: `
+define i32 @swap(i32 %a, i32 %b, i32 %n) {
+entry:
+	br label %loop
+
+loop:
+	%i = phi i32 [ 0, %entry ], [ %i.next, %loop ]
+	%x = phi i32 [ %a, %entry ], [ %y, %loop ]
+	%y = phi i32 [ %b, %entry ], [ %x, %loop ]
+	%i.next = add i32 %i, 1
+	%c = icmp slt i32 %i.next, %n
+	br i1 %c, label %loop, label %exit
+
+exit:
+	%r = sub i32 %x, %y
+	ret i32 %r
+}`,
+			want: `
+define i32 @swap(i32 %a, i32 %b, i32 %n) {
+entry:
+	%i.slot = alloca i32
+	%x.slot = alloca i32
+	%y.slot = alloca i32
+	store i32 0, i32* %i.slot
+	store i32 %a, i32* %x.slot
+	store i32 %b, i32* %y.slot
+	br label %loop
+
+loop:
+	%i = load i32, i32* %i.slot
+	%x = load i32, i32* %x.slot
+	%y = load i32, i32* %y.slot
+	%i.next = add i32 %i, 1
+	%c = icmp slt i32 %i.next, %n
+	br i1 %c, label %loop.loop_crit_edge, label %exit
+
+loop.loop_crit_edge:
+	store i32 %i.next, i32* %i.slot
+	store i32 %y, i32* %x.slot
+	store i32 %x, i32* %y.slot
+	br label %loop
+
+exit:
+	%r = sub i32 %x, %y
+	ret i32 %r
+}`,
+		},
+		// Critical edges of switch terminators, with several cases to the same
+		// target.
+		{
+			name: "switch",
+			in: `
+define i32 @switch(i32 %x) {
+entry:
+	switch i32 %x, label %exit [
+		i32 0, label %a
+		i32 1, label %exit
+		i32 2, label %exit
+	]
+
+a:
+	br label %exit
+
+exit:
+	%r = phi i32 [ 10, %entry ], [ 20, %a ]
+	ret i32 %r
+}`,
+			want: `
+define i32 @switch(i32 %x) {
+entry:
+	%r.slot = alloca i32
+	switch i32 %x, label %entry.exit_crit_edge [
+		i32 0, label %a
+		i32 1, label %entry.exit_crit_edge
+		i32 2, label %entry.exit_crit_edge
+	]
+
+entry.exit_crit_edge:
+	store i32 10, i32* %r.slot
+	br label %exit
+
+a:
+	store i32 20, i32* %r.slot
+	br label %exit
+
+exit:
+	%r = load i32, i32* %r.slot
+	ret i32 %r
+}`,
+		},
+		// Edges from callbr terminators may not be split; the function is left in
+		// SSA form, as the result of the callbr cannot be copied before the
+		// callbr.
+		{
+			name: "callbr",
+			in: `
+define i32 @callbr(i32 %x) {
+entry:
+	%r = callbr i32 asm "", "=r,r,X"(i32 %x, i8* blockaddress(@callbr, %indirect))
+			to label %exit [label %indirect]
+
+indirect:
+	br label %exit
+
+exit:
+	%y = phi i32 [ %r, %entry ], [ 0, %indirect ]
+	ret i32 %y
+}`,
+			want: `
+define i32 @callbr(i32 %x) {
+entry:
+	%r = callbr i32 asm "", "=r,r,X"(i32 %x, i8* blockaddress(@callbr, %indirect))
+		to label %exit [label %indirect]
+
+indirect:
+	br label %exit
+
+exit:
+	%y = phi i32 [ %r, %entry ], [ 0, %indirect ]
+	ret i32 %y
+}`,
+		},
+	}
+	for _, g := range golden {
+		m, err := asm.ParseString(g.name+".ll", g.in)
+		if err != nil {
+			t.Errorf("%q: unable to parse module; %+v", g.name, err)
+			continue
+		}
+		f := m.Funcs[len(m.Funcs)-1]
+		Run(f)
+		got := strings.TrimSpace(f.LLString())
+		want := strings.TrimSpace(g.want)
+		if got != want {
+			t.Errorf("%q: function mismatch; expected:\n%s\n\ngot:\n%s", g.name, want, got)
+		}
+	}
+}
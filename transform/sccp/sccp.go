@@ -0,0 +1,610 @@
+// Package sccp implements sparse conditional constant propagation, which
+// discovers constant values and unreachable basic blocks of LLVM IR functions.
+//
+// The analysis optimistically assumes that every value is undetermined and that
+// only the entry basic block is executable. It then propagates lattice values
+// (undetermined, constant or overdefined) along the SSA def-use chains and
+// marks control flow edges executable as terminators are evaluated. Phi
+// instructions only merge incoming values of executable edges, and conditional
+// branches on constants only mark the taken edge executable, so that constants
+// and unreachable code are discovered together.
+//
+// Uses of values determined to be constant are replaced by their constant
+// value, conditional branches and switches on constants are folded to
+// unconditional branches, and unreachable basic blocks are removed.
+//
+// The interprocedural variant (RunModule) additionally propagates constant
+// arguments into the parameters of internal functions whose address is not
+// taken, by merging the arguments of all executable call sites.
+package sccp
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/llir/llvm/irutil"
+)
+
+// Run propagates constants of the given function, and reports whether the
+// function was changed.
+func Run(f *ir.Func) bool {
+	if len(f.Blocks) == 0 {
+		return false
+	}
+	s := newSolver(f, nil)
+	s.solve()
+	return s.rewrite()
+}
+
+// RunModule propagates constants of the function definitions of the given
+// module, including constant arguments of internal functions, and reports
+// whether the module was changed.
+func RunModule(m *ir.Module) bool {
+	// Parameters of internal functions start out undetermined, and are lowered
+	// by the arguments of executable call sites.
+	params := make(map[*ir.Param]lattice)
+	internal := internalFuncs(m)
+	for f := range internal {
+		for _, param := range f.Params {
+			params[param] = lattice{}
+		}
+	}
+	var work []*ir.Func
+	queued := make(map[*ir.Func]bool)
+	for _, f := range m.Funcs {
+		if len(f.Blocks) > 0 {
+			work = append(work, f)
+			queued[f] = true
+		}
+	}
+	solvers := make(map[*ir.Func]*solver)
+	for len(work) > 0 {
+		f := work[0]
+		work = work[1:]
+		delete(queued, f)
+		s := newSolver(f, params)
+		s.solve()
+		solvers[f] = s
+		for _, callee := range s.propagateArgs(internal) {
+			if !queued[callee] {
+				work = append(work, callee)
+				queued[callee] = true
+			}
+		}
+	}
+	changed := false
+	for _, f := range m.Funcs {
+		if s, ok := solvers[f]; ok && s.rewrite() {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// --- [ Lattice ] -------------------------------------------------------------
+
+// state is the state of a lattice value.
+type state uint8
+
+// Lattice value states.
+const (
+	// Value not yet determined (top).
+	undetermined state = iota
+	// Value is constant.
+	constantValue
+	// Value not constant (bottom).
+	overdefined
+)
+
+// lattice is a lattice value of the analysis.
+type lattice struct {
+	// State of the lattice value.
+	state state
+	// Constant value; or nil if not constant.
+	c constant.Constant
+}
+
+// meet returns the greatest lower bound of the lattice values x and y.
+func (x lattice) meet(y lattice) lattice {
+	switch {
+	case x.state == undetermined:
+		return y
+	case y.state == undetermined:
+		return x
+	case x.state == overdefined || y.state == overdefined:
+		return lattice{state: overdefined}
+	case irutil.ConstEqual(x.c, y.c):
+		return x
+	}
+	return lattice{state: overdefined}
+}
+
+// equal reports whether the lattice values x and y are identical.
+func (x lattice) equal(y lattice) bool {
+	if x.state != y.state {
+		return false
+	}
+	return x.state != constantValue || irutil.ConstEqual(x.c, y.c)
+}
+
+// --- [ Solver ] --------------------------------------------------------------
+
+// solver solves the constant propagation problem of a function.
+type solver struct {
+	// Function being analyzed.
+	f *ir.Func
+	// Lattice values of parameters; parameters not present are overdefined.
+	params map[*ir.Param]lattice
+	// Lattice values of instructions.
+	values map[value.Value]lattice
+	// Executable basic blocks.
+	execBlocks map[*ir.Block]bool
+	// Executable control flow edges.
+	execEdges map[edge]bool
+	// Users of each instruction.
+	users map[value.Value][]value.User
+	// Parent basic block of each instruction and terminator.
+	blockOf map[value.User]*ir.Block
+	// Worklist of newly executable basic blocks.
+	blockWork []*ir.Block
+	// Worklist of instructions and terminators whose operands have changed.
+	userWork []value.User
+}
+
+// edge is a control flow edge between two basic blocks.
+type edge struct {
+	from, to *ir.Block
+}
+
+// newSolver returns a new solver of the given function, with the given
+// lattice values of parameters.
+func newSolver(f *ir.Func, params map[*ir.Param]lattice) *solver {
+	s := &solver{
+		f:          f,
+		params:     params,
+		values:     make(map[value.Value]lattice),
+		execBlocks: make(map[*ir.Block]bool),
+		execEdges:  make(map[edge]bool),
+		users:      make(map[value.Value][]value.User),
+		blockOf:    make(map[value.User]*ir.Block),
+	}
+	addUser := func(block *ir.Block, user value.User) {
+		s.blockOf[user] = block
+		for _, op := range user.Operands() {
+			v := irutil.Unwrap(*op)
+			switch v.(type) {
+			case ir.Instruction, *ir.Param:
+				s.users[v] = append(s.users[v], user)
+			}
+		}
+	}
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			if user, ok := inst.(value.User); ok {
+				addUser(block, user)
+			}
+		}
+		if block.Term != nil {
+			addUser(block, block.Term)
+		}
+	}
+	return s
+}
+
+// solve computes the lattice values of the function and its executable basic
+// blocks and edges.
+func (s *solver) solve() {
+	s.markBlock(s.f.Blocks[0])
+	for {
+		for len(s.blockWork) > 0 || len(s.userWork) > 0 {
+			for len(s.userWork) > 0 {
+				n := len(s.userWork) - 1
+				user := s.userWork[n]
+				s.userWork = s.userWork[:n]
+				if s.execBlocks[s.blockOf[user]] {
+					s.visit(user)
+				}
+			}
+			for len(s.blockWork) > 0 {
+				block := s.blockWork[0]
+				s.blockWork = s.blockWork[1:]
+				for _, inst := range block.Insts {
+					if user, ok := inst.(value.User); ok {
+						s.visit(user)
+					}
+				}
+				s.visit(block.Term)
+			}
+		}
+		// Branches on values which remain undetermined (e.g. values derived
+		// from undetermined parameters) are resolved as if overdefined, and the
+		// solver is rerun until no such branches remain.
+		if !s.resolveBranches() {
+			break
+		}
+	}
+}
+
+// resolveBranches marks all outgoing edges of executable conditional branches
+// and switches with undetermined conditions executable, and reports whether
+// any edge was marked.
+func (s *solver) resolveBranches() bool {
+	resolved := false
+	for _, block := range s.f.Blocks {
+		if !s.execBlocks[block] {
+			continue
+		}
+		var cond value.Value
+		switch term := block.Term.(type) {
+		case *ir.TermCondBr:
+			cond = term.Cond
+		case *ir.TermSwitch:
+			cond = term.X
+		default:
+			continue
+		}
+		if s.get(cond).state == undetermined {
+			n := len(s.execEdges)
+			s.markEdges(block)
+			if len(s.execEdges) > n {
+				resolved = true
+			}
+		}
+	}
+	return resolved
+}
+
+// get returns the lattice value of the given value.
+func (s *solver) get(v value.Value) lattice {
+	u := irutil.Unwrap(v)
+	switch v := u.(type) {
+	case *ir.Param:
+		if l, ok := s.params[v]; ok {
+			return l
+		}
+		return lattice{state: overdefined}
+	case constant.Constant:
+		return lattice{state: constantValue, c: v}
+	case ir.Instruction:
+		return s.values[u]
+	}
+	return lattice{state: overdefined}
+}
+
+// update lowers the lattice value of v by l, and adds the users of v to the
+// worklist if its lattice value changed.
+func (s *solver) update(v value.Value, l lattice) {
+	old := s.values[v]
+	new := old.meet(l)
+	if new.equal(old) {
+		return
+	}
+	s.values[v] = new
+	s.userWork = append(s.userWork, s.users[v]...)
+}
+
+// markBlock marks the given basic block executable.
+func (s *solver) markBlock(block *ir.Block) {
+	if s.execBlocks[block] {
+		return
+	}
+	s.execBlocks[block] = true
+	s.blockWork = append(s.blockWork, block)
+}
+
+// markEdge marks the control flow edge from -> to executable.
+func (s *solver) markEdge(from, to *ir.Block) {
+	e := edge{from: from, to: to}
+	if s.execEdges[e] {
+		return
+	}
+	s.execEdges[e] = true
+	if s.execBlocks[to] {
+		// Reevaluate phi instructions of the already executable basic block.
+		for _, phi := range irutil.Phis(to) {
+			s.visit(phi)
+		}
+		return
+	}
+	s.markBlock(to)
+}
+
+// visit evaluates the given instruction or terminator.
+func (s *solver) visit(user value.User) {
+	block := s.blockOf[user]
+	switch user := user.(type) {
+	case *ir.InstPhi:
+		l := lattice{}
+		for _, inc := range user.Incs {
+			if s.execEdges[edge{from: inc.Pred.(*ir.Block), to: block}] {
+				l = l.meet(s.get(inc.X))
+			}
+		}
+		s.update(user, l)
+	case *ir.TermCondBr:
+		l := s.get(user.Cond)
+		switch {
+		case l.state == undetermined:
+		case l.state == constantValue && isInt(l.c):
+			if l.c.(*constant.Int).X.Sign() != 0 {
+				s.markEdge(block, user.TargetTrue.(*ir.Block))
+			} else {
+				s.markEdge(block, user.TargetFalse.(*ir.Block))
+			}
+		default:
+			s.markEdges(block)
+		}
+	case *ir.TermSwitch:
+		l := s.get(user.X)
+		switch {
+		case l.state == undetermined:
+		case l.state == constantValue && isInt(l.c):
+			s.markEdge(block, switchTarget(user, l.c))
+		default:
+			s.markEdges(block)
+		}
+	case ir.Terminator:
+		s.markEdges(block)
+	case ir.Instruction:
+		v, ok := user.(value.Value)
+		if !ok || v.Type().Equal(types.Void) {
+			return
+		}
+		undet := false
+		lookup := func(v value.Value) constant.Constant {
+			l := s.get(v)
+			if l.state == undetermined {
+				undet = true
+			}
+			return l.c
+		}
+		if c := irutil.FoldInstWith(user, lookup); c != nil {
+			s.update(v, lattice{state: constantValue, c: c})
+		} else if !undet {
+			s.update(v, lattice{state: overdefined})
+		}
+	}
+}
+
+// markEdges marks all outgoing control flow edges of the given basic block
+// executable.
+func (s *solver) markEdges(block *ir.Block) {
+	for _, succ := range block.Term.Succs() {
+		s.markEdge(block, succ)
+	}
+}
+
+// propagateArgs lowers the lattice values of the parameters of the given
+// internal functions by the arguments of the executable call sites of the
+// function, and returns the callees whose parameters changed.
+func (s *solver) propagateArgs(internal map[*ir.Func]bool) []*ir.Func {
+	var changed []*ir.Func
+	for _, block := range s.f.Blocks {
+		if !s.execBlocks[block] {
+			continue
+		}
+		for _, inst := range block.Insts {
+			if call, ok := inst.(*ir.InstCall); ok {
+				changed = s.propagateCall(internal, call.Callee, call.Args, changed)
+			}
+		}
+		switch term := block.Term.(type) {
+		case *ir.TermInvoke:
+			changed = s.propagateCall(internal, term.Invokee, term.Args, changed)
+		case *ir.TermCallBr:
+			changed = s.propagateCall(internal, term.Callee, term.Args, changed)
+		}
+	}
+	return changed
+}
+
+// propagateCall lowers the lattice values of the parameters of the callee by
+// the given arguments if the callee is an internal function, and appends the
+// callee to changed if any parameter changed.
+func (s *solver) propagateCall(internal map[*ir.Func]bool, callee value.Value, args []value.Value, changed []*ir.Func) []*ir.Func {
+	f, ok := callee.(*ir.Func)
+	if !ok || !internal[f] {
+		return changed
+	}
+	updated := false
+	for i, param := range f.Params {
+		old := s.params[param]
+		new := old.meet(s.get(args[i]))
+		if !new.equal(old) {
+			s.params[param] = new
+			updated = true
+		}
+	}
+	if updated {
+		changed = append(changed, f)
+	}
+	return changed
+}
+
+// --- [ Rewrite ] -------------------------------------------------------------
+
+// rewrite replaces constant values of the function, folds branches on
+// constants and removes unreachable basic blocks, and reports whether the
+// function was changed.
+func (s *solver) rewrite() bool {
+	repl := make(map[value.Value]value.Value)
+	dead := make(map[ir.Instruction]bool)
+	deadBlocks := make(map[*ir.Block]bool)
+	for _, param := range s.f.Params {
+		if l := s.get(param); l.state == constantValue {
+			repl[param] = l.c
+		}
+	}
+	changed := false
+	for _, block := range s.f.Blocks {
+		if !s.execBlocks[block] {
+			deadBlocks[block] = true
+			continue
+		}
+		for _, inst := range block.Insts {
+			v, ok := inst.(value.Value)
+			if !ok {
+				continue
+			}
+			if l := s.values[v]; l.state == constantValue {
+				repl[v] = l.c
+				if irutil.IsTriviallyDead(inst) {
+					dead[inst] = true
+				}
+			}
+		}
+		if s.foldTerm(block) {
+			changed = true
+		}
+	}
+	// Remove incoming values of unreachable predecessors.
+	for _, block := range s.f.Blocks {
+		if deadBlocks[block] {
+			continue
+		}
+		for pred := range deadBlocks {
+			irutil.RemovePhiPred(block, pred)
+		}
+	}
+	if len(repl) == 0 && len(deadBlocks) == 0 && !changed {
+		return false
+	}
+	irutil.RemoveBlocks(s.f, deadBlocks)
+	irutil.ReplaceAll(s.f, repl)
+	irutil.RemoveInsts(s.f, dead)
+	irutil.ResetLocalIDs(s.f)
+	return true
+}
+
+// foldTerm replaces a conditional branch or switch terminator of the given
+// basic block with an unconditional branch if only one of its outgoing edges is
+// executable, and reports whether the terminator was folded.
+func (s *solver) foldTerm(block *ir.Block) bool {
+	var target *ir.Block
+	var md ir.Metadata
+	switch term := block.Term.(type) {
+	case *ir.TermCondBr:
+		md = term.Metadata
+	case *ir.TermSwitch:
+		md = term.Metadata
+	default:
+		return false
+	}
+	for _, succ := range block.Term.Succs() {
+		if !s.execEdges[edge{from: block, to: succ}] {
+			continue
+		}
+		if target != nil && target != succ {
+			// More than one executable successor.
+			return false
+		}
+		target = succ
+	}
+	if target == nil {
+		return false
+	}
+	for _, succ := range block.Term.Succs() {
+		if succ != target {
+			irutil.RemovePhiPred(succ, block)
+		}
+	}
+	br := ir.NewBr(target)
+	br.Metadata = md
+	block.Term = br
+	return true
+}
+
+// ### [ Helper functions ] ####################################################
+
+// internalFuncs returns the set of function definitions of the given module
+// with internal or private linkage whose address is not taken; i.e. which are
+// only used as the callee of direct calls with matching arguments.
+func internalFuncs(m *ir.Module) map[*ir.Func]bool {
+	internal := make(map[*ir.Func]bool)
+	for _, f := range m.Funcs {
+		switch f.Linkage {
+		case enum.LinkageInternal, enum.LinkagePrivate:
+			if len(f.Blocks) > 0 && !f.Sig.Variadic {
+				internal[f] = true
+			}
+		}
+	}
+	escape := func(c constant.Constant) {
+		irutil.WalkConst(c, func(c constant.Constant) bool {
+			if f, ok := c.(*ir.Func); ok {
+				delete(internal, f)
+			}
+			return true
+		})
+	}
+	// escapeOperands marks the functions referred to by the operands of the
+	// given instruction or terminator as address taken, except for the callee
+	// operand of direct calls.
+	escapeOperands := func(user value.User, callee *value.Value, nargs int) {
+		for _, op := range user.Operands() {
+			if op == callee {
+				continue
+			}
+			if c, ok := irutil.Unwrap(*op).(constant.Constant); ok {
+				escape(c)
+			}
+		}
+		if callee == nil {
+			return
+		}
+		if f, ok := (*callee).(*ir.Func); ok && nargs != len(f.Params) {
+			delete(internal, f)
+		}
+	}
+	for _, f := range m.Funcs {
+		for _, block := range f.Blocks {
+			for _, inst := range block.Insts {
+				switch inst := inst.(type) {
+				case *ir.InstCall:
+					escapeOperands(inst, &inst.Callee, len(inst.Args))
+				case value.User:
+					escapeOperands(inst, nil, 0)
+				}
+			}
+			switch term := block.Term.(type) {
+			case *ir.TermInvoke:
+				escapeOperands(term, &term.Invokee, len(term.Args))
+			case *ir.TermCallBr:
+				escapeOperands(term, &term.Callee, len(term.Args))
+			case value.User:
+				escapeOperands(term, nil, 0)
+			}
+		}
+	}
+	for _, g := range m.Globals {
+		if g.Init != nil {
+			escape(g.Init)
+		}
+	}
+	for _, alias := range m.Aliases {
+		escape(alias.Aliasee)
+	}
+	for _, ifunc := range m.IFuncs {
+		escape(ifunc.Resolver)
+	}
+	return internal
+}
+
+// switchTarget returns the target basic block of the given switch terminator
+// for the control variable x.
+func switchTarget(term *ir.TermSwitch, x constant.Constant) *ir.Block {
+	for _, c := range term.Cases {
+		if y, ok := c.X.(constant.Constant); ok && irutil.ConstEqual(x, y) {
+			return c.Target.(*ir.Block)
+		}
+	}
+	return term.TargetDefault.(*ir.Block)
+}
+
+// isInt reports whether c is an integer constant.
+func isInt(c constant.Constant) bool {
+	_, ok := c.(*constant.Int)
+	return ok
+}
@@ -0,0 +1,212 @@
+package sccp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+)
+
+func TestRun(t *testing.T) {
+	golden := []struct {
+		name string
+		in   string
+		want string
+	}{
+		// Conditional branch on a constant; phi of the executable edge.
+		{
+			name: "branch",
+			in: `
+define i32 @branch(i32 %x) {
+entry:
+	%a = add i32 2, 3
+	%c = icmp eq i32 %a, 5
+	br i1 %c, label %then, label %else
+
+then:
+	%b = mul i32 %a, 2
+	br label %join
+
+else:
+	%d = add i32 %x, 1
+	br label %join
+
+join:
+	%r = phi i32 [ %b, %then ], [ %d, %else ]
+	%s = add i32 %r, %x
+	ret i32 %s
+}`,
+			want: `
+define i32 @branch(i32 %x) {
+entry:
+	br label %then
+
+then:
+	br label %join
+
+join:
+	%s = add i32 10, %x
+	ret i32 %s
+}`,
+		},
+		// Loop with a loop-invariant phi.
+		{
+			name: "loop",
+			in: `
+define i32 @loop(i32 %n) {
+entry:
+	br label %loop
+
+loop:
+	%i = phi i32 [ 0, %entry ], [ %i.next, %body ]
+	%k = phi i32 [ 7, %entry ], [ %k.next, %body ]
+	%cmp = icmp slt i32 %i, %n
+	br i1 %cmp, label %body, label %exit
+
+body:
+	%k.next = add i32 %k, 0
+	%i.next = add i32 %i, 1
+	br label %loop
+
+exit:
+	ret i32 %k
+}`,
+			want: `
+define i32 @loop(i32 %n) {
+entry:
+	br label %loop
+
+loop:
+	%i = phi i32 [ 0, %entry ], [ %i.next, %body ]
+	%cmp = icmp slt i32 %i, %n
+	br i1 %cmp, label %body, label %exit
+
+body:
+	%i.next = add i32 %i, 1
+	br label %loop
+
+exit:
+	ret i32 7
+}`,
+		},
+		// Switch on a constant.
+		{
+			name: "switch",
+			in: `
+define i32 @switch() {
+entry:
+	%x = add i32 1, 1
+	switch i32 %x, label %default [
+		i32 1, label %one
+		i32 2, label %two
+	]
+
+one:
+	br label %exit
+
+two:
+	br label %exit
+
+default:
+	br label %exit
+
+exit:
+	%r = phi i32 [ 10, %one ], [ 20, %two ], [ 30, %default ]
+	ret i32 %r
+}`,
+			want: `
+define i32 @switch() {
+entry:
+	br label %two
+
+two:
+	br label %exit
+
+exit:
+	ret i32 20
+}`,
+		},
+	}
+	for _, g := range golden {
+		m, err := asm.ParseString(g.name+".ll", g.in)
+		if err != nil {
+			t.Errorf("%q: unable to parse module; %+v", g.name, err)
+			continue
+		}
+		f := m.Funcs[len(m.Funcs)-1]
+		Run(f)
+		got := strings.TrimSpace(f.LLString())
+		want := strings.TrimSpace(g.want)
+		if got != want {
+			t.Errorf("%q: function mismatch; expected:\n%s\n\ngot:\n%s", g.name, want, got)
+		}
+	}
+}
+
+func TestRunModule(t *testing.T) {
+	// Constant arguments are propagated into @scale, but not into @escaped as
+	// its address is taken.
+	const in = `
+define internal i32 @scale(i32 %x, i32 %k) {
+entry:
+	%c = icmp eq i32 %k, 0
+	br i1 %c, label %zero, label %mul
+
+zero:
+	ret i32 0
+
+mul:
+	%r = mul i32 %x, %k
+	ret i32 %r
+}
+
+define internal i32 @escaped(i32 %x) {
+entry:
+	ret i32 %x
+}
+
+@fp = global i32 (i32)* @escaped
+
+define i32 @main(i32 %a) {
+entry:
+	%b = call i32 @scale(i32 %a, i32 4)
+	%c = call i32 @scale(i32 %b, i32 4)
+	%d = call i32 @escaped(i32 1)
+	%e = add i32 %c, %d
+	ret i32 %e
+}`
+	const want = `
+@fp = global i32 (i32)* @escaped
+
+define internal i32 @scale(i32 %x, i32 %k) {
+entry:
+	br label %mul
+
+mul:
+	%r = mul i32 %x, 4
+	ret i32 %r
+}
+
+define internal i32 @escaped(i32 %x) {
+entry:
+	ret i32 %x
+}
+
+define i32 @main(i32 %a) {
+entry:
+	%b = call i32 @scale(i32 %a, i32 4)
+	%c = call i32 @scale(i32 %b, i32 4)
+	%d = call i32 @escaped(i32 1)
+	%e = add i32 %c, %d
+	ret i32 %e
+}`
+	m, err := asm.ParseString("ipsccp.ll", in)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	RunModule(m)
+	got := strings.TrimSpace(m.String())
+	if got != strings.TrimSpace(want) {
+		t.Errorf("module mismatch; expected:\n%s\n\ngot:\n%s", strings.TrimSpace(want), got)
+	}
+}
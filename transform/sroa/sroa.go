@@ -0,0 +1,771 @@
+// Package sroa implements scalar replacement of aggregates for LLVM IR
+// functions.
+//
+// Allocas of struct and array types are split into one alloca per scalar
+// element (i.e. per leaf field of the aggregate), provided that every access of
+// the alloca is at a constant byte offset, as computed from constant
+// getelementptr indices using the data layout of the module. The scalar allocas
+// may subsequently be promoted to SSA values.
+//
+// Besides loads and stores of scalar elements, the following accesses are
+// supported:
+//
+//   - loads and stores of entire (nested) aggregates, which are rewritten into
+//     accesses of their scalar elements, using extractvalue and insertvalue
+//     instructions;
+//   - llvm.memset intrinsic calls setting the entire alloca to a byte value
+//     representable by each scalar element;
+//   - llvm.memcpy and llvm.memmove intrinsic calls copying the entire alloca to
+//     or from another memory location of the same size.
+package sroa
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/llir/llvm/analysis/alias"
+	"github.com/llir/llvm/intrinsics"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/datalayout"
+	"github.com/llir/llvm/ir/metadata"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/llir/llvm/irutil"
+	"github.com/pkg/errors"
+)
+
+// MaxElements is the maximum number of scalar elements of allocas split by
+// scalar replacement of aggregates.
+const MaxElements = 64
+
+// Run splits the aggregate allocas of the given function into scalar allocas,
+// based on the data layout dl, and reports whether the function was changed.
+// The default data layout is used if dl is nil.
+func Run(f *ir.Func, dl *datalayout.Layout) bool {
+	if dl == nil {
+		dl = datalayout.Default()
+	}
+	aa := alias.NewBasic(dl)
+	changed := false
+	// Splitting an alloca may rewrite memcpy calls into accesses of another
+	// alloca, which may in turn be split; repeat until no alloca is split.
+	for {
+		split := false
+		users := userMap(f)
+		for _, block := range f.Blocks {
+			for _, inst := range block.Insts {
+				alloca, ok := inst.(*ir.InstAlloca)
+				if !ok {
+					continue
+				}
+				if s := analyze(dl, aa, alloca, users); s != nil {
+					s.rewrite(f)
+					split = true
+					break
+				}
+			}
+			if split {
+				break
+			}
+		}
+		if !split {
+			break
+		}
+		changed = true
+	}
+	if changed {
+		irutil.ResetLocalIDs(f)
+	}
+	return changed
+}
+
+// RunModule splits the aggregate allocas of the function definitions of the
+// given module, based on the data layout of the module, and reports whether the
+// module was changed.
+func RunModule(m *ir.Module) (bool, error) {
+	dl, err := datalayout.Parse(m.DataLayout)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	changed := false
+	for _, f := range m.Funcs {
+		if Run(f, dl) {
+			changed = true
+		}
+	}
+	return changed, nil
+}
+
+// --- [ Scalar elements ] -----------------------------------------------------
+
+// element is a scalar element of an aggregate type.
+type element struct {
+	// Byte offset of the element within the aggregate.
+	offset uint64
+	// Type of the element.
+	typ types.Type
+	// Indices of the element within the aggregate, as used by extractvalue and
+	// insertvalue instructions.
+	path []uint64
+}
+
+// elements returns the scalar elements of the given type at the given byte
+// offset, or false if the type has more than max scalar elements. Elements of
+// zero size are omitted.
+func elements(dl *datalayout.Layout, t types.Type, offset uint64, path []uint64, elems []element, max int) ([]element, bool) {
+	sub := func(i int) []uint64 {
+		p := make([]uint64, len(path), len(path)+1)
+		copy(p, path)
+		return append(p, uint64(i))
+	}
+	ok := true
+	switch t := t.(type) {
+	case *types.StructType:
+		if t.Opaque {
+			return nil, false
+		}
+		layout := dl.StructLayout(t)
+		for i, field := range t.Fields {
+			if elems, ok = elements(dl, field, offset+layout.Offsets[i], sub(i), elems, max); !ok {
+				return nil, false
+			}
+		}
+		return elems, true
+	case *types.ArrayType:
+		if t.Len > uint64(max) {
+			return nil, false
+		}
+		size := dl.AllocSize(t.ElemType)
+		for i := uint64(0); i < t.Len; i++ {
+			if elems, ok = elements(dl, t.ElemType, offset+i*size, sub(int(i)), elems, max); !ok {
+				return nil, false
+			}
+		}
+		return elems, true
+	}
+	if dl.StoreSize(t) == 0 {
+		return elems, true
+	}
+	if len(elems) >= max {
+		return nil, false
+	}
+	return append(elems, element{offset: offset, typ: t, path: path}), true
+}
+
+// --- [ Analysis ] ------------------------------------------------------------
+
+// split is an alloca to be split into scalar allocas.
+type split struct {
+	dl *datalayout.Layout
+	// Alias analysis used to compute the underlying objects of pointers.
+	aa *alias.Basic
+	// Aggregate alloca.
+	alloca *ir.InstAlloca
+	// Scalar elements of the allocated type.
+	elems []element
+	// Index of the scalar element at each byte offset.
+	index map[uint64]int
+	// Byte offset of each pointer derived from the alloca.
+	ptrs map[value.Value]uint64
+	// Memory accesses of the alloca, in order of discovery.
+	accesses []ir.Instruction
+	// Users of each value of the function.
+	users map[value.Value][]value.User
+}
+
+// analyze returns the split of the given alloca, or nil if the alloca cannot be
+// split. The users of each value of the function are given by users, and the
+// underlying objects of pointers are computed using aa.
+func analyze(dl *datalayout.Layout, aa *alias.Basic, alloca *ir.InstAlloca, users map[value.Value][]value.User) *split {
+	switch alloca.ElemType.(type) {
+	case *types.StructType, *types.ArrayType:
+	default:
+		return nil
+	}
+	if alloca.NElems != nil {
+		if n, ok := alloca.NElems.(*constant.Int); !ok || !n.X.IsInt64() || n.X.Int64() != 1 {
+			return nil
+		}
+	}
+	if alloca.InAlloca || alloca.SwiftError {
+		return nil
+	}
+	elems, ok := elements(dl, alloca.ElemType, 0, nil, nil, MaxElements)
+	if !ok || len(elems) == 0 {
+		return nil
+	}
+	s := &split{
+		dl:     dl,
+		aa:     aa,
+		alloca: alloca,
+		elems:  elems,
+		index:  make(map[uint64]int),
+		ptrs:   map[value.Value]uint64{alloca: 0},
+		users:  users,
+	}
+	for i, elem := range elems {
+		s.index[elem.offset] = i
+	}
+	work := []value.Value{alloca}
+	seen := make(map[ir.Instruction]bool)
+	for len(work) > 0 {
+		ptr := work[len(work)-1]
+		work = work[:len(work)-1]
+		for _, user := range users[ptr] {
+			derived, offset, ok := s.visit(user, ptr, s.ptrs[ptr])
+			if !ok {
+				return nil
+			}
+			if derived != nil {
+				if _, ok := s.ptrs[derived]; !ok {
+					s.ptrs[derived] = offset
+					work = append(work, derived)
+				}
+			} else if inst := user.(ir.Instruction); !seen[inst] {
+				seen[inst] = true
+				s.accesses = append(s.accesses, inst)
+			}
+		}
+	}
+	return s
+}
+
+// visit checks that the given user of the pointer ptr at the given byte offset
+// of the alloca permits the alloca to be split. A pointer derived from ptr by
+// user is returned along with its byte offset, if any; otherwise the user is a
+// memory access of the alloca.
+func (s *split) visit(user value.User, ptr value.Value, offset uint64) (value.Value, uint64, bool) {
+	switch user := user.(type) {
+	case *ir.InstGetElementPtr:
+		if user.Src != ptr {
+			return nil, 0, false
+		}
+		indices, ok := constIndices(user.Indices)
+		if !ok {
+			return nil, 0, false
+		}
+		delta, err := s.dl.IndexedOffset(user.ElemType, indices)
+		if err != nil || int64(offset)+delta < 0 {
+			return nil, 0, false
+		}
+		return user, uint64(int64(offset) + delta), true
+	case *ir.InstBitCast:
+		return user, offset, true
+	case *ir.InstLoad:
+		if user.Volatile || user.Atomic || !s.covers(offset, user.ElemType) {
+			return nil, 0, false
+		}
+		return nil, 0, true
+	case *ir.InstStore:
+		if user.Dst != ptr || user.Src == ptr || user.Volatile || user.Atomic || !s.covers(offset, user.Src.Type()) {
+			return nil, 0, false
+		}
+		return nil, 0, true
+	case *ir.InstCall:
+		if !s.isSupportedCall(user, ptr, offset) {
+			return nil, 0, false
+		}
+		return nil, 0, true
+	}
+	return nil, 0, false
+}
+
+// covers reports whether the scalar elements of the given type at the given
+// byte offset correspond exactly to scalar elements of the alloca.
+func (s *split) covers(offset uint64, t types.Type) bool {
+	elems, ok := elements(s.dl, t, offset, nil, nil, len(s.elems))
+	if !ok || len(elems) == 0 {
+		return false
+	}
+	for _, elem := range elems {
+		i, ok := s.index[elem.offset]
+		if !ok || !s.elems[i].typ.Equal(elem.typ) {
+			return false
+		}
+	}
+	return true
+}
+
+// isSupportedCall reports whether the given call instruction using the pointer
+// ptr at the given byte offset of the alloca is supported.
+func (s *split) isSupportedCall(call *ir.InstCall, ptr value.Value, offset uint64) bool {
+	switch id, _ := intrinsics.LookupCall(call); id {
+	case intrinsics.LifetimeStart, intrinsics.LifetimeEnd:
+		return true
+	case intrinsics.Memset:
+		if arg(call, 0) != ptr || offset != 0 || !s.isWholeObject(arg(call, 2)) || isVolatile(arg(call, 3)) {
+			return false
+		}
+		b, ok := arg(call, 1).(*constant.Int)
+		if !ok {
+			return false
+		}
+		for _, elem := range s.elems {
+			if splat(elem.typ, byte(b.X.Int64())) == nil {
+				return false
+			}
+		}
+		return true
+	case intrinsics.Memcpy, intrinsics.Memmove:
+		if offset != 0 || !s.isWholeObject(arg(call, 2)) || isVolatile(arg(call, 3)) {
+			return false
+		}
+		// The alloca must be either the source or the destination.
+		var other value.Value
+		switch ptr {
+		case arg(call, 0):
+			other = arg(call, 1)
+		case arg(call, 1):
+			other = arg(call, 0)
+		default:
+			return false
+		}
+		return s.aa.UnderlyingObject(other) != s.alloca
+	}
+	return false
+}
+
+// isWholeObject reports whether the given length covers the entire alloca.
+func (s *split) isWholeObject(length value.Value) bool {
+	n, ok := length.(*constant.Int)
+	return ok && n.X.IsUint64() && n.X.Uint64() == s.dl.AllocSize(s.alloca.ElemType)
+}
+
+// --- [ Rewrite ] -------------------------------------------------------------
+
+// rewriter tracks the rewrite of a split alloca.
+type rewriter struct {
+	*split
+	f *ir.Func
+	// Scalar allocas, one per scalar element.
+	allocas []*ir.InstAlloca
+	// Instructions to insert before each instruction.
+	before map[ir.Instruction][]ir.Instruction
+	// Instructions to remove.
+	dead map[ir.Instruction]bool
+	// Replacement of each removed value.
+	repl map[value.Value]value.Value
+}
+
+// rewrite splits the alloca into scalar allocas and rewrites its accesses.
+func (s *split) rewrite(f *ir.Func) {
+	r := &rewriter{
+		split:  s,
+		f:      f,
+		before: make(map[ir.Instruction][]ir.Instruction),
+		dead:   map[ir.Instruction]bool{s.alloca: true},
+		repl:   make(map[value.Value]value.Value),
+	}
+	name := irutil.LocalName(s.alloca)
+	for _, elem := range s.elems {
+		a := ir.NewAlloca(elem.typ)
+		if len(name) > 0 {
+			a.SetName(irutil.UniqueLocalName(f, name+"."+pathString(elem.path)))
+		}
+		if s.alloca.Align != 0 {
+			a.Align = ir.Align(minAlign(uint64(s.alloca.Align), elem.offset))
+		}
+		a.AddrSpace = s.alloca.AddrSpace
+		r.allocas = append(r.allocas, a)
+	}
+	r.before[s.alloca] = nil
+	for _, a := range r.allocas {
+		r.before[s.alloca] = append(r.before[s.alloca], a)
+	}
+	for ptr := range s.ptrs {
+		if inst, ok := ptr.(ir.Instruction); ok {
+			r.dead[inst] = true
+		}
+	}
+	for _, access := range s.accesses {
+		r.dead[access] = true
+		switch access := access.(type) {
+		case *ir.InstLoad:
+			r.rewriteLoad(access)
+		case *ir.InstStore:
+			r.rewriteStore(access)
+		case *ir.InstCall:
+			r.rewriteCall(access)
+		}
+	}
+	r.removeDebugUses()
+	for _, block := range f.Blocks {
+		var insts []ir.Instruction
+		for _, inst := range block.Insts {
+			insts = append(insts, r.before[inst]...)
+			if !r.dead[inst] {
+				insts = append(insts, inst)
+			}
+		}
+		block.Insts = insts
+	}
+	irutil.ReplaceAll(f, r.repl)
+}
+
+// elemsAt returns the indices of the scalar elements of the alloca
+// corresponding to the scalar elements of the given type at the given byte
+// offset, and the scalar elements of the type.
+func (r *rewriter) elemsAt(offset uint64, t types.Type) ([]int, []element) {
+	elems, _ := elements(r.dl, t, offset, nil, nil, len(r.elems))
+	indices := make([]int, len(elems))
+	for i, elem := range elems {
+		indices[i] = r.index[elem.offset]
+	}
+	return indices, elems
+}
+
+// rewriteLoad rewrites the given load into loads of scalar allocas.
+func (r *rewriter) rewriteLoad(load *ir.InstLoad) {
+	indices, elems := r.elemsAt(r.ptrs[load.Src], load.ElemType)
+	name := irutil.LocalName(load)
+	if _, ok := load.ElemType.(*types.StructType); !ok {
+		if _, ok := load.ElemType.(*types.ArrayType); !ok {
+			// Scalar load.
+			l := ir.NewLoad(load.ElemType, r.allocas[indices[0]])
+			l.SetName(name)
+			r.emit(load, l)
+			r.repl[load] = l
+			return
+		}
+	}
+	// Aggregate load; load each scalar element.
+	vals := make([]value.Value, len(elems))
+	for i, elem := range elems {
+		l := ir.NewLoad(elem.typ, r.allocas[indices[i]])
+		r.emit(load, l)
+		vals[i] = l
+	}
+	// Extract values directly from the loaded scalar elements.
+	needAggregate := false
+	for _, user := range r.users[load] {
+		ev, ok := user.(*ir.InstExtractValue)
+		if !ok || ev.X != load {
+			needAggregate = true
+			continue
+		}
+		r.dead[ev] = true
+		r.repl[ev] = r.assemble(load, ev.Type(), ev.Indices, elems, vals, irutil.LocalName(ev))
+	}
+	if needAggregate {
+		r.repl[load] = r.assemble(load, load.ElemType, nil, elems, vals, name)
+	}
+}
+
+// assemble returns the aggregate (or scalar) value of the given type at the
+// given path of the loaded scalar elements, emitting insertvalue instructions
+// before inst as needed.
+func (r *rewriter) assemble(inst ir.Instruction, t types.Type, path []uint64, elems []element, vals []value.Value, name string) value.Value {
+	var agg value.Value = constant.NewUndef(t)
+	var last *ir.InstInsertValue
+	for i, elem := range elems {
+		if !hasPrefix(elem.path, path) {
+			continue
+		}
+		rest := elem.path[len(path):]
+		if len(rest) == 0 {
+			// Scalar element.
+			if l, ok := vals[i].(*ir.InstLoad); ok && l.IsUnnamed() {
+				l.SetName(name)
+			}
+			return vals[i]
+		}
+		last = ir.NewInsertValue(agg, vals[i], rest...)
+		r.emit(inst, last)
+		agg = last
+	}
+	if last != nil {
+		last.SetName(name)
+	}
+	return agg
+}
+
+// rewriteStore rewrites the given store into stores to scalar allocas.
+func (r *rewriter) rewriteStore(store *ir.InstStore) {
+	indices, elems := r.elemsAt(r.ptrs[store.Dst], store.Src.Type())
+	for i, elem := range elems {
+		v := r.extract(store, store.Src, elem.path)
+		r.emit(store, ir.NewStore(v, r.allocas[indices[i]]))
+	}
+}
+
+// extract returns the scalar element at the given path of the aggregate value
+// agg, emitting an extractvalue instruction before inst if the element cannot
+// be determined from constants or insertvalue instructions.
+func (r *rewriter) extract(inst ir.Instruction, agg value.Value, path []uint64) value.Value {
+	for len(path) > 0 {
+		switch v := agg.(type) {
+		case *ir.InstInsertValue:
+			switch {
+			case hasPrefix(path, v.Indices):
+				agg, path = v.Elem, path[len(v.Indices):]
+				continue
+			case hasPrefix(v.Indices, path):
+				// Partially overwritten sub-aggregate.
+			default:
+				agg = v.X
+				continue
+			}
+		case constant.Constant:
+			if c := extractConst(v, path); c != nil {
+				return c
+			}
+		}
+		ev := ir.NewExtractValue(agg, path...)
+		r.emit(inst, ev)
+		return ev
+	}
+	return agg
+}
+
+// rewriteCall rewrites the given intrinsic call using the alloca.
+func (r *rewriter) rewriteCall(call *ir.InstCall) {
+	switch id, _ := intrinsics.LookupCall(call); id {
+	case intrinsics.Memset:
+		b := byte(arg(call, 1).(*constant.Int).X.Int64())
+		for i, elem := range r.elems {
+			r.emit(call, ir.NewStore(splat(elem.typ, b), r.allocas[i]))
+		}
+	case intrinsics.Memcpy, intrinsics.Memmove:
+		dst, src := arg(call, 0), arg(call, 1)
+		if _, ok := r.ptrs[dst]; ok {
+			// Copy into the alloca.
+			other := r.typedPtr(call, src)
+			for i, elem := range r.elems {
+				l := ir.NewLoad(elem.typ, r.elemPtr(call, other, elem))
+				r.emit(call, l)
+				r.emit(call, ir.NewStore(l, r.allocas[i]))
+			}
+			return
+		}
+		// Copy from the alloca.
+		other := r.typedPtr(call, dst)
+		for i, elem := range r.elems {
+			l := ir.NewLoad(elem.typ, r.allocas[i])
+			r.emit(call, l)
+			r.emit(call, ir.NewStore(l, r.elemPtr(call, other, elem)))
+		}
+	}
+	// Lifetime intrinsics are removed.
+}
+
+// typedPtr returns the given pointer cast to a pointer to the allocated type,
+// emitting a bitcast instruction before inst if needed.
+func (r *rewriter) typedPtr(inst ir.Instruction, ptr value.Value) value.Value {
+	want := types.NewPointer(r.alloca.ElemType)
+	want.AddrSpace = ptr.Type().(*types.PointerType).AddrSpace
+	if cast, ok := ptr.(*ir.InstBitCast); ok && cast.From.Type().Equal(want) {
+		return cast.From
+	}
+	if ptr.Type().Equal(want) {
+		return ptr
+	}
+	cast := ir.NewBitCast(ptr, want)
+	r.emit(inst, cast)
+	return cast
+}
+
+// elemPtr returns a pointer to the given scalar element of the aggregate
+// pointed to by ptr, emitting a getelementptr instruction before inst.
+func (r *rewriter) elemPtr(inst ir.Instruction, ptr value.Value, elem element) value.Value {
+	indices := []value.Value{constant.NewInt(types.I64, 0)}
+	t := r.alloca.ElemType
+	for _, index := range elem.path {
+		switch tt := t.(type) {
+		case *types.StructType:
+			indices = append(indices, constant.NewInt(types.I32, int64(index)))
+			t = tt.Fields[index]
+		case *types.ArrayType:
+			indices = append(indices, constant.NewInt(types.I64, int64(index)))
+			t = tt.ElemType
+		}
+	}
+	gep := ir.NewGetElementPtr(r.alloca.ElemType, ptr, indices...)
+	gep.InBounds = true
+	r.emit(inst, gep)
+	return gep
+}
+
+// removeDebugUses removes debug intrinsic calls referring to pointers derived
+// from the alloca.
+func (r *rewriter) removeDebugUses() {
+	for _, block := range r.f.Blocks {
+		for _, inst := range block.Insts {
+			call, ok := inst.(*ir.InstCall)
+			if !ok || !irutil.IsDebugIntrinsic(call) {
+				continue
+			}
+			for _, a := range call.Args {
+				if md, ok := irutil.Unwrap(a).(*metadata.Value); ok {
+					if v, ok := md.Value.(value.Value); ok {
+						if _, ok := r.ptrs[v]; ok {
+							r.dead[call] = true
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// emit emits the new instruction inst before the instruction at.
+func (r *rewriter) emit(at, inst ir.Instruction) {
+	r.before[at] = append(r.before[at], inst)
+}
+
+// ### [ Helper functions ] ####################################################
+
+// userMap returns the users of each value of the given function.
+func userMap(f *ir.Func) map[value.Value][]value.User {
+	users := make(map[value.Value][]value.User)
+	add := func(user value.User) {
+		for _, op := range user.Operands() {
+			v := irutil.Unwrap(*op)
+			users[v] = append(users[v], user)
+		}
+	}
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			add(inst)
+		}
+		add(block.Term)
+	}
+	return users
+}
+
+// constIndices returns the given getelementptr indices as integers, or false if
+// any index is not a constant integer.
+func constIndices(indices []value.Value) ([]int64, bool) {
+	var is []int64
+	for _, index := range indices {
+		c, ok := index.(*constant.Int)
+		if !ok || !c.X.IsInt64() {
+			return nil, false
+		}
+		is = append(is, c.X.Int64())
+	}
+	return is, true
+}
+
+// arg returns the i:th argument of the given call instruction.
+func arg(call *ir.InstCall, i int) value.Value {
+	return irutil.Unwrap(call.Args[i])
+}
+
+// isVolatile reports whether the given volatile flag argument of a memory
+// intrinsic may be true.
+func isVolatile(v value.Value) bool {
+	c, ok := v.(*constant.Int)
+	return !ok || c.X.Sign() != 0
+}
+
+// splat returns the constant of the given scalar type with each byte set to b,
+// or nil if not representable.
+func splat(t types.Type, b byte) constant.Constant {
+	switch t := t.(type) {
+	case *types.IntType:
+		if b == 0 {
+			return constant.NewInt(t, 0)
+		}
+		if t.BitSize%8 != 0 {
+			return nil
+		}
+		x := new(big.Int)
+		for i := uint64(0); i < t.BitSize/8; i++ {
+			x.Lsh(x, 8)
+			x.Or(x, big.NewInt(int64(b)))
+		}
+		if t.BitSize > 1 && x.Bit(int(t.BitSize)-1) == 1 {
+			// Sign extend, as integer constants are represented as signed.
+			x.Sub(x, new(big.Int).Lsh(big.NewInt(1), uint(t.BitSize)))
+		}
+		return &constant.Int{Typ: t, X: x}
+	case *types.FloatType:
+		if b == 0 {
+			return constant.NewFloat(t, 0)
+		}
+	case *types.PointerType:
+		if b == 0 {
+			return constant.NewNull(t)
+		}
+	case *types.VectorType:
+		if b == 0 {
+			return constant.NewZeroInitializer(t)
+		}
+	}
+	return nil
+}
+
+// extractConst returns the element at the given path of the constant aggregate
+// c, or nil if the element cannot be determined.
+func extractConst(c constant.Constant, path []uint64) constant.Constant {
+	for _, index := range path {
+		switch v := c.(type) {
+		case *constant.Struct:
+			c = v.Fields[index]
+		case *constant.Array:
+			c = v.Elems[index]
+		case *constant.CharArray:
+			c = constant.NewInt(types.I8, int64(v.X[index]))
+		case *constant.ZeroInitializer:
+			c = constant.NewZeroInitializer(subType(v.Typ, index))
+		case *constant.Undef:
+			c = constant.NewUndef(subType(v.Typ, index))
+		case *constant.Poison:
+			c = constant.NewPoison(subType(v.Typ, index))
+		default:
+			return nil
+		}
+	}
+	if z, ok := c.(*constant.ZeroInitializer); ok {
+		// Use the canonical zero value of scalar types.
+		switch t := z.Typ.(type) {
+		case *types.IntType, *types.FloatType, *types.PointerType:
+			return splat(t, 0)
+		}
+	}
+	return c
+}
+
+// subType returns the type of the element at the given index of the aggregate
+// type t.
+func subType(t types.Type, index uint64) types.Type {
+	switch t := t.(type) {
+	case *types.StructType:
+		return t.Fields[index]
+	case *types.ArrayType:
+		return t.ElemType
+	}
+	panic(fmt.Errorf("unable to index into non-aggregate type %v", t))
+}
+
+// hasPrefix reports whether the path has the given prefix.
+func hasPrefix(path, prefix []uint64) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if path[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// pathString returns the string representation of the given path, with indices
+// separated by dots.
+func pathString(path []uint64) string {
+	var ss []string
+	for _, index := range path {
+		ss = append(ss, fmt.Sprint(index))
+	}
+	return strings.Join(ss, ".")
+}
+
+// minAlign returns the largest power of two alignment dividing both align and
+// offset.
+func minAlign(align, offset uint64) uint64 {
+	x := align | offset
+	return x & -x
+}
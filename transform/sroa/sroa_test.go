@@ -0,0 +1,157 @@
+package sroa
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+)
+
+func TestRunModule(t *testing.T) {
+	golden := []struct {
+		name string
+		in   string
+		want string
+	}{
+		// Fields accessed through constant getelementptr indices and byte
+		// offsets, and extractvalue of a loaded aggregate.
+		{
+			name: "fields",
+			in: `
+target datalayout = "e-i64:64"
+
+%pair = type { i32, i64 }
+
+define i64 @f(i32 %a, i64 %b) {
+entry:
+	%p = alloca %pair, align 8
+	%x = getelementptr inbounds %pair, %pair* %p, i64 0, i32 0
+	store i32 %a, i32* %x
+	%raw = bitcast %pair* %p to i8*
+	%off = getelementptr inbounds i8, i8* %raw, i64 8
+	%y = bitcast i8* %off to i64*
+	store i64 %b, i64* %y
+	%v = load %pair, %pair* %p
+	%e0 = extractvalue %pair %v, 0
+	%e1 = extractvalue %pair %v, 1
+	%z = zext i32 %e0 to i64
+	%r = add i64 %z, %e1
+	ret i64 %r
+}`,
+			want: `
+define i64 @f(i32 %a, i64 %b) {
+entry:
+	%p.0 = alloca i32, align 8
+	%p.1 = alloca i64, align 8
+	store i32 %a, i32* %p.0
+	store i64 %b, i64* %p.1
+	%e0 = load i32, i32* %p.0
+	%e1 = load i64, i64* %p.1
+	%z = zext i32 %e0 to i64
+	%r = add i64 %z, %e1
+	ret i64 %r
+}`,
+		},
+		// Nested aggregates stored and loaded as a whole.
+		{
+			name: "aggregate",
+			in: `
+target datalayout = "e-i64:64"
+
+define { i8, [2 x i16] } @f(i8 %a, i16 %b) {
+entry:
+	%p = alloca { i8, [2 x i16] }
+	%agg = insertvalue { i8, [2 x i16] } zeroinitializer, i8 %a, 0
+	store { i8, [2 x i16] } %agg, { i8, [2 x i16] }* %p
+	%q = getelementptr { i8, [2 x i16] }, { i8, [2 x i16] }* %p, i64 0, i32 1, i64 1
+	store i16 %b, i16* %q
+	%v = load { i8, [2 x i16] }, { i8, [2 x i16] }* %p
+	ret { i8, [2 x i16] } %v
+}`,
+			want: `
+define { i8, [2 x i16] } @f(i8 %a, i16 %b) {
+entry:
+	%p.0 = alloca i8
+	%p.1.0 = alloca i16
+	%p.1.1 = alloca i16
+	%agg = insertvalue { i8, [2 x i16] } zeroinitializer, i8 %a, 0
+	store i8 %a, i8* %p.0
+	store i16 0, i16* %p.1.0
+	store i16 0, i16* %p.1.1
+	store i16 %b, i16* %p.1.1
+	%0 = load i8, i8* %p.0
+	%1 = load i16, i16* %p.1.0
+	%2 = load i16, i16* %p.1.1
+	%3 = insertvalue { i8, [2 x i16] } undef, i8 %0, 0
+	%4 = insertvalue { i8, [2 x i16] } %3, i16 %1, 1, 0
+	%v = insertvalue { i8, [2 x i16] } %4, i16 %2, 1, 1
+	ret { i8, [2 x i16] } %v
+}`,
+		},
+		// Memory intrinsics over the entire object; escaping allocas are not
+		// split.
+		{
+			name: "intrinsics",
+			in: `
+target datalayout = "e-i64:64"
+
+%pair = type { i32, i64 }
+
+declare void @llvm.memset.p0i8.i64(i8*, i8, i64, i1)
+
+declare void @llvm.memcpy.p0i8.p0i8.i64(i8*, i8*, i64, i1)
+
+declare void @g(%pair*)
+
+define i32 @f(%pair* %src) {
+entry:
+	%q = alloca %pair
+	%c = bitcast %pair* %q to i8*
+	%s = bitcast %pair* %src to i8*
+	call void @llvm.memset.p0i8.i64(i8* %c, i8 -1, i64 16, i1 false)
+	call void @llvm.memcpy.p0i8.p0i8.i64(i8* %c, i8* %s, i64 16, i1 false)
+	%x = getelementptr %pair, %pair* %q, i64 0, i32 0
+	%l = load i32, i32* %x
+	%esc = alloca %pair
+	call void @g(%pair* %esc)
+	ret i32 %l
+}`,
+			want: `
+define i32 @f(%pair* %src) {
+entry:
+	%q.0 = alloca i32
+	%q.1 = alloca i64
+	%s = bitcast %pair* %src to i8*
+	store i32 -1, i32* %q.0
+	store i64 -1, i64* %q.1
+	%0 = getelementptr inbounds %pair, %pair* %src, i64 0, i32 0
+	%1 = load i32, i32* %0
+	store i32 %1, i32* %q.0
+	%2 = getelementptr inbounds %pair, %pair* %src, i64 0, i32 1
+	%3 = load i64, i64* %2
+	store i64 %3, i64* %q.1
+	%l = load i32, i32* %q.0
+	%esc = alloca %pair
+	call void @g(%pair* %esc)
+	ret i32 %l
+}`,
+		},
+	}
+	for _, g := range golden {
+		m, err := asm.ParseString(g.name+".ll", g.in)
+		if err != nil {
+			t.Errorf("%q: unable to parse module; %+v", g.name, err)
+			continue
+		}
+		if _, err := RunModule(m); err != nil {
+			t.Errorf("%q: unable to run pass; %+v", g.name, err)
+			continue
+		}
+		f := m.Funcs[len(m.Funcs)-1]
+		got := strings.TrimSpace(f.LLString())
+		want := strings.TrimSpace(g.want)
+		if got != want {
+			t.Errorf("%q: function mismatch; expected:\n%s\n\ngot:\n%s", g.name, want, got)
+		}
+	}
+}